@@ -1,11 +1,92 @@
 CREATE TYPE order_status AS ENUM ('active', 'inactive', 'closed', 'cancelled');
 CREATE TYPE measurement_units AS ENUM ('kg', 'g', 'l', 'shots', 'ml');
+CREATE TYPE order_channel AS ENUM ('dine_in', 'takeaway', 'delivery');
+-- declared in the order lines move through, MIN() is the least advanced
+CREATE TYPE prep_status AS ENUM ('queued', 'in_progress', 'ready', 'handed_off');
 
 CREATE TABLE inventory (
     ingredient_id VARCHAR(50) PRIMARY KEY,
     name VARCHAR(50) NOT NULL,
     quantity DECIMAL(10,2) NOT NULL,
     unit measurement_units NOT NULL,
+    cost_per_unit DECIMAL(10,4) NOT NULL DEFAULT 0,
+    reorder_level DECIMAL(10,2) NOT NULL DEFAULT 0, -- stock at or below it is reported low, 0 never
+    allergens TEXT[] NOT NULL DEFAULT '{}',
+    dietary_tags TEXT[] NOT NULL DEFAULT '{}',
+    -- nutrition values are given per nutrition_basis nutrition_unit, e.g. per 100 g
+    nutrition_basis DECIMAL(10,2),
+    nutrition_unit measurement_units,
+    calories DECIMAL(10,2),
+    protein DECIMAL(10,2),
+    fat DECIMAL(10,2),
+    carbs DECIMAL(10,2),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    archived_at TIMESTAMP WITH TIME ZONE,
+    version INT NOT NULL DEFAULT 1 -- raised by every change, for If-Match
+);
+
+-- Prep items (syrups, cold brew concentrate, ...) are produced from other
+-- inventory items. Quantities are per one unit of the prep item.
+CREATE TABLE inventory_recipes (
+    prep_item_id VARCHAR(50) NOT NULL REFERENCES inventory(ingredient_id),
+    ingredient_id VARCHAR(50) NOT NULL REFERENCES inventory(ingredient_id),
+    quantity DECIMAL(10,4) NOT NULL,
+    PRIMARY KEY (prep_item_id, ingredient_id)
+);
+
+CREATE TABLE customers (
+    customer_id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    phone VARCHAR(30),
+    email VARCHAR(100),
+    notes TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TYPE employee_role AS ENUM ('barista', 'shift_lead', 'manager');
+
+CREATE TABLE employees (
+    employee_id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    role employee_role NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    password_hash VARCHAR(200), -- employees without a password cannot log in
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- API keys for integrations, only the SHA-256 of the key is stored and the
+-- role caps what the key may do
+CREATE TABLE api_keys (
+    api_key_id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    role employee_role NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+-- clock_out stays empty while the shift is running
+CREATE TABLE shifts (
+    shift_id SERIAL PRIMARY KEY,
+    employee_id INT NOT NULL REFERENCES employees(employee_id),
+    clock_in TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    clock_out TIMESTAMP WITH TIME ZONE,
+    CHECK (clock_out IS NULL OR clock_out >= clock_in)
+);
+
+CREATE UNIQUE INDEX shifts_one_open_idx ON shifts (employee_id) WHERE clock_out IS NULL;
+
+CREATE TYPE table_status AS ENUM ('free', 'occupied', 'needs_cleaning');
+
+CREATE TABLE dining_tables (
+    table_id SERIAL PRIMARY KEY,
+    number INT NOT NULL UNIQUE,
+    seats INT NOT NULL CHECK (seats > 0),
+    area VARCHAR(50),
+    status table_status NOT NULL DEFAULT 'free',
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
@@ -13,11 +94,20 @@ CREATE TABLE inventory (
 CREATE TABLE orders (
     order_id SERIAL PRIMARY KEY,
     customer_name VARCHAR(50) NOT NULL,
+    customer_id INT REFERENCES customers(customer_id),
+    employee_id INT REFERENCES employees(employee_id),
+    parent_order_id INT REFERENCES orders(order_id),
+    table_id INT REFERENCES dining_tables(table_id), -- active dine-in orders on a table are its tab
     status order_status NOT NULL,
+    channel order_channel NOT NULL DEFAULT 'dine_in',
+    priority INT NOT NULL DEFAULT 0, -- higher is made first in the priority queue
+    scheduled_for TIMESTAMP WITH TIME ZONE, -- pickup time of a pre-order
     order_date TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     last_status_change TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     total_amount DECIMAL(10,2) NOT NULL,
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    tip DECIMAL(10,2) NOT NULL DEFAULT 0, -- left when closing, tips on payments are kept there
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    version INT NOT NULL DEFAULT 1 -- raised by every change, for If-Match
 );
 
 
@@ -27,7 +117,9 @@ CREATE TABLE menu_items (
     name VARCHAR(50) NOT NULL,
     description VARCHAR(100),
     category VARCHAR(50),
-    price DECIMAL(10,2) NOT NULL
+    price DECIMAL(10,2) NOT NULL,
+    archived_at TIMESTAMP WITH TIME ZONE,
+    version INT NOT NULL DEFAULT 1 -- raised by every change, for If-Match
 );
 
 CREATE TABLE menu_item_ingredients (
@@ -42,7 +134,55 @@ CREATE TABLE order_items (
     menu_item_id VARCHAR(50) REFERENCES menu_items(menu_item_id),
     quantity INT NOT NULL,
     price DECIMAL(10,2) NOT NULL,
-    customization JSONB
+    customization JSONB,
+    prep_status prep_status NOT NULL DEFAULT 'queued',
+    prep_changed_at TIMESTAMP WITH TIME ZONE,
+    prepared_by INT REFERENCES employees(employee_id)
+);
+
+-- stock held back for open orders until they are closed, cancelled or
+-- deleted, orders created later cannot count on it
+CREATE TABLE inventory_reservations (
+    reservation_id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(order_id) ON DELETE CASCADE,
+    ingredient_id VARCHAR(50) NOT NULL REFERENCES inventory(ingredient_id),
+    quantity DECIMAL(10,4) NOT NULL
+);
+
+CREATE INDEX inventory_reservations_ingredient_idx ON inventory_reservations (ingredient_id);
+
+-- pre-orders are picked up in 15 minute slots between opens_at and
+-- closes_at (server local time), each slot takes at most slot_capacity
+-- orders and their lines reach the prep queue lead_minutes before pickup
+CREATE TABLE pickup_settings (
+    settings_id INT PRIMARY KEY DEFAULT 1 CHECK (settings_id = 1),
+    slot_capacity INT NOT NULL DEFAULT 10,
+    lead_minutes INT NOT NULL DEFAULT 15,
+    opens_at TIME NOT NULL DEFAULT '07:00',
+    closes_at TIME NOT NULL DEFAULT '18:00'
+);
+
+INSERT INTO pickup_settings DEFAULT VALUES;
+
+-- the prep station that makes the items of a category, the route without
+-- a category takes everything else
+CREATE TABLE station_routes (
+    route_id SERIAL PRIMARY KEY,
+    channel order_channel, -- NULL routes every channel
+    category VARCHAR(50),
+    station VARCHAR(30) NOT NULL,
+    UNIQUE NULLS NOT DISTINCT (channel, category)
+);
+
+INSERT INTO station_routes (category, station) VALUES (NULL, 'bar');
+
+-- discount lines lower the order total, e.g. redeemed loyalty points
+CREATE TABLE order_discounts (
+    order_discount_id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(order_id),
+    kind VARCHAR(20) NOT NULL,
+    description VARCHAR(100),
+    amount DECIMAL(10,2) NOT NULL
 );
 
 CREATE TABLE order_status_history (
@@ -50,6 +190,7 @@ CREATE TABLE order_status_history (
     order_id INT NOT NULL REFERENCES orders(order_id),
     old_status order_status NOT NULL,
     new_status order_status NOT NULL,
+    notes TEXT,
     change_time TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -59,14 +200,187 @@ CREATE TABLE inventory_transactions (
     old_quantity DECIMAL(10,2) NOT NULL,
     new_quantity DECIMAL(10,2) NOT NULL,
     unit measurement_units NOT NULL,
+    employee_id INT REFERENCES employees(employee_id),
     modified_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
+-- extra inventory used per unit sold on a channel, e.g. a cup and a lid
+-- for every takeaway drink. A rule without a category applies to every
+-- item, all matching rules are added up.
+CREATE TABLE packaging_rules (
+    rule_id SERIAL PRIMARY KEY,
+    channel order_channel NOT NULL,
+    category VARCHAR(50),
+    ingredient_id VARCHAR(50) NOT NULL REFERENCES inventory(ingredient_id),
+    quantity DECIMAL(10,4) NOT NULL CHECK (quantity > 0)
+);
+
+CREATE TYPE loyalty_entry_type AS ENUM ('earn', 'redeem', 'reversal');
+
+-- points earned per currency unit spent, a rule with a category overrides
+-- the default rule (category IS NULL) for items of that category
+CREATE TABLE loyalty_rules (
+    rule_id SERIAL PRIMARY KEY,
+    category VARCHAR(50) UNIQUE,
+    points_per_unit DECIMAL(10,2) NOT NULL
+);
+
+INSERT INTO loyalty_rules (category, points_per_unit) VALUES (NULL, 1);
+
+CREATE TABLE loyalty_ledger (
+    entry_id SERIAL PRIMARY KEY,
+    customer_id INT NOT NULL REFERENCES customers(customer_id),
+    order_id INT REFERENCES orders(order_id) ON DELETE SET NULL,
+    entry_type loyalty_entry_type NOT NULL,
+    points INT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TYPE gift_card_transaction_type AS ENUM ('issue', 'load', 'spend', 'refund');
+
+CREATE TABLE gift_cards (
+    code VARCHAR(32) PRIMARY KEY,
+    initial_balance DECIMAL(10,2) NOT NULL,
+    balance DECIMAL(10,2) NOT NULL CHECK (balance >= 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- amount is positive for issues, loads and refunds and negative for spends
+CREATE TABLE gift_card_transactions (
+    transaction_id SERIAL PRIMARY KEY,
+    code VARCHAR(32) NOT NULL REFERENCES gift_cards(code),
+    order_id INT REFERENCES orders(order_id) ON DELETE SET NULL,
+    transaction_type gift_card_transaction_type NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    balance_after DECIMAL(10,2) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TYPE tender_type AS ENUM ('cash', 'card', 'gift_card');
+
+-- amount is what the payment covers of the order, the tip comes on top
+CREATE TABLE payments (
+    payment_id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(order_id),
+    tender tender_type NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    tip DECIMAL(10,2) NOT NULL DEFAULT 0,
+    tendered DECIMAL(10,2),
+    change_due DECIMAL(10,2) NOT NULL DEFAULT 0,
+    gift_card_code VARCHAR(32) REFERENCES gift_cards(code),
+    processor_reference VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE refunds (
+    refund_id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(order_id),
+    amount DECIMAL(10,2) NOT NULL,
+    reason VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE refund_items (
+    refund_id INT NOT NULL REFERENCES refunds(refund_id),
+    menu_item_id VARCHAR(50) NOT NULL REFERENCES menu_items(menu_item_id),
+    quantity INT NOT NULL,
+    amount DECIMAL(10,2) NOT NULL
+);
+
+-- how a refund was paid back, split over the original payments
+CREATE TABLE refund_tenders (
+    refund_id INT NOT NULL REFERENCES refunds(refund_id),
+    payment_id INT NOT NULL REFERENCES payments(payment_id),
+    amount DECIMAL(10,2) NOT NULL,
+    processor_reference VARCHAR(100)
+);
+
+-- one row per create, update or delete made through the API, before is
+-- empty for creates and after for deletes
+CREATE TABLE audit_log (
+    audit_id SERIAL PRIMARY KEY,
+    actor_employee_id INT REFERENCES employees(employee_id),
+    actor_api_key_id INT REFERENCES api_keys(api_key_id),
+    action VARCHAR(20) NOT NULL,
+    entity_type VARCHAR(30) NOT NULL,
+    entity_id VARCHAR(50) NOT NULL,
+    before JSONB,
+    after JSONB,
+    diff JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX audit_log_entity_idx ON audit_log (entity_type, entity_id, created_at);
+
+-- events are written here in the same transaction as the change they
+-- describe, the dispatcher hands them to every publisher and records which
+-- ones got them in dispatched_to
+CREATE TABLE outbox (
+    event_id BIGSERIAL PRIMARY KEY,
+    dedup_id UUID NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    dispatched_to TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- the secret signs the payloads, it is kept as is since it is needed to sign
+CREATE TABLE webhooks (
+    webhook_id SERIAL PRIMARY KEY,
+    url VARCHAR(500) NOT NULL,
+    event_types TEXT[] NOT NULL,
+    secret VARCHAR(100) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TYPE delivery_status AS ENUM ('pending', 'delivered', 'failed');
+
+-- one row per event and webhook, retried with backoff until delivered or
+-- out of attempts
+CREATE TABLE webhook_deliveries (
+    delivery_id SERIAL PRIMARY KEY,
+    webhook_id INT NOT NULL REFERENCES webhooks(webhook_id) ON DELETE CASCADE,
+    event_id BIGINT NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    status delivery_status NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_status_code INT,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (webhook_id, event_id) -- an event published again is not queued twice
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+-- responses to mutating requests sent with an Idempotency-Key, replayed
+-- when the same caller retries the same request until expires_at.
+-- status_code is NULL while the first request is still running
+CREATE TABLE idempotency_keys (
+    caller VARCHAR(50) NOT NULL, -- employee:<id> or api_key:<id>
+    idempotency_key VARCHAR(255) NOT NULL,
+    fingerprint CHAR(64) NOT NULL, -- SHA-256 of method, path and body
+    status_code INT,
+    content_type VARCHAR(100),
+    response_body BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (caller, idempotency_key)
+);
+
+CREATE INDEX idempotency_keys_expires_idx ON idempotency_keys (expires_at);
+
 CREATE TABLE price_history (
     price_history_id SERIAL PRIMARY KEY,
     menu_item_id VARCHAR(50) REFERENCES menu_items(menu_item_id),
     old_price DECIMAL(10,2) NOT NULL,
     new_price DECIMAL(10,2) NOT NULL,
+    employee_id INT REFERENCES employees(employee_id),
     change_time TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
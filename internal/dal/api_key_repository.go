@@ -0,0 +1,74 @@
+package dal
+
+import (
+	"database/sql"
+
+	"hot-coffee/internal/utils"
+	"hot-coffee/models"
+)
+
+type APIKeyRepository interface {
+	SaveAPIKey(key models.APIKey, keyHash string) (int, error)
+	GetAll() ([]models.APIKey, error)
+	GetByHash(keyHash string) (models.APIKey, error)
+	Revoke(id int) (bool, error)
+}
+
+type apiKeyRepo struct {
+	path string
+}
+
+func NewAPIKeyRepo(path string) *apiKeyRepo {
+	return &apiKeyRepo{path: path}
+}
+
+func (r *apiKeyRepo) SaveAPIKey(key models.APIKey, keyHash string) (int, error) {
+	var id int
+	err := utils.DB.QueryRow(`INSERT INTO api_keys (name, key_hash, role, created_at) VALUES ($1, $2, $3, $4) RETURNING api_key_id`,
+		key.Name, keyHash, key.Role, key.CreatedAt).Scan(&id)
+	return id, err
+}
+
+func (r *apiKeyRepo) GetAll() ([]models.APIKey, error) {
+	rows, err := utils.DB.Query(`SELECT api_key_id, name, role, created_at, revoked_at FROM api_keys ORDER BY api_key_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// GetByHash only finds keys that have not been revoked.
+func (r *apiKeyRepo) GetByHash(keyHash string) (models.APIKey, error) {
+	row := utils.DB.QueryRow(`SELECT api_key_id, name, role, created_at, revoked_at FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`, keyHash)
+	return scanAPIKey(row)
+}
+
+func scanAPIKey(row rowScanner) (models.APIKey, error) {
+	var key models.APIKey
+	var revokedAt sql.NullString
+	if err := row.Scan(&key.ID, &key.Name, &key.Role, &key.CreatedAt, &revokedAt); err != nil {
+		return models.APIKey{}, err
+	}
+	key.RevokedAt = revokedAt.String
+	return key, nil
+}
+
+// Revoke reports false when there was no active key with the id.
+func (r *apiKeyRepo) Revoke(id int) (bool, error) {
+	result, err := utils.DB.Exec(`UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE api_key_id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
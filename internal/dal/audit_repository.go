@@ -0,0 +1,163 @@
+package dal
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"strconv"
+
+	"hot-coffee/internal/utils"
+	"hot-coffee/models"
+)
+
+type AuditRepository interface {
+	GetEntries(filter models.AuditFilter) ([]models.AuditEntry, error)
+}
+
+type auditRepo struct {
+	path string
+}
+
+func NewAuditRepo(path string) *auditRepo {
+	return &auditRepo{path: path}
+}
+
+// auditQueries read the stored columns of an audited entity as one JSON
+// object, with the rows kept for it in other tables. The row is locked, so
+// the snapshot taken before a change is what the change starts from.
+var auditQueries = map[string]string{
+	models.AuditOrder: `
+		SELECT to_jsonb(o) || jsonb_build_object(
+			'items', COALESCE((SELECT jsonb_agg(to_jsonb(oi) - 'order_id' ORDER BY oi.order_item_id) FROM order_items oi WHERE oi.order_id = o.order_id), '[]'),
+			'discounts', COALESCE((SELECT jsonb_agg(to_jsonb(od) - 'order_id' ORDER BY od.order_discount_id) FROM order_discounts od WHERE od.order_id = o.order_id), '[]'))
+		FROM orders o WHERE o.order_id = $1::int
+		FOR UPDATE OF o`,
+	models.AuditMenuItem: `
+		SELECT to_jsonb(m) || jsonb_build_object(
+			'ingredients', COALESCE((SELECT jsonb_agg(to_jsonb(mi) - 'menu_item_id' ORDER BY mi.ingredient_id) FROM menu_item_ingredients mi WHERE mi.menu_item_id = m.menu_item_id), '[]'))
+		FROM menu_items m WHERE m.menu_item_id = $1
+		FOR UPDATE OF m`,
+	models.AuditInventoryItem: `
+		SELECT to_jsonb(i) || jsonb_build_object(
+			'recipe', COALESCE((SELECT jsonb_agg(to_jsonb(ir) - 'prep_item_id' ORDER BY ir.ingredient_id) FROM inventory_recipes ir WHERE ir.prep_item_id = i.ingredient_id), '[]'))
+		FROM inventory i WHERE i.ingredient_id = $1
+		FOR UPDATE OF i`,
+}
+
+// auditSnapshot reads an entity inside the transaction that changes it,
+// nil when it is not stored.
+func auditSnapshot(tx *sql.Tx, entityType, entityID string) (json.RawMessage, error) {
+	var snapshot []byte
+	err := tx.QueryRow(auditQueries[entityType], entityID).Scan(&snapshot)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// writeAudit logs a change made by actor in tx. Before is the snapshot
+// taken when the change started, nil for creates, the after snapshot is
+// read here and is nil when the row is gone.
+func writeAudit(tx *sql.Tx, actor models.Principal, action, entityType, entityID string, before json.RawMessage) error {
+	after, err := auditSnapshot(tx, entityType, entityID)
+	if err != nil {
+		return err
+	}
+	diff, err := diffSnapshots(before, after)
+	if err != nil {
+		return err
+	}
+	diffJSON, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO audit_log (actor_employee_id, actor_api_key_id, action, entity_type, entity_id, before, after, diff)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		nullInt(actor.EmployeeID), nullInt(actor.APIKeyID), action, entityType, entityID,
+		nullJSON(before), nullJSON(after), string(diffJSON))
+	return err
+}
+
+// diffSnapshots compares the top level fields of two JSON objects. A field
+// missing on one side shows up as null there.
+func diffSnapshots(before, after json.RawMessage) (map[string]models.FieldChange, error) {
+	beforeFields := map[string]interface{}{}
+	afterFields := map[string]interface{}{}
+	if len(before) > 0 {
+		if err := json.Unmarshal(before, &beforeFields); err != nil {
+			return nil, err
+		}
+	}
+	if len(after) > 0 {
+		if err := json.Unmarshal(after, &afterFields); err != nil {
+			return nil, err
+		}
+	}
+	diff := map[string]models.FieldChange{}
+	for field, value := range beforeFields {
+		if !reflect.DeepEqual(value, afterFields[field]) {
+			diff[field] = models.FieldChange{Before: value, After: afterFields[field]}
+		}
+	}
+	for field, value := range afterFields {
+		if _, found := beforeFields[field]; !found {
+			diff[field] = models.FieldChange{After: value}
+		}
+	}
+	return diff, nil
+}
+
+// GetEntries lists the newest entries first. Empty filter fields match
+// everything, From and To are inclusive dates.
+func (r *auditRepo) GetEntries(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	query := `SELECT audit_id, actor_employee_id, actor_api_key_id, action, entity_type, entity_id, before, after, diff, created_at
+		FROM audit_log WHERE TRUE`
+	var args []interface{}
+	addCondition := func(condition string, value string) {
+		args = append(args, value)
+		query += ` AND ` + condition + ` $` + strconv.Itoa(len(args))
+	}
+	if filter.EntityType != "" {
+		addCondition("entity_type =", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		addCondition("entity_id =", filter.EntityID)
+	}
+	if filter.From != "" {
+		addCondition("created_at::date >=", filter.From)
+	}
+	if filter.To != "" {
+		addCondition("created_at::date <=", filter.To)
+	}
+	query += ` ORDER BY created_at DESC, audit_id DESC`
+
+	rows, err := utils.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var entry models.AuditEntry
+		var employeeID, apiKeyID sql.NullInt64
+		var before, after []byte
+		var diff []byte
+		if err := rows.Scan(&entry.ID, &employeeID, &apiKeyID, &entry.Action, &entry.EntityType, &entry.EntityID,
+			&before, &after, &diff, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entry.ActorEmployeeID = int(employeeID.Int64)
+		entry.ActorAPIKeyID = int(apiKeyID.Int64)
+		entry.Before = before
+		entry.After = after
+		if err := json.Unmarshal(diff, &entry.Diff); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,74 @@
+package dal
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   map[string]models.FieldChange
+	}{
+		{
+			name:  "create",
+			after: `{"name":"Latte","price":4.5}`,
+			want: map[string]models.FieldChange{
+				"name":  {After: "Latte"},
+				"price": {After: 4.5},
+			},
+		},
+		{
+			name:   "delete",
+			before: `{"name":"Latte"}`,
+			want:   map[string]models.FieldChange{"name": {Before: "Latte"}},
+		},
+		{
+			name:   "changed fields only",
+			before: `{"name":"Latte","price":4.5,"ingredients":[{"ingredient_id":"milk","quantity":200}]}`,
+			after:  `{"name":"Latte","price":5,"ingredients":[{"ingredient_id":"milk","quantity":250}]}`,
+			want: map[string]models.FieldChange{
+				"price": {Before: 4.5, After: 5.0},
+				"ingredients": {
+					Before: []interface{}{map[string]interface{}{"ingredient_id": "milk", "quantity": 200.0}},
+					After:  []interface{}{map[string]interface{}{"ingredient_id": "milk", "quantity": 250.0}},
+				},
+			},
+		},
+		{
+			name:   "archived",
+			before: `{"name":"Latte","archived_at":null}`,
+			after:  `{"name":"Latte","archived_at":"2024-05-01T10:00:00Z"}`,
+			want:   map[string]models.FieldChange{"archived_at": {After: "2024-05-01T10:00:00Z"}},
+		},
+		{
+			name:   "unchanged",
+			before: `{"name":"Latte"}`,
+			after:  `{"name":"Latte"}`,
+			want:   map[string]models.FieldChange{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before, after json.RawMessage
+			if tt.before != "" {
+				before = json.RawMessage(tt.before)
+			}
+			if tt.after != "" {
+				after = json.RawMessage(tt.after)
+			}
+			got, err := diffSnapshots(before, after)
+			if err != nil {
+				t.Fatalf("diffSnapshots() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffSnapshots() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
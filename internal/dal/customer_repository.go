@@ -0,0 +1,138 @@
+package dal
+
+import (
+	"database/sql"
+
+	"hot-coffee/internal/utils"
+	"hot-coffee/models"
+
+	"github.com/lib/pq"
+)
+
+type CustomerRepository interface {
+	SaveCustomer(customer models.Customer) (int, error)
+	GetAll(name string) ([]models.Customer, error)
+	GetByID(id int) (models.Customer, error)
+	Exists(id int) (bool, error)
+	Update(customer models.Customer) error
+	Merge(targetID int, sourceIDs []int) error
+}
+
+type customerRepo struct {
+	path string
+}
+
+func NewCustomerRepo(path string) *customerRepo {
+	return &customerRepo{path: path}
+}
+
+func (r *customerRepo) SaveCustomer(customer models.Customer) (int, error) {
+	query := `INSERT INTO customers (name, phone, email, notes, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6) RETURNING customer_id`
+	var id int
+	err := utils.DB.QueryRow(query, customer.Name, nullString(customer.Phone), nullString(customer.Email),
+		nullString(customer.Notes), customer.CreatedAt, customer.UpdatedAt).Scan(&id)
+	return id, err
+}
+
+// GetAll lists customers, optionally only those whose name matches
+// case-insensitively, which helps finding duplicates before a merge.
+func (r *customerRepo) GetAll(name string) ([]models.Customer, error) {
+	query := `SELECT customer_id, name, phone, email, notes, created_at, updated_at FROM customers`
+	var args []interface{}
+	if name != "" {
+		query += ` WHERE name ILIKE '%' || $1 || '%'`
+		args = append(args, name)
+	}
+	query += ` ORDER BY customer_id`
+
+	rows, err := utils.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var customers []models.Customer
+	for rows.Next() {
+		customer, err := scanCustomer(rows)
+		if err != nil {
+			return nil, err
+		}
+		customers = append(customers, customer)
+	}
+	return customers, rows.Err()
+}
+
+func (r *customerRepo) GetByID(id int) (models.Customer, error) {
+	row := utils.DB.QueryRow(`SELECT customer_id, name, phone, email, notes, created_at, updated_at FROM customers WHERE customer_id = $1`, id)
+	return scanCustomer(row)
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCustomer(row rowScanner) (models.Customer, error) {
+	var customer models.Customer
+	var phone, email, notes sql.NullString
+	err := row.Scan(&customer.ID, &customer.Name, &phone, &email, &notes, &customer.CreatedAt, &customer.UpdatedAt)
+	if err != nil {
+		return models.Customer{}, err
+	}
+	customer.Phone = phone.String
+	customer.Email = email.String
+	customer.Notes = notes.String
+	return customer, nil
+}
+
+func (r *customerRepo) Exists(id int) (bool, error) {
+	var exists bool
+	err := utils.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM customers WHERE customer_id = $1)`, id).Scan(&exists)
+	return exists, err
+}
+
+func (r *customerRepo) Update(customer models.Customer) error {
+	query := `UPDATE customers SET name = $1, phone = $2, email = $3, notes = $4, updated_at = $5 WHERE customer_id = $6`
+	_, err := utils.DB.Exec(query, customer.Name, nullString(customer.Phone), nullString(customer.Email),
+		nullString(customer.Notes), customer.UpdatedAt, customer.ID)
+	return err
+}
+
+// Merge moves the orders and loyalty points of the source customers to the
+// target and removes the sources. Contact fields missing on the target are
+// taken from the sources and their notes are appended.
+func (r *customerRepo) Merge(targetID int, sourceIDs []int) error {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE orders SET customer_id = $1, version = version + 1 WHERE customer_id = ANY($2)`, targetID, pq.Array(sourceIDs))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE loyalty_ledger SET customer_id = $1 WHERE customer_id = ANY($2)`, targetID, pq.Array(sourceIDs))
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE customers t SET
+			phone = COALESCE(t.phone, (SELECT phone FROM customers WHERE customer_id = ANY($2) AND phone IS NOT NULL ORDER BY customer_id LIMIT 1)),
+			email = COALESCE(t.email, (SELECT email FROM customers WHERE customer_id = ANY($2) AND email IS NOT NULL ORDER BY customer_id LIMIT 1)),
+			notes = NULLIF(CONCAT_WS(E'\n', t.notes, (SELECT STRING_AGG(notes, E'\n' ORDER BY customer_id) FROM customers WHERE customer_id = ANY($2))), ''),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE t.customer_id = $1
+	`
+	_, err = tx.Exec(query, targetID, pq.Array(sourceIDs))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM customers WHERE customer_id = ANY($1)`, pq.Array(sourceIDs))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,87 @@
+package dal
+
+import (
+	"database/sql"
+
+	"hot-coffee/internal/utils"
+	"hot-coffee/models"
+)
+
+type EmployeeRepository interface {
+	SaveEmployee(employee models.Employee, passwordHash string) (int, error)
+	GetAll() ([]models.Employee, error)
+	GetByID(id int) (models.Employee, error)
+	Update(employee models.Employee) error
+	SetPasswordHash(id int, passwordHash string) error
+	GetPasswordHash(id int) (string, error)
+	HasLoginManager() (bool, error)
+}
+
+type employeeRepo struct {
+	path string
+}
+
+func NewEmployeeRepo(path string) *employeeRepo {
+	return &employeeRepo{path: path}
+}
+
+func (r *employeeRepo) SaveEmployee(employee models.Employee, passwordHash string) (int, error) {
+	query := `INSERT INTO employees (name, role, active, password_hash, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING employee_id`
+	var id int
+	err := utils.DB.QueryRow(query, employee.Name, employee.Role, employee.Active, nullString(passwordHash), employee.CreatedAt, employee.UpdatedAt).Scan(&id)
+	return id, err
+}
+
+func (r *employeeRepo) GetAll() ([]models.Employee, error) {
+	rows, err := utils.DB.Query(`SELECT employee_id, name, role, active, created_at, updated_at FROM employees ORDER BY employee_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var employees []models.Employee
+	for rows.Next() {
+		employee, err := scanEmployee(rows)
+		if err != nil {
+			return nil, err
+		}
+		employees = append(employees, employee)
+	}
+	return employees, rows.Err()
+}
+
+func (r *employeeRepo) GetByID(id int) (models.Employee, error) {
+	row := utils.DB.QueryRow(`SELECT employee_id, name, role, active, created_at, updated_at FROM employees WHERE employee_id = $1`, id)
+	return scanEmployee(row)
+}
+
+func scanEmployee(row rowScanner) (models.Employee, error) {
+	var employee models.Employee
+	err := row.Scan(&employee.ID, &employee.Name, &employee.Role, &employee.Active, &employee.CreatedAt, &employee.UpdatedAt)
+	return employee, err
+}
+
+func (r *employeeRepo) Update(employee models.Employee) error {
+	query := `UPDATE employees SET name = $1, role = $2, active = $3, updated_at = $4 WHERE employee_id = $5`
+	_, err := utils.DB.Exec(query, employee.Name, employee.Role, employee.Active, employee.UpdatedAt, employee.ID)
+	return err
+}
+
+func (r *employeeRepo) SetPasswordHash(id int, passwordHash string) error {
+	_, err := utils.DB.Exec(`UPDATE employees SET password_hash = $1, updated_at = CURRENT_TIMESTAMP WHERE employee_id = $2`, passwordHash, id)
+	return err
+}
+
+// GetPasswordHash returns an empty hash for employees that cannot log in.
+func (r *employeeRepo) GetPasswordHash(id int) (string, error) {
+	var hash sql.NullString
+	err := utils.DB.QueryRow(`SELECT password_hash FROM employees WHERE employee_id = $1`, id).Scan(&hash)
+	return hash.String, err
+}
+
+// HasLoginManager reports whether any active manager is able to log in.
+func (r *employeeRepo) HasLoginManager() (bool, error) {
+	var exists bool
+	err := utils.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM employees WHERE role = 'manager' AND active AND password_hash IS NOT NULL)`).Scan(&exists)
+	return exists, err
+}
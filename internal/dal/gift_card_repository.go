@@ -0,0 +1,154 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+
+	"hot-coffee/internal/utils"
+	"hot-coffee/models"
+)
+
+type GiftCardRepository interface {
+	Issue(card models.GiftCard) error
+	Exists(code string) (bool, error)
+	GetByCode(code string) (models.GiftCard, error)
+	Load(code string, amount float64) (models.GiftCard, error)
+	GetTransactions(code string) ([]models.GiftCardTransaction, error)
+	GetLiability() (float64, error)
+}
+
+type giftCardRepo struct {
+	path string
+}
+
+func NewGiftCardRepo(path string) *giftCardRepo {
+	return &giftCardRepo{path: path}
+}
+
+func (r *giftCardRepo) Issue(card models.GiftCard) error {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO gift_cards (code, initial_balance, balance, created_at, updated_at) VALUES ($1, $2, $2, $3, $4)`,
+		card.Code, card.InitialBalance, card.CreatedAt, card.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if err = addGiftCardTransaction(tx, card.Code, 0, "issue", card.InitialBalance, card.InitialBalance); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func addGiftCardTransaction(tx *sql.Tx, code string, orderID int, transactionType string, amount, balanceAfter float64) error {
+	_, err := tx.Exec(`INSERT INTO gift_card_transactions (code, order_id, transaction_type, amount, balance_after) VALUES ($1, $2, $3, $4, $5)`,
+		code, nullInt(orderID), transactionType, amount, balanceAfter)
+	return err
+}
+
+func (r *giftCardRepo) Exists(code string) (bool, error) {
+	var exists bool
+	err := utils.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM gift_cards WHERE code = $1)`, code).Scan(&exists)
+	return exists, err
+}
+
+func (r *giftCardRepo) GetByCode(code string) (models.GiftCard, error) {
+	var card models.GiftCard
+	err := utils.DB.QueryRow(`SELECT code, initial_balance, balance, created_at, updated_at FROM gift_cards WHERE code = $1`, code).
+		Scan(&card.Code, &card.InitialBalance, &card.Balance, &card.CreatedAt, &card.UpdatedAt)
+	return card, err
+}
+
+func (r *giftCardRepo) Load(code string, amount float64) (models.GiftCard, error) {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return models.GiftCard{}, err
+	}
+	defer tx.Rollback()
+
+	var card models.GiftCard
+	err = tx.QueryRow(`UPDATE gift_cards SET balance = balance + $1, updated_at = CURRENT_TIMESTAMP WHERE code = $2
+					   RETURNING code, initial_balance, balance, created_at, updated_at`, amount, code).
+		Scan(&card.Code, &card.InitialBalance, &card.Balance, &card.CreatedAt, &card.UpdatedAt)
+	if err != nil {
+		return models.GiftCard{}, err
+	}
+	if err = addGiftCardTransaction(tx, code, 0, "load", amount, card.Balance); err != nil {
+		return models.GiftCard{}, err
+	}
+	return card, tx.Commit()
+}
+
+// spendGiftCard takes up to amount from the card, less when the balance is
+// lower, and returns what was applied. It runs in the transaction that saves
+// the payment.
+func spendGiftCard(tx *sql.Tx, code string, orderID int, amount float64) (float64, error) {
+	var balance float64
+	err := tx.QueryRow(`SELECT balance FROM gift_cards WHERE code = $1 FOR UPDATE`, code).Scan(&balance)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("gift card not found")
+		}
+		return 0, err
+	}
+	applied := math.Min(amount, balance)
+	if applied <= 0 {
+		return 0, errors.New("gift card has no balance left")
+	}
+	balance -= applied
+	_, err = tx.Exec(`UPDATE gift_cards SET balance = $1, updated_at = CURRENT_TIMESTAMP WHERE code = $2`, balance, code)
+	if err != nil {
+		return 0, err
+	}
+	return applied, addGiftCardTransaction(tx, code, orderID, "spend", -applied, balance)
+}
+
+// refundGiftCard puts amount back on the card for a refunded order, in the
+// transaction that saves the refund.
+func refundGiftCard(tx *sql.Tx, code string, orderID int, amount float64) error {
+	var balance float64
+	err := tx.QueryRow(`UPDATE gift_cards SET balance = balance + $1, updated_at = CURRENT_TIMESTAMP WHERE code = $2 RETURNING balance`, amount, code).Scan(&balance)
+	if err != nil {
+		return err
+	}
+	return addGiftCardTransaction(tx, code, orderID, "refund", amount, balance)
+}
+
+func (r *giftCardRepo) GetTransactions(code string) ([]models.GiftCardTransaction, error) {
+	query := `
+		SELECT transaction_id, code, order_id, transaction_type, amount, balance_after, created_at
+		FROM gift_card_transactions
+		WHERE code = $1
+		ORDER BY transaction_id
+	`
+	rows, err := utils.DB.Query(query, code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []models.GiftCardTransaction
+	for rows.Next() {
+		var transaction models.GiftCardTransaction
+		var orderID sql.NullInt64
+		err := rows.Scan(&transaction.ID, &transaction.Code, &orderID, &transaction.TransactionType,
+			&transaction.Amount, &transaction.BalanceAfter, &transaction.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		transaction.OrderID = int(orderID.Int64)
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
+}
+
+// GetLiability is the value still owed to card holders.
+func (r *giftCardRepo) GetLiability() (float64, error) {
+	var liability float64
+	err := utils.DB.QueryRow(`SELECT COALESCE(SUM(balance), 0) FROM gift_cards`).Scan(&liability)
+	return liability, err
+}
@@ -0,0 +1,88 @@
+package dal
+
+import (
+	"database/sql"
+	"time"
+
+	"hot-coffee/internal/utils"
+	"hot-coffee/models"
+)
+
+type IdempotencyRepository interface {
+	Reserve(caller, key, fingerprint string, ttl time.Duration) (bool, error)
+	Get(caller, key string) (models.IdempotencyRecord, error)
+	Complete(caller, key string, record models.IdempotencyRecord) error
+	Release(caller, key string) error
+	PurgeExpired() (int, error)
+}
+
+type idempotencyRepo struct {
+	path string
+}
+
+func NewIdempotencyRepo(path string) *idempotencyRepo {
+	return &idempotencyRepo{path: path}
+}
+
+// Reserve claims the key for a new request, false when it is taken and not
+// expired yet.
+func (r *idempotencyRepo) Reserve(caller, key, fingerprint string, ttl time.Duration) (bool, error) {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM idempotency_keys WHERE caller = $1 AND idempotency_key = $2 AND expires_at <= CURRENT_TIMESTAMP`, caller, key)
+	if err != nil {
+		return false, err
+	}
+	result, err := tx.Exec(`
+		INSERT INTO idempotency_keys (caller, idempotency_key, fingerprint, expires_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP + make_interval(secs => $4))
+		ON CONFLICT DO NOTHING`, caller, key, fingerprint, ttl.Seconds())
+	if err != nil {
+		return false, err
+	}
+	reserved, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return reserved == 1, tx.Commit()
+}
+
+func (r *idempotencyRepo) Get(caller, key string) (models.IdempotencyRecord, error) {
+	var record models.IdempotencyRecord
+	var statusCode sql.NullInt64
+	var contentType sql.NullString
+	err := utils.DB.QueryRow(`
+		SELECT fingerprint, status_code, content_type, response_body FROM idempotency_keys
+		WHERE caller = $1 AND idempotency_key = $2`, caller, key).Scan(&record.Fingerprint, &statusCode, &contentType, &record.Body)
+	record.StatusCode = int(statusCode.Int64)
+	record.ContentType = contentType.String
+	return record, err
+}
+
+// Complete stores the response of the request that reserved the key.
+func (r *idempotencyRepo) Complete(caller, key string, record models.IdempotencyRecord) error {
+	_, err := utils.DB.Exec(`
+		UPDATE idempotency_keys SET status_code = $3, content_type = $4, response_body = $5
+		WHERE caller = $1 AND idempotency_key = $2`,
+		caller, key, record.StatusCode, nullString(record.ContentType), record.Body)
+	return err
+}
+
+// Release frees the key so the request can be tried again.
+func (r *idempotencyRepo) Release(caller, key string) error {
+	_, err := utils.DB.Exec(`DELETE FROM idempotency_keys WHERE caller = $1 AND idempotency_key = $2`, caller, key)
+	return err
+}
+
+func (r *idempotencyRepo) PurgeExpired() (int, error) {
+	result, err := utils.DB.Exec(`DELETE FROM idempotency_keys WHERE expires_at <= CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
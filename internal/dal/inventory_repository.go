@@ -1,23 +1,33 @@
 package dal
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"math"
 	"os"
 
 	"hot-coffee/internal/utils"
 	"hot-coffee/models"
+
+	"github.com/lib/pq"
 )
 
 type InventoryRepository interface {
 	SaveAll(item []models.InventoryItem) error
 	GetAll() ([]models.InventoryItem, error)
 	Exists(id string) (bool, error)
-	AddItem(item models.InventoryItem) error
-	DeleteItem(id string) error
-	UpdateItem(item models.InventoryItem) error
-	CheckInventory(items []models.OrderItem) (bool, error)
-	UpdateInventory(items []models.OrderItem) error
+	AddItem(item models.InventoryItem, actor models.Principal) error
+	ArchiveItem(id string, version int, actor models.Principal) error
+	RestoreItem(id string, actor models.Principal) error
+	IsArchived(id string) (bool, error)
+	GetDependentMenuItems(id string) ([]string, error)
+	GetDependentPrepItems(id string) ([]string, error)
+	UpdateItem(item models.InventoryItem, actor models.Principal) error
+	CheckInventory(items []models.OrderItem, channel string, orderID int) (bool, error)
 	GetLeftovers(sortBy string, offset, limit int) ([]models.InventoryItem, int, error)
+	ApplyProduction(deltas map[string]float64, actor models.Principal) error
+	GetLowStock() ([]models.InventoryItem, error)
 }
 
 type inventoryRepo struct {
@@ -28,21 +38,166 @@ func NewInventoryRepo(path string) *inventoryRepo {
 	return &inventoryRepo{path: path}
 }
 
-func (r *inventoryRepo) AddItem(item models.InventoryItem) error {
-	_, err := utils.DB.Exec(`INSERT INTO inventory(ingredient_id, name, quantity, unit, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)`,
-		item.IngredientID, item.Name, item.Quantity, item.Unit, item.CreatedAt, item.UpdatedAt)
+func (r *inventoryRepo) AddItem(item models.InventoryItem, actor models.Principal) error {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO inventory(ingredient_id, name, quantity, unit, cost_per_unit, reorder_level, allergens, dietary_tags, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		item.IngredientID, item.Name, item.Quantity, item.Unit, item.CostPerUnit, item.ReorderLevel,
+		pq.Array(item.Allergens), pq.Array(item.DietaryTags), item.CreatedAt, item.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if err = saveNutrition(tx, item); err != nil {
+		return err
+	}
+	if err = saveRecipe(tx, item); err != nil {
+		return err
+	}
+	if err = writeAudit(tx, actor, "create", models.AuditInventoryItem, item.IngredientID, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func saveNutrition(tx *sql.Tx, item models.InventoryItem) error {
+	query := `UPDATE inventory SET nutrition_basis = $1, nutrition_unit = $2, calories = $3, protein = $4, fat = $5, carbs = $6 WHERE ingredient_id = $7`
+	if item.Nutrition == nil {
+		_, err := tx.Exec(query, nil, nil, nil, nil, nil, nil, item.IngredientID)
+		return err
+	}
+	n := item.Nutrition
+	_, err := tx.Exec(query, n.Basis, n.Unit, n.Calories, n.Protein, n.Fat, n.Carbs, item.IngredientID)
 	return err
 }
 
-func (r *inventoryRepo) DeleteItem(id string) error {
-	query := `DELETE FROM inventory WHERE ingredient_id = $1`
-	_, err := utils.DB.Exec(query, id)
+func saveRecipe(tx *sql.Tx, item models.InventoryItem) error {
+	_, err := tx.Exec(`DELETE FROM inventory_recipes WHERE prep_item_id = $1`, item.IngredientID)
 	if err != nil {
 		return err
 	}
+	for _, component := range item.Recipe {
+		_, err = tx.Exec(`INSERT INTO inventory_recipes (prep_item_id, ingredient_id, quantity) VALUES ($1, $2, $3)`,
+			item.IngredientID, component.IngredientID, component.Quantity)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// ArchiveItem hides the ingredient from the inventory instead of deleting it,
+// recipes of archived menu items and transaction history still point to it.
+// With a version set the ingredient must still be at it.
+func (r *inventoryRepo) ArchiveItem(id string, version int, actor models.Principal) error {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := auditSnapshot(tx, models.AuditInventoryItem, id)
+	if err != nil {
+		return err
+	}
+	query := `UPDATE inventory SET archived_at = CURRENT_TIMESTAMP, version = version + 1 WHERE ingredient_id = $1 AND archived_at IS NULL AND ($2 = 0 OR version = $2)`
+	result, err := tx.Exec(query, id, version)
+	if err != nil {
+		return err
+	}
+	if version != 0 {
+		if err = checkVersionUpdate(result); err != nil {
+			return err
+		}
+	}
+	if err = writeAudit(tx, actor, "delete", models.AuditInventoryItem, id, before); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *inventoryRepo) RestoreItem(id string, actor models.Principal) error {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := auditSnapshot(tx, models.AuditInventoryItem, id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE inventory SET archived_at = NULL, version = version + 1 WHERE ingredient_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if err = writeAudit(tx, actor, "restore", models.AuditInventoryItem, id, before); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *inventoryRepo) IsArchived(id string) (bool, error) {
+	var archived bool
+	err := utils.DB.QueryRow(`SELECT archived_at IS NOT NULL FROM inventory WHERE ingredient_id = $1`, id).Scan(&archived)
+	return archived, err
+}
+
+// GetDependentMenuItems lists the active menu items whose recipe uses the ingredient.
+func (r *inventoryRepo) GetDependentMenuItems(id string) ([]string, error) {
+	query := `
+		SELECT DISTINCT m.menu_item_id
+		FROM menu_item_ingredients mi
+		JOIN menu_items m ON mi.menu_item_id = m.menu_item_id
+		WHERE mi.ingredient_id = $1 AND m.archived_at IS NULL
+		ORDER BY m.menu_item_id
+	`
+	rows, err := utils.DB.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var menuItemIDs []string
+	for rows.Next() {
+		var menuItemID string
+		if err := rows.Scan(&menuItemID); err != nil {
+			return nil, err
+		}
+		menuItemIDs = append(menuItemIDs, menuItemID)
+	}
+	return menuItemIDs, rows.Err()
+}
+
+// GetDependentPrepItems lists the active prep items whose recipe uses the ingredient.
+func (r *inventoryRepo) GetDependentPrepItems(id string) ([]string, error) {
+	query := `
+		SELECT ir.prep_item_id
+		FROM inventory_recipes ir
+		JOIN inventory i ON ir.prep_item_id = i.ingredient_id
+		WHERE ir.ingredient_id = $1 AND i.archived_at IS NULL
+		ORDER BY ir.prep_item_id
+	`
+	rows, err := utils.DB.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prepItemIDs []string
+	for rows.Next() {
+		var prepItemID string
+		if err := rows.Scan(&prepItemID); err != nil {
+			return nil, err
+		}
+		prepItemIDs = append(prepItemIDs, prepItemID)
+	}
+	return prepItemIDs, rows.Err()
+}
+
 func (r *inventoryRepo) SaveAll(item []models.InventoryItem) error {
 	jsonData, err := json.MarshalIndent(item, "", "  ")
 	if err != nil {
@@ -65,7 +220,12 @@ func (r *inventoryRepo) SaveAll(item []models.InventoryItem) error {
 }
 
 func (r *inventoryRepo) GetAll() ([]models.InventoryItem, error) {
-	query := `SELECT ingredient_id, name, quantity, unit, created_at, updated_at FROM inventory;`
+	query := `
+	SELECT ingredient_id, name, quantity,
+		COALESCE((SELECT SUM(r.quantity) FROM inventory_reservations r WHERE r.ingredient_id = inventory.ingredient_id), 0),
+		unit, cost_per_unit, reorder_level, allergens, dietary_tags,
+		nutrition_basis, nutrition_unit, calories, protein, fat, carbs, created_at, updated_at, version
+	FROM inventory WHERE archived_at IS NULL;`
 
 	rows, err := utils.DB.Query(query)
 	if err != nil {
@@ -73,21 +233,63 @@ func (r *inventoryRepo) GetAll() ([]models.InventoryItem, error) {
 	}
 
 	defer rows.Close()
-	var inventory models.InventoryItem
 	var inventoryData []models.InventoryItem
 	for rows.Next() {
+		var inventory models.InventoryItem
+		var basis, calories, protein, fat, carbs sql.NullFloat64
+		var nutritionUnit sql.NullString
 		err := rows.Scan(&inventory.IngredientID, &inventory.Name,
-			&inventory.Quantity, &inventory.Unit,
-			&inventory.CreatedAt, &inventory.UpdatedAt)
+			&inventory.Quantity, &inventory.Reserved, &inventory.Unit, &inventory.CostPerUnit, &inventory.ReorderLevel,
+			pq.Array(&inventory.Allergens), pq.Array(&inventory.DietaryTags),
+			&basis, &nutritionUnit, &calories, &protein, &fat, &carbs,
+			&inventory.CreatedAt, &inventory.UpdatedAt, &inventory.Version)
 		if err != nil {
 			return nil, err
 		}
+		inventory.Available = inventory.Quantity - inventory.Reserved
+		if basis.Valid && nutritionUnit.Valid {
+			inventory.Nutrition = &models.Nutrition{
+				Basis:    basis.Float64,
+				Unit:     nutritionUnit.String,
+				Calories: calories.Float64,
+				Protein:  protein.Float64,
+				Fat:      fat.Float64,
+				Carbs:    carbs.Float64,
+			}
+		}
 		inventoryData = append(inventoryData, inventory)
 	}
 
+	recipes, err := r.getRecipes()
+	if err != nil {
+		return nil, err
+	}
+	for i := range inventoryData {
+		inventoryData[i].Recipe = recipes[inventoryData[i].IngredientID]
+	}
+
 	return inventoryData, nil
 }
 
+func (r *inventoryRepo) getRecipes() (map[string][]models.InventoryRecipeIngredient, error) {
+	rows, err := utils.DB.Query(`SELECT prep_item_id, ingredient_id, quantity FROM inventory_recipes ORDER BY prep_item_id, ingredient_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recipes := make(map[string][]models.InventoryRecipeIngredient)
+	for rows.Next() {
+		var prepItemID string
+		var component models.InventoryRecipeIngredient
+		if err := rows.Scan(&prepItemID, &component.IngredientID, &component.Quantity); err != nil {
+			return nil, err
+		}
+		recipes[prepItemID] = append(recipes[prepItemID], component)
+	}
+	return recipes, rows.Err()
+}
+
 func (r *inventoryRepo) Exists(id string) (bool, error) {
 	var exists bool
 	query := `SELECT EXISTS (SELECT 1 FROM inventory WHERE ingredient_id = $1)`
@@ -98,34 +300,140 @@ func (r *inventoryRepo) Exists(id string) (bool, error) {
 	return exists, nil
 }
 
-func (r *inventoryRepo) UpdateItem(item models.InventoryItem) error {
+// UpdateItem replaces the ingredient, logging a change of stock and
+// recording stock that was added as received. With a version set the
+// ingredient must still be at it.
+func (r *inventoryRepo) UpdateItem(item models.InventoryItem, actor models.Principal) error {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := auditSnapshot(tx, models.AuditInventoryItem, item.IngredientID)
+	if err != nil {
+		return err
+	}
+
 	var quantity float64
-	err := utils.DB.QueryRow(`SELECT quantity FROM inventory WHERE ingredient_id = $1`, item.IngredientID).Scan(&quantity)
+	var version int
+	err = tx.QueryRow(`SELECT quantity, version FROM inventory WHERE ingredient_id = $1 FOR UPDATE`, item.IngredientID).Scan(&quantity, &version)
 	if err != nil {
 		return err
 	}
+	if item.Version != 0 && item.Version != version {
+		return errors.New("version mismatch")
+	}
 	if quantity != item.Quantity {
-		query := `INSERT INTO inventory_transactions (ingredient_id, old_quantity, new_quantity, unit) VALUES ($1, $2, $3, $4)`
-		_, err = utils.DB.Exec(query, item.IngredientID, quantity, item.Quantity, item.Unit)
+		query := `INSERT INTO inventory_transactions (ingredient_id, old_quantity, new_quantity, unit, employee_id) VALUES ($1, $2, $3, $4, $5)`
+		_, err = tx.Exec(query, item.IngredientID, quantity, item.Quantity, item.Unit, nullInt(actor.EmployeeID))
+		if err != nil {
+			return err
+		}
+	}
+	if item.Quantity > quantity {
+		err = writeOutbox(tx, models.EventInventoryReceived, models.InventoryReceivedEvent{
+			IngredientID: item.IngredientID,
+			Name:         item.Name,
+			Received:     math.Round((item.Quantity-quantity)*100) / 100,
+			Quantity:     item.Quantity,
+			Unit:         item.Unit,
+			EmployeeID:   actor.EmployeeID,
+		})
 		if err != nil {
 			return err
 		}
 	}
 
-	query := `UPDATE inventory SET name = $1, quantity = $2, unit = $3, updated_at = $4 WHERE ingredient_id = $5`
-	_, err = utils.DB.Exec(query, item.Name, item.Quantity, item.Unit, item.UpdatedAt, item.IngredientID)
-	return err
+	query := `UPDATE inventory SET name = $1, quantity = $2, unit = $3, cost_per_unit = $4, reorder_level = $5, allergens = $6, dietary_tags = $7, updated_at = $8, version = version + 1 WHERE ingredient_id = $9`
+	_, err = tx.Exec(query, item.Name, item.Quantity, item.Unit, item.CostPerUnit, item.ReorderLevel,
+		pq.Array(item.Allergens), pq.Array(item.DietaryTags), item.UpdatedAt, item.IngredientID)
+	if err != nil {
+		return err
+	}
+	if err = saveRecipe(tx, item); err != nil {
+		return err
+	}
+	if err = saveNutrition(tx, item); err != nil {
+		return err
+	}
+	if err = writeAudit(tx, actor, "update", models.AuditInventoryItem, item.IngredientID, before); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ApplyProduction adds the given deltas to the stock in one transaction,
+// logging each change in inventory_transactions. Stock reserved for open
+// orders cannot be used up.
+func (r *inventoryRepo) ApplyProduction(deltas map[string]float64, actor models.Principal) error {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for ingredientID, delta := range deltas {
+		if delta == 0 {
+			continue
+		}
+		before, err := auditSnapshot(tx, models.AuditInventoryItem, ingredientID)
+		if err != nil {
+			return err
+		}
+		var quantity, reserved float64
+		var unit string
+		err = tx.QueryRow(`
+			SELECT quantity, unit,
+				COALESCE((SELECT SUM(r.quantity) FROM inventory_reservations r WHERE r.ingredient_id = inventory.ingredient_id), 0)
+			FROM inventory WHERE ingredient_id = $1 FOR UPDATE`, ingredientID).Scan(&quantity, &unit, &reserved)
+		if err != nil {
+			return err
+		}
+		newQuantity := quantity + delta
+		if newQuantity < 0 || (delta < 0 && newQuantity < reserved) {
+			return errors.New("not enough ingredient: " + ingredientID)
+		}
+		_, err = tx.Exec(`UPDATE inventory SET quantity = $1, updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE ingredient_id = $2`, newQuantity, ingredientID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO inventory_transactions (ingredient_id, old_quantity, new_quantity, unit, employee_id) VALUES ($1, $2, $3, $4, $5)`,
+			ingredientID, quantity, newQuantity, unit, nullInt(actor.EmployeeID))
+		if err != nil {
+			return err
+		}
+		if err = writeAudit(tx, actor, "update", models.AuditInventoryItem, ingredientID, before); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
-func (r *inventoryRepo) CheckInventory(items []models.OrderItem) (bool, error) {
+// itemUsageQuery lists what one unit of menu item $1 takes from inventory
+// when sold on channel $2, its recipe plus the packaging of the channel.
+const itemUsageQuery = `
+	SELECT ingredient_id, quantity FROM menu_item_ingredients WHERE menu_item_id = $1
+	UNION ALL
+	SELECT pr.ingredient_id, pr.quantity
+	FROM packaging_rules pr
+	JOIN menu_items m ON m.menu_item_id = $1
+	WHERE pr.channel::text = $2 AND (pr.category IS NULL OR pr.category = m.category)
+`
+
+// CheckInventory checks the items against the stock that is not reserved.
+// orderID is the order being updated, what it reserved itself is counted
+// as available to it.
+func (r *inventoryRepo) CheckInventory(items []models.OrderItem, channel string, orderID int) (bool, error) {
 	for _, item := range items {
 		query := `
-			SELECT mi.ingredient_id, mi.quantity, i.quantity 
-			FROM menu_item_ingredients mi
-			JOIN inventory i ON mi.ingredient_id = i.ingredient_id
-			WHERE mi.menu_item_id = $1
+			SELECT u.ingredient_id, SUM(u.quantity),
+				i.quantity - COALESCE((SELECT SUM(ir.quantity) FROM inventory_reservations ir WHERE ir.ingredient_id = u.ingredient_id AND ir.order_id <> $3), 0)
+			FROM (` + itemUsageQuery + `) u
+			JOIN inventory i ON u.ingredient_id = i.ingredient_id
+			GROUP BY u.ingredient_id, i.quantity
 		`
-		rows, err := utils.DB.Query(query, item.MenuItemID)
+		rows, err := utils.DB.Query(query, item.MenuItemID, channel, orderID)
 		if err != nil {
 			return false, err
 		}
@@ -149,47 +457,106 @@ func (r *inventoryRepo) CheckInventory(items []models.OrderItem) (bool, error) {
 	return true, nil
 }
 
-func (r *inventoryRepo) UpdateInventory(items []models.OrderItem) error {
+// reserveInventory holds back what the order's items will take from stock
+// when it closes. Reserved stock is not available to other orders.
+func reserveInventory(tx *sql.Tx, orderID int, channel string, items []models.OrderItem) error {
+	query := `
+		INSERT INTO inventory_reservations (order_id, ingredient_id, quantity)
+		SELECT $3::int, u.ingredient_id, SUM(u.quantity) * $4::int
+		FROM (` + itemUsageQuery + `) u
+		GROUP BY u.ingredient_id
+	`
 	for _, item := range items {
-		query := `
-		SELECT ingredient_id, quantity
-		FROM menu_item_ingredients
-		WHERE menu_item_id = $1;
-		`
-		rows, err := utils.DB.Query(query, item.MenuItemID)
-		if err != nil {
+		if _, err := tx.Exec(query, item.MenuItemID, channel, orderID, item.Quantity); err != nil {
 			return err
 		}
-		defer rows.Close()
-
-		updateQuery := `
-		UPDATE inventory 
-		SET quantity = quantity - $1
-		WHERE ingredient_id = $2;
-		`
+	}
+	return nil
+}
 
-		for rows.Next() {
-			var ingredientID string
-			var requiredQuantity float64
+// releaseInventory drops what the orders reserved.
+func releaseInventory(tx *sql.Tx, orderIDs ...int) error {
+	_, err := tx.Exec(`DELETE FROM inventory_reservations WHERE order_id = ANY($1)`, pq.Array(orderIDs))
+	return err
+}
 
-			if err := rows.Scan(&ingredientID, &requiredQuantity); err != nil {
-				return err
-			}
+// checkReserved fails when an ingredient the order reserved is reserved
+// beyond its stock. The ingredients are locked first, so orders reserving
+// the same stock are checked one after the other.
+func checkReserved(tx *sql.Tx, orderID int) error {
+	_, err := tx.Exec(`
+		SELECT 1 FROM inventory
+		WHERE ingredient_id IN (SELECT ingredient_id FROM inventory_reservations WHERE order_id = $1)
+		ORDER BY ingredient_id FOR UPDATE`, orderID)
+	if err != nil {
+		return err
+	}
+	var short bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM inventory i
+			JOIN inventory_reservations r ON r.ingredient_id = i.ingredient_id
+			WHERE i.ingredient_id IN (SELECT ingredient_id FROM inventory_reservations WHERE order_id = $1)
+			GROUP BY i.ingredient_id, i.quantity
+			HAVING SUM(r.quantity) > i.quantity)`, orderID).Scan(&short)
+	if err != nil {
+		return err
+	}
+	if short {
+		return errors.New("not enough inventory for order")
+	}
+	return nil
+}
 
-			totalQuantity := item.Quantity * int(requiredQuantity)
+// takeReserved turns what the order reserved into stock taken out,
+// logging each change in inventory_transactions.
+func takeReserved(tx *sql.Tx, orderID, employeeID int) error {
+	rows, err := tx.Query(`
+		SELECT ingredient_id, SUM(quantity) FROM inventory_reservations
+		WHERE order_id = $1 GROUP BY ingredient_id ORDER BY ingredient_id`, orderID)
+	if err != nil {
+		return err
+	}
+	var ingredientIDs []string
+	var quantities []float64
+	for rows.Next() {
+		var ingredientID string
+		var quantity float64
+		if err := rows.Scan(&ingredientID, &quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		ingredientIDs = append(ingredientIDs, ingredientID)
+		quantities = append(quantities, quantity)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
 
-			_, err := utils.DB.Exec(updateQuery, totalQuantity, ingredientID)
-			if err != nil {
-				return err
-			}
+	updateQuery := `
+		UPDATE inventory
+		SET quantity = quantity - $1, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		WHERE ingredient_id = $2
+		RETURNING quantity + $1, quantity, unit;
+	`
+	logQuery := `INSERT INTO inventory_transactions (ingredient_id, old_quantity, new_quantity, unit, employee_id) VALUES ($1, $2, $3, $4, $5)`
+	for i, ingredientID := range ingredientIDs {
+		var oldQuantity, newQuantity float64
+		var unit string
+		if err = tx.QueryRow(updateQuery, quantities[i], ingredientID).Scan(&oldQuantity, &newQuantity, &unit); err != nil {
+			return err
+		}
+		if _, err = tx.Exec(logQuery, ingredientID, oldQuantity, newQuantity, unit, nullInt(employeeID)); err != nil {
+			return err
 		}
 	}
-	return nil
+	return releaseInventory(tx, orderID)
 }
 
 func (r *inventoryRepo) GetLeftovers(sortBy string, offset, limit int) ([]models.InventoryItem, int, error) {
-	query := `SELECT name, quantity FROM inventory`
-	countQuery := `SELECT COUNT(*) FROM inventory`
+	query := `SELECT name, quantity FROM inventory WHERE archived_at IS NULL`
+	countQuery := `SELECT COUNT(*) FROM inventory WHERE archived_at IS NULL`
 
 	switch sortBy {
 	case "quantity":
@@ -223,3 +590,25 @@ func (r *inventoryRepo) GetLeftovers(sortBy string, offset, limit int) ([]models
 
 	return items, total, nil
 }
+
+// GetLowStock lists the items at or below their reorder level.
+func (r *inventoryRepo) GetLowStock() ([]models.InventoryItem, error) {
+	rows, err := utils.DB.Query(`
+		SELECT ingredient_id, name, quantity, unit, reorder_level FROM inventory
+		WHERE archived_at IS NULL AND reorder_level > 0 AND quantity <= reorder_level
+		ORDER BY ingredient_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.InventoryItem
+	for rows.Next() {
+		var item models.InventoryItem
+		if err := rows.Scan(&item.IngredientID, &item.Name, &item.Quantity, &item.Unit, &item.ReorderLevel); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,135 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+
+	"hot-coffee/internal/utils"
+	"hot-coffee/models"
+)
+
+type LoyaltyRepository interface {
+	GetRules() ([]models.LoyaltyRule, error)
+	ReplaceRules(rules []models.LoyaltyRule) error
+	AddEntry(entry models.LoyaltyEntry) error
+	GetBalance(customerID int) (int, error)
+	GetEntries(customerID int) ([]models.LoyaltyEntry, error)
+}
+
+type loyaltyRepo struct {
+	path string
+}
+
+func NewLoyaltyRepo(path string) *loyaltyRepo {
+	return &loyaltyRepo{path: path}
+}
+
+func (r *loyaltyRepo) GetRules() ([]models.LoyaltyRule, error) {
+	rows, err := utils.DB.Query(`SELECT rule_id, category, points_per_unit FROM loyalty_rules ORDER BY category NULLS FIRST`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.LoyaltyRule
+	for rows.Next() {
+		var rule models.LoyaltyRule
+		var category sql.NullString
+		if err := rows.Scan(&rule.ID, &category, &rule.PointsPerUnit); err != nil {
+			return nil, err
+		}
+		rule.Category = category.String
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r *loyaltyRepo) ReplaceRules(rules []models.LoyaltyRule) error {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`DELETE FROM loyalty_rules`); err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		_, err = tx.Exec(`INSERT INTO loyalty_rules (category, points_per_unit) VALUES ($1, $2)`, nullString(rule.Category), rule.PointsPerUnit)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *loyaltyRepo) AddEntry(entry models.LoyaltyEntry) error {
+	_, err := utils.DB.Exec(`INSERT INTO loyalty_ledger (customer_id, order_id, entry_type, points) VALUES ($1, $2, $3, $4)`,
+		entry.CustomerID, nullInt(entry.OrderID), entry.EntryType, entry.Points)
+	return err
+}
+
+func (r *loyaltyRepo) GetBalance(customerID int) (int, error) {
+	var balance int
+	err := utils.DB.QueryRow(`SELECT COALESCE(SUM(points), 0) FROM loyalty_ledger WHERE customer_id = $1`, customerID).Scan(&balance)
+	return balance, err
+}
+
+func (r *loyaltyRepo) GetEntries(customerID int) ([]models.LoyaltyEntry, error) {
+	query := `
+		SELECT entry_id, customer_id, order_id, entry_type, points, created_at
+		FROM loyalty_ledger
+		WHERE customer_id = $1
+		ORDER BY entry_id
+	`
+	rows, err := utils.DB.Query(query, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.LoyaltyEntry
+	for rows.Next() {
+		var entry models.LoyaltyEntry
+		var orderID sql.NullInt64
+		if err := rows.Scan(&entry.ID, &entry.CustomerID, &orderID, &entry.EntryType, &entry.Points, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entry.OrderID = int(orderID.Int64)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// redeemPoints spends points of the customer on a new order, in the
+// transaction that saves the order. The customer row is locked, so two
+// orders cannot both spend the same balance.
+func redeemPoints(tx *sql.Tx, customerID, orderID, points int) error {
+	if _, err := tx.Exec(`SELECT 1 FROM customers WHERE customer_id = $1 FOR UPDATE`, customerID); err != nil {
+		return err
+	}
+	var balance int
+	err := tx.QueryRow(`SELECT COALESCE(SUM(points), 0) FROM loyalty_ledger WHERE customer_id = $1`, customerID).Scan(&balance)
+	if err != nil {
+		return err
+	}
+	if balance < points {
+		return errors.New("not enough loyalty points")
+	}
+	_, err = tx.Exec(`INSERT INTO loyalty_ledger (customer_id, order_id, entry_type, points) VALUES ($1, $2, 'redeem', $3)`,
+		customerID, orderID, -points)
+	return err
+}
+
+// reverseOrderPoints undoes everything an order earned or redeemed. It runs
+// in the transaction that cancels or deletes the order, before the order row
+// is gone and its ledger entries lose their order_id.
+func reverseOrderPoints(db execer, orderID int) error {
+	_, err := db.Exec(`
+		INSERT INTO loyalty_ledger (customer_id, order_id, entry_type, points)
+		SELECT MIN(customer_id), $1, 'reversal', -SUM(points)
+		FROM loyalty_ledger
+		WHERE order_id = $1
+		HAVING COALESCE(SUM(points), 0) <> 0`, orderID)
+	return err
+}
@@ -2,18 +2,22 @@ package dal
 
 import (
 	"database/sql"
+	"errors"
 
 	"hot-coffee/internal/utils"
 	"hot-coffee/models"
 )
 
 type MenuRepository interface {
-	DeleteMenuItem(menuItemID string) error
+	ArchiveMenuItem(menuItemID string, version int, actor models.Principal) error
+	RestoreMenuItem(menuItemID string, actor models.Principal) error
+	IsArchived(menuItemID string) (bool, error)
 	GetAll() ([]models.MenuItem, error)
+	GetAllIncludingArchived() ([]models.MenuItem, error)
 	Exists(menuID string) (bool, error)
 	GetMenuItemPrice(menuItemID string) (float64, error)
-	SaveMenuItem(menuItem models.MenuItem) error
-	Update(menu models.MenuItem) error
+	SaveMenuItem(menuItem models.MenuItem, actor models.Principal) error
+	Update(menu models.MenuItem, actor models.Principal) error
 }
 
 type menuRepo struct {
@@ -24,38 +28,87 @@ func NewMenuRepo(path string) *menuRepo {
 	return &menuRepo{path: path}
 }
 
-func (r *menuRepo) DeleteMenuItem(menuItemID string) error {
+// ArchiveMenuItem hides the item from the menu and from ordering. The row and
+// its ingredients are kept so that order history and reports stay intact.
+// With a version set the item must still be at it.
+func (r *menuRepo) ArchiveMenuItem(menuItemID string, version int, actor models.Principal) error {
 	tx, err := utils.DB.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	_, err = tx.Exec(`DELETE FROM menu_item_ingredients WHERE menu_item_id = $1`, menuItemID)
+	before, err := auditSnapshot(tx, models.AuditMenuItem, menuItemID)
+	if err != nil {
+		return err
+	}
+	result, err := tx.Exec(`UPDATE menu_items SET archived_at = CURRENT_TIMESTAMP, version = version + 1 WHERE menu_item_id = $1 AND archived_at IS NULL AND ($2 = 0 OR version = $2)`, menuItemID, version)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
+	if version != 0 {
+		if err = checkVersionUpdate(result); err != nil {
+			return err
+		}
+	}
+	if err = writeAudit(tx, actor, "delete", models.AuditMenuItem, menuItemID, before); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-	_, err = tx.Exec(`DELETE FROM menu_items WHERE menu_item_id = $1`, menuItemID)
+func (r *menuRepo) RestoreMenuItem(menuItemID string, actor models.Principal) error {
+	tx, err := utils.DB.Begin()
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
+	defer tx.Rollback()
 
+	before, err := auditSnapshot(tx, models.AuditMenuItem, menuItemID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE menu_items SET archived_at = NULL, version = version + 1 WHERE menu_item_id = $1`, menuItemID)
+	if err != nil {
+		return err
+	}
+	if err = writeAudit(tx, actor, "restore", models.AuditMenuItem, menuItemID, before); err != nil {
+		return err
+	}
 	return tx.Commit()
 }
 
+func (r *menuRepo) IsArchived(menuItemID string) (bool, error) {
+	var archived bool
+	err := utils.DB.QueryRow(`SELECT archived_at IS NOT NULL FROM menu_items WHERE menu_item_id = $1`, menuItemID).Scan(&archived)
+	return archived, err
+}
+
+// GetAll returns the active menu, archived items are left out.
 func (r *menuRepo) GetAll() ([]models.MenuItem, error) {
+	return r.getAll(false)
+}
+
+// GetAllIncludingArchived is used by reports, which still have to price
+// orders for items that are no longer on the menu.
+func (r *menuRepo) GetAllIncludingArchived() ([]models.MenuItem, error) {
+	return r.getAll(true)
+}
+
+func (r *menuRepo) getAll(includeArchived bool) ([]models.MenuItem, error) {
 	var menuItems []models.MenuItem
 	menuItemMap := make(map[string]*models.MenuItem)
 
 	query := `
 	SELECT 
-		m.menu_item_id, m.name, m.description, m.price, 
+		m.menu_item_id, m.name, m.description, m.category, m.price, m.archived_at, m.version,
 		mi.ingredient_id, mi.quantity
 	FROM menu_items m
-	LEFT JOIN menu_item_ingredients mi ON m.menu_item_id = mi.menu_item_id;
+	LEFT JOIN menu_item_ingredients mi ON m.menu_item_id = mi.menu_item_id
 	`
+	if !includeArchived {
+		query += " WHERE m.archived_at IS NULL"
+	}
 
 	rows, err := utils.DB.Query(query)
 	if err != nil {
@@ -66,10 +119,12 @@ func (r *menuRepo) GetAll() ([]models.MenuItem, error) {
 	for rows.Next() {
 		var menuID, name, description string
 		var price float64
+		var version int
+		var category, archivedAt sql.NullString
 		var ingredientID sql.NullString
 		var quantity sql.NullFloat64
 
-		err := rows.Scan(&menuID, &name, &description, &price, &ingredientID, &quantity)
+		err := rows.Scan(&menuID, &name, &description, &category, &price, &archivedAt, &version, &ingredientID, &quantity)
 		if err != nil {
 			return nil, err
 		}
@@ -79,8 +134,11 @@ func (r *menuRepo) GetAll() ([]models.MenuItem, error) {
 				ID:          menuID,
 				Name:        name,
 				Description: description,
+				Category:    category.String,
 				Price:       price,
 				Ingredients: []models.MenuItemIngredient{},
+				ArchivedAt:  archivedAt.String,
+				Version:     version,
 			}
 			menuItemMap[menuID] = menuItem
 		}
@@ -114,45 +172,65 @@ func (r *menuRepo) GetMenuItemPrice(menuItemID string) (float64, error) {
 	return price, err
 }
 
-func (r *menuRepo) SaveMenuItem(menuItem models.MenuItem) error {
-	query := `INSERT INTO menu_items(menu_item_id, name, description, price) VALUES ($1, $2, $3, $4)`
-	_, err := utils.DB.Exec(query, menuItem.ID, menuItem.Name, menuItem.Description, menuItem.Price)
+func (r *menuRepo) SaveMenuItem(menuItem models.MenuItem, actor models.Principal) error {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO menu_items(menu_item_id, name, description, category, price) VALUES ($1, $2, $3, $4, $5)`
+	_, err = tx.Exec(query, menuItem.ID, menuItem.Name, menuItem.Description, nullString(menuItem.Category), menuItem.Price)
 	if err != nil {
 		return err
 	}
 	for _, ingredient := range menuItem.Ingredients {
 		ingredientQuery := `INSERT INTO menu_item_ingredients(menu_item_id, ingredient_id, quantity) VALUES ($1, $2, $3)`
-		_, err = utils.DB.Exec(ingredientQuery, menuItem.ID, ingredient.IngredientID, ingredient.Quantity)
+		_, err = tx.Exec(ingredientQuery, menuItem.ID, ingredient.IngredientID, ingredient.Quantity)
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	if err = writeAudit(tx, actor, "create", models.AuditMenuItem, menuItem.ID, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *menuRepo) Update(menu models.MenuItem) error {
+// Update replaces the item, logging a change of price. With a version set
+// the item must still be at it.
+func (r *menuRepo) Update(menu models.MenuItem, actor models.Principal) error {
 	tx, err := utils.DB.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
+
+	before, err := auditSnapshot(tx, models.AuditMenuItem, menu.ID)
+	if err != nil {
+		return err
+	}
 	var price float64
-	err = tx.QueryRow(`SELECT price FROM menu_items WHERE menu_item_id = $1`, menu.ID).Scan(&price)
+	var version int
+	err = tx.QueryRow(`SELECT price, version FROM menu_items WHERE menu_item_id = $1 FOR UPDATE`, menu.ID).Scan(&price, &version)
 	if err != nil {
 		return err
 	}
+	if menu.Version != 0 && menu.Version != version {
+		return errors.New("version mismatch")
+	}
 	if price != menu.Price {
-		_, err = tx.Exec(`INSERT INTO price_history (menu_item_id, old_price, new_price) VALUES ($1, $2, $3)`, menu.ID, price, menu.Price)
+		_, err = tx.Exec(`INSERT INTO price_history (menu_item_id, old_price, new_price, employee_id) VALUES ($1, $2, $3, $4)`, menu.ID, price, menu.Price, nullInt(actor.EmployeeID))
 		if err != nil {
 			return err
 		}
 	}
 	query := `
 		UPDATE menu_items 
-		SET name = $1, description = $2, price = $3 
-		WHERE menu_item_id = $4
+		SET name = $1, description = $2, category = $3, price = $4, version = version + 1
+		WHERE menu_item_id = $5
 	`
-	_, err = tx.Exec(query, menu.Name, menu.Description, menu.Price, menu.ID)
+	_, err = tx.Exec(query, menu.Name, menu.Description, nullString(menu.Category), menu.Price, menu.ID)
 	if err != nil {
 		return err
 	}
@@ -173,6 +251,9 @@ func (r *menuRepo) Update(menu models.MenuItem) error {
 			return err
 		}
 	}
+	if err = writeAudit(tx, actor, "update", models.AuditMenuItem, menu.ID, before); err != nil {
+		return err
+	}
 
 	err = tx.Commit()
 	if err != nil {
@@ -5,48 +5,283 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"hot-coffee/internal/utils"
 	"hot-coffee/models"
+
+	"github.com/lib/pq"
 )
 
 type OrderRepository interface {
-	SaveOrder(models.Order) (int, error)
+	SaveOrder(order models.Order, actor models.Principal) (int, error)
 	GetAll() ([]models.Order, error)
+	GetByCustomerID(customerID int) ([]models.Order, error)
 	OrderExists(orderID int) (bool, error)
-	UpdateOrder(order models.Order) error
-	DeleteOrder(orderID int) error
-	CloseOrder(id int) error
-	GetNumberOfOrderedItems(startDate, endDate string) (map[string]int, error)
-	GetOrdersGroupedByDay(month string) (map[string]interface{}, error)
-	GetOrdersGroupedByMonth(year string) (map[string]interface{}, error)
+	UpdateOrder(order models.Order, actor models.Principal) error
+	DeleteOrder(order models.Order, version int, actor models.Principal) error
+	CloseOrder(order models.Order, tip float64, actor models.Principal) error
+	CancelOrder(id int, oldStatus string, actor models.Principal) error
+	SplitOrder(parentID int, children []models.Order, actor models.Principal) ([]int, error)
+	MergeOrders(target models.Order, sourceIDs []int, actor models.Principal) error
+	GetDiscounts(orderID int) ([]models.OrderDiscount, error)
+	GetTotalSales(channel string) (float64, error)
+	GetNumberOfOrderedItems(startDate, endDate, channel string) (map[string]int, error)
+	GetOrdersGroupedByDay(month, channel string) (map[string]interface{}, error)
+	GetOrdersGroupedByMonth(year, channel string) (map[string]interface{}, error)
 }
 
 type orderRepo struct {
 	path string
 }
 
-func (r *orderRepo) SaveOrder(order models.Order) (int, error) {
-	query := `INSERT INTO orders (customer_name, status,order_date,last_status_change, total_amount, updated_at) 
-			  VALUES ($1, $2, $3, $4, $5, $6) RETURNING order_id`
+// SaveOrder saves a new order, which needs enough unreserved stock. An
+// order opened on a table takes the table, which must be free or seated
+// without a tab, a pre-order takes a place in its pickup slot. Redeemed loyalty points are
+// spent with it.
+func (r *orderRepo) SaveOrder(order models.Order, actor models.Principal) (int, error) {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if order.ScheduledFor != "" {
+		if err = checkSlotCapacity(tx, 0, order.ScheduledFor); err != nil {
+			return 0, err
+		}
+	}
+
+	if order.TableID != 0 {
+		if err = takeTable(tx, order.TableID); err != nil {
+			return 0, err
+		}
+	}
+
+	orderID, err := insertOrder(tx, order)
+	if err != nil {
+		return 0, err
+	}
+	if err = checkReserved(tx, orderID); err != nil {
+		return 0, err
+	}
+	if order.RedeemPoints > 0 {
+		if err = redeemPoints(tx, order.CustomerID, orderID, order.RedeemPoints); err != nil {
+			return 0, err
+		}
+	}
+	order.ID = orderID
+	if err = writeOutbox(tx, models.EventOrderCreated, order); err != nil {
+		return 0, err
+	}
+	if err = writeAudit(tx, actor, "create", models.AuditOrder, strconv.Itoa(orderID), nil); err != nil {
+		return 0, err
+	}
+	return orderID, tx.Commit()
+}
+
+// takeTable opens the tab of a table: a free table, or one guests were
+// seated at without an order yet. The table row is locked first, so the
+// check for an open tab sees an order another transaction just opened.
+func takeTable(tx *sql.Tx, tableID int) error {
+	var status string
+	err := tx.QueryRow(`SELECT status FROM dining_tables WHERE table_id = $1 FOR UPDATE`, tableID).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("table not found")
+		}
+		return err
+	}
+	var open bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM orders WHERE table_id = $1 AND status = 'active')`, tableID).Scan(&open)
+	if err != nil {
+		return err
+	}
+	if open || (status != "free" && status != "occupied") {
+		return errors.New("table is not free")
+	}
+	_, err = tx.Exec(`UPDATE dining_tables SET status = 'occupied', updated_at = CURRENT_TIMESTAMP WHERE table_id = $1`, tableID)
+	return err
+}
+
+// insertOrder saves the order with its items and discounts inside tx and
+// reserves the ingredients of its items.
+func insertOrder(tx *sql.Tx, order models.Order) (int, error) {
+	query := `INSERT INTO orders (customer_name, customer_id, parent_order_id, table_id, employee_id, status, channel, priority, scheduled_for, order_date, last_status_change, total_amount, updated_at) 
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING order_id`
 	var orderID int
-	err := utils.DB.QueryRow(query, order.CustomerName, order.Status, order.CreatedAt, order.CreatedAt, order.TotalAmount, order.UpdatedAt).Scan(&orderID)
+	err := tx.QueryRow(query, order.CustomerName, nullInt(order.CustomerID), nullInt(order.ParentOrderID), nullInt(order.TableID), nullInt(order.EmployeeID), order.Status, order.Channel, order.Priority, nullString(order.ScheduledFor), order.CreatedAt, order.CreatedAt, order.TotalAmount, order.UpdatedAt).Scan(&orderID)
 	if err != nil {
 		return 0, err
 	}
 
-	for _, item := range order.Items {
-		query := `INSERT INTO order_items (order_id, menu_item_id, quantity, price, customization) 
-				  VALUES ($1, $2, $3, $4, $5)`
+	if err = insertOrderItems(tx, orderID, order.Items); err != nil {
+		return 0, err
+	}
+	if err = reserveInventory(tx, orderID, order.Channel, order.Items); err != nil {
+		return 0, err
+	}
 
-		_, err := utils.DB.Exec(query, orderID, item.MenuItemID, item.Quantity, item.Price, string(item.Customization))
+	for _, discount := range order.Discounts {
+		_, err := tx.Exec(`INSERT INTO order_discounts (order_id, kind, description, amount) VALUES ($1, $2, $3, $4)`,
+			orderID, discount.Kind, discount.Description, discount.Amount)
 		if err != nil {
 			return 0, err
 		}
 	}
 
-	return orderID, err
+	return orderID, nil
+}
+
+// insertOrderItems saves the lines of an order, lines without a prep status
+// start queued.
+func insertOrderItems(tx *sql.Tx, orderID int, items []models.OrderItem) error {
+	query := `INSERT INTO order_items (order_id, menu_item_id, quantity, price, customization, prep_status) 
+			  VALUES ($1, $2, $3, $4, $5, COALESCE($6::prep_status, 'queued'))`
+	for _, item := range items {
+		_, err := tx.Exec(query, orderID, item.MenuItemID, item.Quantity, item.Price, nullString(string(item.Customization)), nullString(item.PrepStatus))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveOrderLines makes the lines of an order match items. A line stays, and
+// keeps its line_id, while its menu item is still ordered; the other lines
+// are removed before the rest of items is inserted, so a line whose menu
+// item was changed becomes a new line. Who prepared a line is kept while its
+// prep status does not change.
+func saveOrderLines(tx *sql.Tx, orderID int, items []models.OrderItem) error {
+	rows, err := tx.Query(`SELECT order_item_id, menu_item_id FROM order_items WHERE order_id = $1`, orderID)
+	if err != nil {
+		return err
+	}
+	lineOfMenuItem := make(map[string]int)
+	for rows.Next() {
+		var lineID int
+		var menuItemID string
+		if err := rows.Scan(&lineID, &menuItemID); err != nil {
+			rows.Close()
+			return err
+		}
+		lineOfMenuItem[menuItemID] = lineID
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	updated, inserted := matchOrderLines(lineOfMenuItem, items)
+	keptIDs := []int{}
+	for _, item := range updated {
+		keptIDs = append(keptIDs, item.LineID)
+	}
+	_, err = tx.Exec(`DELETE FROM order_items WHERE order_id = $1 AND NOT (order_item_id = ANY($2))`, orderID, pq.Array(keptIDs))
+	if err != nil {
+		return err
+	}
+	query := `
+		UPDATE order_items
+		SET quantity = $2, price = $3, customization = $4,
+			prep_changed_at = CASE WHEN prep_status = COALESCE($5::prep_status, 'queued') THEN prep_changed_at ELSE CURRENT_TIMESTAMP END,
+			prepared_by = CASE WHEN prep_status = COALESCE($5::prep_status, 'queued') THEN prepared_by END,
+			prep_status = COALESCE($5::prep_status, 'queued')
+		WHERE order_item_id = $1
+	`
+	for _, item := range updated {
+		_, err = tx.Exec(query, item.LineID, item.Quantity, item.Price, nullString(string(item.Customization)), nullString(item.PrepStatus))
+		if err != nil {
+			return err
+		}
+	}
+	return insertOrderItems(tx, orderID, inserted)
+}
+
+// matchOrderLines splits items into the lines that update the stored line of
+// their menu item and the ones to insert. The line_id sent with an item is
+// not trusted, a line cannot change its menu item.
+func matchOrderLines(lineOfMenuItem map[string]int, items []models.OrderItem) (updated, inserted []models.OrderItem) {
+	kept := make(map[int]bool)
+	for _, item := range items {
+		item.LineID = lineOfMenuItem[item.MenuItemID]
+		if item.LineID == 0 || kept[item.LineID] {
+			item.LineID = 0
+			inserted = append(inserted, item)
+			continue
+		}
+		kept[item.LineID] = true
+		updated = append(updated, item)
+	}
+	return updated, inserted
+}
+
+// SplitOrder saves the child orders and marks the parent inactive, with a
+// history entry naming the children. Children with lines reserve their own
+// stock, when none has lines the parent's reserved stock is taken now.
+func (r *orderRepo) SplitOrder(parentID int, children []models.Order, actor models.Principal) ([]int, error) {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := auditSnapshot(tx, models.AuditOrder, strconv.Itoa(parentID))
+	if err != nil {
+		return nil, err
+	}
+
+	even := true
+	for _, child := range children {
+		if len(child.Items) > 0 {
+			even = false
+		}
+	}
+	if even {
+		err = takeReserved(tx, parentID, actor.EmployeeID)
+	} else {
+		err = releaseInventory(tx, parentID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var childIDs []int
+	var names []string
+	for _, child := range children {
+		child.ParentOrderID = parentID
+		childID, err := insertOrder(tx, child)
+		if err != nil {
+			return nil, err
+		}
+		child.ID = childID
+		if err = writeOutbox(tx, models.EventOrderCreated, child); err != nil {
+			return nil, err
+		}
+		if err = writeAudit(tx, actor, "create", models.AuditOrder, strconv.Itoa(childID), nil); err != nil {
+			return nil, err
+		}
+		childIDs = append(childIDs, childID)
+		names = append(names, strconv.Itoa(childID))
+	}
+
+	_, err = tx.Exec(`INSERT INTO order_status_history (order_id, old_status, new_status, notes) VALUES ($1, 'active', 'inactive', $2)`,
+		parentID, "split into orders "+strings.Join(names, ", "))
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`UPDATE orders SET status = 'inactive', last_status_change = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE order_id = $1`, parentID)
+	if err != nil {
+		return nil, err
+	}
+	err = writeOutbox(tx, models.EventOrderStatusChanged, models.OrderStatusEvent{OrderID: parentID, OldStatus: "active", Status: "inactive"})
+	if err != nil {
+		return nil, err
+	}
+	if err = writeAudit(tx, actor, "update", models.AuditOrder, strconv.Itoa(parentID), before); err != nil {
+		return nil, err
+	}
+	return childIDs, tx.Commit()
 }
 
 func NewOrderRepo(path string) *orderRepo {
@@ -54,16 +289,26 @@ func NewOrderRepo(path string) *orderRepo {
 }
 
 func (r *orderRepo) GetAll() ([]models.Order, error) {
+	return r.getOrders("")
+}
+
+func (r *orderRepo) GetByCustomerID(customerID int) ([]models.Order, error) {
+	return r.getOrders("WHERE o.customer_id = $1", customerID)
+}
+
+func (r *orderRepo) getOrders(where string, args ...interface{}) ([]models.Order, error) {
 	query := `
 	SELECT 
-		o.order_id, o.customer_name, o.status, o.order_date, 
-		o.last_status_change, o.total_amount, o.updated_at,
-		oi.menu_item_id, oi.quantity, oi.price, oi.customization
+		o.order_id, o.customer_name, o.customer_id, o.parent_order_id, o.table_id, o.employee_id, o.status, o.channel, o.priority, o.scheduled_for, o.order_date, 
+		o.last_status_change, o.total_amount,
+		o.tip + COALESCE((SELECT SUM(p.tip) FROM payments p WHERE p.order_id = o.order_id), 0),
+		o.updated_at, o.version, oi.order_item_id, oi.menu_item_id, oi.quantity, oi.price, oi.customization, oi.prep_status
 	FROM orders o
 	LEFT JOIN order_items oi ON o.order_id = oi.order_id
-	ORDER BY o.order_id;
+	` + where + `
+	ORDER BY o.order_id, oi.order_item_id;
 	`
-	rows, err := utils.DB.Query(query)
+	rows, err := utils.DB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -75,21 +320,29 @@ func (r *orderRepo) GetAll() ([]models.Order, error) {
 		var order models.Order
 		var orderItem models.OrderItem
 		var customizationJSON []byte
-		var menuItemID sql.NullString
-		var quantity sql.NullInt64
+		var menuItemID, prepStatus, scheduledFor sql.NullString
+		var lineID, quantity sql.NullInt64
 		var price sql.NullFloat64
+		var customerID, parentOrderID, tableID, employeeID sql.NullInt64
 
 		err := rows.Scan(
-			&order.ID, &order.CustomerName, &order.Status, &order.CreatedAt,
-			&order.LastStatusChange, &order.TotalAmount, &order.UpdatedAt,
-			&menuItemID, &quantity, &price, &customizationJSON,
+			&order.ID, &order.CustomerName, &customerID, &parentOrderID, &tableID, &employeeID, &order.Status, &order.Channel, &order.Priority, &scheduledFor, &order.CreatedAt,
+			&order.LastStatusChange, &order.TotalAmount, &order.Tip,
+			&order.UpdatedAt, &order.Version, &lineID, &menuItemID, &quantity, &price, &customizationJSON, &prepStatus,
 		)
 		if err != nil {
 			return nil, err
 		}
+		order.CustomerID = int(customerID.Int64)
+		order.ParentOrderID = int(parentOrderID.Int64)
+		order.TableID = int(tableID.Int64)
+		order.EmployeeID = int(employeeID.Int64)
+		order.ScheduledFor = scheduledFor.String
+		orderItem.LineID = int(lineID.Int64)
 		orderItem.MenuItemID = menuItemID.String
 		orderItem.Quantity = int(quantity.Int64)
 		orderItem.Price = price.Float64
+		orderItem.PrepStatus = prepStatus.String
 
 		if len(customizationJSON) > 0 {
 			if err := json.Unmarshal(customizationJSON, &orderItem.Customization); err != nil {
@@ -97,22 +350,149 @@ func (r *orderRepo) GetAll() ([]models.Order, error) {
 			}
 		}
 
-		if existingOrder, exists := ordersMap[order.ID]; exists {
+		existingOrder, exists := ordersMap[order.ID]
+		if !exists {
+			order.Items = []models.OrderItem{}
+			existingOrder = &order
+			ordersMap[order.ID] = existingOrder
+		}
+		// orders split evenly have no lines of their own
+		if menuItemID.Valid {
 			existingOrder.Items = append(existingOrder.Items, orderItem)
-		} else {
-			order.Items = []models.OrderItem{orderItem}
-			ordersMap[order.ID] = &order
 		}
 	}
 
+	discounts, err := r.getDiscounts(ordersMap)
+	if err != nil {
+		return nil, err
+	}
+
 	var orders []models.Order
 	for _, order := range ordersMap {
+		order.Discounts = discounts[order.ID]
+		order.PrepStatus = orderPrepStatus(order.Items)
 		orders = append(orders, *order)
 	}
 
 	return orders, nil
 }
 
+func (r *orderRepo) getDiscounts(ordersMap map[int]*models.Order) (map[int][]models.OrderDiscount, error) {
+	var orderIDs []int
+	for id := range ordersMap {
+		orderIDs = append(orderIDs, id)
+	}
+	rows, err := utils.DB.Query(`SELECT order_id, kind, COALESCE(description, ''), amount FROM order_discounts WHERE order_id = ANY($1) ORDER BY order_discount_id`, pq.Array(orderIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	discounts := make(map[int][]models.OrderDiscount)
+	for rows.Next() {
+		var orderID int
+		var discount models.OrderDiscount
+		if err := rows.Scan(&orderID, &discount.Kind, &discount.Description, &discount.Amount); err != nil {
+			return nil, err
+		}
+		discounts[orderID] = append(discounts[orderID], discount)
+	}
+	return discounts, rows.Err()
+}
+
+// MergeOrders replaces the target's lines and total with the merged ones,
+// moves the discounts and loyalty entries of the sources onto the target
+// and marks the sources inactive, with the events of both changes.
+func (r *orderRepo) MergeOrders(target models.Order, sourceIDs []int, actor models.Principal) error {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	allIDs := append([]int{target.ID}, sourceIDs...)
+	var active int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM (SELECT order_id FROM orders WHERE order_id = ANY($1) AND status = 'active' FOR UPDATE) locked`, pq.Array(allIDs)).Scan(&active)
+	if err != nil {
+		return err
+	}
+	if active != len(allIDs) {
+		return errors.New("only active orders can be merged")
+	}
+	before := make([]json.RawMessage, len(allIDs))
+	for i, id := range allIDs {
+		if before[i], err = auditSnapshot(tx, models.AuditOrder, strconv.Itoa(id)); err != nil {
+			return err
+		}
+	}
+
+	if err = saveOrderLines(tx, target.ID, target.Items); err != nil {
+		return err
+	}
+	if err = releaseInventory(tx, allIDs...); err != nil {
+		return err
+	}
+	if err = reserveInventory(tx, target.ID, target.Channel, target.Items); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE order_discounts SET order_id = $1 WHERE order_id = ANY($2)`, target.ID, pq.Array(sourceIDs))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE loyalty_ledger SET order_id = $1 WHERE order_id = ANY($2)`, target.ID, pq.Array(sourceIDs))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE orders SET total_amount = $1, updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE order_id = $2`, target.TotalAmount, target.ID)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, id := range sourceIDs {
+		names = append(names, strconv.Itoa(id))
+	}
+	_, err = tx.Exec(`INSERT INTO order_status_history (order_id, old_status, new_status, notes) VALUES ($1, 'active', 'active', $2)`,
+		target.ID, "merged orders "+strings.Join(names, ", "))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO order_status_history (order_id, old_status, new_status, notes)
+		SELECT order_id, 'active', 'inactive', $2 FROM orders WHERE order_id = ANY($1)`,
+		pq.Array(sourceIDs), "merged into order "+strconv.Itoa(target.ID))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE orders SET status = 'inactive', last_status_change = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE order_id = ANY($1)`, pq.Array(sourceIDs))
+	if err != nil {
+		return err
+	}
+	for _, id := range sourceIDs {
+		err = writeOutbox(tx, models.EventOrderStatusChanged, models.OrderStatusEvent{OrderID: id, OldStatus: "active", Status: "inactive"})
+		if err != nil {
+			return err
+		}
+	}
+	if err = writeOutbox(tx, models.EventOrderUpdated, target); err != nil {
+		return err
+	}
+	for i, id := range allIDs {
+		if err = writeAudit(tx, actor, "update", models.AuditOrder, strconv.Itoa(id), before[i]); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *orderRepo) GetDiscounts(orderID int) ([]models.OrderDiscount, error) {
+	discounts, err := r.getDiscounts(map[int]*models.Order{orderID: nil})
+	if err != nil {
+		return nil, err
+	}
+	return discounts[orderID], nil
+}
+
 func (r *orderRepo) OrderExists(orderID int) (bool, error) {
 	var exists bool
 	query := `SELECT EXISTS (SELECT 1 FROM orders WHERE order_id = $1)`
@@ -120,59 +500,179 @@ func (r *orderRepo) OrderExists(orderID int) (bool, error) {
 	return exists, err
 }
 
-func (r *orderRepo) UpdateOrder(order models.Order) error {
+// UpdateOrder saves the order and its lines, keeping the line_id of lines
+// that are still there, and reserves again for the new lines. A pre-order
+// moved to another pickup time needs a place in the new slot. The order
+// must still be active, and with a version set still at it. Its status is
+// not changed here.
+func (r *orderRepo) UpdateOrder(order models.Order, actor models.Principal) error {
 	tx, err := utils.DB.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
+	before, err := auditSnapshot(tx, models.AuditOrder, strconv.Itoa(order.ID))
+	if err != nil {
+		return err
+	}
+
+	if order.ScheduledFor != "" {
+		var moved bool
+		err = tx.QueryRow(`SELECT scheduled_for IS DISTINCT FROM $2::timestamptz FROM orders WHERE order_id = $1`, order.ID, order.ScheduledFor).Scan(&moved)
+		if err != nil {
+			return err
+		}
+		if moved {
+			if err = checkSlotCapacity(tx, order.ID, order.ScheduledFor); err != nil {
+				return err
+			}
+		}
+	}
+
 	query := `
 		UPDATE orders 
-		SET customer_name = $1, status = $2, total_amount = $3, updated_at = $4
-		WHERE order_id = $5
+		SET customer_name = $1, customer_id = $2, channel = $3, priority = $4, scheduled_for = $5, total_amount = $6, updated_at = $7,
+			version = version + 1
+		WHERE order_id = $8 AND status = 'active' AND ($9 = 0 OR version = $9)
 	`
-	_, err = tx.Exec(query, order.CustomerName, order.Status, order.TotalAmount, order.UpdatedAt, order.ID)
+	result, err := tx.Exec(query, order.CustomerName, nullInt(order.CustomerID), order.Channel, order.Priority, nullString(order.ScheduledFor), order.TotalAmount, order.UpdatedAt, order.ID, order.Version)
 	if err != nil {
 		return err
 	}
+	if err = checkVersionUpdate(result); err != nil {
+		var status string
+		if scanErr := tx.QueryRow(`SELECT status FROM orders WHERE order_id = $1`, order.ID).Scan(&status); scanErr == nil && status != "active" {
+			return errors.New("order is not active")
+		}
+		return err
+	}
 
-	deleteQuery := `DELETE FROM order_items WHERE order_id = $1`
-	_, err = tx.Exec(deleteQuery, order.ID)
+	if err = saveOrderLines(tx, order.ID, order.Items); err != nil {
+		return err
+	}
+	if err = releaseInventory(tx, order.ID); err != nil {
+		return err
+	}
+	if err = reserveInventory(tx, order.ID, order.Channel, order.Items); err != nil {
+		return err
+	}
+	if err = checkReserved(tx, order.ID); err != nil {
+		return err
+	}
+	if err = writeOutbox(tx, models.EventOrderUpdated, order); err != nil {
+		return err
+	}
+	if err = writeAudit(tx, actor, "update", models.AuditOrder, strconv.Itoa(order.ID), before); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		return err
 	}
 
-	insertQuery := `
-		INSERT INTO order_items (order_id, menu_item_id, quantity, price, customization)
-		VALUES ($1, $2, $3, $4, $5::jsonb)
-	`
-	for _, item := range order.Items {
-		_, err := tx.Exec(insertQuery, order.ID, item.MenuItemID, item.Quantity, item.Price, item.Customization)
-		if err != nil {
+	return nil
+}
+
+// CloseOrder closes the order as it was read before closing and takes the
+// stock it reserved, its events are saved with the change. Only an active
+// order is closed, so of two closes at once the second one fails.
+func (r *orderRepo) CloseOrder(order models.Order, tip float64, actor models.Principal) error {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := auditSnapshot(tx, models.AuditOrder, strconv.Itoa(order.ID))
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`UPDATE orders SET status = 'closed', tip = tip + $2, last_status_change = CURRENT_TIMESTAMP, version = version + 1 WHERE order_id = $1 AND status = 'active'`, order.ID, tip)
+	if err != nil {
+		return err
+	}
+	closedRows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if closedRows == 0 {
+		var status string
+		if err = tx.QueryRow(`SELECT status FROM orders WHERE order_id = $1`, order.ID).Scan(&status); err != nil {
 			return err
 		}
+		if status == "closed" {
+			return errors.New("order is already closed")
+		}
+		return errors.New("order is " + status)
 	}
-
-	err = tx.Commit()
+	_, err = tx.Exec(`INSERT INTO order_status_history (order_id, old_status, new_status) VALUES ($1, $2, $3)`, order.ID, order.Status, "closed")
 	if err != nil {
 		return err
 	}
+	if err = takeReserved(tx, order.ID, actor.EmployeeID); err != nil {
+		return err
+	}
 
-	return nil
+	err = writeOutbox(tx, models.EventOrderStatusChanged, models.OrderStatusEvent{OrderID: order.ID, OldStatus: order.Status, Status: "closed"})
+	if err != nil {
+		return err
+	}
+	closed := order
+	closed.Status = "closed"
+	closed.Tip += tip
+	if err = writeOutbox(tx, models.EventOrderClosed, closed); err != nil {
+		return err
+	}
+	if err = writeAudit(tx, actor, "update", models.AuditOrder, strconv.Itoa(order.ID), before); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *orderRepo) CloseOrder(id int) error {
-	_, err := utils.DB.Exec(`INSERT INTO order_status_history (order_id, old_status, new_status) VALUES ($1, $2, $3)`, id, "active", "closed")
+func (r *orderRepo) CancelOrder(id int, oldStatus string, actor models.Principal) error {
+	tx, err := utils.DB.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	_, err = utils.DB.Exec(`UPDATE orders SET status = 'closed' WHERE order_id = $1`, id)
-	return err
+	before, err := auditSnapshot(tx, models.AuditOrder, strconv.Itoa(id))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO order_status_history (order_id, old_status, new_status) VALUES ($1, $2, 'cancelled')`, id, oldStatus)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE orders SET status = 'cancelled', last_status_change = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE order_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if err = releaseInventory(tx, id); err != nil {
+		return err
+	}
+	if err = reverseOrderPoints(tx, id); err != nil {
+		return err
+	}
+	err = writeOutbox(tx, models.EventOrderStatusChanged, models.OrderStatusEvent{OrderID: id, OldStatus: oldStatus, Status: "cancelled"})
+	if err != nil {
+		return err
+	}
+	if err = writeAudit(tx, actor, "update", models.AuditOrder, strconv.Itoa(id), before); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *orderRepo) DeleteOrder(orderID int) error {
+// DeleteOrder removes the order with everything that belongs to it and
+// records the deleted order as an event. With a version set the order must
+// still be at it.
+func (r *orderRepo) DeleteOrder(order models.Order, version int, actor models.Principal) error {
+	orderID := order.ID
 	var status string
 
 	err := utils.DB.QueryRow(`SELECT status FROM orders WHERE order_id = $1`, orderID).Scan(&status)
@@ -192,33 +692,95 @@ func (r *orderRepo) DeleteOrder(orderID int) error {
 		return err
 	}
 
+	before, err := auditSnapshot(tx, models.AuditOrder, strconv.Itoa(orderID))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
 	_, err = tx.Exec(`DELETE FROM order_items WHERE order_id = $1`, orderID)
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
-	_, err = tx.Exec(`DELETE FROM orders WHERE order_id = $1`, orderID)
+	if err = releaseInventory(tx, orderID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM order_discounts WHERE order_id = $1`, orderID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM order_status_history WHERE order_id = $1`, orderID)
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
+	if err = reverseOrderPoints(tx, orderID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM orders WHERE order_id = $1 AND ($2 = 0 OR version = $2)`, orderID, version)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = checkVersionUpdate(result); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = writeOutbox(tx, models.EventOrderDeleted, order); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = writeAudit(tx, actor, "delete", models.AuditOrder, strconv.Itoa(orderID), before); err != nil {
+		tx.Rollback()
+		return err
+	}
 
 	return tx.Commit()
 }
 
-func (r *orderRepo) GetNumberOfOrderedItems(startDate, endDate string) (map[string]int, error) {
+// GetTotalSales sums what closed orders were sold for, after discounts and
+// less what was refunded of them. Tips are not part of the total. Split and
+// merged orders are inactive and counted on the orders that replaced them.
+func (r *orderRepo) GetTotalSales(channel string) (float64, error) {
+	query := `
+		SELECT COALESCE(SUM(o.total_amount - COALESCE((SELECT SUM(rf.amount) FROM refunds rf WHERE rf.order_id = o.order_id), 0)), 0)
+		FROM orders o
+		WHERE o.status = 'closed' AND ($1 = '' OR o.channel::text = $1)
+	`
+	var sales float64
+	err := utils.DB.QueryRow(query, channel).Scan(&sales)
+	return sales, err
+}
+
+// evenSplitParent matches an order o that was split evenly: its shares carry
+// no lines, so the items sold stay counted on it.
+const evenSplitParent = `
+	EXISTS (SELECT 1 FROM orders c WHERE c.parent_order_id = o.order_id)
+	AND NOT EXISTS (SELECT 1 FROM orders c JOIN order_items ci ON ci.order_id = c.order_id WHERE c.parent_order_id = o.order_id)`
+
+// GetNumberOfOrderedItems and the grouped reports below count every
+// channel when channel is empty.
+func (r *orderRepo) GetNumberOfOrderedItems(startDate, endDate, channel string) (map[string]int, error) {
 	query := `
 		SELECT mi.name, SUM(oi.quantity) 
 		FROM order_items oi
 		JOIN menu_items mi ON oi.menu_item_id = mi.menu_item_id
 		JOIN orders o ON oi.order_id = o.order_id
+		WHERE (o.status <> 'inactive' OR (` + evenSplitParent + `))
 	`
 
 	var args []interface{}
 	if startDate != "" && endDate != "" {
-		query += " WHERE o.order_date BETWEEN $1 AND $2"
+		query += " AND o.order_date BETWEEN $1 AND $2"
 		args = append(args, startDate, endDate)
 	}
+	if channel != "" {
+		args = append(args, channel)
+		query += " AND o.channel::text = $" + strconv.Itoa(len(args))
+	}
 
 	query += " GROUP BY mi.name"
 
@@ -242,17 +804,17 @@ func (r *orderRepo) GetNumberOfOrderedItems(startDate, endDate string) (map[stri
 	return items, nil
 }
 
-func (r *orderRepo) GetOrdersGroupedByDay(month string) (map[string]interface{}, error) {
+func (r *orderRepo) GetOrdersGroupedByDay(month, channel string) (map[string]interface{}, error) {
 	query := `
         SELECT 
     		EXTRACT(DAY FROM order_date)::int AS day, 
     	COUNT(*) 
 		FROM orders 
-		WHERE TO_CHAR(order_date, 'FMMonth') ILIKE $1 
+		WHERE TO_CHAR(order_date, 'FMMonth') ILIKE $1 AND ($2 = '' OR channel::text = $2)
 		GROUP BY day 
 		ORDER BY day;`
 
-	rows, err := utils.DB.Query(query, month)
+	rows, err := utils.DB.Query(query, month, channel)
 	if err != nil {
 		return nil, err
 	}
@@ -276,19 +838,19 @@ func (r *orderRepo) GetOrdersGroupedByDay(month string) (map[string]interface{},
 	return result, nil
 }
 
-func (r *orderRepo) GetOrdersGroupedByMonth(year string) (map[string]interface{}, error) {
+func (r *orderRepo) GetOrdersGroupedByMonth(year, channel string) (map[string]interface{}, error) {
 	query := `
         SELECT 
     		EXTRACT(MONTH FROM order_date)::int AS month_num,
     		TO_CHAR(order_date, 'Month') AS month_name,
     	COUNT(*) 
 		FROM orders 
-		WHERE EXTRACT(YEAR FROM order_date)::text = $1
+		WHERE EXTRACT(YEAR FROM order_date)::text = $1 AND ($2 = '' OR channel::text = $2)
 		GROUP BY month_num, month_name
 		ORDER BY month_num;
 `
 
-	rows, err := utils.DB.Query(query, year)
+	rows, err := utils.DB.Query(query, year, channel)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,65 @@
+package dal
+
+import (
+	"reflect"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestMatchOrderLines(t *testing.T) {
+	// the order has a latte on line 10 and a croissant on line 11
+	stored := map[string]int{"latte": 10, "croissant": 11}
+	tests := []struct {
+		name         string
+		items        []models.OrderItem
+		wantUpdated  []models.OrderItem
+		wantInserted []models.OrderItem
+	}{
+		{
+			name:        "lines keep their ids",
+			items:       []models.OrderItem{{LineID: 10, MenuItemID: "latte", Quantity: 2}, {LineID: 11, MenuItemID: "croissant", Quantity: 1}},
+			wantUpdated: []models.OrderItem{{LineID: 10, MenuItemID: "latte", Quantity: 2}, {LineID: 11, MenuItemID: "croissant", Quantity: 1}},
+		},
+		{
+			name:        "swapped menu items stay on their own lines",
+			items:       []models.OrderItem{{LineID: 10, MenuItemID: "croissant", Quantity: 1}, {LineID: 11, MenuItemID: "latte", Quantity: 2}},
+			wantUpdated: []models.OrderItem{{LineID: 11, MenuItemID: "croissant", Quantity: 1}, {LineID: 10, MenuItemID: "latte", Quantity: 2}},
+		},
+		{
+			name:         "changed menu item is a new line",
+			items:        []models.OrderItem{{LineID: 10, MenuItemID: "mocha", Quantity: 1}, {LineID: 11, MenuItemID: "croissant", Quantity: 1}},
+			wantUpdated:  []models.OrderItem{{LineID: 11, MenuItemID: "croissant", Quantity: 1}},
+			wantInserted: []models.OrderItem{{MenuItemID: "mocha", Quantity: 1}},
+		},
+		{
+			name:         "line without id matches its menu item",
+			items:        []models.OrderItem{{MenuItemID: "latte", Quantity: 3}, {MenuItemID: "muffin", Quantity: 1}},
+			wantUpdated:  []models.OrderItem{{LineID: 10, MenuItemID: "latte", Quantity: 3}},
+			wantInserted: []models.OrderItem{{MenuItemID: "muffin", Quantity: 1}},
+		},
+		{
+			name:         "unknown line id",
+			items:        []models.OrderItem{{LineID: 99, MenuItemID: "muffin", Quantity: 1}},
+			wantInserted: []models.OrderItem{{MenuItemID: "muffin", Quantity: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated, inserted := matchOrderLines(stored, tt.items)
+			if !reflect.DeepEqual(updated, tt.wantUpdated) {
+				t.Errorf("updated = %+v, want %+v", updated, tt.wantUpdated)
+			}
+			if !reflect.DeepEqual(inserted, tt.wantInserted) {
+				t.Errorf("inserted = %+v, want %+v", inserted, tt.wantInserted)
+			}
+			menuItems := make(map[string]bool)
+			for _, item := range append(updated, inserted...) {
+				if menuItems[item.MenuItemID] {
+					t.Errorf("menu item %s is on two lines", item.MenuItemID)
+				}
+				menuItems[item.MenuItemID] = true
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package dal
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"hot-coffee/internal/utils"
+	"hot-coffee/models"
+
+	"github.com/lib/pq"
+)
+
+type OutboxRepository interface {
+	Append(eventType string, data interface{}) error
+	GetPending(publisher string, limit int) ([]models.Event, error)
+	MarkDispatched(publisher string, eventID int64) error
+	Purge(olderThan time.Duration, publishers []string) (int, error)
+}
+
+type outboxRepo struct {
+	path string
+}
+
+func NewOutboxRepo(path string) *outboxRepo {
+	return &outboxRepo{path: path}
+}
+
+// execer is a transaction or the database itself.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// writeOutbox saves an event, inside the transaction of the change it
+// describes when there is one.
+func writeOutbox(db execer, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO outbox (event_type, payload) VALUES ($1, $2)`, eventType, string(payload))
+	return err
+}
+
+// Append saves an event on its own, for changes that are not made in a
+// transaction of their own.
+func (r *outboxRepo) Append(eventType string, data interface{}) error {
+	return writeOutbox(utils.DB, eventType, data)
+}
+
+// GetPending lists the oldest events the publisher did not get yet.
+func (r *outboxRepo) GetPending(publisher string, limit int) ([]models.Event, error) {
+	rows, err := utils.DB.Query(`
+		SELECT event_id, dedup_id, event_type, payload, created_at FROM outbox
+		WHERE NOT ($1 = ANY(dispatched_to))
+		ORDER BY event_id
+		LIMIT $2`, publisher, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.Event
+	for rows.Next() {
+		var event models.Event
+		var payload string
+		if err := rows.Scan(&event.ID, &event.DedupID, &event.Type, &payload, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		event.Data = []byte(payload)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func (r *outboxRepo) MarkDispatched(publisher string, eventID int64) error {
+	_, err := utils.DB.Exec(`
+		UPDATE outbox SET dispatched_to = array_append(dispatched_to, $1)
+		WHERE event_id = $2 AND NOT ($1 = ANY(dispatched_to))`, publisher, eventID)
+	return err
+}
+
+// Purge removes events older than olderThan that every publisher got.
+func (r *outboxRepo) Purge(olderThan time.Duration, publishers []string) (int, error) {
+	result, err := utils.DB.Exec(`
+		DELETE FROM outbox
+		WHERE created_at < CURRENT_TIMESTAMP - make_interval(secs => $1) AND dispatched_to @> $2`,
+		olderThan.Seconds(), pq.Array(publishers))
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
@@ -0,0 +1,62 @@
+package dal
+
+import (
+	"database/sql"
+
+	"hot-coffee/internal/utils"
+	"hot-coffee/models"
+)
+
+type PackagingRepository interface {
+	GetRules() ([]models.PackagingRule, error)
+	ReplaceRules(rules []models.PackagingRule) error
+}
+
+type packagingRepo struct {
+	path string
+}
+
+func NewPackagingRepo(path string) *packagingRepo {
+	return &packagingRepo{path: path}
+}
+
+func (r *packagingRepo) GetRules() ([]models.PackagingRule, error) {
+	rows, err := utils.DB.Query(`SELECT rule_id, channel, category, ingredient_id, quantity FROM packaging_rules
+		ORDER BY channel, category NULLS FIRST, ingredient_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.PackagingRule
+	for rows.Next() {
+		var rule models.PackagingRule
+		var category sql.NullString
+		if err := rows.Scan(&rule.ID, &rule.Channel, &category, &rule.IngredientID, &rule.Quantity); err != nil {
+			return nil, err
+		}
+		rule.Category = category.String
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r *packagingRepo) ReplaceRules(rules []models.PackagingRule) error {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`DELETE FROM packaging_rules`); err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		_, err = tx.Exec(`INSERT INTO packaging_rules (channel, category, ingredient_id, quantity) VALUES ($1, $2, $3, $4)`,
+			rule.Channel, nullString(rule.Category), rule.IngredientID, rule.Quantity)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
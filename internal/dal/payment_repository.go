@@ -0,0 +1,265 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+
+	"hot-coffee/internal/utils"
+	"hot-coffee/models"
+)
+
+type PaymentRepository interface {
+	SavePayment(payment models.Payment) (models.Payment, error)
+	GetPayments(orderID int) ([]models.Payment, error)
+	GetRefunds(orderID int) ([]models.Refund, error)
+	GetPaidAmount(orderID int) (paid float64, refunded float64, err error)
+	HasPayments(orderID int) (bool, error)
+	SaveRefund(refund models.Refund) (int, error)
+}
+
+type paymentRepo struct {
+	path string
+}
+
+func NewPaymentRepo(path string) *paymentRepo {
+	return &paymentRepo{path: path}
+}
+
+// paidAmountQuery is what the payments of an order cover, tips excluded,
+// and what has been refunded of it.
+const paidAmountQuery = `
+	SELECT
+		(SELECT COALESCE(SUM(amount), 0) FROM payments WHERE order_id = $1),
+		(SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE order_id = $1)`
+
+// lockPayableOrder locks the order, so payments and refunds of it are saved
+// one at a time, and returns its status and what is still due.
+func lockPayableOrder(tx *sql.Tx, orderID int) (string, float64, error) {
+	var status string
+	var total, paid, refunded float64
+	err := tx.QueryRow(`SELECT status, total_amount FROM orders WHERE order_id = $1 FOR UPDATE`, orderID).Scan(&status, &total)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", 0, errors.New("order not found")
+		}
+		return "", 0, err
+	}
+	if err = tx.QueryRow(paidAmountQuery, orderID).Scan(&paid, &refunded); err != nil {
+		return "", 0, err
+	}
+	return status, math.Round((total-(paid-refunded))*100) / 100, nil
+}
+
+// SavePayment saves a payment towards an active order once no other payment
+// has covered what it pays since it was checked. A gift card is debited in
+// the same transaction and pays the order before the tip, so the saved
+// amount can be lower than requested.
+func (r *paymentRepo) SavePayment(payment models.Payment) (models.Payment, error) {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return models.Payment{}, err
+	}
+	defer tx.Rollback()
+
+	status, due, err := lockPayableOrder(tx, payment.OrderID)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	if status != "active" {
+		return models.Payment{}, errors.New("only active orders can be paid")
+	}
+	if due <= 0 {
+		return models.Payment{}, errors.New("order is already paid")
+	}
+	if payment.Amount > due {
+		return models.Payment{}, errors.New("payment exceeds the amount due")
+	}
+	if payment.Tender == "gift_card" {
+		applied, err := spendGiftCard(tx, payment.GiftCardCode, payment.OrderID, payment.Amount+payment.Tip)
+		if err != nil {
+			return models.Payment{}, err
+		}
+		payment.Tip = math.Round(math.Max(applied-payment.Amount, 0)*100) / 100
+		payment.Amount = math.Round(math.Min(applied, payment.Amount)*100) / 100
+	}
+
+	query := `
+		INSERT INTO payments (order_id, tender, amount, tip, tendered, change_due, gift_card_code, processor_reference)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING payment_id
+	`
+	var tendered interface{}
+	if payment.Tendered > 0 {
+		tendered = payment.Tendered
+	}
+	err = tx.QueryRow(query, payment.OrderID, payment.Tender, payment.Amount, payment.Tip, tendered,
+		payment.ChangeDue, nullString(payment.GiftCardCode), nullString(payment.ProcessorReference)).Scan(&payment.ID)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	return payment, tx.Commit()
+}
+
+func (r *paymentRepo) GetPayments(orderID int) ([]models.Payment, error) {
+	query := `
+		SELECT payment_id, order_id, tender, amount, tip, tendered, change_due, gift_card_code, processor_reference, created_at
+		FROM payments
+		WHERE order_id = $1
+		ORDER BY payment_id
+	`
+	rows, err := utils.DB.Query(query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []models.Payment
+	for rows.Next() {
+		var payment models.Payment
+		var tendered sql.NullFloat64
+		var giftCardCode, reference sql.NullString
+		err := rows.Scan(&payment.ID, &payment.OrderID, &payment.Tender, &payment.Amount, &payment.Tip,
+			&tendered, &payment.ChangeDue, &giftCardCode, &reference, &payment.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		payment.Tendered = tendered.Float64
+		payment.GiftCardCode = giftCardCode.String
+		payment.ProcessorReference = reference.String
+		payments = append(payments, payment)
+	}
+	return payments, rows.Err()
+}
+
+func (r *paymentRepo) GetRefunds(orderID int) ([]models.Refund, error) {
+	rows, err := utils.DB.Query(`SELECT refund_id, order_id, amount, COALESCE(reason, ''), created_at FROM refunds WHERE order_id = $1 ORDER BY refund_id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refunds []models.Refund
+	refundIndex := make(map[int]int)
+	for rows.Next() {
+		var refund models.Refund
+		if err := rows.Scan(&refund.ID, &refund.OrderID, &refund.Amount, &refund.Reason, &refund.CreatedAt); err != nil {
+			return nil, err
+		}
+		refundIndex[refund.ID] = len(refunds)
+		refunds = append(refunds, refund)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	itemRows, err := utils.DB.Query(`
+		SELECT ri.refund_id, ri.menu_item_id, ri.quantity, ri.amount
+		FROM refund_items ri JOIN refunds rf ON ri.refund_id = rf.refund_id
+		WHERE rf.order_id = $1`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+	for itemRows.Next() {
+		var refundID int
+		var line models.RefundLine
+		if err := itemRows.Scan(&refundID, &line.MenuItemID, &line.Quantity, &line.Amount); err != nil {
+			return nil, err
+		}
+		refund := &refunds[refundIndex[refundID]]
+		refund.Lines = append(refund.Lines, line)
+	}
+	if err = itemRows.Err(); err != nil {
+		return nil, err
+	}
+
+	tenderRows, err := utils.DB.Query(`
+		SELECT rt.refund_id, rt.payment_id, rt.amount, COALESCE(rt.processor_reference, '')
+		FROM refund_tenders rt JOIN refunds rf ON rt.refund_id = rf.refund_id
+		WHERE rf.order_id = $1`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer tenderRows.Close()
+	for tenderRows.Next() {
+		var refundID int
+		var tender models.RefundTender
+		if err := tenderRows.Scan(&refundID, &tender.PaymentID, &tender.Amount, &tender.ProcessorReference); err != nil {
+			return nil, err
+		}
+		refund := &refunds[refundIndex[refundID]]
+		refund.Tenders = append(refund.Tenders, tender)
+	}
+	return refunds, tenderRows.Err()
+}
+
+// GetPaidAmount returns what the payments of an order cover, tips excluded,
+// and what has been refunded of it.
+func (r *paymentRepo) GetPaidAmount(orderID int) (float64, float64, error) {
+	var paid, refunded float64
+	err := utils.DB.QueryRow(paidAmountQuery, orderID).Scan(&paid, &refunded)
+	return paid, refunded, err
+}
+
+func (r *paymentRepo) HasPayments(orderID int) (bool, error) {
+	var exists bool
+	err := utils.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM payments WHERE order_id = $1)`, orderID).Scan(&exists)
+	return exists, err
+}
+
+// SaveRefund saves a refund once no other refund has taken back what it
+// refunds since it was checked. Gift card tenders are put back on the card in
+// the same transaction.
+func (r *paymentRepo) SaveRefund(refund models.Refund) (int, error) {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, _, err = lockPayableOrder(tx, refund.OrderID); err != nil {
+		return 0, err
+	}
+	for _, tender := range refund.Tenders {
+		var refundable float64
+		var tenderType, giftCardCode string
+		err = tx.QueryRow(`
+			SELECT p.amount - COALESCE((SELECT SUM(rt.amount) FROM refund_tenders rt WHERE rt.payment_id = p.payment_id), 0),
+				p.tender, COALESCE(p.gift_card_code, '')
+			FROM payments p WHERE p.payment_id = $1 AND p.order_id = $2`, tender.PaymentID, refund.OrderID).
+			Scan(&refundable, &tenderType, &giftCardCode)
+		if err != nil {
+			return 0, err
+		}
+		if math.Round(tender.Amount*100) > math.Round(refundable*100) {
+			return 0, errors.New("refund exceeds what was paid")
+		}
+		if tenderType == "gift_card" {
+			if err = refundGiftCard(tx, giftCardCode, refund.OrderID, tender.Amount); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	var refundID int
+	err = tx.QueryRow(`INSERT INTO refunds (order_id, amount, reason) VALUES ($1, $2, $3) RETURNING refund_id`,
+		refund.OrderID, refund.Amount, nullString(refund.Reason)).Scan(&refundID)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range refund.Lines {
+		_, err = tx.Exec(`INSERT INTO refund_items (refund_id, menu_item_id, quantity, amount) VALUES ($1, $2, $3, $4)`,
+			refundID, line.MenuItemID, line.Quantity, line.Amount)
+		if err != nil {
+			return 0, err
+		}
+	}
+	for _, tender := range refund.Tenders {
+		_, err = tx.Exec(`INSERT INTO refund_tenders (refund_id, payment_id, amount, processor_reference) VALUES ($1, $2, $3, $4)`,
+			refundID, tender.PaymentID, tender.Amount, nullString(tender.ProcessorReference))
+		if err != nil {
+			return 0, err
+		}
+	}
+	return refundID, tx.Commit()
+}
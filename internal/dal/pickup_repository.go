@@ -0,0 +1,96 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"hot-coffee/internal/utils"
+	"hot-coffee/models"
+)
+
+type PickupRepository interface {
+	GetSettings() (models.PickupSettings, error)
+	UpdateSettings(settings models.PickupSettings) error
+	CountBooked(from, to time.Time) (map[int64]int, error)
+}
+
+type pickupRepo struct {
+	path string
+}
+
+func NewPickupRepo(path string) *pickupRepo {
+	return &pickupRepo{path: path}
+}
+
+// bookedOrders are the pre-orders that take a place in their slot. A split
+// order keeps the one place of its parent.
+const bookedOrders = `
+	SELECT COALESCE(parent_order_id, order_id) AS booking_id, scheduled_for FROM orders
+	WHERE scheduled_for IS NOT NULL AND status IN ('active', 'closed')
+`
+
+func (r *pickupRepo) GetSettings() (models.PickupSettings, error) {
+	var settings models.PickupSettings
+	err := utils.DB.QueryRow(`
+		SELECT slot_capacity, lead_minutes, to_char(opens_at, 'HH24:MI'), to_char(closes_at, 'HH24:MI')
+		FROM pickup_settings`).Scan(&settings.SlotCapacity, &settings.LeadMinutes, &settings.OpensAt, &settings.ClosesAt)
+	return settings, err
+}
+
+func (r *pickupRepo) UpdateSettings(settings models.PickupSettings) error {
+	_, err := utils.DB.Exec(`UPDATE pickup_settings SET slot_capacity = $1, lead_minutes = $2, opens_at = $3, closes_at = $4`,
+		settings.SlotCapacity, settings.LeadMinutes, settings.OpensAt, settings.ClosesAt)
+	return err
+}
+
+// CountBooked counts the booked orders of the slots starting in [from, to),
+// keyed by the Unix time of the slot start.
+func (r *pickupRepo) CountBooked(from, to time.Time) (map[int64]int, error) {
+	rows, err := utils.DB.Query(`
+		SELECT EXTRACT(EPOCH FROM `+slotOf("b.scheduled_for")+`)::bigint AS slot, COUNT(DISTINCT b.booking_id)
+		FROM (`+bookedOrders+`) b
+		WHERE b.scheduled_for >= $1 AND b.scheduled_for < $2
+		GROUP BY slot`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	booked := make(map[int64]int)
+	for rows.Next() {
+		var slot int64
+		var count int
+		if err := rows.Scan(&slot, &count); err != nil {
+			return nil, err
+		}
+		booked[slot] = count
+	}
+	return booked, rows.Err()
+}
+
+// checkSlotCapacity fails when the slot of scheduledFor is full without the
+// order. It locks the settings row, so pre-orders are booked one at a time.
+func checkSlotCapacity(tx *sql.Tx, orderID int, scheduledFor string) error {
+	var capacity int
+	if err := tx.QueryRow(`SELECT slot_capacity FROM pickup_settings FOR UPDATE`).Scan(&capacity); err != nil {
+		return err
+	}
+	var booked int
+	err := tx.QueryRow(`
+		SELECT COUNT(DISTINCT b.booking_id) FROM (`+bookedOrders+`) b
+		WHERE b.booking_id <> $1 AND `+slotOf("b.scheduled_for")+` = `+slotOf("$2::timestamptz"),
+		orderID, scheduledFor).Scan(&booked)
+	if err != nil {
+		return err
+	}
+	if booked >= capacity {
+		return errors.New("pickup slot is full")
+	}
+	return nil
+}
+
+// slotOf is the start of the 15 minute pickup slot a time falls in.
+func slotOf(column string) string {
+	return `date_bin('15 minutes', ` + column + `, TIMESTAMPTZ '2000-01-01 00:00:00+00')`
+}
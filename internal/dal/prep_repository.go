@@ -0,0 +1,213 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+
+	"hot-coffee/internal/utils"
+	"hot-coffee/models"
+)
+
+type PrepRepository interface {
+	GetQueue(station string, byPriority bool) ([]models.QueueLine, error)
+	GetLine(lineID int) (models.QueueLine, error)
+	AdvanceLine(line models.QueueLine, to string, employeeID int) error
+	GetRoutes() ([]models.StationRoute, error)
+	ReplaceRoutes(routes []models.StationRoute) error
+}
+
+type prepRepo struct {
+	path string
+}
+
+func NewPrepRepo(path string) *prepRepo {
+	return &prepRepo{path: path}
+}
+
+// queueLineQuery reads order lines with the station their category is
+// routed to, a route for the order's channel comes before one for every
+// channel and a category route before a default one. Lines of a split parent stay on the queue only when the split
+// was even, otherwise the children carry them. Pre-orders are held back
+// until the lead time before their pickup.
+const queueLineQuery = `
+	SELECT oi.order_item_id, o.order_id, o.customer_name, o.table_id, o.channel, o.priority,
+		oi.menu_item_id, m.name, oi.quantity, oi.customization,
+		COALESCE(
+			(SELECT sr.station FROM station_routes sr WHERE sr.channel = o.channel AND sr.category = m.category),
+			(SELECT sr.station FROM station_routes sr WHERE sr.channel IS NULL AND sr.category = m.category),
+			(SELECT sr.station FROM station_routes sr WHERE sr.channel = o.channel AND sr.category IS NULL),
+			(SELECT sr.station FROM station_routes sr WHERE sr.channel IS NULL AND sr.category IS NULL),
+			'bar') AS station,
+		oi.prep_status, o.order_date, o.scheduled_for, oi.prep_changed_at, oi.prepared_by
+	FROM order_items oi
+	JOIN orders o ON o.order_id = oi.order_id
+	JOIN menu_items m ON m.menu_item_id = oi.menu_item_id
+	WHERE (o.status IN ('active', 'closed')
+		OR (o.status = 'inactive'
+			AND EXISTS (SELECT 1 FROM orders c WHERE c.parent_order_id = o.order_id)
+			AND NOT EXISTS (SELECT 1 FROM orders c JOIN order_items ci ON ci.order_id = c.order_id WHERE c.parent_order_id = o.order_id)))
+		AND (o.scheduled_for IS NULL
+			OR o.scheduled_for - make_interval(mins => (SELECT lead_minutes FROM pickup_settings)) <= CURRENT_TIMESTAMP)
+`
+
+// GetQueue orders the lines by when they are due, a pre-order is due at
+// its pickup time and any other order when it was placed.
+func (r *prepRepo) GetQueue(station string, byPriority bool) ([]models.QueueLine, error) {
+	order := `ORDER BY COALESCE(q.scheduled_for, q.order_date), q.order_item_id`
+	if byPriority {
+		order = `ORDER BY q.priority DESC, COALESCE(q.scheduled_for, q.order_date), q.order_item_id`
+	}
+	query := `
+		SELECT * FROM (` + queueLineQuery + ` AND oi.prep_status <> 'handed_off') q
+		WHERE $1 = '' OR q.station = $1
+		` + order
+	rows, err := utils.DB.Query(query, station)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []models.QueueLine{}
+	for rows.Next() {
+		line, err := scanQueueLine(rows)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+func (r *prepRepo) GetLine(lineID int) (models.QueueLine, error) {
+	row := utils.DB.QueryRow(queueLineQuery+` AND oi.order_item_id = $1`, lineID)
+	line, err := scanQueueLine(row)
+	if err == sql.ErrNoRows {
+		return models.QueueLine{}, errors.New("line not found")
+	}
+	return line, err
+}
+
+// AdvanceLine moves a line on only while it still has the status the
+// caller saw, so two baristas cannot take the same step twice. The order
+// gets a new version and the change is saved to the outbox with it.
+func (r *prepRepo) AdvanceLine(line models.QueueLine, to string, employeeID int) error {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE order_items SET prep_status = $1, prep_changed_at = CURRENT_TIMESTAMP, prepared_by = $2
+		WHERE order_item_id = $3 AND prep_status = $4`,
+		to, nullInt(employeeID), line.LineID, line.PrepStatus)
+	if err != nil {
+		return err
+	}
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if changed == 0 {
+		return errors.New("line status changed")
+	}
+	if _, err = tx.Exec(`UPDATE orders SET updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE order_id = $1`, line.OrderID); err != nil {
+		return err
+	}
+	err = writeOutbox(tx, models.EventOrderLineChanged, models.LineStatusEvent{
+		OrderID:    line.OrderID,
+		LineID:     line.LineID,
+		MenuItemID: line.MenuItemID,
+		Station:    line.Station,
+		OldStatus:  line.PrepStatus,
+		Status:     to,
+		EmployeeID: employeeID,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func scanQueueLine(row rowScanner) (models.QueueLine, error) {
+	var line models.QueueLine
+	var tableID, preparedBy sql.NullInt64
+	var customization, scheduledFor, changedAt sql.NullString
+	err := row.Scan(&line.LineID, &line.OrderID, &line.CustomerName, &tableID, &line.Channel, &line.Priority,
+		&line.MenuItemID, &line.Name, &line.Quantity, &customization,
+		&line.Station, &line.PrepStatus, &line.OrderedAt, &scheduledFor, &changedAt, &preparedBy)
+	if err != nil {
+		return models.QueueLine{}, err
+	}
+	line.TableID = int(tableID.Int64)
+	line.PreparedBy = int(preparedBy.Int64)
+	line.ScheduledFor = scheduledFor.String
+	line.PrepChangedAt = changedAt.String
+	if customization.Valid {
+		line.Customization = []byte(customization.String)
+	}
+	return line, nil
+}
+
+func (r *prepRepo) GetRoutes() ([]models.StationRoute, error) {
+	rows, err := utils.DB.Query(`SELECT route_id, channel, category, station FROM station_routes ORDER BY channel NULLS FIRST, category NULLS FIRST`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var routes []models.StationRoute
+	for rows.Next() {
+		var route models.StationRoute
+		var channel, category sql.NullString
+		if err := rows.Scan(&route.ID, &channel, &category, &route.Station); err != nil {
+			return nil, err
+		}
+		route.Channel = channel.String
+		route.Category = category.String
+		routes = append(routes, route)
+	}
+	return routes, rows.Err()
+}
+
+func (r *prepRepo) ReplaceRoutes(routes []models.StationRoute) error {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`DELETE FROM station_routes`); err != nil {
+		return err
+	}
+	for _, route := range routes {
+		_, err = tx.Exec(`INSERT INTO station_routes (channel, category, station) VALUES ($1, $2, $3)`, nullString(route.Channel), nullString(route.Category), route.Station)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// orderPrepStatus derives the status of an order from its lines: it is
+// only as far along as its least advanced line, except that an order with
+// some work started is in progress.
+func orderPrepStatus(items []models.OrderItem) string {
+	if len(items) == 0 {
+		return ""
+	}
+	counts := make(map[string]int)
+	for _, item := range items {
+		counts[item.PrepStatus]++
+	}
+	switch {
+	case counts["handed_off"] == len(items):
+		return "handed_off"
+	case counts["ready"]+counts["handed_off"] == len(items):
+		return "ready"
+	case counts["queued"] == len(items):
+		return "queued"
+	default:
+		return "in_progress"
+	}
+}
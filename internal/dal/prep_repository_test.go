@@ -0,0 +1,34 @@
+package dal
+
+import (
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestOrderPrepStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{name: "no lines", want: ""},
+		{name: "all queued", lines: []string{"queued", "queued"}, want: "queued"},
+		{name: "one started", lines: []string{"queued", "in_progress"}, want: "in_progress"},
+		{name: "one ready", lines: []string{"queued", "ready"}, want: "in_progress"},
+		{name: "all ready", lines: []string{"ready", "ready"}, want: "ready"},
+		{name: "ready or handed off", lines: []string{"handed_off", "ready"}, want: "ready"},
+		{name: "all handed off", lines: []string{"handed_off", "handed_off"}, want: "handed_off"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var items []models.OrderItem
+			for _, status := range tt.lines {
+				items = append(items, models.OrderItem{PrepStatus: status})
+			}
+			if got := orderPrepStatus(items); got != tt.want {
+				t.Errorf("orderPrepStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
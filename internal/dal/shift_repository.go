@@ -0,0 +1,114 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+
+	"hot-coffee/internal/utils"
+	"hot-coffee/models"
+)
+
+type ShiftRepository interface {
+	ClockIn(employeeID int) (models.Shift, error)
+	ClockOut(employeeID int) (models.Shift, error)
+	GetShifts(employeeID int) ([]models.Shift, error)
+	GetLabor(from, to string) ([]models.LaborEntry, error)
+}
+
+type shiftRepo struct {
+	path string
+}
+
+func NewShiftRepo(path string) *shiftRepo {
+	return &shiftRepo{path: path}
+}
+
+// shiftColumns computes hours up to now for a shift that is still running.
+const shiftColumns = `shift_id, employee_id, clock_in, clock_out,
+	EXTRACT(EPOCH FROM COALESCE(clock_out, CURRENT_TIMESTAMP) - clock_in) / 3600`
+
+func (r *shiftRepo) ClockIn(employeeID int) (models.Shift, error) {
+	var open bool
+	err := utils.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM shifts WHERE employee_id = $1 AND clock_out IS NULL)`, employeeID).Scan(&open)
+	if err != nil {
+		return models.Shift{}, err
+	}
+	if open {
+		return models.Shift{}, errors.New("employee is already clocked in")
+	}
+	row := utils.DB.QueryRow(`INSERT INTO shifts (employee_id) VALUES ($1) RETURNING `+shiftColumns, employeeID)
+	return scanShift(row)
+}
+
+func (r *shiftRepo) ClockOut(employeeID int) (models.Shift, error) {
+	row := utils.DB.QueryRow(`UPDATE shifts SET clock_out = CURRENT_TIMESTAMP WHERE employee_id = $1 AND clock_out IS NULL RETURNING `+shiftColumns, employeeID)
+	shift, err := scanShift(row)
+	if err == sql.ErrNoRows {
+		return models.Shift{}, errors.New("employee is not clocked in")
+	}
+	return shift, err
+}
+
+func (r *shiftRepo) GetShifts(employeeID int) ([]models.Shift, error) {
+	rows, err := utils.DB.Query(`SELECT `+shiftColumns+` FROM shifts WHERE employee_id = $1 ORDER BY clock_in DESC`, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shifts []models.Shift
+	for rows.Next() {
+		shift, err := scanShift(rows)
+		if err != nil {
+			return nil, err
+		}
+		shifts = append(shifts, shift)
+	}
+	return shifts, rows.Err()
+}
+
+func scanShift(row rowScanner) (models.Shift, error) {
+	var shift models.Shift
+	var clockOut sql.NullString
+	err := row.Scan(&shift.ID, &shift.EmployeeID, &shift.ClockIn, &clockOut, &shift.Hours)
+	if err != nil {
+		return models.Shift{}, err
+	}
+	shift.ClockOut = clockOut.String
+	return shift, nil
+}
+
+// GetLabor sums the hours worked per employee and day between from and to,
+// both inclusive. Every shift is cut at midnight so each day only gets the
+// part of the shift that fell on it.
+func (r *shiftRepo) GetLabor(from, to string) ([]models.LaborEntry, error) {
+	query := `
+		SELECT TO_CHAR(d.day, 'YYYY-MM-DD'), e.employee_id, e.name, e.role,
+			SUM(EXTRACT(EPOCH FROM
+				LEAST(COALESCE(s.clock_out, CURRENT_TIMESTAMP), d.day + INTERVAL '1 day') - GREATEST(s.clock_in, d.day)
+			) / 3600)
+		FROM shifts s
+		JOIN employees e ON e.employee_id = s.employee_id
+		CROSS JOIN LATERAL generate_series(
+			date_trunc('day', s.clock_in), date_trunc('day', COALESCE(s.clock_out, CURRENT_TIMESTAMP)), INTERVAL '1 day'
+		) AS d(day)
+		WHERE d.day::date BETWEEN $1 AND $2
+		GROUP BY d.day, e.employee_id, e.name, e.role
+		ORDER BY d.day, e.employee_id
+	`
+	rows, err := utils.DB.Query(query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.LaborEntry
+	for rows.Next() {
+		var entry models.LaborEntry
+		if err := rows.Scan(&entry.Date, &entry.EmployeeID, &entry.Name, &entry.Role, &entry.Hours); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,168 @@
+package dal
+
+import (
+	"database/sql"
+	"errors"
+
+	"hot-coffee/internal/utils"
+	"hot-coffee/models"
+)
+
+type TableRepository interface {
+	SaveTable(table models.Table) (int, error)
+	GetAll() ([]models.Table, error)
+	GetByID(id int) (models.Table, error)
+	UpdateTable(table models.Table) error
+	SetStatus(id int, status string) error
+	Release(id int, status string) error
+	TransferTab(fromID, toID int) ([]int, error)
+}
+
+type tableRepo struct {
+	path string
+}
+
+func NewTableRepo(path string) *tableRepo {
+	return &tableRepo{path: path}
+}
+
+func (r *tableRepo) SaveTable(table models.Table) (int, error) {
+	var id int
+	err := utils.DB.QueryRow(`INSERT INTO dining_tables (number, seats, area, status, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING table_id`,
+		table.Number, table.Seats, nullString(table.Area), table.Status, table.CreatedAt, table.UpdatedAt).Scan(&id)
+	return id, err
+}
+
+func (r *tableRepo) GetAll() ([]models.Table, error) {
+	return r.getTables("")
+}
+
+func (r *tableRepo) GetByID(id int) (models.Table, error) {
+	tables, err := r.getTables("WHERE t.table_id = $1", id)
+	if err != nil {
+		return models.Table{}, err
+	}
+	if len(tables) == 0 {
+		return models.Table{}, sql.ErrNoRows
+	}
+	return tables[0], nil
+}
+
+// getTables reads tables with the active orders on them.
+func (r *tableRepo) getTables(where string, args ...interface{}) ([]models.Table, error) {
+	query := `
+		SELECT t.table_id, t.number, t.seats, t.area, t.status, t.created_at, t.updated_at,
+			o.order_id, o.customer_name, o.total_amount, o.order_date
+		FROM dining_tables t
+		LEFT JOIN orders o ON o.table_id = t.table_id AND o.status = 'active'
+		` + where + `
+		ORDER BY t.number, o.order_id
+	`
+	rows, err := utils.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []models.Table
+	for rows.Next() {
+		var table models.Table
+		var area sql.NullString
+		var orderID sql.NullInt64
+		var customerName, openedAt sql.NullString
+		var total sql.NullFloat64
+		if err := rows.Scan(&table.ID, &table.Number, &table.Seats, &area, &table.Status, &table.CreatedAt, &table.UpdatedAt,
+			&orderID, &customerName, &total, &openedAt); err != nil {
+			return nil, err
+		}
+		if len(tables) == 0 || tables[len(tables)-1].ID != table.ID {
+			table.Area = area.String
+			table.OpenOrders = []models.TableOrder{}
+			tables = append(tables, table)
+		}
+		if orderID.Valid {
+			current := &tables[len(tables)-1]
+			current.OpenOrders = append(current.OpenOrders, models.TableOrder{
+				OrderID:      int(orderID.Int64),
+				CustomerName: customerName.String,
+				TotalAmount:  total.Float64,
+				OpenedAt:     openedAt.String,
+			})
+			current.OpenTotal += total.Float64
+		}
+	}
+	return tables, rows.Err()
+}
+
+func (r *tableRepo) UpdateTable(table models.Table) error {
+	_, err := utils.DB.Exec(`UPDATE dining_tables SET number = $1, seats = $2, area = $3, updated_at = $4 WHERE table_id = $5`,
+		table.Number, table.Seats, nullString(table.Area), table.UpdatedAt, table.ID)
+	return err
+}
+
+func (r *tableRepo) SetStatus(id int, status string) error {
+	_, err := utils.DB.Exec(`UPDATE dining_tables SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE table_id = $2`, status, id)
+	return err
+}
+
+// Release sets the status of a table once the last order of its tab is no
+// longer active.
+func (r *tableRepo) Release(id int, status string) error {
+	_, err := utils.DB.Exec(`
+		UPDATE dining_tables SET status = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE table_id = $2 AND NOT EXISTS (SELECT 1 FROM orders WHERE table_id = $2 AND status = 'active')`,
+		status, id)
+	return err
+}
+
+// TransferTab moves the active orders of one table to a free table in one
+// transaction, the table left behind needs cleaning. It returns the ids of
+// the orders moved.
+func (r *tableRepo) TransferTab(fromID, toID int) ([]int, error) {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var toStatus string
+	err = tx.QueryRow(`SELECT status FROM dining_tables WHERE table_id = $1 FOR UPDATE`, toID).Scan(&toStatus)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("table not found")
+		}
+		return nil, err
+	}
+	if toStatus != "free" {
+		return nil, errors.New("target table is not free")
+	}
+
+	rows, err := tx.Query(`UPDATE orders SET table_id = $1, updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE table_id = $2 AND status = 'active' RETURNING order_id`, toID, fromID)
+	if err != nil {
+		return nil, err
+	}
+	var orderIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		orderIDs = append(orderIDs, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(orderIDs) == 0 {
+		return nil, errors.New("table has no open tab")
+	}
+
+	if _, err = tx.Exec(`UPDATE dining_tables SET status = 'needs_cleaning', updated_at = CURRENT_TIMESTAMP WHERE table_id = $1`, fromID); err != nil {
+		return nil, err
+	}
+	if _, err = tx.Exec(`UPDATE dining_tables SET status = 'occupied', updated_at = CURRENT_TIMESTAMP WHERE table_id = $1`, toID); err != nil {
+		return nil, err
+	}
+	return orderIDs, tx.Commit()
+}
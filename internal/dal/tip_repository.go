@@ -0,0 +1,56 @@
+package dal
+
+import (
+	"hot-coffee/internal/utils"
+	"hot-coffee/models"
+)
+
+type TipRepository interface {
+	GetTipsByOrder(date string) ([]models.OrderTip, error)
+	GetTotalTips(channel string) (float64, error)
+}
+
+type tipRepo struct {
+	path string
+}
+
+func NewTipRepo(path string) *tipRepo {
+	return &tipRepo{path: path}
+}
+
+// tipsQuery lists every tip with the day it was left on and the channel of
+// its order, payment tips on the day of the payment and close tips on the
+// day the order was closed.
+const tipsQuery = `
+	SELECT p.order_id, p.tip, p.created_at::date AS day, o.channel
+	FROM payments p JOIN orders o ON o.order_id = p.order_id WHERE p.tip > 0
+	UNION ALL
+	SELECT order_id, tip, last_status_change::date AS day, channel FROM orders WHERE status = 'closed' AND tip > 0
+`
+
+func (r *tipRepo) GetTipsByOrder(date string) ([]models.OrderTip, error) {
+	query := `SELECT order_id, SUM(tip) FROM (` + tipsQuery + `) tips WHERE day = $1 GROUP BY order_id ORDER BY order_id`
+	rows, err := utils.DB.Query(query, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tips []models.OrderTip
+	for rows.Next() {
+		var tip models.OrderTip
+		if err := rows.Scan(&tip.OrderID, &tip.Tip); err != nil {
+			return nil, err
+		}
+		tips = append(tips, tip)
+	}
+	return tips, rows.Err()
+}
+
+// GetTotalTips sums the tips of every channel when channel is empty.
+func (r *tipRepo) GetTotalTips(channel string) (float64, error) {
+	var total float64
+	query := `SELECT COALESCE(SUM(tip), 0) FROM (` + tipsQuery + `) tips WHERE $1 = '' OR channel::text = $1`
+	err := utils.DB.QueryRow(query, channel).Scan(&total)
+	return total, err
+}
@@ -1,6 +1,8 @@
 package dal
 
 import (
+	"database/sql"
+	"encoding/json"
 	"errors"
 	"os"
 )
@@ -12,3 +14,41 @@ func OpenFile(path string) (*os.File, error) {
 	}
 	return file, err
 }
+
+// nullString stores empty optional fields as NULL.
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nullInt stores unset optional references as NULL.
+func nullInt(i int) interface{} {
+	if i == 0 {
+		return nil
+	}
+	return i
+}
+
+// nullJSON stores a missing document as NULL. Documents are sent as text,
+// lib/pq would send a byte slice as bytea.
+func nullJSON(document json.RawMessage) interface{} {
+	if len(document) == 0 {
+		return nil
+	}
+	return string(document)
+}
+
+// checkVersionUpdate fails when a conditional update or delete found no row
+// at the expected version, the row was changed since it was read.
+func checkVersionUpdate(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New("version mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package dal
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"hot-coffee/internal/utils"
+	"hot-coffee/models"
+
+	"github.com/lib/pq"
+)
+
+type WebhookRepository interface {
+	SaveWebhook(webhook models.Webhook) (int, error)
+	GetAll() ([]models.Webhook, error)
+	GetByID(id int) (models.Webhook, error)
+	UpdateWebhook(webhook models.Webhook) (bool, error)
+	DeleteWebhook(id int) (bool, error)
+	QueueDeliveries(event models.Event) (int, error)
+	ClaimDue(limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	RecordAttempt(delivery models.WebhookDelivery, retryAfter time.Duration) error
+	GetDeliveries(webhookID int, status string) ([]models.WebhookDelivery, error)
+}
+
+type webhookRepo struct {
+	path string
+}
+
+func NewWebhookRepo(path string) *webhookRepo {
+	return &webhookRepo{path: path}
+}
+
+func (r *webhookRepo) SaveWebhook(webhook models.Webhook) (int, error) {
+	var id int
+	err := utils.DB.QueryRow(`INSERT INTO webhooks (url, event_types, secret, active, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING webhook_id`,
+		webhook.URL, pq.Array(webhook.EventTypes), webhook.Secret, webhook.Active, webhook.CreatedAt, webhook.UpdatedAt).Scan(&id)
+	return id, err
+}
+
+func (r *webhookRepo) GetAll() ([]models.Webhook, error) {
+	rows, err := utils.DB.Query(`SELECT webhook_id, url, event_types, secret, active, created_at, updated_at FROM webhooks ORDER BY webhook_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func (r *webhookRepo) GetByID(id int) (models.Webhook, error) {
+	row := utils.DB.QueryRow(`SELECT webhook_id, url, event_types, secret, active, created_at, updated_at FROM webhooks WHERE webhook_id = $1`, id)
+	return scanWebhook(row)
+}
+
+func scanWebhook(row rowScanner) (models.Webhook, error) {
+	var webhook models.Webhook
+	err := row.Scan(&webhook.ID, &webhook.URL, pq.Array(&webhook.EventTypes), &webhook.Secret, &webhook.Active, &webhook.CreatedAt, &webhook.UpdatedAt)
+	return webhook, err
+}
+
+// UpdateWebhook keeps the current secret when none is given.
+func (r *webhookRepo) UpdateWebhook(webhook models.Webhook) (bool, error) {
+	result, err := utils.DB.Exec(`
+		UPDATE webhooks SET url = $1, event_types = $2, secret = COALESCE($3, secret), active = $4, updated_at = $5
+		WHERE webhook_id = $6`,
+		webhook.URL, pq.Array(webhook.EventTypes), nullString(webhook.Secret), webhook.Active, webhook.UpdatedAt, webhook.ID)
+	if err != nil {
+		return false, err
+	}
+	changed, err := result.RowsAffected()
+	return changed > 0, err
+}
+
+// DeleteWebhook also removes its delivery log.
+func (r *webhookRepo) DeleteWebhook(id int) (bool, error) {
+	result, err := utils.DB.Exec(`DELETE FROM webhooks WHERE webhook_id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
+// QueueDeliveries adds a pending delivery of the event for every active
+// webhook subscribed to its type and returns how many were queued. Events
+// that were queued before are skipped.
+func (r *webhookRepo) QueueDeliveries(event models.Event) (int, error) {
+	payload, err := eventPayload(event)
+	if err != nil {
+		return 0, err
+	}
+	result, err := utils.DB.Exec(`
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+		SELECT webhook_id, $1, $2, $3 FROM webhooks WHERE active AND $2 = ANY(event_types)
+		ON CONFLICT (webhook_id, event_id) DO NOTHING`,
+		event.ID, event.Type, payload)
+	if err != nil {
+		return 0, err
+	}
+	queued, err := result.RowsAffected()
+	return int(queued), err
+}
+
+// ClaimDue takes up to limit deliveries that are due and pushes their next
+// attempt back by lease, so another worker does not pick them up while
+// they are being sent.
+func (r *webhookRepo) ClaimDue(limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	rows, err := utils.DB.Query(`
+		UPDATE webhook_deliveries SET next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $2)
+		WHERE delivery_id IN (
+			SELECT delivery_id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING `+deliveryColumns,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanDeliveries(rows)
+}
+
+// RecordAttempt saves the outcome of the latest attempt, a delivery that
+// is still pending is tried again after retryAfter.
+func (r *webhookRepo) RecordAttempt(delivery models.WebhookDelivery, retryAfter time.Duration) error {
+	_, err := utils.DB.Exec(`
+		UPDATE webhook_deliveries SET status = $1, attempts = $2, last_status_code = $3, last_error = $4,
+			next_attempt_at = CASE WHEN $1 = 'pending' THEN CURRENT_TIMESTAMP + make_interval(secs => $5) END,
+			delivered_at = CASE WHEN $1 = 'delivered' THEN CURRENT_TIMESTAMP END
+		WHERE delivery_id = $6`,
+		delivery.Status, delivery.Attempts, nullInt(delivery.LastStatusCode), nullString(delivery.LastError), retryAfter.Seconds(), delivery.ID)
+	return err
+}
+
+// GetDeliveries is the delivery log of a webhook, newest first.
+func (r *webhookRepo) GetDeliveries(webhookID int, status string) ([]models.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE webhook_id = $1`
+	args := []interface{}{webhookID}
+	if status != "" {
+		args = append(args, status)
+		query += ` AND status = $` + strconv.Itoa(len(args))
+	}
+	query += ` ORDER BY delivery_id DESC`
+	rows, err := utils.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanDeliveries(rows)
+}
+
+const deliveryColumns = `delivery_id, webhook_id, event_id, event_type, payload, status, attempts,
+	next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+
+func scanDeliveries(rows *sql.Rows) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		var payload string
+		var nextAttemptAt, lastError, deliveredAt sql.NullString
+		var lastStatusCode sql.NullInt64
+		err := rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.EventType, &payload, &delivery.Status, &delivery.Attempts,
+			&nextAttemptAt, &lastStatusCode, &lastError, &delivery.CreatedAt, &deliveredAt)
+		if err != nil {
+			return nil, err
+		}
+		delivery.Payload = []byte(payload)
+		delivery.NextAttemptAt = nextAttemptAt.String
+		delivery.LastStatusCode = int(lastStatusCode.Int64)
+		delivery.LastError = lastError.String
+		delivery.DeliveredAt = deliveredAt.String
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// eventPayload is the body posted for an event, sent as text like nullJSON.
+func eventPayload(event models.Event) (string, error) {
+	payload, err := json.Marshal(event)
+	return string(payload), err
+}
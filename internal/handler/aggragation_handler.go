@@ -23,22 +23,43 @@ func NewAggragationHandler(service service.AggragationService) *aggragationHandl
 }
 
 func (h *aggragationHandler) GetAllSales(w http.ResponseWriter, r *http.Request) {
-	salesAmount, err := h.aggragationService.GetTotalSales()
+	channel := r.URL.Query().Get("channel")
+	salesAmount, err := h.aggragationService.GetTotalSales(channel)
 	if err != nil {
+		if isChannelError(err) {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+			slog.Error("Failed", err.Error(), "no total sales to post")
+			return
+		}
 		message := err.Error()
 		if len(message) > 20 {
 			if message[:20] == "menu item not found:" {
 				RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
 				slog.Error("Failed", err.Error(), "no total sales to post")
+				return
 			}
 		}
 		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
 		slog.Error("Failed", err.Error(), "no total sales to post")
+		return
+	}
+	liability, err := h.aggragationService.GetGiftCardLiability()
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed", err.Error(), "no total sales to post")
+		return
 	}
-	jsonData, err := json.MarshalIndent(models.TotalSales{Sales: salesAmount}, "", "   ")
+	tips, err := h.aggragationService.GetTotalTips(channel)
 	if err != nil {
 		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
 		slog.Error("Failed", err.Error(), "no total sales to post")
+		return
+	}
+	jsonData, err := json.MarshalIndent(models.TotalSales{Sales: salesAmount, Tips: tips, GiftCardLiability: liability}, "", "   ")
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed", err.Error(), "no total sales to post")
+		return
 	}
 	slog.Info("total sales posted", "total", salesAmount)
 	w.Header().Set("Content-Type", "application/json")
@@ -46,15 +67,22 @@ func (h *aggragationHandler) GetAllSales(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *aggragationHandler) GetPopularSales(w http.ResponseWriter, r *http.Request) {
-	list, err := h.aggragationService.GetPopularMenuItems()
+	list, err := h.aggragationService.GetPopularMenuItems(r.URL.Query().Get("channel"))
 	if err != nil {
+		if isChannelError(err) {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+			slog.Error("Failed", err.Error(), "no popular items")
+			return
+		}
 		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
 		slog.Error("Failed", err.Error(), "no total sales to post")
+		return
 	}
 	jsonData, err := json.MarshalIndent(list, "", "   ")
 	if err != nil {
 		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
 		slog.Error("Failed", err.Error(), "no total sales to post")
+		return
 	}
 	slog.Info("popular sales posted", "popular", list)
 	w.Header().Set("Content-Type", "application/json")
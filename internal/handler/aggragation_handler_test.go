@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+type fakeAggragationService struct {
+	service.AggragationService
+	salesErr     error
+	liabilityErr error
+	tipsChannel  string
+}
+
+func (s *fakeAggragationService) GetTotalSales(channel string) (float64, error) {
+	return 120.5, s.salesErr
+}
+
+func (s *fakeAggragationService) GetGiftCardLiability() (float64, error) {
+	return 40, s.liabilityErr
+}
+
+func (s *fakeAggragationService) GetTotalTips(channel string) (float64, error) {
+	s.tipsChannel = channel
+	return 9.5, nil
+}
+
+func TestGetAllSales(t *testing.T) {
+	tests := []struct {
+		name         string
+		salesErr     error
+		liabilityErr error
+		status       int
+		message      string
+	}{
+		{name: "totals", status: http.StatusOK},
+		{name: "unknown channel", salesErr: errors.New("channel must be dine_in, takeaway or delivery"), status: http.StatusBadRequest, message: "channel must be dine_in, takeaway or delivery"},
+		{name: "menu item gone", salesErr: errors.New("menu item not found: latte"), status: http.StatusNotFound, message: "menu item not found: latte"},
+		{name: "sales failed", salesErr: errors.New("connection refused"), status: http.StatusInternalServerError, message: "connection refused"},
+		{name: "liability failed", liabilityErr: errors.New("connection refused"), status: http.StatusInternalServerError, message: "connection refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewAggragationHandler(&fakeAggragationService{salesErr: tt.salesErr, liabilityErr: tt.liabilityErr})
+			w := httptest.NewRecorder()
+			h.GetAllSales(w, httptest.NewRequest(http.MethodGet, "/reports/total-sales", nil))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d", w.Code, tt.status)
+			}
+			// a single JSON document, nothing written after the error
+			decoder := json.NewDecoder(w.Body)
+			if tt.message != "" {
+				var got ErrorResponse
+				if err := decoder.Decode(&got); err != nil || got.Message != tt.message {
+					t.Errorf("body %+v (%v), want message %q", got, err, tt.message)
+				}
+			} else {
+				var got models.TotalSales
+				if err := decoder.Decode(&got); err != nil {
+					t.Fatal(err)
+				}
+				if got != (models.TotalSales{Sales: 120.5, Tips: 9.5, GiftCardLiability: 40}) {
+					t.Errorf("totals %+v", got)
+				}
+			}
+			if decoder.More() {
+				t.Errorf("more than one document written")
+			}
+		})
+	}
+}
+
+func TestGetAllSalesTipsFollowChannel(t *testing.T) {
+	aggragation := &fakeAggragationService{}
+	h := NewAggragationHandler(aggragation)
+	w := httptest.NewRecorder()
+	h.GetAllSales(w, httptest.NewRequest(http.MethodGet, "/reports/total-sales?channel=delivery", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusOK)
+	}
+	if aggragation.tipsChannel != "delivery" {
+		t.Errorf("tips summed for channel %q, want delivery", aggragation.tipsChannel)
+	}
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+type AuditHandler interface {
+	GetAuditLog(w http.ResponseWriter, r *http.Request)
+}
+
+type auditHandler struct {
+	auditService service.AuditService
+}
+
+func NewAuditHandler(auditService service.AuditService) *auditHandler {
+	return &auditHandler{auditService: auditService}
+}
+
+func (h *auditHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.AuditFilter{
+		EntityType: query.Get("entity"),
+		EntityID:   query.Get("id"),
+		From:       query.Get("from"),
+		To:         query.Get("to"),
+	}
+	entries, err := h.auditService.GetEntries(filter)
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to GetEntries", err.Error(), "no audit log")
+		return
+	}
+	if err = setBodyToJson(w, entries); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no audit log")
+		return
+	}
+	slog.Info("audit log got", "entries", len(entries))
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+type AuthHandler interface {
+	PostLogin(w http.ResponseWriter, r *http.Request)
+	GetMe(w http.ResponseWriter, r *http.Request)
+	PostAPIKey(w http.ResponseWriter, r *http.Request)
+	GetAPIKeys(w http.ResponseWriter, r *http.Request)
+	DeleteAPIKey(w http.ResponseWriter, r *http.Request)
+}
+
+type authHandler struct {
+	authService service.AuthService
+}
+
+func NewAuthHandler(authService service.AuthService) *authHandler {
+	return &authHandler{authService: authService}
+}
+
+func (h *authHandler) PostLogin(w http.ResponseWriter, r *http.Request) {
+	var request models.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no login")
+		return
+	}
+	response, err := h.authService.Login(request)
+	if err != nil {
+		if err.Error() == "invalid credentials" {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusUnauthorized)
+		} else {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		}
+		slog.Error("Failed to Login", err.Error(), "no login")
+		return
+	}
+	if err = setBodyToJson(w, response); err != nil {
+		slog.Error("Failed to setBodyToJson", err.Error(), "logged in")
+		return
+	}
+	slog.Info("employee logged in", "employeeID", response.Employee.ID)
+}
+
+func (h *authHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	principal, _ := principalFrom(r)
+	if err := setBodyToJson(w, principal); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no principal")
+	}
+}
+
+func (h *authHandler) PostAPIKey(w http.ResponseWriter, r *http.Request) {
+	var key models.APIKey
+	if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no API key created")
+		return
+	}
+	key, err := h.authService.CreateAPIKey(key)
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to CreateAPIKey", err.Error(), "no API key created")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = setBodyToJson(w, key); err != nil {
+		slog.Error("Failed to setBodyToJson", err.Error(), "API key created")
+		return
+	}
+	slog.Info("API key created", "apiKeyID", key.ID)
+}
+
+func (h *authHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.authService.GetAPIKeys()
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to GetAPIKeys", err.Error(), "no API keys")
+		return
+	}
+	if err = setBodyToJson(w, keys); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no API keys")
+		return
+	}
+	slog.Info("API keys got", "count", len(keys))
+}
+
+func (h *authHandler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 4 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no API key revoked")
+		return
+	}
+	id, err := strconv.Atoi(pathParam[3])
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid API key id"}, http.StatusBadRequest)
+		slog.Error("Failed", err.Error(), "no API key revoked")
+		return
+	}
+	if err = h.authService.RevokeAPIKey(id); err != nil {
+		if err.Error() == "API key not found" {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
+		} else {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		}
+		slog.Error("Failed to RevokeAPIKey", err.Error(), "no API key revoked")
+		return
+	}
+	slog.Info("API key revoked", "apiKeyID", id)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+type CustomerHandler interface {
+	PostCustomer(w http.ResponseWriter, r *http.Request)
+	GetAllCustomers(w http.ResponseWriter, r *http.Request)
+	GetCustomerByID(w http.ResponseWriter, r *http.Request)
+	PutCustomer(w http.ResponseWriter, r *http.Request)
+	PostMergeCustomers(w http.ResponseWriter, r *http.Request)
+	GetCustomerOrders(w http.ResponseWriter, r *http.Request)
+}
+
+type customerHandler struct {
+	customerService service.CustomerService
+}
+
+func NewCustomerHandler(customerService service.CustomerService) *customerHandler {
+	return &customerHandler{customerService: customerService}
+}
+
+func (h *customerHandler) PostCustomer(w http.ResponseWriter, r *http.Request) {
+	var customer models.Customer
+	if err := json.NewDecoder(r.Body).Decode(&customer); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no customer posted")
+		return
+	}
+	id, err := h.customerService.AddCustomer(customer)
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to AddCustomer", err.Error(), "no customer posted")
+		return
+	}
+	customer.ID = id
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = setBodyToJson(w, customer); err != nil {
+		slog.Error("Failed to setBodyToJson", err.Error(), "customer posted")
+		return
+	}
+	slog.Info("customer posted", "customerID", id)
+}
+
+func (h *customerHandler) GetAllCustomers(w http.ResponseWriter, r *http.Request) {
+	customers, err := h.customerService.GetCustomers(r.URL.Query().Get("name"))
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to GetCustomers", err.Error(), "no customers")
+		return
+	}
+	if err = setBodyToJson(w, customers); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no customers")
+		return
+	}
+	slog.Info("customers got", "count", len(customers))
+}
+
+func (h *customerHandler) GetCustomerByID(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 3 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no customer")
+		return
+	}
+	id, err := strconv.Atoi(pathParam[2])
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid customer id"}, http.StatusBadRequest)
+		slog.Error("Failed", err.Error(), "no customer")
+		return
+	}
+	customer, err := h.customerService.GetCustomerById(id)
+	if err != nil {
+		respondCustomerError(w, err)
+		return
+	}
+	if err = setBodyToJson(w, customer); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no customer")
+		return
+	}
+	slog.Info("customer got", "customerID", id)
+}
+
+func (h *customerHandler) PutCustomer(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 3 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no customer updated")
+		return
+	}
+	id, err := strconv.Atoi(pathParam[2])
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid customer id"}, http.StatusBadRequest)
+		slog.Error("Failed", err.Error(), "no customer updated")
+		return
+	}
+	var customer models.Customer
+	if err := json.NewDecoder(r.Body).Decode(&customer); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no customer updated")
+		return
+	}
+	customer.ID = id
+	if err = h.customerService.UpdateCustomer(customer); err != nil {
+		respondCustomerError(w, err)
+		return
+	}
+	slog.Info("customer updated", "customerID", id)
+}
+
+func (h *customerHandler) PostMergeCustomers(w http.ResponseWriter, r *http.Request) {
+	var req models.MergeCustomersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no customers merged")
+		return
+	}
+	if err := h.customerService.MergeCustomers(req); err != nil {
+		respondCustomerError(w, err)
+		return
+	}
+	slog.Info("customers merged", "targetID", req.TargetID, "sourceIDs", req.SourceIDs)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *customerHandler) GetCustomerOrders(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 4 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no customer orders")
+		return
+	}
+	id, err := strconv.Atoi(pathParam[2])
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid customer id"}, http.StatusBadRequest)
+		slog.Error("Failed", err.Error(), "no customer orders")
+		return
+	}
+	history, err := h.customerService.GetCustomerOrders(id)
+	if err != nil {
+		respondCustomerError(w, err)
+		return
+	}
+	if err = setBodyToJson(w, history); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no customer orders")
+		return
+	}
+	slog.Info("customer orders got", "customerID", id)
+}
+
+func respondCustomerError(w http.ResponseWriter, err error) {
+	if err.Error() == "customer not found" {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
+		slog.Error("Failed", err.Error(), "customer not found")
+		return
+	}
+	RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+	slog.Error("Failed", err.Error(), "customer request failed")
+}
@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+type EmployeeHandler interface {
+	PostEmployee(w http.ResponseWriter, r *http.Request)
+	GetAllEmployees(w http.ResponseWriter, r *http.Request)
+	GetEmployeeByID(w http.ResponseWriter, r *http.Request)
+	PutEmployee(w http.ResponseWriter, r *http.Request)
+	PostClockIn(w http.ResponseWriter, r *http.Request)
+	PostClockOut(w http.ResponseWriter, r *http.Request)
+	GetShifts(w http.ResponseWriter, r *http.Request)
+	GetLaborReport(w http.ResponseWriter, r *http.Request)
+}
+
+type employeeHandler struct {
+	employeeService service.EmployeeService
+}
+
+func NewEmployeeHandler(employeeService service.EmployeeService) *employeeHandler {
+	return &employeeHandler{employeeService: employeeService}
+}
+
+func (h *employeeHandler) PostEmployee(w http.ResponseWriter, r *http.Request) {
+	var employee models.Employee
+	if err := json.NewDecoder(r.Body).Decode(&employee); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no employee posted")
+		return
+	}
+	// anonymous callers only get here while bootstrapping the first manager
+	if _, found := principalFrom(r); !found && (employee.Role != "manager" || employee.Password == "") {
+		RespondWithJson(w, ErrorResponse{Message: "the first employee must be a manager with a password"}, http.StatusForbidden)
+		slog.Error("Failed", "bootstrap without manager", "no employee posted")
+		return
+	}
+	employee, err := h.employeeService.AddEmployee(employee)
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to AddEmployee", err.Error(), "no employee posted")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = setBodyToJson(w, employee); err != nil {
+		slog.Error("Failed to setBodyToJson", err.Error(), "employee posted")
+		return
+	}
+	slog.Info("employee posted", "employeeID", employee.ID)
+}
+
+func (h *employeeHandler) GetAllEmployees(w http.ResponseWriter, r *http.Request) {
+	employees, err := h.employeeService.GetEmployees()
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to GetEmployees", err.Error(), "no employees")
+		return
+	}
+	if err = setBodyToJson(w, employees); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no employees")
+		return
+	}
+	slog.Info("employees got", "count", len(employees))
+}
+
+func (h *employeeHandler) GetEmployeeByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := employeeIDFromPath(w, r, 3)
+	if !ok {
+		return
+	}
+	employee, err := h.employeeService.GetEmployee(id)
+	if err != nil {
+		respondEmployeeError(w, err)
+		return
+	}
+	if err = setBodyToJson(w, employee); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no employee")
+		return
+	}
+	slog.Info("employee got", "employeeID", id)
+}
+
+func (h *employeeHandler) PutEmployee(w http.ResponseWriter, r *http.Request) {
+	id, ok := employeeIDFromPath(w, r, 3)
+	if !ok {
+		return
+	}
+	var employee models.Employee
+	if err := json.NewDecoder(r.Body).Decode(&employee); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no employee updated")
+		return
+	}
+	employee.ID = id
+	employee, err := h.employeeService.UpdateEmployee(employee)
+	if err != nil {
+		respondEmployeeError(w, err)
+		return
+	}
+	if err = setBodyToJson(w, employee); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "employee updated")
+		return
+	}
+	slog.Info("employee updated", "employeeID", id)
+}
+
+func (h *employeeHandler) PostClockIn(w http.ResponseWriter, r *http.Request) {
+	id, ok := employeeIDFromPath(w, r, 4)
+	if !ok || !canClock(w, r, id) {
+		return
+	}
+	shift, err := h.employeeService.ClockIn(id)
+	if err != nil {
+		respondEmployeeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = setBodyToJson(w, shift); err != nil {
+		slog.Error("Failed to setBodyToJson", err.Error(), "clocked in")
+		return
+	}
+	slog.Info("employee clocked in", "employeeID", id, "shiftID", shift.ID)
+}
+
+func (h *employeeHandler) PostClockOut(w http.ResponseWriter, r *http.Request) {
+	id, ok := employeeIDFromPath(w, r, 4)
+	if !ok || !canClock(w, r, id) {
+		return
+	}
+	shift, err := h.employeeService.ClockOut(id)
+	if err != nil {
+		respondEmployeeError(w, err)
+		return
+	}
+	if err = setBodyToJson(w, shift); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "clocked out")
+		return
+	}
+	slog.Info("employee clocked out", "employeeID", id, "shiftID", shift.ID)
+}
+
+func (h *employeeHandler) GetShifts(w http.ResponseWriter, r *http.Request) {
+	id, ok := employeeIDFromPath(w, r, 4)
+	if !ok || !canClock(w, r, id) {
+		return
+	}
+	shifts, err := h.employeeService.GetShifts(id)
+	if err != nil {
+		respondEmployeeError(w, err)
+		return
+	}
+	if err = setBodyToJson(w, shifts); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no shifts")
+		return
+	}
+	slog.Info("shifts got", "employeeID", id)
+}
+
+func (h *employeeHandler) GetLaborReport(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.employeeService.GetLaborReport(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to GetLaborReport", err.Error(), "no labor report")
+		return
+	}
+	if err = setBodyToJson(w, entries); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no labor report")
+		return
+	}
+	slog.Info("labor report got", "entries", len(entries))
+}
+
+// employeeIDFromPath reads the id from /employees/{id} when parts is 3 and
+// from /employees/{id}/action when parts is 4.
+func employeeIDFromPath(w http.ResponseWriter, r *http.Request, parts int) (int, bool) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != parts {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no employee")
+		return 0, false
+	}
+	id, err := strconv.Atoi(pathParam[2])
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid employee id"}, http.StatusBadRequest)
+		slog.Error("Failed", err.Error(), "no employee")
+		return 0, false
+	}
+	return id, true
+}
+
+// canClock lets baristas clock only themselves in and out and see their
+// own shifts, shift leads and managers can do it for anyone.
+func canClock(w http.ResponseWriter, r *http.Request, id int) bool {
+	principal, _ := principalFrom(r)
+	if principal.EmployeeID == id || service.RoleAllows(principal.Role, "shift_lead") {
+		return true
+	}
+	RespondWithJson(w, ErrorResponse{Message: "baristas can only clock themselves in and out"}, http.StatusForbidden)
+	slog.Error("Failed", "forbidden", "clock for another employee")
+	return false
+}
+
+func respondEmployeeError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case "employee not found":
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
+	case "employee is already clocked in", "employee is not clocked in", "employee is inactive":
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusConflict)
+	default:
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+	}
+	slog.Error("Failed", err.Error(), "employee request failed")
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hot-coffee/models"
+)
+
+// etagOf is the entity tag of a resource, its stored version. Writes are
+// guarded by the same version, so a tag only goes stale when a write would
+// be refused. Derived fields such as reserved stock or prep status change
+// without a new tag.
+func etagOf(v interface{}) string {
+	var version int
+	switch resource := v.(type) {
+	case models.Order:
+		version = resource.Version
+	case models.MenuItem:
+		version = resource.Version
+	case models.InventoryItem:
+		version = resource.Version
+	default:
+		return ""
+	}
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// respondWithETag answers with the representation and its ETag, as a GET of
+// the resource would return it.
+func respondWithETag(w http.ResponseWriter, v interface{}, status int) {
+	js, err := json.MarshalIndent(v, "", "	")
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etagOf(v))
+	w.WriteHeader(status)
+	w.Write(js)
+}
+
+// notModified sets the ETag of the representation and answers 304 when the
+// client sent it in If-None-Match.
+func notModified(w http.ResponseWriter, r *http.Request, current interface{}) bool {
+	etag := etagOf(current)
+	w.Header().Set("ETag", etag)
+	if !matchesETag(r.Header.Get("If-None-Match"), etag, true) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// preconditionFailed answers 412 when the request has an If-Match the current
+// representation does not match, current is nil when the entity is missing.
+func preconditionFailed(w http.ResponseWriter, r *http.Request, current interface{}) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" || (current != nil && matchesETag(ifMatch, etagOf(current), false)) {
+		return false
+	}
+	RespondWithJson(w, ErrorResponse{Message: "resource was changed, get it again"}, http.StatusPreconditionFailed)
+	slog.Error("Failed", "If-Match does not match", "request refused")
+	return true
+}
+
+// ifMatchVersion is the version a conditional write must still find, 0 for
+// requests without If-Match, which overwrite whatever is there.
+func ifMatchVersion(r *http.Request, version int) int {
+	if r.Header.Get("If-Match") == "" {
+		return 0
+	}
+	return version
+}
+
+// respondVersionMismatch answers when the entity was changed between the
+// check and the write: 412 for an If-Match, 409 for a patch that was applied
+// to what was read.
+func respondVersionMismatch(w http.ResponseWriter, r *http.Request, err error) bool {
+	if err.Error() != "version mismatch" {
+		return false
+	}
+	if r.Header.Get("If-Match") != "" {
+		RespondWithJson(w, ErrorResponse{Message: "resource was changed, get it again"}, http.StatusPreconditionFailed)
+	} else {
+		RespondWithJson(w, ErrorResponse{Message: "resource was changed while it was updated, try again"}, http.StatusConflict)
+	}
+	slog.Error("Failed", err.Error(), "request refused")
+	return true
+}
+
+// matchesETag compares the tags of an If-Match or If-None-Match header with
+// etag. If-None-Match uses the weak comparison, which ignores a W/ prefix.
+func matchesETag(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestMatchesETag(t *testing.T) {
+	const etag = `"abc"`
+	tests := []struct {
+		name   string
+		header string
+		weak   bool
+		want   bool
+	}{
+		{name: "same tag", header: `"abc"`, want: true},
+		{name: "one of a list", header: `"x", "abc"`, want: true},
+		{name: "wildcard", header: `*`, want: true},
+		{name: "other tag", header: `"abd"`},
+		{name: "empty", header: ``},
+		{name: "weak tag in If-Match", header: `W/"abc"`},
+		{name: "weak tag in If-None-Match", header: `W/"abc"`, weak: true, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesETag(tt.header, etag, tt.weak); got != tt.want {
+				t.Errorf("matchesETag(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	current := models.Order{ID: 1, CustomerName: "Ann", Status: "active", Version: 3}
+	changed := current
+	changed.Version = 4
+	derived := current
+	derived.PrepStatus = "ready"
+	derived.Items = []models.OrderItem{{MenuItemID: "latte", Quantity: 1, AllergenWarnings: []string{"milk"}}}
+	etag := etagOf(current)
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		ifMatch     string
+		current     interface{}
+		notModified bool
+		failed      bool
+		version     int
+	}{
+		{name: "unconditional", current: current},
+		{name: "cached copy is current", ifNoneMatch: etag, current: current, notModified: true},
+		{name: "cached copy is stale", ifNoneMatch: etagOf(changed), current: current},
+		{name: "If-Match holds", ifMatch: etag, current: current, version: 3},
+		{name: "If-Match is stale", ifMatch: etagOf(changed), current: current, failed: true, version: 3},
+		{name: "If-Match on a missing entity", ifMatch: etag, current: nil, failed: true, version: 3},
+		{name: "If-Match any", ifMatch: "*", current: current, version: 3},
+		{name: "derived fields changed", ifMatch: etagOf(derived), current: current, version: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/orders/1", nil)
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			if tt.current != nil {
+				w := httptest.NewRecorder()
+				if got := notModified(w, r, tt.current); got != tt.notModified {
+					t.Errorf("notModified() = %v, want %v", got, tt.notModified)
+				}
+				if w.Header().Get("ETag") != etag {
+					t.Errorf("ETag = %s, want %s", w.Header().Get("ETag"), etag)
+				}
+			}
+
+			w := httptest.NewRecorder()
+			if got := preconditionFailed(w, r, tt.current); got != tt.failed {
+				t.Errorf("preconditionFailed() = %v, want %v", got, tt.failed)
+			}
+			if tt.failed && w.Code != http.StatusPreconditionFailed {
+				t.Errorf("status %d, want %d", w.Code, http.StatusPreconditionFailed)
+			}
+			if got := ifMatchVersion(r, current.Version); got != tt.version {
+				t.Errorf("ifMatchVersion() = %d, want %d", got, tt.version)
+			}
+		})
+	}
+}
+
+func TestRespondVersionMismatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch string
+		err     error
+		handled bool
+		status  int
+	}{
+		{name: "conditional write", ifMatch: `"abc"`, err: errors.New("version mismatch"), handled: true, status: http.StatusPreconditionFailed},
+		{name: "write of what was read", err: errors.New("version mismatch"), handled: true, status: http.StatusConflict},
+		{name: "other error", ifMatch: `"abc"`, err: errors.New("order not found")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/orders/1", nil)
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			if got := respondVersionMismatch(w, r, tt.err); got != tt.handled {
+				t.Fatalf("respondVersionMismatch() = %v, want %v", got, tt.handled)
+			}
+			if tt.handled && w.Code != tt.status {
+				t.Errorf("status %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+// heartbeatInterval keeps idle streams from being cut by proxies.
+const heartbeatInterval = 15 * time.Second
+
+type EventHandler interface {
+	GetEvents(w http.ResponseWriter, r *http.Request)
+}
+
+type eventHandler struct {
+	bus *service.EventBus
+}
+
+func NewEventHandler(bus *service.EventBus) *eventHandler {
+	return &eventHandler{bus: bus}
+}
+
+// GetEvents streams events as Server-Sent Events. A client resumes with
+// the Last-Event-ID header, which EventSource sends on reconnect, or the
+// last_event_id query parameter.
+func (h *eventHandler) GetEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		RespondWithJson(w, ErrorResponse{Message: "streaming is not supported"}, http.StatusInternalServerError)
+		slog.Error("Failed", "no flusher", "no event stream")
+		return
+	}
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var lastID int64
+	if lastEventID != "" {
+		var err error
+		lastID, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || lastID < 0 {
+			RespondWithJson(w, ErrorResponse{Message: "Invalid last event id"}, http.StatusBadRequest)
+			slog.Error("Failed", "bad last event id", "no event stream")
+			return
+		}
+	}
+
+	missed, events, cancel := h.bus.Subscribe(lastID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	for _, event := range missed {
+		writeEvent(w, event)
+	}
+	flusher.Flush()
+	slog.Info("event stream opened", "last_event_id", lastID, "missed", len(missed))
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			slog.Info("event stream closed", "last_event_id", lastID)
+			return
+		case event, open := <-events:
+			if !open {
+				slog.Info("event stream dropped, client fell behind", "last_event_id", lastID)
+				return
+			}
+			writeEvent(w, event)
+			lastID = event.ID
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event models.Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
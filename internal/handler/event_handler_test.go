@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+func TestGetEventsResume(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		query  string
+		status int
+		want   string
+	}{
+		{
+			name:   "from the start",
+			status: http.StatusOK,
+			want: "id: 1\nevent: order.created\ndata: {\"order_id\":1}\n\n" +
+				"id: 2\nevent: order.closed\ndata: {\"order_id\":1}\n\n" +
+				"id: 3\nevent: inventory.low\ndata: {\"ingredient_id\":\"milk\"}\n\n",
+		},
+		{
+			name:   "Last-Event-ID header",
+			header: "2",
+			status: http.StatusOK,
+			want:   "id: 3\nevent: inventory.low\ndata: {\"ingredient_id\":\"milk\"}\n\n",
+		},
+		{
+			name:   "query parameter",
+			query:  "?last_event_id=1",
+			status: http.StatusOK,
+			want: "id: 2\nevent: order.closed\ndata: {\"order_id\":1}\n\n" +
+				"id: 3\nevent: inventory.low\ndata: {\"ingredient_id\":\"milk\"}\n\n",
+		},
+		{
+			name:   "header wins over query",
+			header: "3",
+			query:  "?last_event_id=1",
+			status: http.StatusOK,
+		},
+		{name: "not a number", header: "abc", status: http.StatusBadRequest},
+		{name: "negative", query: "?last_event_id=-1", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := service.NewEventBus(10)
+			bus.HandleEvent(models.Event{ID: 1, Type: models.EventOrderCreated, Data: json.RawMessage(`{"order_id":1}`)})
+			bus.HandleEvent(models.Event{ID: 2, Type: models.EventOrderClosed, Data: json.RawMessage(`{"order_id":1}`)})
+			bus.HandleEvent(models.Event{ID: 3, Type: models.EventInventoryLow, Data: json.RawMessage(`{"ingredient_id":"milk"}`)})
+
+			// the client is gone once the missed events are written
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			r := httptest.NewRequest(http.MethodGet, "/events"+tt.query, nil).WithContext(ctx)
+			if tt.header != "" {
+				r.Header.Set("Last-Event-ID", tt.header)
+			}
+			w := httptest.NewRecorder()
+			NewEventHandler(bus).GetEvents(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d", w.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+				t.Errorf("content type %q", got)
+			}
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("stream %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
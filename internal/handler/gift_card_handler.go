@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+type GiftCardHandler interface {
+	PostGiftCard(w http.ResponseWriter, r *http.Request)
+	GetGiftCard(w http.ResponseWriter, r *http.Request)
+	PostLoadGiftCard(w http.ResponseWriter, r *http.Request)
+	GetGiftCardTransactions(w http.ResponseWriter, r *http.Request)
+}
+
+type giftCardHandler struct {
+	giftCardService service.GiftCardService
+}
+
+func NewGiftCardHandler(giftCardService service.GiftCardService) *giftCardHandler {
+	return &giftCardHandler{giftCardService: giftCardService}
+}
+
+func (h *giftCardHandler) PostGiftCard(w http.ResponseWriter, r *http.Request) {
+	var card models.GiftCard
+	if err := json.NewDecoder(r.Body).Decode(&card); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no gift card issued")
+		return
+	}
+	card, err := h.giftCardService.IssueGiftCard(card)
+	if err != nil {
+		if err.Error() == "gift card already exists" {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusConflict)
+			slog.Error("Failed to IssueGiftCard", err.Error(), "no gift card issued")
+			return
+		}
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to IssueGiftCard", err.Error(), "no gift card issued")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = setBodyToJson(w, card); err != nil {
+		slog.Error("Failed to setBodyToJson", err.Error(), "gift card issued")
+		return
+	}
+	slog.Info("gift card issued", "code", card.Code)
+}
+
+func (h *giftCardHandler) GetGiftCard(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 3 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no gift card")
+		return
+	}
+	card, err := h.giftCardService.GetGiftCard(pathParam[2])
+	if err != nil {
+		respondGiftCardError(w, err)
+		return
+	}
+	if err = setBodyToJson(w, card); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no gift card")
+		return
+	}
+	slog.Info("gift card got", "code", card.Code)
+}
+
+func (h *giftCardHandler) PostLoadGiftCard(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 4 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no gift card loaded")
+		return
+	}
+	var req models.GiftCardAmountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no gift card loaded")
+		return
+	}
+	card, err := h.giftCardService.LoadGiftCard(pathParam[2], req.Amount)
+	if err != nil {
+		respondGiftCardError(w, err)
+		return
+	}
+	if err = setBodyToJson(w, card); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "gift card loaded")
+		return
+	}
+	slog.Info("gift card loaded", "code", card.Code, "amount", req.Amount)
+}
+
+func (h *giftCardHandler) GetGiftCardTransactions(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 4 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no gift card transactions")
+		return
+	}
+	transactions, err := h.giftCardService.GetTransactions(pathParam[2])
+	if err != nil {
+		respondGiftCardError(w, err)
+		return
+	}
+	if err = setBodyToJson(w, transactions); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no gift card transactions")
+		return
+	}
+	slog.Info("gift card transactions got", "code", pathParam[2])
+}
+
+func respondGiftCardError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case "gift card not found":
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
+	default:
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+	}
+	slog.Error("Failed", err.Error(), "gift card request failed")
+}
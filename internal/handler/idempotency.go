@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+)
+
+// Idempotent makes a mutating request sent with an Idempotency-Key safe to
+// retry: the first response is stored and returned again for the same
+// request, reusing the key for another request is refused with 422.
+// Anonymous requests, such as logins, are never stored.
+func Idempotent(idempotencyService service.IdempotencyService, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(HeaderIdempotencyKey)
+		principal, found := principalFrom(r)
+		if key == "" || !found || r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+			slog.Error("Failed to read body", err.Error(), "request rejected")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		caller := idempotencyCaller(principal)
+		fingerprint := service.IdempotencyFingerprint(r.Method, r.URL.RequestURI(), body)
+		stored, replay, err := idempotencyService.Begin(caller, key, fingerprint)
+		if err != nil {
+			respondIdempotencyError(w, err)
+			return
+		}
+		if replay {
+			if stored.ContentType != "" {
+				w.Header().Set("Content-Type", stored.ContentType)
+			}
+			w.Header().Set(HeaderIdempotentReplayed, "true")
+			w.WriteHeader(stored.StatusCode)
+			w.Write(stored.Body)
+			slog.Info("response replayed", "key", key, "status", stored.StatusCode)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		next(recorder, r)
+		response := models.IdempotencyRecord{
+			StatusCode:  recorder.statusCode(),
+			ContentType: w.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		}
+		if err = idempotencyService.Finish(caller, key, response); err != nil {
+			slog.Error("Failed to Finish", err.Error(), "response for key "+key+" not stored")
+		}
+	}
+}
+
+// idempotencyCaller scopes keys to who sends them, so two clients cannot
+// see each other's responses.
+func idempotencyCaller(principal models.Principal) string {
+	if principal.APIKeyID != 0 {
+		return "api_key:" + strconv.Itoa(principal.APIKeyID)
+	}
+	return "employee:" + strconv.Itoa(principal.EmployeeID)
+}
+
+func respondIdempotencyError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case "Idempotency-Key was already used for a different request":
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusUnprocessableEntity)
+	case "a request with this Idempotency-Key is still in progress":
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusConflict)
+	case "Idempotency-Key must be at most 255 characters":
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+	default:
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+	}
+	slog.Error("Failed", err.Error(), "idempotent request refused")
+}
+
+// responseRecorder passes the response on while keeping a copy.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(data []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(data)
+	return rec.ResponseWriter.Write(data)
+}
+
+func (rec *responseRecorder) statusCode() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+// memoryIdempotencyRepo keeps the keys in memory for the middleware tests.
+type memoryIdempotencyRepo struct {
+	records map[string]models.IdempotencyRecord
+}
+
+func (r *memoryIdempotencyRepo) Reserve(caller, key, fingerprint string, ttl time.Duration) (bool, error) {
+	if _, found := r.records[caller+" "+key]; found {
+		return false, nil
+	}
+	r.records[caller+" "+key] = models.IdempotencyRecord{Fingerprint: fingerprint}
+	return true, nil
+}
+
+func (r *memoryIdempotencyRepo) Get(caller, key string) (models.IdempotencyRecord, error) {
+	record, found := r.records[caller+" "+key]
+	if !found {
+		return models.IdempotencyRecord{}, sql.ErrNoRows
+	}
+	return record, nil
+}
+
+func (r *memoryIdempotencyRepo) Complete(caller, key string, record models.IdempotencyRecord) error {
+	record.Fingerprint = r.records[caller+" "+key].Fingerprint
+	r.records[caller+" "+key] = record
+	return nil
+}
+
+func (r *memoryIdempotencyRepo) Release(caller, key string) error {
+	delete(r.records, caller+" "+key)
+	return nil
+}
+
+func (r *memoryIdempotencyRepo) PurgeExpired() (int, error) {
+	return 0, nil
+}
+
+func TestIdempotent(t *testing.T) {
+	type request struct {
+		key       string
+		body      string
+		principal *models.Principal
+	}
+	employee := &models.Principal{EmployeeID: 1, Role: "barista"}
+	tests := []struct {
+		name     string
+		status   int
+		requests []request
+		// calls is how often the handler ran, codes and replayed are per
+		// request.
+		calls    int
+		codes    []int
+		replayed []bool
+	}{
+		{
+			name:     "retry is replayed",
+			status:   http.StatusCreated,
+			requests: []request{{"k1", `{"a":1}`, employee}, {"k1", `{"a":1}`, employee}},
+			calls:    1,
+			codes:    []int{http.StatusCreated, http.StatusCreated},
+			replayed: []bool{false, true},
+		},
+		{
+			name:     "key reused for another body",
+			status:   http.StatusCreated,
+			requests: []request{{"k1", `{"a":1}`, employee}, {"k1", `{"a":2}`, employee}},
+			calls:    1,
+			codes:    []int{http.StatusCreated, http.StatusUnprocessableEntity},
+			replayed: []bool{false, false},
+		},
+		{
+			name:     "keys belong to their caller",
+			status:   http.StatusCreated,
+			requests: []request{{"k1", `{"a":1}`, employee}, {"k1", `{"a":1}`, &models.Principal{APIKeyID: 1, Role: "barista"}}},
+			calls:    2,
+			codes:    []int{http.StatusCreated, http.StatusCreated},
+			replayed: []bool{false, false},
+		},
+		{
+			name:     "server error is retried",
+			status:   http.StatusInternalServerError,
+			requests: []request{{"k1", `{"a":1}`, employee}, {"k1", `{"a":1}`, employee}},
+			calls:    2,
+			codes:    []int{http.StatusInternalServerError, http.StatusInternalServerError},
+			replayed: []bool{false, false},
+		},
+		{
+			name:     "no key",
+			status:   http.StatusCreated,
+			requests: []request{{"", `{"a":1}`, employee}, {"", `{"a":1}`, employee}},
+			calls:    2,
+			codes:    []int{http.StatusCreated, http.StatusCreated},
+			replayed: []bool{false, false},
+		},
+		{
+			name:     "anonymous request",
+			status:   http.StatusCreated,
+			requests: []request{{"k1", `{"a":1}`, nil}, {"k1", `{"a":1}`, nil}},
+			calls:    2,
+			codes:    []int{http.StatusCreated, http.StatusCreated},
+			replayed: []bool{false, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			next := func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				RespondWithJson(w, ErrorResponse{Message: "call " + strconv.Itoa(calls)}, tt.status)
+			}
+			idempotencyService := service.NewIdempotencyService(&memoryIdempotencyRepo{records: map[string]models.IdempotencyRecord{}}, time.Hour)
+			handler := Idempotent(idempotencyService, next)
+
+			var first string
+			for i, req := range tt.requests {
+				r := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(req.body))
+				if req.key != "" {
+					r.Header.Set(HeaderIdempotencyKey, req.key)
+				}
+				if req.principal != nil {
+					r = r.WithContext(context.WithValue(r.Context(), principalKey, *req.principal))
+				}
+				w := httptest.NewRecorder()
+				handler(w, r)
+
+				if w.Code != tt.codes[i] {
+					t.Errorf("request %d: status %d, want %d", i, w.Code, tt.codes[i])
+				}
+				replayed := w.Header().Get(HeaderIdempotentReplayed) == "true"
+				if replayed != tt.replayed[i] {
+					t.Errorf("request %d: replayed %v, want %v", i, replayed, tt.replayed[i])
+				}
+				if i == 0 {
+					first = w.Body.String()
+				} else if replayed && w.Body.String() != first {
+					t.Errorf("request %d: replayed body %q, want %q", i, w.Body.String(), first)
+				}
+			}
+			if calls != tt.calls {
+				t.Errorf("handler ran %d times, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
@@ -2,9 +2,11 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"hot-coffee/internal/service"
 	"hot-coffee/models"
@@ -15,8 +17,12 @@ type InventoryHandler interface {
 	GetAllItem(w http.ResponseWriter, r *http.Request)
 	GetItemById(w http.ResponseWriter, r *http.Request)
 	DeleteItem(w http.ResponseWriter, r *http.Request)
+	RestoreItem(w http.ResponseWriter, r *http.Request)
 	PutItem(w http.ResponseWriter, r *http.Request)
+	PatchItem(w http.ResponseWriter, r *http.Request)
 	GetLeftovers(w http.ResponseWriter, r *http.Request)
+	PostProduce(w http.ResponseWriter, r *http.Request)
+	GetRecipe(w http.ResponseWriter, r *http.Request)
 }
 
 type inventoryHandler struct {
@@ -27,6 +33,16 @@ func NewInventoryHandler(inventoryService service.InventoryService) *inventoryHa
 	return &inventoryHandler{inventoryService: inventoryService}
 }
 
+// storedInventoryItem is the item If-Match is checked against, nil when it
+// cannot be read.
+func (h *inventoryHandler) storedInventoryItem(id string) interface{} {
+	item, err := h.inventoryService.GetInventoryItemById(id)
+	if err != nil {
+		return nil
+	}
+	return item
+}
+
 func (h *inventoryHandler) PostItem(w http.ResponseWriter, r *http.Request) {
 	var newInventoryItem models.InventoryItem
 	if err := json.NewDecoder(r.Body).Decode(&newInventoryItem); err != nil {
@@ -34,7 +50,7 @@ func (h *inventoryHandler) PostItem(w http.ResponseWriter, r *http.Request) {
 		slog.Error("Failed to decode", err.Error(), "no new item to post")
 		return
 	}
-	if err := h.inventoryService.AddInventoryItem(newInventoryItem); err != nil {
+	if err := h.inventoryService.AddInventoryItem(newInventoryItem, actor(r)); err != nil {
 		if err.Error() == "item already exists" {
 			RespondWithJson(w, ErrorResponse{Message: "item already exists"}, http.StatusConflict)
 			slog.Error("Item already exists")
@@ -78,6 +94,9 @@ func (h *inventoryHandler) GetItemById(w http.ResponseWriter, r *http.Request) {
 		slog.Error("Failed to get", err.Error(), "no new item to post")
 		return
 	}
+	if notModified(w, r, inventoryItem) {
+		return
+	}
 	err = setBodyToJson(w, inventoryItem)
 	if err != nil {
 		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
@@ -89,7 +108,26 @@ func (h *inventoryHandler) GetItemById(w http.ResponseWriter, r *http.Request) {
 
 func (h *inventoryHandler) DeleteItem(w http.ResponseWriter, r *http.Request) {
 	idForDeletion := r.URL.Path[len("/inventory/"):]
-	if err := h.inventoryService.DeleteInventoryItem(idForDeletion); err != nil {
+	stored := h.storedInventoryItem(idForDeletion)
+	if preconditionFailed(w, r, stored) {
+		return
+	}
+	var version int
+	if current, ok := stored.(models.InventoryItem); ok {
+		version = ifMatchVersion(r, current.Version)
+	}
+	if err := h.inventoryService.DeleteInventoryItem(idForDeletion, version, actor(r)); err != nil {
+		if respondVersionMismatch(w, r, err) {
+			return
+		}
+		var inUse *service.IngredientInUseError
+		if errors.As(err, &inUse) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(IngredientInUseResponse{Message: err.Error(), MenuItems: inUse.MenuItemIDs, PrepItems: inUse.PrepItemIDs})
+			slog.Error("Failed to delete", err.Error(), "ingredient is still in use")
+			return
+		}
 		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
 		slog.Error("Failed to MarshalIndent", err.Error(), "no new item to post")
 		return
@@ -98,6 +136,28 @@ func (h *inventoryHandler) DeleteItem(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (h *inventoryHandler) RestoreItem(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 4 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed to get", "wrong input", "no item restored")
+		return
+	}
+	id := pathParam[2]
+	if err := h.inventoryService.RestoreInventoryItem(id, actor(r)); err != nil {
+		if err.Error() == "inventory item is not archived" {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusConflict)
+			slog.Error("Failed to restore", err.Error(), "no item restored")
+			return
+		}
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
+		slog.Error("Failed to restore", err.Error(), "no item restored")
+		return
+	}
+	slog.Info("Inventory restored", "inventoryID", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (h *inventoryHandler) PutItem(w http.ResponseWriter, r *http.Request) {
 	var inventoryItem models.InventoryItem
 	err := json.NewDecoder(r.Body).Decode(&inventoryItem)
@@ -106,13 +166,74 @@ func (h *inventoryHandler) PutItem(w http.ResponseWriter, r *http.Request) {
 		slog.Error("Failed to decode", err.Error(), "no new item to post")
 		return
 	}
-	err = h.inventoryService.UpdateInventoryItem(inventoryItem)
+	stored := h.storedInventoryItem(inventoryItem.IngredientID)
+	if preconditionFailed(w, r, stored) {
+		return
+	}
+	inventoryItem.Version = 0
+	if current, ok := stored.(models.InventoryItem); ok {
+		inventoryItem.Version = ifMatchVersion(r, current.Version)
+	}
+	if !h.updateItem(w, r, inventoryItem) {
+		return
+	}
+	slog.Info("Inventory put", "inventoryID", inventoryItem.IngredientID)
+}
+
+// PatchItem applies a JSON Merge Patch to the inventory item and answers
+// with the patched item. Reserved and available stock are computed and
+// cannot be patched.
+func (h *inventoryHandler) PatchItem(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Path[len("/inventory/"):]
+	current, err := h.inventoryService.GetInventoryItemById(id)
 	if err != nil {
 		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
-		slog.Error("Failed to MarshalIndent", err.Error(), "no new item to post")
+		slog.Error("Failed to get", err.Error(), "no item patched")
 		return
 	}
-	slog.Info("Inventory put", "inventoryID", inventoryItem.IngredientID)
+	if preconditionFailed(w, r, current) {
+		return
+	}
+	var inventoryItem models.InventoryItem
+	if err = applyMergePatch(r, current, &inventoryItem); err != nil {
+		respondPatchError(w, err)
+		slog.Error("Failed to applyMergePatch", err.Error(), "no item patched")
+		return
+	}
+	if inventoryItem.IngredientID != current.IngredientID {
+		RespondWithJson(w, ErrorResponse{Message: "Inventory ID conflict"}, http.StatusBadRequest)
+		return
+	}
+	inventoryItem.Version = current.Version
+	if !h.updateItem(w, r, inventoryItem) {
+		return
+	}
+	updated, err := h.inventoryService.GetInventoryItemById(id)
+	if err != nil {
+		slog.Error("Failed to get", err.Error(), "item patched but not returned")
+		return
+	}
+	respondWithETag(w, updated, http.StatusOK)
+	slog.Info("Inventory patched", "inventoryID", id)
+}
+
+// updateItem saves the changed item, it answers the request when the
+// change is refused.
+func (h *inventoryHandler) updateItem(w http.ResponseWriter, r *http.Request, inventoryItem models.InventoryItem) bool {
+	err := h.inventoryService.UpdateInventoryItem(inventoryItem, actor(r))
+	if err != nil {
+		if respondVersionMismatch(w, r, err) {
+			return false
+		}
+		status := http.StatusNotFound
+		if err.Error() == "invalid inventory item" {
+			status = http.StatusBadRequest
+		}
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, status)
+		slog.Error("Failed to UpdateInventoryItem", err.Error(), "no new item to post")
+		return false
+	}
+	return true
 }
 
 func (h *inventoryHandler) GetLeftovers(w http.ResponseWriter, r *http.Request) {
@@ -139,3 +260,59 @@ func (h *inventoryHandler) GetLeftovers(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusOK)
 	w.Write(jsonData)
 }
+
+func (h *inventoryHandler) PostProduce(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 4 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed to get", "wrong input", "nothing produced")
+		return
+	}
+	id := pathParam[2]
+	var req models.ProduceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "nothing produced")
+		return
+	}
+	if err := h.inventoryService.Produce(id, req.Quantity, actor(r)); err != nil {
+		if err.Error() == "inventory item not found" {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
+			slog.Error("Failed to produce", err.Error(), "nothing produced")
+			return
+		}
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to produce", err.Error(), "nothing produced")
+		return
+	}
+	slog.Info("Inventory produced", "inventoryID", id, "quantity", req.Quantity)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *inventoryHandler) GetRecipe(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 4 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed to get", "wrong input", "no recipe")
+		return
+	}
+	id := pathParam[2]
+	summary, err := h.inventoryService.GetRecipeSummary(id)
+	if err != nil {
+		if err.Error() == "inventory item not found" {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
+			slog.Error("Failed to get recipe", err.Error(), "no recipe")
+			return
+		}
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to get recipe", err.Error(), "no recipe")
+		return
+	}
+	err = setBodyToJson(w, summary)
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to get recipe", err.Error(), "no recipe")
+		return
+	}
+	slog.Info("Inventory recipe got", "inventoryID", id)
+}
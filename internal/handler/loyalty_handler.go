@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+type LoyaltyHandler interface {
+	GetCustomerLoyalty(w http.ResponseWriter, r *http.Request)
+	GetRules(w http.ResponseWriter, r *http.Request)
+	PutRules(w http.ResponseWriter, r *http.Request)
+}
+
+type loyaltyHandler struct {
+	loyaltyService service.LoyaltyService
+}
+
+func NewLoyaltyHandler(loyaltyService service.LoyaltyService) *loyaltyHandler {
+	return &loyaltyHandler{loyaltyService: loyaltyService}
+}
+
+func (h *loyaltyHandler) GetCustomerLoyalty(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 4 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no loyalty account")
+		return
+	}
+	id, err := strconv.Atoi(pathParam[2])
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid customer id"}, http.StatusBadRequest)
+		slog.Error("Failed", err.Error(), "no loyalty account")
+		return
+	}
+	account, err := h.loyaltyService.GetAccount(id)
+	if err != nil {
+		respondCustomerError(w, err)
+		return
+	}
+	if err = setBodyToJson(w, account); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no loyalty account")
+		return
+	}
+	slog.Info("loyalty account got", "customerID", id)
+}
+
+func (h *loyaltyHandler) GetRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.loyaltyService.GetRules()
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to GetRules", err.Error(), "no loyalty rules")
+		return
+	}
+	if err = setBodyToJson(w, rules); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no loyalty rules")
+		return
+	}
+	slog.Info("loyalty rules got", "count", len(rules))
+}
+
+func (h *loyaltyHandler) PutRules(w http.ResponseWriter, r *http.Request) {
+	var rules []models.LoyaltyRule
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no loyalty rules updated")
+		return
+	}
+	if err := h.loyaltyService.UpdateRules(rules); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to UpdateRules", err.Error(), "no loyalty rules updated")
+		return
+	}
+	slog.Info("loyalty rules updated", "count", len(rules))
+}
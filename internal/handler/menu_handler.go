@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"hot-coffee/internal/service"
@@ -15,7 +17,13 @@ type MenuHandler interface {
 	GetAllMenuHandler(w http.ResponseWriter, r *http.Request)
 	GetMenuItemHandler(w http.ResponseWriter, r *http.Request)
 	PutMenuHandler(w http.ResponseWriter, r *http.Request)
+	PatchMenuHandler(w http.ResponseWriter, r *http.Request)
+	PostIngredientHandler(w http.ResponseWriter, r *http.Request)
+	DeleteIngredientHandler(w http.ResponseWriter, r *http.Request)
 	DeleteMenuHandler(w http.ResponseWriter, r *http.Request)
+	RestoreMenuHandler(w http.ResponseWriter, r *http.Request)
+	GetNutritionHandler(w http.ResponseWriter, r *http.Request)
+	GetNutritionCSVHandler(w http.ResponseWriter, r *http.Request)
 }
 
 type menuHandler struct {
@@ -26,10 +34,20 @@ func NewMenuHandler(menuService service.MenuServiceInterface) *menuHandler {
 	return &menuHandler{menuService: menuService}
 }
 
+// storedMenuItem is the menu item If-Match is checked against, nil when it
+// cannot be read.
+func (h *menuHandler) storedMenuItem(id string) interface{} {
+	menuItem, err := h.menuService.GetMenuItemById(id)
+	if err != nil {
+		return nil
+	}
+	return menuItem
+}
+
 func (h *menuHandler) PostMenuHandler(w http.ResponseWriter, r *http.Request) {
 	var newMenuitem models.MenuItem
 	json.NewDecoder(r.Body).Decode(&newMenuitem)
-	err := h.menuService.AddMenuItem(newMenuitem)
+	err := h.menuService.AddMenuItem(newMenuitem, actor(r))
 	if err != nil {
 		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
 		slog.Error("Failed to AddMenuItem", err.Error(), "no menu posted")
@@ -40,7 +58,11 @@ func (h *menuHandler) PostMenuHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *menuHandler) GetAllMenuHandler(w http.ResponseWriter, r *http.Request) {
-	menuItems, err := h.menuService.GetAllMenuItems()
+	var excludeAllergens []string
+	if exclude := r.URL.Query().Get("excludeAllergens"); exclude != "" {
+		excludeAllergens = strings.Split(exclude, ",")
+	}
+	menuItems, err := h.menuService.GetAllMenuItems(excludeAllergens)
 	if err != nil {
 		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
 		slog.Error("Failed to GetAllMenuItems", err.Error(), "no menu posted")
@@ -71,6 +93,9 @@ func (h *menuHandler) GetMenuItemHandler(w http.ResponseWriter, r *http.Request)
 		slog.Error("Failed to GetMenuItemById", err.Error(), "no menu posted")
 		return
 	}
+	if notModified(w, r, menuItem) {
+		return
+	}
 	err = setBodyToJson(w, menuItem)
 	if err != nil {
 		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
@@ -91,14 +116,163 @@ func (h *menuHandler) PutMenuHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	if menuItem.ID != id {
 		RespondWithJson(w, ErrorResponse{Message: "Menu ID conflict"}, http.StatusBadRequest)
+		return
+	}
+	stored := h.storedMenuItem(id)
+	if preconditionFailed(w, r, stored) {
+		return
+	}
+	menuItem.Version = 0
+	if current, ok := stored.(models.MenuItem); ok {
+		menuItem.Version = ifMatchVersion(r, current.Version)
+	}
+	if !h.updateMenuItem(w, r, menuItem) {
+		return
+	}
+	slog.Info("menu posted", "menuID", menuItem.ID)
+}
+
+// PatchMenuHandler applies a JSON Merge Patch to the menu item, e.g. only a
+// new price, and answers with the patched item.
+func (h *menuHandler) PatchMenuHandler(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 3 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed to get input", "wrong input", "no menu patched")
+		return
+	}
+	current, found := h.currentMenuItem(w, r, pathParam[2])
+	if !found {
+		return
+	}
+	var menuItem models.MenuItem
+	if err := applyMergePatch(r, current, &menuItem); err != nil {
+		respondPatchError(w, err)
+		slog.Error("Failed to applyMergePatch", err.Error(), "no menu patched")
+		return
+	}
+	if menuItem.ID != current.ID {
+		RespondWithJson(w, ErrorResponse{Message: "Menu ID conflict"}, http.StatusBadRequest)
+		return
+	}
+	menuItem.Version = current.Version
+	if !h.updateMenuItem(w, r, menuItem) {
+		return
+	}
+	h.respondMenuItem(w, menuItem.ID, http.StatusOK)
+	slog.Info("menu patched", "menuID", menuItem.ID)
+}
+
+// PostIngredientHandler adds one ingredient to the recipe of the menu item.
+func (h *menuHandler) PostIngredientHandler(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 4 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed to get input", "wrong input", "no ingredient added")
+		return
+	}
+	var ingredient models.MenuItemIngredient
+	if err := json.NewDecoder(r.Body).Decode(&ingredient); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no ingredient added")
+		return
+	}
+	current, found := h.currentMenuItem(w, r, pathParam[2])
+	if !found {
+		return
+	}
+	for _, existing := range current.Ingredients {
+		if existing.IngredientID == ingredient.IngredientID {
+			RespondWithJson(w, ErrorResponse{Message: "ingredient is already in the recipe"}, http.StatusConflict)
+			slog.Error("Failed", "ingredient is already in the recipe", "no ingredient added")
+			return
+		}
+	}
+	menuItem := current
+	menuItem.Ingredients = append(append([]models.MenuItemIngredient{}, current.Ingredients...), ingredient)
+	if !h.updateMenuItem(w, r, menuItem) {
+		return
+	}
+	h.respondMenuItem(w, menuItem.ID, http.StatusCreated)
+	slog.Info("ingredient added", "menuID", menuItem.ID, "ingredientID", ingredient.IngredientID)
+}
+
+// DeleteIngredientHandler removes one ingredient from the recipe of the menu
+// item.
+func (h *menuHandler) DeleteIngredientHandler(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 5 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed to get input", "wrong input", "no ingredient removed")
+		return
 	}
-	err = h.menuService.UpdateMenu(menuItem)
+	current, found := h.currentMenuItem(w, r, pathParam[2])
+	if !found {
+		return
+	}
+	ingredientID := pathParam[4]
+	menuItem := current
+	menuItem.Ingredients = []models.MenuItemIngredient{}
+	for _, ingredient := range current.Ingredients {
+		if ingredient.IngredientID != ingredientID {
+			menuItem.Ingredients = append(menuItem.Ingredients, ingredient)
+		}
+	}
+	if len(menuItem.Ingredients) == len(current.Ingredients) {
+		RespondWithJson(w, ErrorResponse{Message: "ingredient is not in the recipe"}, http.StatusNotFound)
+		slog.Error("Failed", "ingredient is not in the recipe", "no ingredient removed")
+		return
+	}
+	if !h.updateMenuItem(w, r, menuItem) {
+		return
+	}
+	h.respondMenuItem(w, menuItem.ID, http.StatusOK)
+	slog.Info("ingredient removed", "menuID", menuItem.ID, "ingredientID", ingredientID)
+}
+
+// currentMenuItem reads the item a patch or recipe change starts from, it
+// answers the request when the item is missing or If-Match does not match.
+func (h *menuHandler) currentMenuItem(w http.ResponseWriter, r *http.Request, id string) (models.MenuItem, bool) {
+	current, err := h.menuService.GetMenuItemById(id)
 	if err != nil {
 		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
+		slog.Error("Failed to GetMenuItemById", err.Error(), "no menu patched")
+		return models.MenuItem{}, false
+	}
+	if preconditionFailed(w, r, current) {
+		return models.MenuItem{}, false
+	}
+	return current, true
+}
+
+// updateMenuItem saves the changed item, it answers the request when the
+// change is refused.
+func (h *menuHandler) updateMenuItem(w http.ResponseWriter, r *http.Request, menuItem models.MenuItem) bool {
+	err := h.menuService.UpdateMenu(menuItem, actor(r))
+	if err != nil {
+		if respondVersionMismatch(w, r, err) {
+			return false
+		}
+		status := http.StatusNotFound
+		if err.Error() == "invalid menu" || strings.HasPrefix(err.Error(), "invalid ingredient") || strings.HasPrefix(err.Error(), "ingredient listed twice") {
+			status = http.StatusBadRequest
+		}
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, status)
 		slog.Error("Failed to UpdateMenuItem", err.Error(), "no menu posted")
+		return false
+	}
+	return true
+}
+
+// respondMenuItem answers a change with the item as it is now.
+func (h *menuHandler) respondMenuItem(w http.ResponseWriter, id string, status int) {
+	menuItem, err := h.menuService.GetMenuItemById(id)
+	if err != nil {
+		w.WriteHeader(status)
+		slog.Error("Failed to GetMenuItemById", err.Error(), "item changed but not returned")
 		return
 	}
-	slog.Info("menu posted", "menuID", menuItem.ID)
+	respondWithETag(w, menuItem, status)
 }
 
 func (h *menuHandler) DeleteMenuHandler(w http.ResponseWriter, r *http.Request) {
@@ -109,8 +283,19 @@ func (h *menuHandler) DeleteMenuHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	id := pathParam[2]
-	err := h.menuService.DeleteMenuItemById(id)
+	stored := h.storedMenuItem(id)
+	if preconditionFailed(w, r, stored) {
+		return
+	}
+	var version int
+	if current, ok := stored.(models.MenuItem); ok {
+		version = ifMatchVersion(r, current.Version)
+	}
+	err := h.menuService.DeleteMenuItemById(id, version, actor(r))
 	if err != nil {
+		if respondVersionMismatch(w, r, err) {
+			return
+		}
 		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
 		slog.Error("Failed to DeleteMenuItemById", err.Error(), "no menu posted")
 		return
@@ -118,3 +303,84 @@ func (h *menuHandler) DeleteMenuHandler(w http.ResponseWriter, r *http.Request)
 	slog.Info("menu posted", "menuID", id)
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (h *menuHandler) RestoreMenuHandler(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 4 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed to get", "wrong input", "no menu restored")
+		return
+	}
+	id := pathParam[2]
+	err := h.menuService.RestoreMenuItemById(id, actor(r))
+	if err != nil {
+		if err.Error() == "menu item is not archived" {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusConflict)
+			slog.Error("Failed to RestoreMenuItemById", err.Error(), "no menu restored")
+			return
+		}
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
+		slog.Error("Failed to RestoreMenuItemById", err.Error(), "no menu restored")
+		return
+	}
+	slog.Info("menu restored", "menuID", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *menuHandler) GetNutritionHandler(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 4 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed to get input", "wrong input", "no nutrition")
+		return
+	}
+	id := pathParam[2]
+	nutrition, err := h.menuService.GetMenuItemNutrition(id)
+	if err != nil {
+		if err.Error() == "menu item not found" {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
+			slog.Error("Failed to GetMenuItemNutrition", err.Error(), "no nutrition")
+			return
+		}
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to GetMenuItemNutrition", err.Error(), "no nutrition")
+		return
+	}
+	err = setBodyToJson(w, nutrition)
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no nutrition")
+		return
+	}
+	slog.Info("nutrition got", "menuID", id)
+}
+
+func (h *menuHandler) GetNutritionCSVHandler(w http.ResponseWriter, r *http.Request) {
+	table, err := h.menuService.GetMenuNutrition()
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to GetMenuNutrition", err.Error(), "no nutrition")
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="menu_nutrition.csv"`)
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"menu_item_id", "name", "calories", "protein", "fat", "carbs", "missing_ingredients"})
+	for _, row := range table {
+		writer.Write([]string{
+			row.MenuItemID,
+			row.Name,
+			strconv.FormatFloat(row.Calories, 'f', 1, 64),
+			strconv.FormatFloat(row.Protein, 'f', 1, 64),
+			strconv.FormatFloat(row.Fat, 'f', 1, 64),
+			strconv.FormatFloat(row.Carbs, 'f', 1, 64),
+			strings.Join(row.MissingIngredients, " "),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		slog.Error("Failed to write csv", err.Error(), "no nutrition")
+		return
+	}
+	slog.Info("nutrition csv exported", "rows", len(table))
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+type fakeNutritionService struct {
+	service.MenuServiceInterface
+	table []models.MenuItemNutrition
+}
+
+func (s *fakeNutritionService) GetMenuNutrition() ([]models.MenuItemNutrition, error) {
+	return s.table, nil
+}
+
+func TestGetNutritionCSV(t *testing.T) {
+	h := NewMenuHandler(&fakeNutritionService{table: []models.MenuItemNutrition{
+		{MenuItemID: "latte", Name: "Caffe Latte", Calories: 128, Protein: 6.8, Fat: 7.2, Carbs: 9.6},
+		{MenuItemID: "mocha", Name: "Mocha, large", Calories: 210.5, Carbs: 30, MissingIngredients: []string{"cocoa", "espresso"}},
+	}})
+	w := httptest.NewRecorder()
+	h.GetNutritionCSVHandler(w, httptest.NewRequest(http.MethodGet, "/menu/nutrition.csv", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/csv" {
+		t.Errorf("content type %q", got)
+	}
+	want := "menu_item_id,name,calories,protein,fat,carbs,missing_ingredients\n" +
+		"latte,Caffe Latte,128.0,6.8,7.2,9.6,\n" +
+		"mocha,\"Mocha, large\",210.5,0.0,0.0,30.0,cocoa espresso\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("csv\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+type contextKey string
+
+const principalKey contextKey = "principal"
+
+// Permissions a route can require besides a role.
+const (
+	PermissionPublic = "public"
+	// PermissionBootstrap lets anyone in until a manager can log in, and
+	// managers only afterwards.
+	PermissionBootstrap = "bootstrap"
+)
+
+// Authenticate resolves the caller from an "Authorization: Bearer" staff
+// token or an X-API-Key header. Requests without credentials pass through
+// anonymously and are refused later by Require, wrong credentials are
+// refused here.
+func Authenticate(authService service.AuthService, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var principal models.Principal
+		var err error
+		if key := r.Header.Get("X-API-Key"); key != "" {
+			principal, err = authService.AuthenticateAPIKey(key)
+		} else if header := r.Header.Get("Authorization"); header != "" {
+			token, found := strings.CutPrefix(header, "Bearer ")
+			if !found {
+				RespondWithJson(w, ErrorResponse{Message: "Authorization must be a Bearer token"}, http.StatusUnauthorized)
+				slog.Error("Failed", "bad authorization header", "request rejected")
+				return
+			}
+			principal, err = authService.AuthenticateToken(token)
+		} else {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err != nil {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusUnauthorized)
+			slog.Error("Failed to authenticate", err.Error(), "request rejected")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey, principal)))
+	})
+}
+
+// Require refuses callers below the required role, which may also be
+// PermissionPublic or PermissionBootstrap.
+func Require(authService service.AuthService, required string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		need := required
+		if need == PermissionPublic {
+			next(w, r)
+			return
+		}
+		principal, found := principalFrom(r)
+		if need == PermissionBootstrap {
+			if !found {
+				bootstrap, err := authService.NeedsBootstrap()
+				if err != nil {
+					RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+					slog.Error("Failed", err.Error(), "request rejected")
+					return
+				}
+				if bootstrap {
+					next(w, r)
+					return
+				}
+			}
+			need = "manager"
+		}
+		if !found {
+			RespondWithJson(w, ErrorResponse{Message: "authentication required"}, http.StatusUnauthorized)
+			slog.Error("Failed", "no credentials", r.Method+" "+r.URL.Path)
+			return
+		}
+		if !service.RoleAllows(principal.Role, need) {
+			RespondWithJson(w, ErrorResponse{Message: "requires role " + need}, http.StatusForbidden)
+			slog.Error("Failed", "forbidden", r.Method+" "+r.URL.Path)
+			return
+		}
+		next(w, r)
+	})
+}
+
+func principalFrom(r *http.Request) (models.Principal, bool) {
+	principal, found := r.Context().Value(principalKey).(models.Principal)
+	return principal, found
+}
+
+// actor is who makes the request, changes are logged in the audit log
+// under it. It is the zero principal for anonymous requests.
+func actor(r *http.Request) models.Principal {
+	principal, _ := principalFrom(r)
+	return principal
+}
+
+// actingEmployee is the employee making the request, 0 for API keys and
+// anonymous requests.
+func actingEmployee(r *http.Request) int {
+	principal, _ := principalFrom(r)
+	return principal.EmployeeID
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+// bootstrapAuth answers NeedsBootstrap, the other methods are not used by
+// Require.
+type bootstrapAuth struct {
+	service.AuthService
+	bootstrap bool
+}
+
+func (a *bootstrapAuth) NeedsBootstrap() (bool, error) {
+	return a.bootstrap, nil
+}
+
+func requireRequest(principal *models.Principal) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/employees", nil)
+	if principal != nil {
+		r = r.WithContext(context.WithValue(r.Context(), principalKey, *principal))
+	}
+	return r
+}
+
+func TestRequire(t *testing.T) {
+	barista := &models.Principal{EmployeeID: 2, Role: "barista"}
+	manager := &models.Principal{EmployeeID: 1, Role: "manager"}
+	tests := []struct {
+		name      string
+		required  string
+		bootstrap bool
+		principal *models.Principal
+		status    int
+	}{
+		{name: "public", required: PermissionPublic, status: http.StatusOK},
+		{name: "anonymous", required: "barista", status: http.StatusUnauthorized},
+		{name: "role high enough", required: "barista", principal: manager, status: http.StatusOK},
+		{name: "role too low", required: "manager", principal: barista, status: http.StatusForbidden},
+		{name: "bootstrap before any manager", required: PermissionBootstrap, bootstrap: true, status: http.StatusOK},
+		{name: "bootstrap done", required: PermissionBootstrap, status: http.StatusUnauthorized},
+		{name: "bootstrap done, manager", required: PermissionBootstrap, principal: manager, status: http.StatusOK},
+		{name: "bootstrap done, barista", required: PermissionBootstrap, principal: barista, status: http.StatusForbidden},
+		{name: "signed in during bootstrap", required: PermissionBootstrap, bootstrap: true, principal: barista, status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Require(&bootstrapAuth{bootstrap: tt.bootstrap}, tt.required, func(w http.ResponseWriter, r *http.Request) {})
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, requireRequest(tt.principal))
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+// TestRequireBootstrapStaysOpen checks that an authenticated request does
+// not turn the bootstrap route into a manager-only route for later ones.
+func TestRequireBootstrapStaysOpen(t *testing.T) {
+	h := Require(&bootstrapAuth{bootstrap: true}, PermissionBootstrap, func(w http.ResponseWriter, r *http.Request) {})
+	steps := []struct {
+		principal *models.Principal
+		status    int
+	}{
+		{nil, http.StatusOK},
+		{&models.Principal{EmployeeID: 2, Role: "barista"}, http.StatusForbidden},
+		{nil, http.StatusOK},
+	}
+	for i, step := range steps {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, requireRequest(step.principal))
+		if w.Code != step.status {
+			t.Errorf("request %d: status %d, want %d", i, w.Code, step.status)
+		}
+	}
+
+	// run with -race: concurrent requests share the handler
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var principal *models.Principal
+			if i%2 == 0 {
+				principal = &models.Principal{EmployeeID: 2, Role: "barista"}
+			}
+			h.ServeHTTP(httptest.NewRecorder(), requireRequest(principal))
+		}(i)
+	}
+	wg.Wait()
+}
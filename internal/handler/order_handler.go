@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"reflect"
 	"strconv"
 	"strings"
 
@@ -14,10 +15,16 @@ import (
 type OrderHandler interface {
 	PostOrder(w http.ResponseWriter, r *http.Request)
 	PutOrderByID(w http.ResponseWriter, r *http.Request)
+	PatchOrderByID(w http.ResponseWriter, r *http.Request)
+	PostOrderLine(w http.ResponseWriter, r *http.Request)
+	DeleteOrderLine(w http.ResponseWriter, r *http.Request)
 	DeleteOrderByID(w http.ResponseWriter, r *http.Request)
 	GetOrderByID(w http.ResponseWriter, r *http.Request)
 	GetAllOrders(w http.ResponseWriter, r *http.Request)
 	PostCloseOrder(w http.ResponseWriter, r *http.Request)
+	PostCancelOrder(w http.ResponseWriter, r *http.Request)
+	PostSplitOrder(w http.ResponseWriter, r *http.Request)
+	PostMergeOrders(w http.ResponseWriter, r *http.Request)
 	GetNumberOfOrderedItems(w http.ResponseWriter, r *http.Request)
 	GetOrderedItemsByPeriod(w http.ResponseWriter, r *http.Request)
 	BatchProcessOrders(w http.ResponseWriter, r *http.Request)
@@ -31,6 +38,16 @@ func NewOrderHandler(orderService service.OrderService) *orderHandler {
 	return &orderHandler{orderService: orderService}
 }
 
+// storedOrder is the order If-Match is checked against, nil when it cannot
+// be read.
+func (h *orderHandler) storedOrder(id int) interface{} {
+	order, err := h.orderService.GetOrderItemById(id)
+	if err != nil {
+		return nil
+	}
+	return order
+}
+
 func (h *orderHandler) PostOrder(w http.ResponseWriter, r *http.Request) {
 	var newOrder models.Order
 	err := json.NewDecoder(r.Body).Decode(&newOrder)
@@ -39,13 +56,14 @@ func (h *orderHandler) PostOrder(w http.ResponseWriter, r *http.Request) {
 		slog.Error("Failed to decode", err.Error(), "no order posted")
 		return
 	}
-	err = h.orderService.PostOrUpdate(newOrder, 0)
+	newOrder.EmployeeID = actingEmployee(r)
+	id, err := h.orderService.PostOrUpdate(newOrder, 0, actor(r))
 	if err != nil {
 		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
 		slog.Error("Failed to decode", err.Error(), "no order posted")
 		return
 	}
-	slog.Info("order posted")
+	slog.Info("order posted", "orderID", id)
 	w.WriteHeader(http.StatusCreated)
 }
 
@@ -74,29 +92,216 @@ func (h *orderHandler) PutOrderByID(w http.ResponseWriter, r *http.Request) {
 		slog.Error("Failed", err.Error(), "no order posted")
 		return
 	}
-	if orderItem.Status == "active" {
-		err = h.orderService.PostOrUpdate(newOrder, id)
-		if err != nil {
-			if err.Error() == "not found" {
-				RespondWithJson(w, ErrorResponse{Message: "Order not found"}, http.StatusNotFound)
-				slog.Error("Failed", err.Error(), "no order posted")
-				return
-			}
-			if err.Error() == "bad request" {
-				RespondWithJson(w, ErrorResponse{Message: "Bad request"}, http.StatusBadRequest)
-				slog.Error("Failed", err.Error(), "no order posted")
-				return
-			}
-			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
-			slog.Error("Failed to update", err.Error(), "no order posted")
+	if preconditionFailed(w, r, orderItem) {
+		return
+	}
+	newOrder.Version = ifMatchVersion(r, orderItem.Version)
+	if !h.updateOrder(w, r, orderItem, newOrder) {
+		return
+	}
+	slog.Info("order posted", "orderID", id)
+}
+
+// PatchOrderByID applies a JSON Merge Patch to the order and answers with
+// the patched order. The items array is replaced as a whole, single lines
+// are added and removed under /orders/{id}/items.
+func (h *orderHandler) PatchOrderByID(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 3 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no order patched")
+		return
+	}
+	current, found := h.currentOrder(w, r, pathParam[2])
+	if !found {
+		return
+	}
+	var order models.Order
+	if err := applyMergePatch(r, current, &order); err != nil {
+		respondPatchError(w, err)
+		slog.Error("Failed to applyMergePatch", err.Error(), "no order patched")
+		return
+	}
+	order.Version = current.Version
+	if !h.updateOrder(w, r, current, order) {
+		return
+	}
+	h.respondOrder(w, current.ID, http.StatusOK)
+	slog.Info("order patched", "orderID", current.ID)
+}
+
+// PostOrderLine adds one line to the order.
+func (h *orderHandler) PostOrderLine(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 4 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no order line added")
+		return
+	}
+	var line models.OrderItem
+	if err := json.NewDecoder(r.Body).Decode(&line); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no order line added")
+		return
+	}
+	if line.Quantity <= 0 {
+		RespondWithJson(w, ErrorResponse{Message: "quantity must be positive"}, http.StatusBadRequest)
+		slog.Error("Failed", "quantity must be positive", "no order line added")
+		return
+	}
+	current, found := h.currentOrder(w, r, pathParam[2])
+	if !found {
+		return
+	}
+	line.LineID = 0
+	order := current
+	order.Items = append([]models.OrderItem{}, current.Items...)
+	status := http.StatusCreated
+	merged := false
+	for i, item := range order.Items {
+		if item.MenuItemID != line.MenuItemID {
+			continue
+		}
+		if !sameCustomization(item.Customization, line.Customization) {
+			RespondWithJson(w, ErrorResponse{Message: "menu item is already on the order with another customization, change that line instead"}, http.StatusConflict)
+			slog.Error("Failed", "menu item already on the order", "no order line added")
 			return
 		}
-	} else {
+		order.Items[i].Quantity += line.Quantity
+		status = http.StatusOK
+		merged = true
+		break
+	}
+	if !merged {
+		order.Items = append(order.Items, line)
+	}
+	if !h.updateOrder(w, r, current, order) {
+		return
+	}
+	h.respondOrder(w, current.ID, status)
+	slog.Info("order line added", "orderID", current.ID, "menuItemID", line.MenuItemID)
+}
+
+// DeleteOrderLine removes one line, by its line_id, from the order.
+func (h *orderHandler) DeleteOrderLine(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 5 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no order line removed")
+		return
+	}
+	lineID, err := strconv.Atoi(pathParam[4])
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: "line_id must be a number"}, http.StatusBadRequest)
+		slog.Error("Failed", err.Error(), "no order line removed")
+		return
+	}
+	current, found := h.currentOrder(w, r, pathParam[2])
+	if !found {
+		return
+	}
+	order := current
+	order.Items = []models.OrderItem{}
+	for _, line := range current.Items {
+		if line.LineID != lineID {
+			order.Items = append(order.Items, line)
+		}
+	}
+	if len(order.Items) == len(current.Items) {
+		RespondWithJson(w, ErrorResponse{Message: "order line not found"}, http.StatusNotFound)
+		slog.Error("Failed", "order line not found", "no order line removed")
+		return
+	}
+	if len(order.Items) == 0 {
+		RespondWithJson(w, ErrorResponse{Message: "cannot remove the last line, cancel the order instead"}, http.StatusConflict)
+		slog.Error("Failed", "last order line", "no order line removed")
+		return
+	}
+	if !h.updateOrder(w, r, current, order) {
+		return
+	}
+	h.respondOrder(w, current.ID, http.StatusOK)
+	slog.Info("order line removed", "orderID", current.ID, "lineID", lineID)
+}
+
+// currentOrder reads the order a patch or line change starts from, it
+// answers the request when the order is missing or If-Match does not match.
+func (h *orderHandler) currentOrder(w http.ResponseWriter, r *http.Request, idParam string) (models.Order, bool) {
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid order id"}, http.StatusBadRequest)
+		slog.Error("Failed", err.Error(), "no order patched")
+		return models.Order{}, false
+	}
+	current, err := h.orderService.GetOrderItemById(id)
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
+		slog.Error("Failed", err.Error(), "no order patched")
+		return models.Order{}, false
+	}
+	if preconditionFailed(w, r, current) {
+		return models.Order{}, false
+	}
+	return current, true
+}
+
+// updateOrder saves the changed order, it answers the request when the
+// change is refused. Only active orders change.
+func (h *orderHandler) updateOrder(w http.ResponseWriter, r *http.Request, current, order models.Order) bool {
+	if current.Status != "active" {
 		RespondWithJson(w, ErrorResponse{Message: "order closed"}, http.StatusNotFound)
 		slog.Error("Failed", "no order", "order closed")
+		return false
+	}
+	_, err := h.orderService.PostOrUpdate(order, current.ID, actor(r))
+	if err != nil {
+		if respondVersionMismatch(w, r, err) {
+			return false
+		}
+		if err.Error() == "order is not active" {
+			RespondWithJson(w, ErrorResponse{Message: "order closed"}, http.StatusNotFound)
+			slog.Error("Failed", err.Error(), "order closed")
+			return false
+		}
+		if err.Error() == "not found" {
+			RespondWithJson(w, ErrorResponse{Message: "Order not found"}, http.StatusNotFound)
+			slog.Error("Failed", err.Error(), "no order posted")
+			return false
+		}
+		if err.Error() == "bad request" {
+			RespondWithJson(w, ErrorResponse{Message: "Bad request"}, http.StatusBadRequest)
+			slog.Error("Failed", err.Error(), "no order posted")
+			return false
+		}
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to update", err.Error(), "no order posted")
+		return false
+	}
+	return true
+}
+
+// sameCustomization compares two customizations as JSON values, so spacing
+// and member order do not matter.
+func sameCustomization(a, b json.RawMessage) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	var x, y interface{}
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// respondOrder answers a change with the order as it is now.
+func (h *orderHandler) respondOrder(w http.ResponseWriter, id int, status int) {
+	order, err := h.orderService.GetOrderItemById(id)
+	if err != nil {
+		w.WriteHeader(status)
+		slog.Error("Failed", err.Error(), "order changed but not returned")
 		return
 	}
-	slog.Info("order posted", "orderID", id)
+	respondWithETag(w, order, status)
 }
 
 func (h *orderHandler) DeleteOrderByID(w http.ResponseWriter, r *http.Request) {
@@ -111,8 +316,19 @@ func (h *orderHandler) DeleteOrderByID(w http.ResponseWriter, r *http.Request) {
 		slog.Error("Failed", err.Error(), "no order posted")
 		return
 	}
-	err = h.orderService.DeleteOrder(id)
+	stored := h.storedOrder(id)
+	if preconditionFailed(w, r, stored) {
+		return
+	}
+	var version int
+	if order, ok := stored.(models.Order); ok {
+		version = ifMatchVersion(r, order.Version)
+	}
+	err = h.orderService.DeleteOrder(id, version, actor(r))
 	if err != nil {
+		if respondVersionMismatch(w, r, err) {
+			return
+		}
 		if err.Error() == "not found" {
 			RespondWithJson(w, ErrorResponse{Message: "Order not found"}, http.StatusNotFound)
 			slog.Error("Failed", err.Error(), "no order posted")
@@ -123,8 +339,14 @@ func (h *orderHandler) DeleteOrderByID(w http.ResponseWriter, r *http.Request) {
 			slog.Error("Failed", err.Error(), "no order posted")
 			return
 		}
+		if err.Error() == "cannot delete an order with payments" || err.Error() == "cannot delete an inactive order" {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusConflict)
+			slog.Error("Failed", err.Error(), "no order deleted")
+			return
+		}
 		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
 		slog.Error("Failed", err.Error(), "no order posted")
+		return
 	}
 	slog.Info("order posted", "orderID", id)
 	w.WriteHeader(http.StatusNoContent)
@@ -142,6 +364,9 @@ func (h *orderHandler) GetOrderByID(w http.ResponseWriter, r *http.Request) {
 		slog.Error("Failed", err.Error(), "no order posted")
 		return
 	}
+	if notModified(w, r, orderItem) {
+		return
+	}
 	err = setBodyToJson(w, orderItem)
 	if err != nil {
 		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
@@ -152,8 +377,13 @@ func (h *orderHandler) GetOrderByID(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *orderHandler) GetAllOrders(w http.ResponseWriter, r *http.Request) {
-	orderItems, err := h.orderService.GetOrderItem()
+	orderItems, err := h.orderService.GetOrderItem(r.URL.Query().Get("channel"))
 	if err != nil {
+		if isChannelError(err) {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+			slog.Error("Failed", err.Error(), "no orders")
+			return
+		}
 		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
 		slog.Error("Failed", err.Error(), "no order posted")
 		return
@@ -183,12 +413,30 @@ func (h *orderHandler) PostCloseOrder(w http.ResponseWriter, r *http.Request) {
 		slog.Error("Failed", err.Error(), "no order posted")
 		return
 	}
-	if err := h.orderService.UpdateOrderStatus(id); err != nil {
+	var request models.CloseOrderRequest
+	if r.ContentLength != 0 {
+		if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+			slog.Error("Failed to decode", err.Error(), "order not closed")
+			return
+		}
+	}
+	if err := h.orderService.UpdateOrderStatus(id, request.Tip, actor(r)); err != nil {
 		if err.Error() == "order is already closed" {
 			RespondWithJson(w, ErrorResponse{Message: "Order is already closed"}, http.StatusNotFound)
 			slog.Error("Failed", err.Error(), "order is already closed")
 			return
 		}
+		if err.Error() == "tip cannot be negative" {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+			slog.Error("Failed", err.Error(), "order not closed")
+			return
+		}
+		if err.Error() == "order is not fully paid" || err.Error() == "order is cancelled" || err.Error() == "order is inactive" {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusConflict)
+			slog.Error("Failed", err.Error(), "order not closed")
+			return
+		}
 		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
 		slog.Error("Failed", err.Error(), "no order posted")
 		return
@@ -196,12 +444,114 @@ func (h *orderHandler) PostCloseOrder(w http.ResponseWriter, r *http.Request) {
 	slog.Info("order posted", "orderID", id)
 }
 
+func (h *orderHandler) PostCancelOrder(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 4 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no order cancelled")
+		return
+	}
+	id, err := strconv.Atoi(pathParam[2])
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid order id"}, http.StatusBadRequest)
+		slog.Error("Failed", err.Error(), "no order cancelled")
+		return
+	}
+	if err := h.orderService.CancelOrder(id, actor(r)); err != nil {
+		switch err.Error() {
+		case "order not found":
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
+		case "order is already cancelled", "refund the order's payments before cancelling":
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusConflict)
+		default:
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		}
+		slog.Error("Failed", err.Error(), "no order cancelled")
+		return
+	}
+	slog.Info("order cancelled", "orderID", id)
+}
+
+func (h *orderHandler) PostSplitOrder(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 4 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no order split")
+		return
+	}
+	id, err := strconv.Atoi(pathParam[2])
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid order id"}, http.StatusBadRequest)
+		slog.Error("Failed", err.Error(), "no order split")
+		return
+	}
+	var request models.SplitOrderRequest
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no order split")
+		return
+	}
+	children, err := h.orderService.SplitOrder(id, request, actor(r))
+	if err != nil {
+		switch err.Error() {
+		case "order not found":
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
+		case "only active orders can be split", "cannot split an order with payments":
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusConflict)
+		default:
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		}
+		slog.Error("Failed", err.Error(), "no order split")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = setBodyToJson(w, children); err != nil {
+		slog.Error("Failed to setBodyToJson", err.Error(), "order split")
+		return
+	}
+	slog.Info("order split", "orderID", id, "bills", len(children))
+}
+
+func (h *orderHandler) PostMergeOrders(w http.ResponseWriter, r *http.Request) {
+	var request models.MergeOrdersRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no orders merged")
+		return
+	}
+	order, err := h.orderService.MergeOrders(request, actor(r))
+	if err != nil {
+		switch err.Error() {
+		case "order not found":
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
+		case "only active orders can be merged", "cannot merge an order with payments into another":
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusConflict)
+		default:
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		}
+		slog.Error("Failed", err.Error(), "no orders merged")
+		return
+	}
+	if err = setBodyToJson(w, order); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "orders merged")
+		return
+	}
+	slog.Info("orders merged", "orderID", order.ID)
+}
+
 func (h *orderHandler) GetNumberOfOrderedItems(w http.ResponseWriter, r *http.Request) {
 	startDate := r.URL.Query().Get("startDate")
 	endDate := r.URL.Query().Get("endDate")
+	channel := r.URL.Query().Get("channel")
 
-	items, err := h.orderService.GetNumberOfOrderedItems(startDate, endDate)
+	items, err := h.orderService.GetNumberOfOrderedItems(startDate, endDate, channel)
 	if err != nil {
+		if isChannelError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -219,21 +569,26 @@ func (h *orderHandler) GetOrderedItemsByPeriod(w http.ResponseWriter, r *http.Re
 	period := r.URL.Query().Get("period")
 	month := r.URL.Query().Get("month")
 	year := r.URL.Query().Get("year")
+	channel := r.URL.Query().Get("channel")
 
 	var result interface{}
 	var err error
 
 	switch period {
 	case "day":
-		result, err = h.orderService.GetOrdersGroupedByDay(month)
+		result, err = h.orderService.GetOrdersGroupedByDay(month, channel)
 	case "month":
-		result, err = h.orderService.GetOrdersGroupedByMonth(year)
+		result, err = h.orderService.GetOrdersGroupedByMonth(year, channel)
 	default:
 		http.Error(w, "Invalid period value", http.StatusBadRequest)
 		return
 	}
 
 	if err != nil {
+		if isChannelError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -253,7 +608,10 @@ func (h *orderHandler) BatchProcessOrders(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	resp, err := h.orderService.ProcessBatchOrders(req.Orders)
+	for i := range req.Orders {
+		req.Orders[i].EmployeeID = actingEmployee(r)
+	}
+	resp, err := h.orderService.ProcessBatchOrders(req.Orders, actor(r))
 	if err != nil {
 		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
 		slog.Error("Failed", err.Error(), "no order posted")
@@ -267,3 +625,8 @@ func (h *orderHandler) BatchProcessOrders(w http.ResponseWriter, r *http.Request
 		return
 	}
 }
+
+// isChannelError tells an unknown channel filter apart from failures.
+func isChannelError(err error) bool {
+	return strings.HasPrefix(err.Error(), "channel must be")
+}
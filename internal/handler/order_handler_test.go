@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPostOrderLineRefusesQuantity(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "zero", body: `{"menu_item_id": "latte", "quantity": 0}`},
+		{name: "negative", body: `{"menu_item_id": "latte", "quantity": -2}`},
+		{name: "missing", body: `{"menu_item_id": "latte"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the order service is never reached
+			h := NewOrderHandler(nil)
+			w := httptest.NewRecorder()
+			h.PostOrderLine(w, httptest.NewRequest(http.MethodPost, "/orders/1/lines", strings.NewReader(tt.body)))
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+type PackagingHandler interface {
+	GetRules(w http.ResponseWriter, r *http.Request)
+	PutRules(w http.ResponseWriter, r *http.Request)
+}
+
+type packagingHandler struct {
+	packagingService service.PackagingService
+}
+
+func NewPackagingHandler(packagingService service.PackagingService) *packagingHandler {
+	return &packagingHandler{packagingService: packagingService}
+}
+
+func (h *packagingHandler) GetRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.packagingService.GetRules()
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to GetRules", err.Error(), "no packaging rules")
+		return
+	}
+	if err = setBodyToJson(w, rules); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no packaging rules")
+		return
+	}
+	slog.Info("packaging rules got", "count", len(rules))
+}
+
+func (h *packagingHandler) PutRules(w http.ResponseWriter, r *http.Request) {
+	var rules []models.PackagingRule
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no packaging rules updated")
+		return
+	}
+	if err := h.packagingService.UpdateRules(rules); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to UpdateRules", err.Error(), "no packaging rules updated")
+		return
+	}
+	slog.Info("packaging rules updated", "count", len(rules))
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+)
+
+const contentTypeMergePatch = "application/merge-patch+json"
+
+// applyMergePatch applies the JSON Merge Patch (RFC 7396) in the request body
+// to the representation of current and decodes the result into target. Members
+// set to null are removed, arrays are replaced as a whole.
+func applyMergePatch(r *http.Request, current interface{}, target interface{}) error {
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != contentTypeMergePatch && mediaType != "application/json") {
+			return errors.New("Content-Type must be " + contentTypeMergePatch)
+		}
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	patch, err := decodeJSONValue(body)
+	if err != nil {
+		return errors.New("patch is not valid JSON")
+	}
+	js, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	document, err := decodeJSONValue(js)
+	if err != nil {
+		return err
+	}
+	merged, err := json.Marshal(mergePatch(document, patch))
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(merged, target); err != nil {
+		return errors.New("patched document is invalid: " + err.Error())
+	}
+	return nil
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergePatch(targetObject[name], value)
+	}
+	return targetObject
+}
+
+// decodeJSONValue keeps numbers as they were written, so prices and
+// quantities are not rounded through float64 on the way.
+func decodeJSONValue(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// respondPatchError answers a patch that could not be applied, 415 for a
+// wrong media type and 400 otherwise.
+func respondPatchError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if err.Error() == "Content-Type must be "+contentTypeMergePatch {
+		status = http.StatusUnsupportedMediaType
+	}
+	RespondWithJson(w, ErrorResponse{Message: err.Error()}, status)
+}
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+	current := models.Order{
+		ID:           1,
+		CustomerName: "Ann",
+		Channel:      "dine_in",
+		Priority:     2,
+		ScheduledFor: "2026-10-19T09:00:00Z",
+		Items: []models.OrderItem{
+			{LineID: 10, MenuItemID: "latte", Quantity: 1, Customization: json.RawMessage(`{"remove":["milk"]}`)},
+			{LineID: 11, MenuItemID: "croissant", Quantity: 2},
+		},
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		want        func(order *models.Order)
+		wantErr     string
+		status      int
+	}{
+		{
+			name:        "named fields change",
+			contentType: contentTypeMergePatch,
+			patch:       `{"customer_name": "Bob", "priority": 5}`,
+			want: func(order *models.Order) {
+				order.CustomerName = "Bob"
+				order.Priority = 5
+			},
+		},
+		{
+			name:        "null clears a field",
+			contentType: contentTypeMergePatch,
+			patch:       `{"scheduled_for": null}`,
+			want: func(order *models.Order) {
+				order.ScheduledFor = ""
+			},
+		},
+		{
+			name:        "items are replaced as a whole",
+			contentType: contentTypeMergePatch,
+			patch:       `{"items": [{"line_id": 11, "menu_item_id": "croissant", "quantity": 3}]}`,
+			want: func(order *models.Order) {
+				order.Items = []models.OrderItem{{LineID: 11, MenuItemID: "croissant", Quantity: 3}}
+			},
+		},
+		{
+			name:        "plain JSON is accepted",
+			contentType: "application/json; charset=utf-8",
+			patch:       `{"channel": "takeaway"}`,
+			want: func(order *models.Order) {
+				order.Channel = "takeaway"
+			},
+		},
+		{
+			name:  "no Content-Type",
+			patch: `{}`,
+			want:  func(order *models.Order) {},
+		},
+		{
+			name:        "other media type",
+			contentType: "text/plain",
+			patch:       `{"customer_name": "Bob"}`,
+			wantErr:     "Content-Type must be " + contentTypeMergePatch,
+			status:      http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "invalid JSON",
+			contentType: contentTypeMergePatch,
+			patch:       `{"customer_name": `,
+			wantErr:     "patch is not valid JSON",
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "wrong type for a field",
+			contentType: contentTypeMergePatch,
+			patch:       `{"priority": "high"}`,
+			wantErr:     "patched document is invalid: json: cannot unmarshal string into Go struct field Order.priority of type int",
+			status:      http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/orders/1", strings.NewReader(tt.patch))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			var got models.Order
+			err := applyMergePatch(r, current, &got)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("applyMergePatch() error = %v, want %q", err, tt.wantErr)
+				}
+				w := httptest.NewRecorder()
+				respondPatchError(w, err)
+				if w.Code != tt.status {
+					t.Errorf("status %d, want %d", w.Code, tt.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyMergePatch() error = %v", err)
+			}
+			want := current
+			want.Items = append([]models.OrderItem{}, current.Items...)
+			tt.want(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("applyMergePatch() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		patch  string
+		want   string
+	}{
+		{name: "nested member", target: `{"a": {"b": 1, "c": 2}}`, patch: `{"a": {"b": 3}}`, want: `{"a": {"b": 3, "c": 2}}`},
+		{name: "null removes", target: `{"a": 1, "b": 2}`, patch: `{"a": null}`, want: `{"b": 2}`},
+		{name: "array replaced", target: `{"a": [1, 2]}`, patch: `{"a": [3]}`, want: `{"a": [3]}`},
+		{name: "object over a scalar", target: `{"a": 1}`, patch: `{"a": {"b": null, "c": 1}}`, want: `{"a": {"c": 1}}`},
+		{name: "non-object patch replaces", target: `{"a": 1}`, patch: `[1]`, want: `[1]`},
+		{name: "numbers keep their digits", target: `{"price": 1}`, patch: `{"price": 4.10}`, want: `{"price": 4.10}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := decodeJSONValue([]byte(tt.target))
+			if err != nil {
+				t.Fatal(err)
+			}
+			patch, err := decodeJSONValue([]byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(mergePatch(target, patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := decodeJSONValue([]byte(tt.want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantJSON, _ := json.Marshal(want)
+			if string(got) != string(wantJSON) {
+				t.Errorf("mergePatch() = %s, want %s", got, wantJSON)
+			}
+		})
+	}
+}
+
+func TestSameCustomization(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{name: "none", want: true},
+		{name: "spacing and member order", a: `{"remove":["milk"],"add":[]}`, b: `{ "add": [], "remove": ["milk"] }`, want: true},
+		{name: "one side only", a: `{"remove":["milk"]}`},
+		{name: "different", a: `{"remove":["milk"]}`, b: `{"remove":["sugar"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a, b json.RawMessage
+			if tt.a != "" {
+				a = json.RawMessage(tt.a)
+			}
+			if tt.b != "" {
+				b = json.RawMessage(tt.b)
+			}
+			if got := sameCustomization(a, b); got != tt.want {
+				t.Errorf("sameCustomization() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
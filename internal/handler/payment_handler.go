@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+type PaymentHandler interface {
+	PostPayment(w http.ResponseWriter, r *http.Request)
+	GetPayments(w http.ResponseWriter, r *http.Request)
+	PostRefund(w http.ResponseWriter, r *http.Request)
+}
+
+type paymentHandler struct {
+	paymentService service.PaymentService
+}
+
+func NewPaymentHandler(paymentService service.PaymentService) *paymentHandler {
+	return &paymentHandler{paymentService: paymentService}
+}
+
+func (h *paymentHandler) PostPayment(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 4 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no payment taken")
+		return
+	}
+	id, err := strconv.Atoi(pathParam[2])
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid order id"}, http.StatusBadRequest)
+		slog.Error("Failed", err.Error(), "no payment taken")
+		return
+	}
+	var payment models.Payment
+	if err = json.NewDecoder(r.Body).Decode(&payment); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no payment taken")
+		return
+	}
+	payment, err = h.paymentService.AddPayment(id, payment)
+	if err != nil {
+		respondPaymentError(w, err)
+		slog.Error("Failed to AddPayment", err.Error(), "no payment taken")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = setBodyToJson(w, payment); err != nil {
+		slog.Error("Failed to setBodyToJson", err.Error(), "payment taken")
+		return
+	}
+	slog.Info("payment taken", "orderID", id, "tender", payment.Tender, "amount", payment.Amount)
+}
+
+func (h *paymentHandler) GetPayments(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 4 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no payments")
+		return
+	}
+	id, err := strconv.Atoi(pathParam[2])
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid order id"}, http.StatusBadRequest)
+		slog.Error("Failed", err.Error(), "no payments")
+		return
+	}
+	payments, err := h.paymentService.GetOrderPayments(id)
+	if err != nil {
+		respondPaymentError(w, err)
+		slog.Error("Failed to GetOrderPayments", err.Error(), "no payments")
+		return
+	}
+	if err = setBodyToJson(w, payments); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no payments")
+		return
+	}
+	slog.Info("payments got", "orderID", id)
+}
+
+func (h *paymentHandler) PostRefund(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 4 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no refund made")
+		return
+	}
+	id, err := strconv.Atoi(pathParam[2])
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid order id"}, http.StatusBadRequest)
+		slog.Error("Failed", err.Error(), "no refund made")
+		return
+	}
+	var request models.RefundRequest
+	if r.ContentLength != 0 {
+		if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+			RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+			slog.Error("Failed to decode", err.Error(), "no refund made")
+			return
+		}
+	}
+	refund, err := h.paymentService.RefundOrder(id, request)
+	if err != nil {
+		respondPaymentError(w, err)
+		slog.Error("Failed to RefundOrder", err.Error(), "no refund made")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = setBodyToJson(w, refund); err != nil {
+		slog.Error("Failed to setBodyToJson", err.Error(), "refund made")
+		return
+	}
+	slog.Info("refund made", "orderID", id, "amount", refund.Amount)
+}
+
+func respondPaymentError(w http.ResponseWriter, err error) {
+	switch {
+	case err.Error() == "order not found", err.Error() == "gift card not found":
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
+	case err.Error() == "order is already paid", err.Error() == "only active orders can be paid",
+		err.Error() == "nothing left to refund", err.Error() == "gift card has no balance left":
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusConflict)
+	case strings.HasPrefix(err.Error(), "card declined"):
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusPaymentRequired)
+	case strings.HasPrefix(err.Error(), "card refund failed"):
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadGateway)
+	default:
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+type PickupHandler interface {
+	GetSlots(w http.ResponseWriter, r *http.Request)
+	GetSettings(w http.ResponseWriter, r *http.Request)
+	PutSettings(w http.ResponseWriter, r *http.Request)
+}
+
+type pickupHandler struct {
+	pickupService service.PickupService
+}
+
+func NewPickupHandler(pickupService service.PickupService) *pickupHandler {
+	return &pickupHandler{pickupService: pickupService}
+}
+
+func (h *pickupHandler) GetSlots(w http.ResponseWriter, r *http.Request) {
+	date := r.URL.Query().Get("date")
+	slots, err := h.pickupService.GetSlots(date)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err.Error() == "date must be YYYY-MM-DD" {
+			status = http.StatusBadRequest
+		}
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, status)
+		slog.Error("Failed to GetSlots", err.Error(), "no pickup slots")
+		return
+	}
+	if err = setBodyToJson(w, slots); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no pickup slots")
+		return
+	}
+	slog.Info("pickup slots got", "date", date, "count", len(slots))
+}
+
+func (h *pickupHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.pickupService.GetSettings()
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to GetSettings", err.Error(), "no pickup settings")
+		return
+	}
+	if err = setBodyToJson(w, settings); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no pickup settings")
+		return
+	}
+	slog.Info("pickup settings got")
+}
+
+func (h *pickupHandler) PutSettings(w http.ResponseWriter, r *http.Request) {
+	var settings models.PickupSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no pickup settings updated")
+		return
+	}
+	if err := h.pickupService.UpdateSettings(settings); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to UpdateSettings", err.Error(), "no pickup settings updated")
+		return
+	}
+	slog.Info("pickup settings updated", "slot_capacity", settings.SlotCapacity, "lead_minutes", settings.LeadMinutes)
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+type PrepHandler interface {
+	GetQueue(w http.ResponseWriter, r *http.Request)
+	PostLineAction(w http.ResponseWriter, r *http.Request)
+	GetStations(w http.ResponseWriter, r *http.Request)
+	PutStations(w http.ResponseWriter, r *http.Request)
+}
+
+type prepHandler struct {
+	prepService service.PrepService
+}
+
+func NewPrepHandler(prepService service.PrepService) *prepHandler {
+	return &prepHandler{prepService: prepService}
+}
+
+func (h *prepHandler) GetQueue(w http.ResponseWriter, r *http.Request) {
+	station := r.URL.Query().Get("station")
+	lines, err := h.prepService.GetQueue(station, r.URL.Query().Get("sort"))
+	if err != nil {
+		respondPrepError(w, err)
+		return
+	}
+	if err = setBodyToJson(w, lines); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no queue")
+		return
+	}
+	slog.Info("queue got", "station", station, "count", len(lines))
+}
+
+// PostLineAction handles /queue/{id}/start, /ready and /handoff.
+func (h *prepHandler) PostLineAction(w http.ResponseWriter, r *http.Request) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != 4 {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no line updated")
+		return
+	}
+	id, err := strconv.Atoi(pathParam[2])
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid line id"}, http.StatusBadRequest)
+		slog.Error("Failed", err.Error(), "no line updated")
+		return
+	}
+	line, err := h.prepService.AdvanceLine(id, pathParam[3], actingEmployee(r))
+	if err != nil {
+		respondPrepError(w, err)
+		return
+	}
+	if err = setBodyToJson(w, line); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "line updated")
+		return
+	}
+	slog.Info("line updated", "lineID", id, "prep_status", line.PrepStatus)
+}
+
+func (h *prepHandler) GetStations(w http.ResponseWriter, r *http.Request) {
+	routes, err := h.prepService.GetRoutes()
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to GetRoutes", err.Error(), "no station routes")
+		return
+	}
+	if err = setBodyToJson(w, routes); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no station routes")
+		return
+	}
+	slog.Info("station routes got", "count", len(routes))
+}
+
+func (h *prepHandler) PutStations(w http.ResponseWriter, r *http.Request) {
+	var routes []models.StationRoute
+	if err := json.NewDecoder(r.Body).Decode(&routes); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no station routes updated")
+		return
+	}
+	if err := h.prepService.UpdateRoutes(routes); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to UpdateRoutes", err.Error(), "no station routes updated")
+		return
+	}
+	slog.Info("station routes updated", "count", len(routes))
+}
+
+func respondPrepError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case "line not found":
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
+	case "line is not queued", "line is not in progress", "line is not ready":
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusConflict)
+	case "unknown action", "sort must be fifo or priority":
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+	default:
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+	}
+	slog.Error("Failed", err.Error(), "prep request failed")
+}
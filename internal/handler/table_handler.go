@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+type TableHandler interface {
+	PostTable(w http.ResponseWriter, r *http.Request)
+	GetAllTables(w http.ResponseWriter, r *http.Request)
+	GetTableByID(w http.ResponseWriter, r *http.Request)
+	PutTable(w http.ResponseWriter, r *http.Request)
+	PutTableStatus(w http.ResponseWriter, r *http.Request)
+	PostTransferTab(w http.ResponseWriter, r *http.Request)
+}
+
+type tableHandler struct {
+	tableService service.TableService
+}
+
+func NewTableHandler(tableService service.TableService) *tableHandler {
+	return &tableHandler{tableService: tableService}
+}
+
+func (h *tableHandler) PostTable(w http.ResponseWriter, r *http.Request) {
+	var table models.Table
+	if err := json.NewDecoder(r.Body).Decode(&table); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no table posted")
+		return
+	}
+	table, err := h.tableService.AddTable(table)
+	if err != nil {
+		respondTableError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = setBodyToJson(w, table); err != nil {
+		slog.Error("Failed to setBodyToJson", err.Error(), "table posted")
+		return
+	}
+	slog.Info("table posted", "tableID", table.ID)
+}
+
+func (h *tableHandler) GetAllTables(w http.ResponseWriter, r *http.Request) {
+	tables, err := h.tableService.GetTables()
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to GetTables", err.Error(), "no tables")
+		return
+	}
+	if err = setBodyToJson(w, tables); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no tables")
+		return
+	}
+	slog.Info("tables got", "count", len(tables))
+}
+
+func (h *tableHandler) GetTableByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := tableIDFromPath(w, r, 3)
+	if !ok {
+		return
+	}
+	table, err := h.tableService.GetTable(id)
+	if err != nil {
+		respondTableError(w, err)
+		return
+	}
+	if err = setBodyToJson(w, table); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no table")
+		return
+	}
+	slog.Info("table got", "tableID", id)
+}
+
+func (h *tableHandler) PutTable(w http.ResponseWriter, r *http.Request) {
+	id, ok := tableIDFromPath(w, r, 3)
+	if !ok {
+		return
+	}
+	var table models.Table
+	if err := json.NewDecoder(r.Body).Decode(&table); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no table updated")
+		return
+	}
+	table.ID = id
+	table, err := h.tableService.UpdateTable(table)
+	if err != nil {
+		respondTableError(w, err)
+		return
+	}
+	if err = setBodyToJson(w, table); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "table updated")
+		return
+	}
+	slog.Info("table updated", "tableID", id)
+}
+
+func (h *tableHandler) PutTableStatus(w http.ResponseWriter, r *http.Request) {
+	id, ok := tableIDFromPath(w, r, 4)
+	if !ok {
+		return
+	}
+	var request models.TableStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no table status updated")
+		return
+	}
+	table, err := h.tableService.SetStatus(id, request.Status)
+	if err != nil {
+		respondTableError(w, err)
+		return
+	}
+	if err = setBodyToJson(w, table); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "table status updated")
+		return
+	}
+	slog.Info("table status updated", "tableID", id, "status", request.Status)
+}
+
+func (h *tableHandler) PostTransferTab(w http.ResponseWriter, r *http.Request) {
+	id, ok := tableIDFromPath(w, r, 4)
+	if !ok {
+		return
+	}
+	var request models.TransferTabRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no tab transferred")
+		return
+	}
+	table, err := h.tableService.TransferTab(id, request.TableID)
+	if err != nil {
+		respondTableError(w, err)
+		return
+	}
+	if err = setBodyToJson(w, table); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "tab transferred")
+		return
+	}
+	slog.Info("tab transferred", "from", id, "to", request.TableID)
+}
+
+// tableIDFromPath reads the id from /tables/{id} when parts is 3 and from
+// /tables/{id}/action when parts is 4.
+func tableIDFromPath(w http.ResponseWriter, r *http.Request, parts int) (int, bool) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != parts {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no table")
+		return 0, false
+	}
+	id, err := strconv.Atoi(pathParam[2])
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid table id"}, http.StatusBadRequest)
+		slog.Error("Failed", err.Error(), "no table")
+		return 0, false
+	}
+	return id, true
+}
+
+func respondTableError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case "table not found":
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
+	case "table number already in use", "table has an open tab", "table has no open tab", "target table is not free":
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusConflict)
+	default:
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+	}
+	slog.Error("Failed", err.Error(), "table request failed")
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+type TipHandler interface {
+	GetTipReport(w http.ResponseWriter, r *http.Request)
+}
+
+type tipHandler struct {
+	tipService service.TipService
+}
+
+func NewTipHandler(tipService service.TipService) *tipHandler {
+	return &tipHandler{tipService: tipService}
+}
+
+func (h *tipHandler) GetTipReport(w http.ResponseWriter, r *http.Request) {
+	staff, err := parseStaffHours(r.URL.Query().Get("staff"))
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed", err.Error(), "no tip report")
+		return
+	}
+	report, err := h.tipService.GetTipReport(r.URL.Query().Get("date"), r.URL.Query().Get("rule"), staff)
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to GetTipReport", err.Error(), "no tip report")
+		return
+	}
+	if err = setBodyToJson(w, report); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no tip report")
+		return
+	}
+	slog.Info("tip report got", "date", report.Date, "total", report.TotalTips)
+}
+
+// parseStaffHours reads staff given as "anna:6,ben:4.5", hours are optional.
+func parseStaffHours(value string) ([]models.TipShare, error) {
+	var staff []models.TipShare
+	if value == "" {
+		return staff, nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		name, hours, hasHours := strings.Cut(strings.TrimSpace(entry), ":")
+		member := models.TipShare{Staff: name}
+		if hasHours {
+			parsed, err := strconv.ParseFloat(hours, 64)
+			if err != nil {
+				return nil, err
+			}
+			member.Hours = parsed
+		}
+		staff = append(staff, member)
+	}
+	return staff, nil
+}
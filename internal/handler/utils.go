@@ -19,6 +19,12 @@ type ErrorResponse struct {
 	Message string `json:"Error"`
 }
 
+type IngredientInUseResponse struct {
+	Message   string   `json:"Error"`
+	MenuItems []string `json:"menu_items"`
+	PrepItems []string `json:"prep_items,omitempty"`
+}
+
 func RespondWithJson(w http.ResponseWriter, errorResponse ErrorResponse, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
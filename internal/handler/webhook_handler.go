@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hot-coffee/internal/service"
+	"hot-coffee/models"
+)
+
+type WebhookHandler interface {
+	PostWebhook(w http.ResponseWriter, r *http.Request)
+	GetAllWebhooks(w http.ResponseWriter, r *http.Request)
+	GetWebhookByID(w http.ResponseWriter, r *http.Request)
+	PutWebhook(w http.ResponseWriter, r *http.Request)
+	DeleteWebhook(w http.ResponseWriter, r *http.Request)
+	GetDeliveries(w http.ResponseWriter, r *http.Request)
+}
+
+type webhookHandler struct {
+	webhookService service.WebhookService
+}
+
+func NewWebhookHandler(webhookService service.WebhookService) *webhookHandler {
+	return &webhookHandler{webhookService: webhookService}
+}
+
+func (h *webhookHandler) PostWebhook(w http.ResponseWriter, r *http.Request) {
+	var webhook models.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no webhook created")
+		return
+	}
+	webhook, err := h.webhookService.CreateWebhook(webhook)
+	if err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = setBodyToJson(w, webhook); err != nil {
+		slog.Error("Failed to setBodyToJson", err.Error(), "webhook created")
+		return
+	}
+	slog.Info("webhook created", "webhookID", webhook.ID)
+}
+
+func (h *webhookHandler) GetAllWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.webhookService.GetWebhooks()
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to GetWebhooks", err.Error(), "no webhooks")
+		return
+	}
+	if err = setBodyToJson(w, webhooks); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no webhooks")
+		return
+	}
+	slog.Info("webhooks got", "count", len(webhooks))
+}
+
+func (h *webhookHandler) GetWebhookByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookIDFromPath(w, r, 3)
+	if !ok {
+		return
+	}
+	webhook, err := h.webhookService.GetWebhook(id)
+	if err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+	if err = setBodyToJson(w, webhook); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no webhook")
+		return
+	}
+	slog.Info("webhook got", "webhookID", id)
+}
+
+func (h *webhookHandler) PutWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookIDFromPath(w, r, 3)
+	if !ok {
+		return
+	}
+	var webhook models.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+		slog.Error("Failed to decode", err.Error(), "no webhook updated")
+		return
+	}
+	webhook.ID = id
+	webhook, err := h.webhookService.UpdateWebhook(webhook)
+	if err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+	if err = setBodyToJson(w, webhook); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "webhook updated")
+		return
+	}
+	slog.Info("webhook updated", "webhookID", id)
+}
+
+func (h *webhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookIDFromPath(w, r, 3)
+	if !ok {
+		return
+	}
+	if err := h.webhookService.DeleteWebhook(id); err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+	slog.Info("webhook deleted", "webhookID", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *webhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookIDFromPath(w, r, 4)
+	if !ok {
+		return
+	}
+	deliveries, err := h.webhookService.GetDeliveries(id, r.URL.Query().Get("status"))
+	if err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+	if err = setBodyToJson(w, deliveries); err != nil {
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+		slog.Error("Failed to setBodyToJson", err.Error(), "no deliveries")
+		return
+	}
+	slog.Info("webhook deliveries got", "webhookID", id, "count", len(deliveries))
+}
+
+func webhookIDFromPath(w http.ResponseWriter, r *http.Request, parts int) (int, bool) {
+	pathParam := strings.Split(r.URL.Path, "/")
+	if len(pathParam) != parts {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid path"}, http.StatusBadRequest)
+		slog.Error("Failed", "wrong params", "no webhook")
+		return 0, false
+	}
+	id, err := strconv.Atoi(pathParam[2])
+	if err != nil {
+		RespondWithJson(w, ErrorResponse{Message: "Invalid webhook id"}, http.StatusBadRequest)
+		slog.Error("Failed", err.Error(), "no webhook")
+		return 0, false
+	}
+	return id, true
+}
+
+func respondWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case err.Error() == "webhook not found":
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusNotFound)
+	case err.Error() == "url must be an http or https URL",
+		err.Error() == "at least one event type is required",
+		err.Error() == "secret must be at most 100 characters",
+		err.Error() == "status must be pending, delivered or failed",
+		strings.HasPrefix(err.Error(), "event type must be one of"):
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusBadRequest)
+	default:
+		RespondWithJson(w, ErrorResponse{Message: err.Error()}, http.StatusInternalServerError)
+	}
+	slog.Error("Failed", err.Error(), "webhook request failed")
+}
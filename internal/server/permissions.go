@@ -0,0 +1,110 @@
+package server
+
+import (
+	"crypto/rand"
+	"log/slog"
+	"os"
+
+	"hot-coffee/internal/handler"
+)
+
+// routePermissions is the lowest role allowed on each route. Routes that
+// are missing need a manager.
+var routePermissions = map[string]string{
+	"POST /auth/login":           handler.PermissionPublic,
+	"GET /auth/me":               "barista",
+	"POST /auth/api-keys":        "manager",
+	"GET /auth/api-keys":         "manager",
+	"DELETE /auth/api-keys/{id}": "manager",
+
+	"POST /orders":                        "barista",
+	"GET /orders":                         "barista",
+	"GET /orders/{id}":                    "barista",
+	"PUT /orders/{id}":                    "barista",
+	"PATCH /orders/{id}":                  "barista",
+	"POST /orders/{id}/items":             "barista",
+	"DELETE /orders/{id}/items/{line_id}": "barista",
+	"DELETE /orders/{id}":                 "shift_lead",
+	"POST /orders/{id}/close":             "barista",
+	"POST /orders/{id}/cancel":            "shift_lead",
+	"POST /orders/{id}/split":             "barista",
+	"POST /orders/{id}/payments":          "barista",
+	"GET /orders/{id}/payments":           "barista",
+	"POST /orders/{id}/refunds":           "shift_lead",
+	"GET /orders/numberOfOrderedItems":    "shift_lead",
+	"POST /orders/batch-process":          "barista",
+	"POST /orders/merge":                  "barista",
+	"GET /orders/slots":                   "barista",
+	"GET /orders/slots/settings":          "barista",
+
+	"GET /inventory":               "barista",
+	"GET /inventory/{id}":          "barista",
+	"GET /inventory/{id}/recipe":   "barista",
+	"POST /inventory/{id}/produce": "barista",
+	"GET /inventory/getLeftOvers":  "barista",
+	"GET /packaging/rules":         "barista",
+
+	"GET /menu":                handler.PermissionPublic,
+	"GET /menu/{id}":           handler.PermissionPublic,
+	"GET /menu/{id}/nutrition": handler.PermissionPublic,
+	"GET /menu/nutrition.csv":  handler.PermissionPublic,
+
+	"POST /customers":             "barista",
+	"GET /customers":              "barista",
+	"GET /customers/{id}":         "barista",
+	"PUT /customers/{id}":         "barista",
+	"POST /customers/merge":       "shift_lead",
+	"GET /customers/{id}/orders":  "barista",
+	"GET /customers/{id}/loyalty": "barista",
+	"GET /loyalty/rules":          "barista",
+
+	"POST /giftcards":                    "manager",
+	"GET /giftcards/{code}":              "barista",
+	"POST /giftcards/{code}/load":        "manager",
+	"GET /giftcards/{code}/transactions": "barista",
+
+	"GET /tables":                "barista",
+	"GET /tables/{id}":           "barista",
+	"PUT /tables/{id}/status":    "barista",
+	"POST /tables/{id}/transfer": "barista",
+
+	"GET /queue":               "barista",
+	"POST /queue/{id}/start":   "barista",
+	"POST /queue/{id}/ready":   "barista",
+	"POST /queue/{id}/handoff": "barista",
+	"GET /queue/stations":      "barista",
+
+	"GET /events": "barista",
+
+	"POST /employees":                handler.PermissionBootstrap,
+	"POST /employees/{id}/clock-in":  "barista",
+	"POST /employees/{id}/clock-out": "barista",
+	"GET /employees/{id}/shifts":     "barista",
+
+	"GET /reports/search":               "shift_lead",
+	"GET /reports/orderedItemsByPeriod": "shift_lead",
+	"GET /reports/total-sales":          "shift_lead",
+	"GET /reports/popular-items":        "shift_lead",
+	"GET /reports/tips":                 "shift_lead",
+}
+
+func permissionFor(pattern string) string {
+	if permission, found := routePermissions[pattern]; found {
+		return permission
+	}
+	return "manager"
+}
+
+// authSecret signs staff tokens. Without AUTH_SECRET a random secret is
+// used, so tokens stop working when the server restarts.
+func authSecret() []byte {
+	if secret := os.Getenv("AUTH_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	slog.Warn("AUTH_SECRET is not set, tokens will not survive a restart")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return secret
+}
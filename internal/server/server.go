@@ -8,21 +8,40 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"hot-coffee/internal/dal"
 	"hot-coffee/internal/handler"
 	"hot-coffee/internal/service"
 )
 
+const (
+	// eventHistory is how many recent events a reconnecting client can catch up on.
+	eventHistory = 1000
+	// outboxInterval is how often the outbox is checked for events that
+	// were not dispatched right away.
+	outboxInterval = time.Second
+	// webhookInterval is how often due webhook deliveries are sent.
+	webhookInterval = 5 * time.Second
+	webhookTimeout  = 10 * time.Second
+	// idempotencyPurgeInterval is how often expired idempotency keys are removed.
+	idempotencyPurgeInterval = time.Hour
+)
+
 func StartTheCafe() {
 	port := flag.Int("port", 8081, "The server port")
 	dir := flag.String("dir", "data", "The directory to serve")
 	help := flag.Bool("help", false, "Show help")
+	idempotencyTTL := flag.Duration("idempotency-ttl", 24*time.Hour, "How long idempotency keys are kept")
 	flag.Parse()
 	if *port <= 0 || *port > 65535 {
 		fmt.Println("Invalid port")
 		os.Exit(1)
 	}
+	if *idempotencyTTL <= 0 {
+		fmt.Println("Invalid idempotency TTL")
+		os.Exit(1)
+	}
 	if *help {
 		printHelpUsage()
 		os.Exit(0)
@@ -33,56 +52,207 @@ func StartTheCafe() {
 	}
 	if os.IsExist(os.ErrNotExist) {
 	}
+	idempotencyService := service.NewIdempotencyService(dal.NewIdempotencyRepo(""), *idempotencyTTL)
+	idempotencyService.Start(idempotencyPurgeInterval)
+
+	auditRepo := dal.NewAuditRepo("")
+	auditService := service.NewAuditService(auditRepo)
+	auditHandler := handler.NewAuditHandler(auditService)
+
+	eventBus := service.NewEventBus(eventHistory)
+	eventHandler := handler.NewEventHandler(eventBus)
+
+	webhookRepo := dal.NewWebhookRepo("")
+	webhookService := service.NewWebhookService(webhookRepo, &http.Client{Timeout: webhookTimeout})
+	webhookHandler := handler.NewWebhookHandler(webhookService)
+	webhookService.Start(webhookInterval)
+
+	outboxDispatcher := service.NewOutboxDispatcher(dal.NewOutboxRepo(""))
+	outboxDispatcher.Register("sse", eventBus)
+	outboxDispatcher.Register("webhooks", webhookService)
+	outboxDispatcher.Register("log", service.NewLogSink())
+	outboxDispatcher.Start(outboxInterval)
+
 	inventoryRepo := dal.NewInventoryRepo(filepath.Join(*dir, "inventory.json"))
-	inventoryService := service.NewInventoryService(inventoryRepo)
+	stockAlerts := service.NewStockAlerts(inventoryRepo, outboxDispatcher)
+	inventoryService := service.NewInventoryService(inventoryRepo, outboxDispatcher, stockAlerts)
 	inventoryHandler := handler.NewInventoryHandler(inventoryService)
 
 	menuRepo := dal.NewMenuRepo(filepath.Join(*dir, "menu_items.json"))
-	menuService := service.NewMenuService(menuRepo)
+	menuService := service.NewMenuService(menuRepo, inventoryRepo)
 	menuHandler := handler.NewMenuHandler(menuService)
 
+	customerRepo := dal.NewCustomerRepo("")
+	loyaltyRepo := dal.NewLoyaltyRepo("")
+	giftCardRepo := dal.NewGiftCardRepo("")
+	paymentRepo := dal.NewPaymentRepo("")
+	tipRepo := dal.NewTipRepo("")
+	employeeRepo := dal.NewEmployeeRepo("")
+	shiftRepo := dal.NewShiftRepo("")
+	apiKeyRepo := dal.NewAPIKeyRepo("")
+	tableRepo := dal.NewTableRepo("")
+	pickupRepo := dal.NewPickupRepo("")
+
 	orderRepo := dal.NewOrderRepo(filepath.Join(*dir, "orders.json"))
-	orderService := service.NewOrderService(orderRepo, menuRepo, inventoryRepo)
+	orderService := service.NewOrderService(orderRepo, menuRepo, inventoryRepo, customerRepo, loyaltyRepo, paymentRepo, tableRepo, pickupRepo, outboxDispatcher, stockAlerts)
 	orderHandler := handler.NewOrderHandler(orderService)
 
+	pickupService := service.NewPickupService(pickupRepo)
+	pickupHandler := handler.NewPickupHandler(pickupService)
+
+	customerService := service.NewCustomerService(customerRepo, orderRepo)
+	customerHandler := handler.NewCustomerHandler(customerService)
+
+	loyaltyService := service.NewLoyaltyService(loyaltyRepo, customerRepo)
+	loyaltyHandler := handler.NewLoyaltyHandler(loyaltyService)
+
+	packagingRepo := dal.NewPackagingRepo("")
+	packagingService := service.NewPackagingService(packagingRepo, inventoryRepo)
+	packagingHandler := handler.NewPackagingHandler(packagingService)
+
+	giftCardService := service.NewGiftCardService(giftCardRepo)
+	giftCardHandler := handler.NewGiftCardHandler(giftCardService)
+
+	paymentService := service.NewPaymentService(paymentRepo, orderRepo, service.NewLocalProcessor())
+	paymentHandler := handler.NewPaymentHandler(paymentService)
+
 	reportRepo := dal.NewReportRepo("")
 	reportService := service.NewReportService(reportRepo)
 	reportHandler := handler.NewReportHandler(reportService)
 
-	aggService := service.NewAggragationService(orderRepo, menuRepo)
+	tableService := service.NewTableService(tableRepo)
+	tableHandler := handler.NewTableHandler(tableService)
+
+	prepRepo := dal.NewPrepRepo("")
+	prepService := service.NewPrepService(prepRepo, outboxDispatcher)
+	prepHandler := handler.NewPrepHandler(prepService)
+
+	employeeService := service.NewEmployeeService(employeeRepo, shiftRepo)
+	employeeHandler := handler.NewEmployeeHandler(employeeService)
+
+	authService := service.NewAuthService(employeeRepo, apiKeyRepo, authSecret())
+	authHandler := handler.NewAuthHandler(authService)
+
+	tipService := service.NewTipService(tipRepo, shiftRepo)
+	tipHandler := handler.NewTipHandler(tipService)
+
+	aggService := service.NewAggragationService(orderRepo, giftCardRepo, tipRepo)
 	aggHandler := handler.NewAggragationHandler(aggService)
 	mux := http.NewServeMux()
+	handle := func(pattern string, h http.HandlerFunc) {
+		mux.Handle(pattern, handler.Require(authService, permissionFor(pattern), handler.Idempotent(idempotencyService, h)))
+	}
+
+	handle("POST /auth/login", authHandler.PostLogin)
+	handle("GET /auth/me", authHandler.GetMe)
+	handle("POST /auth/api-keys", authHandler.PostAPIKey)
+	handle("GET /auth/api-keys", authHandler.GetAPIKeys)
+	handle("DELETE /auth/api-keys/{id}", authHandler.DeleteAPIKey)
+
+	handle("POST /orders", orderHandler.PostOrder)
+	handle("GET /orders", orderHandler.GetAllOrders)
+	handle("GET /orders/{id}", orderHandler.GetOrderByID)
+	handle("PUT /orders/{id}", orderHandler.PutOrderByID)
+	handle("PATCH /orders/{id}", orderHandler.PatchOrderByID)
+	handle("POST /orders/{id}/items", orderHandler.PostOrderLine)
+	handle("DELETE /orders/{id}/items/{line_id}", orderHandler.DeleteOrderLine)
+	handle("DELETE /orders/{id}", orderHandler.DeleteOrderByID)
+	handle("POST /orders/{id}/close", orderHandler.PostCloseOrder)
+	handle("POST /orders/{id}/cancel", orderHandler.PostCancelOrder)
+	handle("POST /orders/{id}/split", orderHandler.PostSplitOrder)
+	handle("POST /orders/{id}/payments", paymentHandler.PostPayment)
+	handle("GET /orders/{id}/payments", paymentHandler.GetPayments)
+	handle("POST /orders/{id}/refunds", paymentHandler.PostRefund)
+	handle("GET /orders/numberOfOrderedItems", orderHandler.GetNumberOfOrderedItems)
+	handle("GET /reports/search", reportHandler.GetSearchReport)
+	handle("GET /reports/orderedItemsByPeriod", orderHandler.GetOrderedItemsByPeriod)
+	handle("POST /orders/batch-process", orderHandler.BatchProcessOrders)
+	handle("POST /orders/merge", orderHandler.PostMergeOrders)
+	handle("GET /orders/slots", pickupHandler.GetSlots)
+	handle("GET /orders/slots/settings", pickupHandler.GetSettings)
+	handle("PUT /orders/slots/settings", pickupHandler.PutSettings)
+
+	handle("POST /inventory", inventoryHandler.PostItem)
+	handle("GET /inventory", inventoryHandler.GetAllItem)
+	handle("GET /inventory/{id}", inventoryHandler.GetItemById)
+	handle("PUT /inventory/{id}", inventoryHandler.PutItem)
+	handle("PATCH /inventory/{id}", inventoryHandler.PatchItem)
+	handle("DELETE /inventory/{id}", inventoryHandler.DeleteItem)
+	handle("POST /inventory/{id}/restore", inventoryHandler.RestoreItem)
+	handle("POST /inventory/{id}/produce", inventoryHandler.PostProduce)
+	handle("GET /inventory/{id}/recipe", inventoryHandler.GetRecipe)
+	handle("GET /inventory/getLeftOvers", inventoryHandler.GetLeftovers)
+	handle("GET /packaging/rules", packagingHandler.GetRules)
+	handle("PUT /packaging/rules", packagingHandler.PutRules)
+
+	handle("POST /menu", menuHandler.PostMenuHandler)
+	handle("GET /menu", menuHandler.GetAllMenuHandler)
+	handle("GET /menu/{id}", menuHandler.GetMenuItemHandler)
+	handle("PUT /menu/{id}", menuHandler.PutMenuHandler)
+	handle("PATCH /menu/{id}", menuHandler.PatchMenuHandler)
+	handle("POST /menu/{id}/ingredients", menuHandler.PostIngredientHandler)
+	handle("DELETE /menu/{id}/ingredients/{ingredient_id}", menuHandler.DeleteIngredientHandler)
+	handle("DELETE /menu/{id}", menuHandler.DeleteMenuHandler)
+	handle("POST /menu/{id}/restore", menuHandler.RestoreMenuHandler)
+	handle("GET /menu/{id}/nutrition", menuHandler.GetNutritionHandler)
+	handle("GET /menu/nutrition.csv", menuHandler.GetNutritionCSVHandler)
+
+	handle("POST /customers", customerHandler.PostCustomer)
+	handle("GET /customers", customerHandler.GetAllCustomers)
+	handle("GET /customers/{id}", customerHandler.GetCustomerByID)
+	handle("PUT /customers/{id}", customerHandler.PutCustomer)
+	handle("POST /customers/merge", customerHandler.PostMergeCustomers)
+	handle("GET /customers/{id}/orders", customerHandler.GetCustomerOrders)
+	handle("GET /customers/{id}/loyalty", loyaltyHandler.GetCustomerLoyalty)
+	handle("GET /loyalty/rules", loyaltyHandler.GetRules)
+	handle("PUT /loyalty/rules", loyaltyHandler.PutRules)
+
+	handle("POST /giftcards", giftCardHandler.PostGiftCard)
+	handle("GET /giftcards/{code}", giftCardHandler.GetGiftCard)
+	handle("POST /giftcards/{code}/load", giftCardHandler.PostLoadGiftCard)
+	handle("GET /giftcards/{code}/transactions", giftCardHandler.GetGiftCardTransactions)
+
+	handle("POST /tables", tableHandler.PostTable)
+	handle("GET /tables", tableHandler.GetAllTables)
+	handle("GET /tables/{id}", tableHandler.GetTableByID)
+	handle("PUT /tables/{id}", tableHandler.PutTable)
+	handle("PUT /tables/{id}/status", tableHandler.PutTableStatus)
+	handle("POST /tables/{id}/transfer", tableHandler.PostTransferTab)
+
+	handle("GET /queue", prepHandler.GetQueue)
+	handle("POST /queue/{id}/start", prepHandler.PostLineAction)
+	handle("POST /queue/{id}/ready", prepHandler.PostLineAction)
+	handle("POST /queue/{id}/handoff", prepHandler.PostLineAction)
+	handle("GET /queue/stations", prepHandler.GetStations)
+	handle("PUT /queue/stations", prepHandler.PutStations)
+
+	handle("POST /employees", employeeHandler.PostEmployee)
+	handle("GET /employees", employeeHandler.GetAllEmployees)
+	handle("GET /employees/{id}", employeeHandler.GetEmployeeByID)
+	handle("PUT /employees/{id}", employeeHandler.PutEmployee)
+	handle("POST /employees/{id}/clock-in", employeeHandler.PostClockIn)
+	handle("POST /employees/{id}/clock-out", employeeHandler.PostClockOut)
+	handle("GET /employees/{id}/shifts", employeeHandler.GetShifts)
+	handle("GET /reports/labor", employeeHandler.GetLaborReport)
+
+	handle("GET /reports/total-sales", aggHandler.GetAllSales)
+	handle("GET /reports/popular-items", aggHandler.GetPopularSales)
+	handle("GET /reports/tips", tipHandler.GetTipReport)
+
+	handle("GET /audit", auditHandler.GetAuditLog)
+
+	handle("GET /events", eventHandler.GetEvents)
+
+	handle("POST /webhooks", webhookHandler.PostWebhook)
+	handle("GET /webhooks", webhookHandler.GetAllWebhooks)
+	handle("GET /webhooks/{id}", webhookHandler.GetWebhookByID)
+	handle("PUT /webhooks/{id}", webhookHandler.PutWebhook)
+	handle("DELETE /webhooks/{id}", webhookHandler.DeleteWebhook)
+	handle("GET /webhooks/{id}/deliveries", webhookHandler.GetDeliveries)
 
-	mux.HandleFunc("POST /orders", orderHandler.PostOrder)
-	mux.HandleFunc("GET /orders", orderHandler.GetAllOrders)
-	mux.HandleFunc("GET /orders/{id}", orderHandler.GetOrderByID)
-	mux.HandleFunc("PUT /orders/{id}", orderHandler.PutOrderByID)
-	mux.HandleFunc("DELETE /orders/{id}", orderHandler.DeleteOrderByID)
-	mux.HandleFunc("POST /orders/{id}/close", orderHandler.PostCloseOrder)
-	mux.HandleFunc("GET /orders/numberOfOrderedItems", orderHandler.GetNumberOfOrderedItems)
-	mux.HandleFunc("GET /reports/search", reportHandler.GetSearchReport)
-	mux.HandleFunc("GET /reports/orderedItemsByPeriod", orderHandler.GetOrderedItemsByPeriod)
-	mux.HandleFunc("POST /orders/batch-process", orderHandler.BatchProcessOrders)
-
-	mux.HandleFunc("POST /inventory", inventoryHandler.PostItem)
-	mux.HandleFunc("GET /inventory", inventoryHandler.GetAllItem)
-	mux.HandleFunc("GET /inventory/{id}", inventoryHandler.GetItemById)
-	mux.HandleFunc("PUT /inventory/{id}", inventoryHandler.PutItem)
-	mux.HandleFunc("DELETE /inventory/{id}", inventoryHandler.DeleteItem)
-	mux.HandleFunc("GET /inventory/getLeftOvers", inventoryHandler.GetLeftovers)
-
-	mux.HandleFunc("POST /menu", menuHandler.PostMenuHandler)
-	mux.HandleFunc("GET /menu", menuHandler.GetAllMenuHandler)
-	mux.HandleFunc("GET /menu/{id}", menuHandler.GetMenuItemHandler)
-	mux.HandleFunc("PUT /menu/{id}", menuHandler.PutMenuHandler)
-	mux.HandleFunc("DELETE /menu/{id}", menuHandler.DeleteMenuHandler)
-
-	mux.HandleFunc("GET /reports/total-sales", aggHandler.GetAllSales)
-	mux.HandleFunc("GET /reports/popular-items", aggHandler.GetPopularSales)
-
-	log.Fatal(http.ListenAndServe(":"+strconv.Itoa(*port), mux))
+	log.Fatal(http.ListenAndServe(":"+strconv.Itoa(*port), handler.Authenticate(authService, mux)))
 }
 
 func printHelpUsage() {
-	fmt.Println("./hot-coffee --help\nCoffee Shop Management System\n\nUsage:\n  hot-coffee [--port <N>] [--dir <S>] \n  hot-coffee --help\n\nOptions:\n  --help       Show this screen.\n  --port N     Port number.\n  --dir S      Path to the data directory.")
+	fmt.Println("./hot-coffee --help\nCoffee Shop Management System\n\nUsage:\n  hot-coffee [--port <N>] [--dir <S>] [--idempotency-ttl <D>] \n  hot-coffee --help\n\nOptions:\n  --help       Show this screen.\n  --port N     Port number.\n  --dir S      Path to the data directory.\n  --idempotency-ttl D  How long idempotency keys are kept, e.g. 24h.")
 }
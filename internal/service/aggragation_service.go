@@ -1,77 +1,70 @@
 package service
 
 import (
-	"errors"
-	"fmt"
 	"sort"
-	"strconv"
 
 	"hot-coffee/internal/dal"
 	"hot-coffee/models"
 )
 
 type AggragationService interface {
-	GetTotalSales() (float64, error)
-	GetPopularMenuItems() ([]models.OrderItem, error)
+	GetTotalSales(channel string) (float64, error)
+	GetGiftCardLiability() (float64, error)
+	GetTotalTips(channel string) (float64, error)
+	GetPopularMenuItems(channel string) ([]models.OrderItem, error)
 }
 
 type aggragationService struct {
-	orderRepo dal.OrderRepository
-	menuRepo  dal.MenuRepository
+	orderRepo    dal.OrderRepository
+	giftCardRepo dal.GiftCardRepository
+	tipRepo      dal.TipRepository
 }
 
-func NewAggragationService(orderRepo dal.OrderRepository, menuRepo dal.MenuRepository) *aggragationService {
-	return &aggragationService{orderRepo: orderRepo, menuRepo: menuRepo}
+func NewAggragationService(orderRepo dal.OrderRepository, giftCardRepo dal.GiftCardRepository, tipRepo dal.TipRepository) *aggragationService {
+	return &aggragationService{orderRepo: orderRepo, giftCardRepo: giftCardRepo, tipRepo: tipRepo}
 }
 
-func (s *aggragationService) GetTotalSales() (float64, error) {
-	allMenuItems, err := s.menuRepo.GetAll()
-	if err != nil {
+// GetTotalTips is reported next to the sales, tips are owed to the staff
+// and are never part of revenue. An empty channel counts every channel.
+func (s *aggragationService) GetTotalTips(channel string) (float64, error) {
+	if err := checkChannelFilter(channel); err != nil {
 		return 0, err
 	}
-	menuMap := make(map[string]models.MenuItem)
-	for _, menuItem := range allMenuItems {
-		menuMap[menuItem.ID] = menuItem
+	return s.tipRepo.GetTotalTips(channel)
+}
+
+// GetGiftCardLiability is the unspent balance on all gift cards, money taken
+// in that is still owed as goods.
+func (s *aggragationService) GetGiftCardLiability() (float64, error) {
+	return s.giftCardRepo.GetLiability()
+}
+
+// GetTotalSales is what closed orders were sold for, net of discounts and
+// refunds, so tips left on payments or at close never count as revenue. An
+// empty channel counts every channel.
+func (s *aggragationService) GetTotalSales(channel string) (float64, error) {
+	if err := checkChannelFilter(channel); err != nil {
+		return 0, err
 	}
-	allOrderItems, err := s.orderRepo.GetAll()
+	sales, err := s.orderRepo.GetTotalSales(channel)
 	if err != nil {
 		return 0, err
 	}
-	var totalSales float64
-	for _, orderItem := range allOrderItems {
-		if orderItem.Status == "active" {
-			continue
-		}
-		for _, ingr := range orderItem.Items {
-			itemMenu := menuMap[ingr.MenuItemID]
-			fmt.Println(itemMenu, ingr.MenuItemID)
-			if itemMenu.ID == "" {
-				return 0, errors.New("menu item not found: " + strconv.Itoa(orderItem.ID))
-			} else {
-				items := orderItem.Items
-				for _, item := range items {
-					totalSales += float64(item.Quantity) * itemMenu.Price
-				}
-			}
-		}
-	}
-	return totalSales, nil
+	return roundTo(sales, 2), nil
 }
 
-func (s *aggragationService) GetPopularMenuItems() ([]models.OrderItem, error) {
+func (s *aggragationService) GetPopularMenuItems(channel string) ([]models.OrderItem, error) {
+	if err := checkChannelFilter(channel); err != nil {
+		return nil, err
+	}
 	orderItems, err := s.orderRepo.GetAll()
 	if err != nil {
 		return nil, err
 	}
 
 	itemCount := make(map[string]int)
-
-	for _, order := range orderItems {
-		if order.Status == "closed" {
-			for _, item := range order.Items {
-				itemCount[item.MenuItemID] += item.Quantity
-			}
-		}
+	for _, item := range soldLines(orderItems, channel) {
+		itemCount[item.MenuItemID] += item.Quantity
 	}
 
 	var popularItems []models.OrderItem
@@ -88,3 +81,29 @@ func (s *aggragationService) GetPopularMenuItems() ([]models.OrderItem, error) {
 
 	return popularItems, nil
 }
+
+// soldLines are the lines of the closed orders. The shares of an even split
+// have no lines of their own, the lines of their parent are counted once as
+// soon as one share is closed.
+func soldLines(orders []models.Order, channel string) []models.OrderItem {
+	byID := make(map[int]models.Order)
+	for _, order := range orders {
+		byID[order.ID] = order
+	}
+	countedParents := make(map[int]bool)
+	var lines []models.OrderItem
+	for _, order := range orders {
+		if order.Status != "closed" || (channel != "" && order.Channel != channel) {
+			continue
+		}
+		if len(order.Items) == 0 && order.ParentOrderID != 0 {
+			if !countedParents[order.ParentOrderID] {
+				countedParents[order.ParentOrderID] = true
+				lines = append(lines, byID[order.ParentOrderID].Items...)
+			}
+			continue
+		}
+		lines = append(lines, order.Items...)
+	}
+	return lines
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"hot-coffee/models"
+)
+
+// normalizeTags lower-cases and de-duplicates allergen and dietary tags.
+// The result is never nil so it can be stored in a NOT NULL array column.
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// allergens collects the allergens of id and, for prep items, of everything
+// it is made from. Unknown ingredients contribute nothing.
+func (b *recipeBook) allergens(id string, found map[string]bool, path map[string]bool) {
+	item, ok := b.items[id]
+	if !ok || path[id] {
+		return
+	}
+	for _, allergen := range item.Allergens {
+		found[allergen] = true
+	}
+	path[id] = true
+	defer delete(path, id)
+	for _, component := range item.Recipe {
+		b.allergens(component.IngredientID, found, path)
+	}
+}
+
+// dietaryTags of a prep item are the tags shared by all of its components,
+// raw ingredients use their own tags.
+func (b *recipeBook) dietaryTags(id string, path map[string]bool) map[string]bool {
+	tags := make(map[string]bool)
+	item, ok := b.items[id]
+	if !ok || path[id] {
+		return tags
+	}
+	if !isPrepItem(item) {
+		for _, tag := range item.DietaryTags {
+			tags[tag] = true
+		}
+		return tags
+	}
+	path[id] = true
+	defer delete(path, id)
+	for i, component := range item.Recipe {
+		componentTags := b.dietaryTags(component.IngredientID, path)
+		if i == 0 {
+			tags = componentTags
+			continue
+		}
+		for tag := range tags {
+			if !componentTags[tag] {
+				delete(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// recipeTags computes allergens and dietary tags for a list of ingredient ids.
+func (b *recipeBook) recipeTags(ingredientIDs []string) ([]string, []string) {
+	allergens := make(map[string]bool)
+	var dietary map[string]bool
+	for _, id := range ingredientIDs {
+		b.allergens(id, allergens, map[string]bool{})
+		tags := b.dietaryTags(id, map[string]bool{})
+		if dietary == nil {
+			dietary = tags
+			continue
+		}
+		for tag := range dietary {
+			if !tags[tag] {
+				delete(dietary, tag)
+			}
+		}
+	}
+	return setToSortedSlice(allergens), setToSortedSlice(dietary)
+}
+
+func setToSortedSlice(set map[string]bool) []string {
+	var list []string
+	for value := range set {
+		list = append(list, value)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func menuIngredientIDs(menuItem models.MenuItem) []string {
+	var ids []string
+	for _, ingredient := range menuItem.Ingredients {
+		ids = append(ids, ingredient.IngredientID)
+	}
+	return ids
+}
+
+// lineIngredientIDs applies the structured part of an order line
+// customization to the recipe. Free-form customizations are ignored.
+func lineIngredientIDs(menuItem models.MenuItem, customization json.RawMessage) []string {
+	var modifiers models.Customization
+	if len(customization) > 0 {
+		if err := json.Unmarshal(customization, &modifiers); err != nil {
+			modifiers = models.Customization{}
+		}
+	}
+	removed := make(map[string]bool)
+	for _, id := range modifiers.Remove {
+		removed[id] = true
+	}
+	var ids []string
+	for _, id := range menuIngredientIDs(menuItem) {
+		if !removed[id] {
+			ids = append(ids, id)
+		}
+	}
+	for _, added := range modifiers.Add {
+		ids = append(ids, added.IngredientID)
+	}
+	return ids
+}
+
+func withAllergens(menuItems []models.MenuItem, book *recipeBook) []models.MenuItem {
+	for i := range menuItems {
+		menuItems[i].Allergens, menuItems[i].DietaryTags = book.recipeTags(menuIngredientIDs(menuItems[i]))
+	}
+	return menuItems
+}
+
+func containsAny(values []string, wanted []string) bool {
+	for _, value := range values {
+		for _, w := range wanted {
+			if value == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// annotateAllergenWarnings fills in the allergen warnings of every order line.
+func annotateAllergenWarnings(orders []models.Order, menuItems []models.MenuItem, book *recipeBook) {
+	menuMap := make(map[string]models.MenuItem)
+	for _, menuItem := range menuItems {
+		menuMap[menuItem.ID] = menuItem
+	}
+	for i := range orders {
+		for j := range orders[i].Items {
+			item := &orders[i].Items[j]
+			menuItem, found := menuMap[item.MenuItemID]
+			if !found {
+				continue
+			}
+			item.AllergenWarnings, _ = book.recipeTags(lineIngredientIDs(menuItem, item.Customization))
+		}
+	}
+}
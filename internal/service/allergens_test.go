@@ -0,0 +1,86 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+// taggedInventory has a vanilla syrup made from a vegan and a vegetarian
+// ingredient, so it is only vegetarian.
+var taggedInventory = []models.InventoryItem{
+	{IngredientID: "espresso", DietaryTags: []string{"vegan", "vegetarian"}},
+	{IngredientID: "milk", Allergens: []string{"milk"}, DietaryTags: []string{"vegetarian"}},
+	{IngredientID: "oat_milk", Allergens: []string{"gluten"}, DietaryTags: []string{"vegan", "vegetarian"}},
+	{IngredientID: "sugar", DietaryTags: []string{"vegan", "vegetarian"}},
+	{IngredientID: "honey", DietaryTags: []string{"vegetarian"}},
+	{IngredientID: "syrup", Recipe: []models.InventoryRecipeIngredient{
+		{IngredientID: "sugar", Quantity: 1},
+		{IngredientID: "honey", Quantity: 1},
+	}},
+	{IngredientID: "hazelnut", Allergens: []string{"nuts"}, DietaryTags: []string{"vegan", "vegetarian"}},
+}
+
+func TestRecipeTags(t *testing.T) {
+	tests := []struct {
+		name          string
+		ingredients   []string
+		wantAllergens []string
+		wantDietary   []string
+	}{
+		{name: "vegan", ingredients: []string{"espresso", "oat_milk"}, wantAllergens: []string{"gluten"}, wantDietary: []string{"vegan", "vegetarian"}},
+		{name: "tags shared by all", ingredients: []string{"espresso", "milk"}, wantAllergens: []string{"milk"}, wantDietary: []string{"vegetarian"}},
+		{name: "through a prep item", ingredients: []string{"espresso", "syrup"}, wantDietary: []string{"vegetarian"}},
+		{name: "unknown ingredient", ingredients: []string{"espresso", "cinnamon"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allergens, dietary := newRecipeBook(taggedInventory).recipeTags(tt.ingredients)
+			if !reflect.DeepEqual(allergens, tt.wantAllergens) || !reflect.DeepEqual(dietary, tt.wantDietary) {
+				t.Errorf("recipeTags() = %v, %v, want %v, %v", allergens, dietary, tt.wantAllergens, tt.wantDietary)
+			}
+		})
+	}
+}
+
+func TestAllergenWarnings(t *testing.T) {
+	latte := models.MenuItem{ID: "latte", Ingredients: []models.MenuItemIngredient{
+		{IngredientID: "espresso", Quantity: 30},
+		{IngredientID: "milk", Quantity: 200},
+	}}
+	tests := []struct {
+		name          string
+		customization string
+		want          []string
+	}{
+		{name: "as listed", want: []string{"milk"}},
+		{name: "milk swapped for oat milk", customization: `{"remove":["milk"],"add":[{"ingredient_id":"oat_milk","quantity":200}]}`, want: []string{"gluten"}},
+		{name: "added nuts", customization: `{"add":[{"ingredient_id":"hazelnut","quantity":10}]}`, want: []string{"milk", "nuts"}},
+		{name: "free-form note", customization: `"extra hot"`, want: []string{"milk"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := models.OrderItem{MenuItemID: "latte", Quantity: 1}
+			if tt.customization != "" {
+				line.Customization = json.RawMessage(tt.customization)
+			}
+			orders := []models.Order{{ID: 1, Items: []models.OrderItem{line}}}
+			annotateAllergenWarnings(orders, []models.MenuItem{latte}, newRecipeBook(taggedInventory))
+			if got := orders[0].Items[0].AllergenWarnings; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("warnings %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got := normalizeTags([]string{" Nuts", "milk", "", "MILK", "gluten "})
+	if want := []string{"gluten", "milk", "nuts"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeTags() = %v, want %v", got, want)
+	}
+	if got := normalizeTags(nil); got == nil {
+		t.Errorf("normalizeTags(nil) is nil")
+	}
+}
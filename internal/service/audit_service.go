@@ -0,0 +1,52 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+type AuditService interface {
+	GetEntries(filter models.AuditFilter) ([]models.AuditEntry, error)
+}
+
+type auditService struct {
+	auditRepo dal.AuditRepository
+}
+
+func NewAuditService(auditRepo dal.AuditRepository) *auditService {
+	return &auditService{auditRepo: auditRepo}
+}
+
+// GetEntries lists the audit log newest first, filtered by entity and by
+// an inclusive date range.
+func (s *auditService) GetEntries(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	switch filter.EntityType {
+	case "", models.AuditOrder, models.AuditMenuItem, models.AuditInventoryItem:
+	default:
+		return nil, errors.New("entity must be order, menu_item or inventory_item")
+	}
+	if filter.EntityID != "" && filter.EntityType == "" {
+		return nil, errors.New("id needs an entity")
+	}
+	if filter.From != "" {
+		if _, err := time.Parse("2006-01-02", filter.From); err != nil {
+			return nil, errors.New("from must be in YYYY-MM-DD format")
+		}
+	}
+	if filter.To != "" {
+		if _, err := time.Parse("2006-01-02", filter.To); err != nil {
+			return nil, errors.New("to must be in YYYY-MM-DD format")
+		}
+	}
+	entries, err := s.auditRepo.GetEntries(filter)
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		entries = []models.AuditEntry{}
+	}
+	return entries, nil
+}
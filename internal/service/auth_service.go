@@ -0,0 +1,202 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+const tokenLifetime = 12 * time.Hour
+
+var roleRanks = map[string]int{"barista": 1, "shift_lead": 2, "manager": 3}
+
+// RoleAllows reports whether role is at least required in the order
+// barista, shift_lead, manager.
+func RoleAllows(role, required string) bool {
+	return roleRanks[role] > 0 && roleRanks[role] >= roleRanks[required]
+}
+
+type AuthService interface {
+	Login(request models.LoginRequest) (models.LoginResponse, error)
+	AuthenticateToken(token string) (models.Principal, error)
+	AuthenticateAPIKey(key string) (models.Principal, error)
+	NeedsBootstrap() (bool, error)
+	CreateAPIKey(key models.APIKey) (models.APIKey, error)
+	GetAPIKeys() ([]models.APIKey, error)
+	RevokeAPIKey(id int) error
+}
+
+type authService struct {
+	employeeRepo dal.EmployeeRepository
+	apiKeyRepo   dal.APIKeyRepository
+	secret       []byte
+}
+
+func NewAuthService(employeeRepo dal.EmployeeRepository, apiKeyRepo dal.APIKeyRepository, secret []byte) *authService {
+	return &authService{employeeRepo: employeeRepo, apiKeyRepo: apiKeyRepo, secret: secret}
+}
+
+type tokenClaims struct {
+	EmployeeID int   `json:"sub"`
+	ExpiresAt  int64 `json:"exp"`
+}
+
+func (s *authService) Login(request models.LoginRequest) (models.LoginResponse, error) {
+	invalid := errors.New("invalid credentials")
+	employee, err := s.employeeRepo.GetByID(request.EmployeeID)
+	if err == sql.ErrNoRows {
+		return models.LoginResponse{}, invalid
+	}
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+	hash, err := s.employeeRepo.GetPasswordHash(employee.ID)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+	if hash == "" || !employee.Active {
+		return models.LoginResponse{}, invalid
+	}
+	ok, err := checkPassword(request.Password, hash)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+	if !ok {
+		return models.LoginResponse{}, invalid
+	}
+
+	expiresAt := time.Now().UTC().Add(tokenLifetime)
+	token, err := s.signToken(tokenClaims{EmployeeID: employee.ID, ExpiresAt: expiresAt.Unix()})
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+	return models.LoginResponse{
+		Token:     token,
+		ExpiresAt: expiresAt.Format("2006-01-02T15:04:05Z"),
+		Employee:  employee,
+	}, nil
+}
+
+// signToken encodes the claims as base64url JSON followed by a dot and
+// the base64url HMAC-SHA256 of that payload.
+func (s *authService) signToken(claims tokenClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.sign(encoded)), nil
+}
+
+func (s *authService) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// AuthenticateToken checks the signature and expiry and that the employee
+// is still active, the role is read fresh so role changes apply at once.
+func (s *authService) AuthenticateToken(token string) (models.Principal, error) {
+	invalid := errors.New("invalid or expired token")
+	payload, signature, found := strings.Cut(token, ".")
+	if !found {
+		return models.Principal{}, invalid
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(decoded, s.sign(payload)) {
+		return models.Principal{}, invalid
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return models.Principal{}, invalid
+	}
+	var claims tokenClaims
+	if err = json.Unmarshal(raw, &claims); err != nil || time.Now().Unix() >= claims.ExpiresAt {
+		return models.Principal{}, invalid
+	}
+	employee, err := s.employeeRepo.GetByID(claims.EmployeeID)
+	if err == sql.ErrNoRows || (err == nil && !employee.Active) {
+		return models.Principal{}, invalid
+	}
+	if err != nil {
+		return models.Principal{}, err
+	}
+	return models.Principal{EmployeeID: employee.ID, Role: employee.Role}, nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *authService) AuthenticateAPIKey(key string) (models.Principal, error) {
+	apiKey, err := s.apiKeyRepo.GetByHash(hashAPIKey(key))
+	if err == sql.ErrNoRows {
+		return models.Principal{}, errors.New("invalid API key")
+	}
+	if err != nil {
+		return models.Principal{}, err
+	}
+	return models.Principal{APIKeyID: apiKey.ID, Role: apiKey.Role}, nil
+}
+
+// NeedsBootstrap is true until some active manager can log in, so the
+// first manager can be created without credentials.
+func (s *authService) NeedsBootstrap() (bool, error) {
+	exists, err := s.employeeRepo.HasLoginManager()
+	return !exists, err
+}
+
+// CreateAPIKey returns the key itself only this once, afterwards only its
+// hash is known.
+func (s *authService) CreateAPIKey(key models.APIKey) (models.APIKey, error) {
+	key.Name = strings.TrimSpace(key.Name)
+	if key.Name == "" || len(key.Name) > 50 {
+		return models.APIKey{}, errors.New("invalid API key name")
+	}
+	if roleRanks[key.Role] == 0 {
+		return models.APIKey{}, errors.New("role must be barista, shift_lead or manager")
+	}
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return models.APIKey{}, err
+	}
+	key.Key = "hc_" + hex.EncodeToString(buf)
+	key.CreatedAt = getFormattedTime()
+	key.RevokedAt = ""
+	id, err := s.apiKeyRepo.SaveAPIKey(key, hashAPIKey(key.Key))
+	key.ID = id
+	return key, err
+}
+
+func (s *authService) GetAPIKeys() ([]models.APIKey, error) {
+	keys, err := s.apiKeyRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+	return keys, nil
+}
+
+func (s *authService) RevokeAPIKey(id int) error {
+	revoked, err := s.apiKeyRepo.Revoke(id)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return errors.New("API key not found")
+	}
+	return nil
+}
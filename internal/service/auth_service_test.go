@@ -0,0 +1,227 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// authEmployeeRepo keeps employees with their password hashes.
+type authEmployeeRepo struct {
+	dal.EmployeeRepository
+	employees map[int]models.Employee
+	hashes    map[int]string
+}
+
+func (r *authEmployeeRepo) GetByID(id int) (models.Employee, error) {
+	employee, found := r.employees[id]
+	if !found {
+		return models.Employee{}, sql.ErrNoRows
+	}
+	return employee, nil
+}
+
+func (r *authEmployeeRepo) GetPasswordHash(id int) (string, error) {
+	return r.hashes[id], nil
+}
+
+// fakeAPIKeyRepo keeps keys by hash.
+type fakeAPIKeyRepo struct {
+	dal.APIKeyRepository
+	byHash map[string]models.APIKey
+}
+
+func (r *fakeAPIKeyRepo) SaveAPIKey(key models.APIKey, keyHash string) (int, error) {
+	key.ID = len(r.byHash) + 1
+	key.Key = ""
+	r.byHash[keyHash] = key
+	return key.ID, nil
+}
+
+func (r *fakeAPIKeyRepo) GetByHash(keyHash string) (models.APIKey, error) {
+	key, found := r.byHash[keyHash]
+	if !found {
+		return models.APIKey{}, sql.ErrNoRows
+	}
+	return key, nil
+}
+
+func newAuthFixture(t *testing.T) (*authService, *authEmployeeRepo) {
+	hash, err := hashPassword("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &authEmployeeRepo{
+		employees: map[int]models.Employee{
+			1: {ID: 1, Name: "Ann", Role: "manager", Active: true},
+			2: {ID: 2, Name: "Bob", Role: "barista", Active: false},
+			3: {ID: 3, Name: "Cleo", Role: "barista", Active: true},
+		},
+		hashes: map[int]string{1: hash, 2: hash},
+	}
+	return NewAuthService(repo, &fakeAPIKeyRepo{byHash: map[string]models.APIKey{}}, []byte("test-secret")), repo
+}
+
+func TestPBKDF2(t *testing.T) {
+	// PBKDF2-HMAC-SHA256 test vectors for P = "password", S = "salt"
+	tests := []struct {
+		iterations int
+		want       string
+	}{
+		{iterations: 1, want: "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{iterations: 2, want: "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{iterations: 4096, want: "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+	}
+	for _, tt := range tests {
+		if got := hex.EncodeToString(pbkdf2SHA256([]byte("password"), []byte("salt"), tt.iterations)); got != tt.want {
+			t.Errorf("pbkdf2SHA256(%d) = %s, want %s", tt.iterations, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := hashPassword("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other, _ := hashPassword("s3cret"); other == hash {
+		t.Errorf("two hashes of one password share a salt")
+	}
+	if ok, err := checkPassword("s3cret", hash); !ok || err != nil {
+		t.Errorf("checkPassword(right) = %v, %v", ok, err)
+	}
+	if ok, err := checkPassword("S3cret", hash); ok || err != nil {
+		t.Errorf("checkPassword(wrong) = %v, %v", ok, err)
+	}
+	if _, err := checkPassword("s3cret", "md5$abc"); err == nil {
+		t.Errorf("unknown hash format accepted")
+	}
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  models.LoginRequest
+		wantErr  string
+		wantRole string
+	}{
+		{name: "manager", request: models.LoginRequest{EmployeeID: 1, Password: "s3cret"}, wantRole: "manager"},
+		{name: "wrong password", request: models.LoginRequest{EmployeeID: 1, Password: "guess"}, wantErr: "invalid credentials"},
+		{name: "deactivated", request: models.LoginRequest{EmployeeID: 2, Password: "s3cret"}, wantErr: "invalid credentials"},
+		{name: "no password set", request: models.LoginRequest{EmployeeID: 3, Password: ""}, wantErr: "invalid credentials"},
+		{name: "unknown employee", request: models.LoginRequest{EmployeeID: 9, Password: "s3cret"}, wantErr: "invalid credentials"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newAuthFixture(t)
+			response, err := s.Login(tt.request)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Login() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Login() error = %v", err)
+			}
+			principal, err := s.AuthenticateToken(response.Token)
+			if err != nil || principal.EmployeeID != tt.request.EmployeeID || principal.Role != tt.wantRole {
+				t.Errorf("AuthenticateToken() = %+v, %v", principal, err)
+			}
+		})
+	}
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	s, repo := newAuthFixture(t)
+	valid, err := s.signToken(tokenClaims{EmployeeID: 1, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _ := s.signToken(tokenClaims{EmployeeID: 1, ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+	foreign, _ := NewAuthService(repo, nil, []byte("other-secret")).signToken(tokenClaims{EmployeeID: 1, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	deactivated, _ := s.signToken(tokenClaims{EmployeeID: 2, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	payload, signature, _ := strings.Cut(valid, ".")
+	forged, _ := s.signToken(tokenClaims{EmployeeID: 3, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{name: "valid", token: valid, valid: true},
+		{name: "expired", token: expired},
+		{name: "other secret", token: foreign},
+		{name: "employee deactivated", token: deactivated},
+		{name: "payload swapped", token: forgedPayload + "." + signature},
+		{name: "no signature", token: payload},
+		{name: "garbage", token: "not-a-token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.AuthenticateToken(tt.token)
+			if tt.valid && err != nil {
+				t.Errorf("AuthenticateToken() error = %v", err)
+			}
+			if !tt.valid && (err == nil || err.Error() != "invalid or expired token") {
+				t.Errorf("AuthenticateToken() error = %v, want it refused", err)
+			}
+		})
+	}
+
+	// a role change applies to tokens already handed out
+	repo.employees[1] = models.Employee{ID: 1, Name: "Ann", Role: "shift_lead", Active: true}
+	if principal, _ := s.AuthenticateToken(valid); principal.Role != "shift_lead" {
+		t.Errorf("role %q after the change, want shift_lead", principal.Role)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	s, _ := newAuthFixture(t)
+	key, err := s.CreateAPIKey(models.APIKey{Name: " kiosk ", Role: "barista"})
+	if err != nil {
+		t.Fatalf("CreateAPIKey() error = %v", err)
+	}
+	if !strings.HasPrefix(key.Key, "hc_") || key.Name != "kiosk" || key.ID == 0 {
+		t.Errorf("key %+v", key)
+	}
+	principal, err := s.AuthenticateAPIKey(key.Key)
+	if err != nil || principal.APIKeyID != key.ID || principal.Role != "barista" || principal.EmployeeID != 0 {
+		t.Errorf("AuthenticateAPIKey() = %+v, %v", principal, err)
+	}
+	if _, err := s.AuthenticateAPIKey(key.Key + "x"); err == nil || err.Error() != "invalid API key" {
+		t.Errorf("AuthenticateAPIKey(wrong) error = %v", err)
+	}
+
+	if _, err := s.CreateAPIKey(models.APIKey{Name: "pos", Role: "owner"}); err == nil || err.Error() != "role must be barista, shift_lead or manager" {
+		t.Errorf("CreateAPIKey(owner) error = %v", err)
+	}
+	if _, err := s.CreateAPIKey(models.APIKey{Name: " ", Role: "barista"}); err == nil || err.Error() != "invalid API key name" {
+		t.Errorf("CreateAPIKey(no name) error = %v", err)
+	}
+}
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role, required string
+		want           bool
+	}{
+		{"manager", "barista", true},
+		{"shift_lead", "shift_lead", true},
+		{"barista", "shift_lead", false},
+		{"shift_lead", "manager", false},
+		{"", "barista", false},
+		{"owner", "barista", false},
+	}
+	for _, tt := range tests {
+		if got := RoleAllows(tt.role, tt.required); got != tt.want {
+			t.Errorf("RoleAllows(%q, %q) = %v, want %v", tt.role, tt.required, got, tt.want)
+		}
+	}
+}
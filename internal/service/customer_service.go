@@ -0,0 +1,124 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+type CustomerService interface {
+	AddCustomer(customer models.Customer) (int, error)
+	GetCustomers(name string) ([]models.Customer, error)
+	GetCustomerById(id int) (models.Customer, error)
+	UpdateCustomer(customer models.Customer) error
+	MergeCustomers(req models.MergeCustomersRequest) error
+	GetCustomerOrders(id int) (models.CustomerOrderHistory, error)
+}
+
+type customerService struct {
+	customerRepo dal.CustomerRepository
+	orderRepo    dal.OrderRepository
+}
+
+func NewCustomerService(customerRepo dal.CustomerRepository, orderRepo dal.OrderRepository) *customerService {
+	return &customerService{customerRepo: customerRepo, orderRepo: orderRepo}
+}
+
+func (s *customerService) AddCustomer(customer models.Customer) (int, error) {
+	customer.Name = strings.TrimSpace(customer.Name)
+	if !IsCustomerValid(customer) {
+		return 0, errors.New("invalid customer")
+	}
+	customer.CreatedAt = getFormattedTime()
+	customer.UpdatedAt = customer.CreatedAt
+	return s.customerRepo.SaveCustomer(customer)
+}
+
+func (s *customerService) GetCustomers(name string) ([]models.Customer, error) {
+	customers, err := s.customerRepo.GetAll(strings.TrimSpace(name))
+	if err != nil {
+		return nil, err
+	}
+	if customers == nil {
+		customers = []models.Customer{}
+	}
+	return customers, nil
+}
+
+func (s *customerService) GetCustomerById(id int) (models.Customer, error) {
+	exists, err := s.customerRepo.Exists(id)
+	if err != nil {
+		return models.Customer{}, err
+	}
+	if !exists {
+		return models.Customer{}, errors.New("customer not found")
+	}
+	return s.customerRepo.GetByID(id)
+}
+
+func (s *customerService) UpdateCustomer(customer models.Customer) error {
+	customer.Name = strings.TrimSpace(customer.Name)
+	if !IsCustomerValid(customer) {
+		return errors.New("invalid customer")
+	}
+	exists, err := s.customerRepo.Exists(customer.ID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("customer not found")
+	}
+	customer.UpdatedAt = getFormattedTime()
+	return s.customerRepo.Update(customer)
+}
+
+func (s *customerService) MergeCustomers(req models.MergeCustomersRequest) error {
+	if req.TargetID == 0 || len(req.SourceIDs) == 0 {
+		return errors.New("target_id and source_ids are required")
+	}
+	for _, id := range append([]int{req.TargetID}, req.SourceIDs...) {
+		exists, err := s.customerRepo.Exists(id)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.New("customer not found")
+		}
+	}
+	for _, id := range req.SourceIDs {
+		if id == req.TargetID {
+			return errors.New("cannot merge a customer into itself")
+		}
+	}
+	return s.customerRepo.Merge(req.TargetID, req.SourceIDs)
+}
+
+// GetCustomerOrders returns the order history, lifetime spend only counts
+// closed orders.
+func (s *customerService) GetCustomerOrders(id int) (models.CustomerOrderHistory, error) {
+	customer, err := s.GetCustomerById(id)
+	if err != nil {
+		return models.CustomerOrderHistory{}, err
+	}
+	orders, err := s.orderRepo.GetByCustomerID(id)
+	if err != nil {
+		return models.CustomerOrderHistory{}, err
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].ID < orders[j].ID
+	})
+
+	history := models.CustomerOrderHistory{Customer: customer, Orders: orders, OrderCount: len(orders)}
+	if history.Orders == nil {
+		history.Orders = []models.Order{}
+	}
+	for _, order := range orders {
+		if order.Status == "closed" {
+			history.LifetimeSpend += order.TotalAmount
+		}
+	}
+	return history, nil
+}
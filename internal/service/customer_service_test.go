@@ -0,0 +1,194 @@
+package service
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// fakeCustomerRepo keeps customers by id and records what was saved and
+// merged.
+type fakeCustomerRepo struct {
+	dal.CustomerRepository
+	customers map[int]models.Customer
+	saved     []models.Customer
+	merged    []int
+}
+
+func (r *fakeCustomerRepo) SaveCustomer(customer models.Customer) (int, error) {
+	r.saved = append(r.saved, customer)
+	return len(r.customers) + len(r.saved), nil
+}
+
+func (r *fakeCustomerRepo) Exists(id int) (bool, error) {
+	_, found := r.customers[id]
+	return found, nil
+}
+
+func (r *fakeCustomerRepo) GetByID(id int) (models.Customer, error) {
+	customer, found := r.customers[id]
+	if !found {
+		return models.Customer{}, sql.ErrNoRows
+	}
+	return customer, nil
+}
+
+func (r *fakeCustomerRepo) Merge(targetID int, sourceIDs []int) error {
+	r.merged = append([]int{targetID}, sourceIDs...)
+	return nil
+}
+
+// customerOrderRepo lists the orders of one customer.
+type customerOrderRepo struct {
+	dal.OrderRepository
+	orders []models.Order
+}
+
+func (r *customerOrderRepo) GetByCustomerID(customerID int) ([]models.Order, error) {
+	var orders []models.Order
+	for _, order := range r.orders {
+		if order.CustomerID == customerID {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
+}
+
+func newFakeCustomerRepo() *fakeCustomerRepo {
+	return &fakeCustomerRepo{customers: map[int]models.Customer{
+		1: {ID: 1, Name: "Ann"},
+		2: {ID: 2, Name: "Ann B."},
+		3: {ID: 3, Name: "Bob"},
+	}}
+}
+
+func TestAddCustomer(t *testing.T) {
+	tests := []struct {
+		name     string
+		customer models.Customer
+		wantName string
+		wantErr  string
+	}{
+		{name: "name trimmed", customer: models.Customer{Name: "  Cleo ", Email: "cleo@example.com"}, wantName: "Cleo"},
+		{name: "blank name", customer: models.Customer{Name: "   "}, wantErr: "invalid customer"},
+		{name: "bad email", customer: models.Customer{Name: "Cleo", Email: "cleo.example.com"}, wantErr: "invalid customer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeCustomerRepo()
+			_, err := NewCustomerService(repo, nil).AddCustomer(tt.customer)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("AddCustomer() error = %v, want %q", err, tt.wantErr)
+				}
+				if len(repo.saved) != 0 {
+					t.Errorf("refused customer was saved")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AddCustomer() error = %v", err)
+			}
+			if len(repo.saved) != 1 || repo.saved[0].Name != tt.wantName || repo.saved[0].CreatedAt == "" {
+				t.Errorf("saved %+v, want %s with a creation time", repo.saved, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestMergeCustomers(t *testing.T) {
+	tests := []struct {
+		name    string
+		request models.MergeCustomersRequest
+		wantErr string
+	}{
+		{name: "duplicates", request: models.MergeCustomersRequest{TargetID: 1, SourceIDs: []int{2}}},
+		{name: "no sources", request: models.MergeCustomersRequest{TargetID: 1}, wantErr: "target_id and source_ids are required"},
+		{name: "unknown source", request: models.MergeCustomersRequest{TargetID: 1, SourceIDs: []int{9}}, wantErr: "customer not found"},
+		{name: "into itself", request: models.MergeCustomersRequest{TargetID: 1, SourceIDs: []int{2, 1}}, wantErr: "cannot merge a customer into itself"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeCustomerRepo()
+			err := NewCustomerService(repo, nil).MergeCustomers(tt.request)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("MergeCustomers() error = %v, want %q", err, tt.wantErr)
+				}
+				if repo.merged != nil {
+					t.Errorf("refused merge was stored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MergeCustomers() error = %v", err)
+			}
+			if want := []int{1, 2}; !reflect.DeepEqual(repo.merged, want) {
+				t.Errorf("merged %v, want %v", repo.merged, want)
+			}
+		})
+	}
+}
+
+func TestGetCustomerOrders(t *testing.T) {
+	orderRepo := &customerOrderRepo{orders: []models.Order{
+		{ID: 4, CustomerID: 1, Status: "closed", TotalAmount: 6.5},
+		{ID: 2, CustomerID: 1, Status: "closed", TotalAmount: 4},
+		{ID: 3, CustomerID: 3, Status: "closed", TotalAmount: 9},
+		{ID: 5, CustomerID: 1, Status: "active", TotalAmount: 3},
+	}}
+	s := NewCustomerService(newFakeCustomerRepo(), orderRepo)
+
+	history, err := s.GetCustomerOrders(1)
+	if err != nil {
+		t.Fatalf("GetCustomerOrders() error = %v", err)
+	}
+	var ids []int
+	for _, order := range history.Orders {
+		ids = append(ids, order.ID)
+	}
+	if !reflect.DeepEqual(ids, []int{2, 4, 5}) || history.OrderCount != 3 {
+		t.Errorf("orders %v (count %d), want [2 4 5]", ids, history.OrderCount)
+	}
+	// the open order is not spent yet
+	if history.LifetimeSpend != 10.5 {
+		t.Errorf("lifetime spend %v, want 10.5", history.LifetimeSpend)
+	}
+
+	history, err = s.GetCustomerOrders(2)
+	if err != nil || history.Orders == nil || history.OrderCount != 0 {
+		t.Errorf("history without orders = %+v, %v", history, err)
+	}
+	if _, err := s.GetCustomerOrders(9); err == nil || err.Error() != "customer not found" {
+		t.Errorf("GetCustomerOrders(9) error = %v", err)
+	}
+}
+
+// TestPostOrUpdateTakesCustomerName relies on an order without a customer
+// name being invalid: an order that gets past validation to the stock
+// check has taken the name from the profile.
+func TestPostOrUpdateTakesCustomerName(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   models.Order
+		wantErr string
+	}{
+		{name: "name from the profile", order: models.Order{CustomerID: 3}, wantErr: "not enough inventory for order"},
+		{name: "name given", order: models.Order{CustomerID: 3, CustomerName: "Bobby"}, wantErr: "not enough inventory for order"},
+		{name: "unknown customer", order: models.Order{CustomerID: 9}, wantErr: "customer not found"},
+		{name: "no customer, no name", order: models.Order{}, wantErr: "order is invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewOrderService(&fakeOrderRepo{}, &fakeMenuRepo{}, &fakeInventoryRepo{}, newFakeCustomerRepo(), nil, nil, nil, nil, nil, nil)
+			tt.order.Items = []models.OrderItem{{MenuItemID: "latte", Quantity: 1}}
+			_, err := s.PostOrUpdate(tt.order, 0, models.Principal{})
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("PostOrUpdate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+type EmployeeService interface {
+	AddEmployee(employee models.Employee) (models.Employee, error)
+	GetEmployees() ([]models.Employee, error)
+	GetEmployee(id int) (models.Employee, error)
+	UpdateEmployee(employee models.Employee) (models.Employee, error)
+	ClockIn(id int) (models.Shift, error)
+	ClockOut(id int) (models.Shift, error)
+	GetShifts(id int) ([]models.Shift, error)
+	GetLaborReport(from, to string) ([]models.LaborEntry, error)
+}
+
+type employeeService struct {
+	employeeRepo dal.EmployeeRepository
+	shiftRepo    dal.ShiftRepository
+}
+
+func NewEmployeeService(employeeRepo dal.EmployeeRepository, shiftRepo dal.ShiftRepository) *employeeService {
+	return &employeeService{employeeRepo: employeeRepo, shiftRepo: shiftRepo}
+}
+
+var employeeRoles = map[string]bool{"barista": true, "shift_lead": true, "manager": true}
+
+func isEmployeeValid(employee models.Employee) bool {
+	return employee.Name != "" && len(employee.Name) <= 50 && employeeRoles[employee.Role]
+}
+
+// AddEmployee creates an active employee.
+func (s *employeeService) AddEmployee(employee models.Employee) (models.Employee, error) {
+	employee.Name = strings.TrimSpace(employee.Name)
+	if !isEmployeeValid(employee) {
+		return models.Employee{}, errors.New("invalid employee")
+	}
+	var passwordHash string
+	if employee.Password != "" {
+		hash, err := hashPassword(employee.Password)
+		if err != nil {
+			return models.Employee{}, err
+		}
+		passwordHash = hash
+	}
+	employee.Password = ""
+	employee.Active = true
+	employee.CreatedAt = getFormattedTime()
+	employee.UpdatedAt = employee.CreatedAt
+	id, err := s.employeeRepo.SaveEmployee(employee, passwordHash)
+	employee.ID = id
+	return employee, err
+}
+
+func (s *employeeService) GetEmployees() ([]models.Employee, error) {
+	employees, err := s.employeeRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if employees == nil {
+		employees = []models.Employee{}
+	}
+	return employees, nil
+}
+
+func (s *employeeService) GetEmployee(id int) (models.Employee, error) {
+	employee, err := s.employeeRepo.GetByID(id)
+	if err == sql.ErrNoRows {
+		return models.Employee{}, errors.New("employee not found")
+	}
+	return employee, err
+}
+
+// UpdateEmployee changes the name, role or active flag, and the password
+// when one is given. Employees are deactivated rather than deleted so
+// their history stays attributed.
+func (s *employeeService) UpdateEmployee(employee models.Employee) (models.Employee, error) {
+	employee.Name = strings.TrimSpace(employee.Name)
+	if !isEmployeeValid(employee) {
+		return models.Employee{}, errors.New("invalid employee")
+	}
+	existing, err := s.GetEmployee(employee.ID)
+	if err != nil {
+		return models.Employee{}, err
+	}
+	if employee.Password != "" {
+		hash, err := hashPassword(employee.Password)
+		if err != nil {
+			return models.Employee{}, err
+		}
+		if err = s.employeeRepo.SetPasswordHash(employee.ID, hash); err != nil {
+			return models.Employee{}, err
+		}
+		employee.Password = ""
+	}
+	employee.CreatedAt = existing.CreatedAt
+	employee.UpdatedAt = getFormattedTime()
+	return employee, s.employeeRepo.Update(employee)
+}
+
+func (s *employeeService) ClockIn(id int) (models.Shift, error) {
+	employee, err := s.GetEmployee(id)
+	if err != nil {
+		return models.Shift{}, err
+	}
+	if !employee.Active {
+		return models.Shift{}, errors.New("employee is inactive")
+	}
+	return s.shiftRepo.ClockIn(id)
+}
+
+func (s *employeeService) ClockOut(id int) (models.Shift, error) {
+	if _, err := s.GetEmployee(id); err != nil {
+		return models.Shift{}, err
+	}
+	shift, err := s.shiftRepo.ClockOut(id)
+	shift.Hours = roundTo(shift.Hours, 2)
+	return shift, err
+}
+
+func (s *employeeService) GetShifts(id int) ([]models.Shift, error) {
+	if _, err := s.GetEmployee(id); err != nil {
+		return nil, err
+	}
+	shifts, err := s.shiftRepo.GetShifts(id)
+	if err != nil {
+		return nil, err
+	}
+	if shifts == nil {
+		shifts = []models.Shift{}
+	}
+	for i := range shifts {
+		shifts[i].Hours = roundTo(shifts[i].Hours, 2)
+	}
+	return shifts, nil
+}
+
+// GetLaborReport lists hours per employee per day between from and to.
+// Both default to today.
+func (s *employeeService) GetLaborReport(from, to string) ([]models.LaborEntry, error) {
+	today := time.Now().UTC().Format("2006-01-02")
+	if from == "" {
+		from = today
+	}
+	if to == "" {
+		to = from
+	}
+	fromDate, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return nil, errors.New("from must be in YYYY-MM-DD format")
+	}
+	toDate, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return nil, errors.New("to must be in YYYY-MM-DD format")
+	}
+	if toDate.Before(fromDate) {
+		return nil, errors.New("to must not be before from")
+	}
+	entries, err := s.shiftRepo.GetLabor(from, to)
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		entries = []models.LaborEntry{}
+	}
+	for i := range entries {
+		entries[i].Hours = roundTo(entries[i].Hours, 2)
+	}
+	return entries, nil
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// fakeEmployeeRepo keeps employees by id and records new ones with the
+// password hash saved last.
+type fakeEmployeeRepo struct {
+	dal.EmployeeRepository
+	employees map[int]models.Employee
+	saved     []models.Employee
+	hash      string
+}
+
+func (r *fakeEmployeeRepo) SaveEmployee(employee models.Employee, passwordHash string) (int, error) {
+	r.saved = append(r.saved, employee)
+	r.hash = passwordHash
+	return 10 + len(r.saved), nil
+}
+
+func (r *fakeEmployeeRepo) GetByID(id int) (models.Employee, error) {
+	employee, found := r.employees[id]
+	if !found {
+		return models.Employee{}, sql.ErrNoRows
+	}
+	return employee, nil
+}
+
+// fakeShiftRepo clocks in and reports the labor it was given.
+type fakeShiftRepo struct {
+	dal.ShiftRepository
+	clockedIn []int
+	labor     []models.LaborEntry
+	period    []string
+}
+
+func (r *fakeShiftRepo) ClockIn(employeeID int) (models.Shift, error) {
+	r.clockedIn = append(r.clockedIn, employeeID)
+	return models.Shift{ID: 1, EmployeeID: employeeID}, nil
+}
+
+func (r *fakeShiftRepo) GetLabor(from, to string) ([]models.LaborEntry, error) {
+	r.period = []string{from, to}
+	return r.labor, nil
+}
+
+func TestAddEmployee(t *testing.T) {
+	tests := []struct {
+		name     string
+		employee models.Employee
+		wantErr  string
+	}{
+		{name: "barista", employee: models.Employee{Name: " Ann ", Role: "barista"}},
+		{name: "manager", employee: models.Employee{Name: "Bob", Role: "manager"}},
+		{name: "unknown role", employee: models.Employee{Name: "Cleo", Role: "owner"}, wantErr: "invalid employee"},
+		{name: "no name", employee: models.Employee{Name: " ", Role: "barista"}, wantErr: "invalid employee"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeEmployeeRepo{}
+			employee, err := NewEmployeeService(repo, nil).AddEmployee(tt.employee)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("AddEmployee() error = %v, want %q", err, tt.wantErr)
+				}
+				if len(repo.saved) != 0 {
+					t.Errorf("refused employee was saved")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AddEmployee() error = %v", err)
+			}
+			if !employee.Active || employee.ID == 0 || employee.Name != repo.saved[0].Name || employee.Name[0] == ' ' {
+				t.Errorf("employee %+v, saved %+v", employee, repo.saved[0])
+			}
+		})
+	}
+}
+
+func TestAddEmployeePassword(t *testing.T) {
+	repo := &fakeEmployeeRepo{}
+	employee, err := NewEmployeeService(repo, nil).AddEmployee(models.Employee{Name: "Ann", Role: "manager", Password: "s3cret"})
+	if err != nil {
+		t.Fatalf("AddEmployee() error = %v", err)
+	}
+	if employee.Password != "" || repo.saved[0].Password != "" {
+		t.Errorf("password kept on the employee")
+	}
+	if ok, err := checkPassword("s3cret", repo.hash); !ok || err != nil {
+		t.Errorf("saved hash %q does not match the password: %v", repo.hash, err)
+	}
+}
+
+func TestClockIn(t *testing.T) {
+	employees := map[int]models.Employee{
+		1: {ID: 1, Name: "Ann", Role: "barista", Active: true},
+		2: {ID: 2, Name: "Bob", Role: "barista"},
+	}
+	tests := []struct {
+		name    string
+		id      int
+		wantErr string
+	}{
+		{name: "active", id: 1},
+		{name: "deactivated", id: 2, wantErr: "employee is inactive"},
+		{name: "unknown", id: 3, wantErr: "employee not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shiftRepo := &fakeShiftRepo{}
+			_, err := NewEmployeeService(&fakeEmployeeRepo{employees: employees}, shiftRepo).ClockIn(tt.id)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ClockIn() error = %v, want %q", err, tt.wantErr)
+				}
+				if len(shiftRepo.clockedIn) != 0 {
+					t.Errorf("refused clock in was stored")
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(shiftRepo.clockedIn, []int{tt.id}) {
+				t.Errorf("ClockIn() error = %v, clocked in %v", err, shiftRepo.clockedIn)
+			}
+		})
+	}
+}
+
+func TestGetLaborReport(t *testing.T) {
+	tests := []struct {
+		name       string
+		from, to   string
+		wantPeriod []string
+		wantErr    string
+	}{
+		{name: "one day", from: "2024-05-01", wantPeriod: []string{"2024-05-01", "2024-05-01"}},
+		{name: "range", from: "2024-05-01", to: "2024-05-07", wantPeriod: []string{"2024-05-01", "2024-05-07"}},
+		{name: "bad from", from: "05/01/2024", wantErr: "from must be in YYYY-MM-DD format"},
+		{name: "bad to", from: "2024-05-01", to: "2024-5-7", wantErr: "to must be in YYYY-MM-DD format"},
+		{name: "backwards", from: "2024-05-07", to: "2024-05-01", wantErr: "to must not be before from"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shiftRepo := &fakeShiftRepo{labor: []models.LaborEntry{{Date: "2024-05-01", EmployeeID: 1, Hours: 7.3333333}}}
+			entries, err := NewEmployeeService(&fakeEmployeeRepo{}, shiftRepo).GetLaborReport(tt.from, tt.to)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("GetLaborReport() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetLaborReport() error = %v", err)
+			}
+			if !reflect.DeepEqual(shiftRepo.period, tt.wantPeriod) {
+				t.Errorf("period %v, want %v", shiftRepo.period, tt.wantPeriod)
+			}
+			if len(entries) != 1 || entries[0].Hours != 7.33 {
+				t.Errorf("entries %+v, want hours rounded to 7.33", entries)
+			}
+		})
+	}
+}
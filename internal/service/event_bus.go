@@ -0,0 +1,115 @@
+package service
+
+import (
+	"sync"
+
+	"hot-coffee/models"
+)
+
+var eventTypes = []string{
+	models.EventOrderCreated, models.EventOrderUpdated, models.EventOrderStatusChanged, models.EventOrderDeleted,
+	models.EventOrderClosed, models.EventOrderLineChanged, models.EventInventoryLow, models.EventInventoryReceived,
+}
+
+func isValidEventType(eventType string) bool {
+	for _, t := range eventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// EventPublisher is what services publish their events through.
+type EventPublisher interface {
+	Publish(eventType string, data interface{})
+	// Notify is called after a repository saved events to the outbox
+	// together with its change.
+	Notify()
+}
+
+// EventSink is a publisher the outbox dispatcher hands events to. An event
+// is handed again until HandleEvent succeeds, so sinks see it at least once.
+type EventSink interface {
+	HandleEvent(event models.Event) error
+}
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// it is dropped, it can then reconnect and resume from its last event.
+const subscriberBuffer = 64
+
+// EventBus fans events out to subscribers in the process and keeps the
+// most recent ones so a subscriber can catch up on what it missed.
+type EventBus struct {
+	mu          sync.Mutex
+	history     []models.Event
+	maxHistory  int
+	subscribers map[int]chan models.Event
+	nextSub     int
+}
+
+func NewEventBus(maxHistory int) *EventBus {
+	return &EventBus{maxHistory: maxHistory, subscribers: make(map[int]chan models.Event)}
+}
+
+func (b *EventBus) HandleEvent(event models.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, seen := range b.history {
+		if seen.ID == event.ID {
+			return nil
+		}
+	}
+	b.history = append(b.history, event)
+	if len(b.history) > b.maxHistory {
+		b.history = b.history[len(b.history)-b.maxHistory:]
+	}
+	for id, ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			close(ch)
+			delete(b.subscribers, id)
+		}
+	}
+	return nil
+}
+
+// Subscribe returns the kept events after lastID and a channel with the
+// events published from now on. The channel is closed when the subscriber
+// falls too far behind, cancel stops the subscription.
+//
+// Events are kept in the order they were published, which is not always
+// the order of their IDs, so the catch up starts right after lastID when
+// it is still kept.
+func (b *EventBus) Subscribe(lastID int64) ([]models.Event, <-chan models.Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []models.Event
+	start := -1
+	for i, event := range b.history {
+		if event.ID == lastID {
+			start = i
+		}
+	}
+	for i, event := range b.history {
+		if (start >= 0 && i > start) || (start < 0 && event.ID > lastID) {
+			missed = append(missed, event)
+		}
+	}
+	id := b.nextSub
+	b.nextSub++
+	ch := make(chan models.Event, subscriberBuffer)
+	b.subscribers[id] = ch
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, found := b.subscribers[id]; found {
+			close(ch)
+			delete(b.subscribers, id)
+		}
+	}
+	return missed, ch, cancel
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func eventIDs(events []models.Event) []int64 {
+	var ids []int64
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func TestSubscribeReplay(t *testing.T) {
+	// 5 was published before 4, as happens when transactions commit out of
+	// order
+	published := []int64{1, 2, 3, 5, 4}
+	tests := []struct {
+		name       string
+		maxHistory int
+		lastID     int64
+		want       []int64
+	}{
+		{name: "new subscriber", maxHistory: 10, lastID: 0, want: []int64{1, 2, 3, 5, 4}},
+		{name: "resume in the middle", maxHistory: 10, lastID: 2, want: []int64{3, 5, 4}},
+		{name: "resume after a later id", maxHistory: 10, lastID: 5, want: []int64{4}},
+		{name: "up to date", maxHistory: 10, lastID: 4},
+		{name: "last id no longer kept", maxHistory: 3, lastID: 1, want: []int64{3, 5, 4}},
+		{name: "unknown last id", maxHistory: 10, lastID: 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := NewEventBus(tt.maxHistory)
+			for _, id := range published {
+				bus.HandleEvent(models.Event{ID: id, Type: models.EventOrderCreated})
+			}
+			missed, _, cancel := bus.Subscribe(tt.lastID)
+			defer cancel()
+			if got := eventIDs(missed); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("missed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventBusDelivery(t *testing.T) {
+	bus := NewEventBus(10)
+	_, events, cancel := bus.Subscribe(0)
+	defer cancel()
+
+	bus.HandleEvent(models.Event{ID: 1, Type: models.EventOrderCreated})
+	// handed again by the outbox after a failing sink
+	bus.HandleEvent(models.Event{ID: 1, Type: models.EventOrderCreated})
+	bus.HandleEvent(models.Event{ID: 2, Type: models.EventOrderClosed})
+
+	var got []int64
+	for len(events) > 0 {
+		got = append(got, (<-events).ID)
+	}
+	if want := []int64{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("received %v, want %v", got, want)
+	}
+	missed, _, cancelLate := bus.Subscribe(0)
+	defer cancelLate()
+	if got := eventIDs(missed); !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Errorf("history %v, want [1 2]", got)
+	}
+}
+
+func TestEventBusDropsSlowSubscriber(t *testing.T) {
+	bus := NewEventBus(subscriberBuffer * 2)
+	_, events, cancel := bus.Subscribe(0)
+	defer cancel()
+
+	for id := int64(1); id <= subscriberBuffer+1; id++ {
+		bus.HandleEvent(models.Event{ID: id, Type: models.EventOrderUpdated})
+	}
+	received := 0
+	for range events {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("received %d events before the drop, want %d", received, subscriberBuffer)
+	}
+
+	// it resumes from the last event it got
+	missed, _, cancelResumed := bus.Subscribe(int64(received))
+	defer cancelResumed()
+	if got := eventIDs(missed); !reflect.DeepEqual(got, []int64{subscriberBuffer + 1}) {
+		t.Errorf("resumed with %v, want [%d]", got, subscriberBuffer+1)
+	}
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// The fakes embed the repository interface, a test that reaches a method
+// it did not expect panics on the nil interface.
+
+type fakeOrderRepo struct {
+	dal.OrderRepository
+	orders []models.Order
+}
+
+func (r *fakeOrderRepo) GetAll() ([]models.Order, error) {
+	return r.orders, nil
+}
+
+// DeleteOrder fails like the repository when the order moved past version.
+func (r *fakeOrderRepo) DeleteOrder(order models.Order, version int, actor models.Principal) error {
+	for i, stored := range r.orders {
+		if stored.ID != order.ID {
+			continue
+		}
+		if version != 0 && version != stored.Version {
+			return errors.New("version mismatch")
+		}
+		r.orders = append(r.orders[:i:i], r.orders[i+1:]...)
+		return nil
+	}
+	return errors.New("order not found")
+}
+
+type fakePaymentRepo struct {
+	dal.PaymentRepository
+	payments  []models.Payment
+	refunds   []models.Refund
+	saveErr   error
+	saved     []models.Payment
+	refundErr error
+	refunded  []models.Refund
+}
+
+func (r *fakePaymentRepo) GetPayments(orderID int) ([]models.Payment, error) {
+	return append([]models.Payment{}, r.payments...), nil
+}
+
+func (r *fakePaymentRepo) GetRefunds(orderID int) ([]models.Refund, error) {
+	return r.refunds, nil
+}
+
+func (r *fakePaymentRepo) GetPaidAmount(orderID int) (float64, float64, error) {
+	var paid, refunded float64
+	for _, payment := range r.payments {
+		paid += payment.Amount
+	}
+	for _, refund := range r.refunds {
+		refunded += refund.Amount
+	}
+	return paid, refunded, nil
+}
+
+func (r *fakePaymentRepo) HasPayments(orderID int) (bool, error) {
+	return len(r.payments) > 0, nil
+}
+
+func (r *fakePaymentRepo) SavePayment(payment models.Payment) (models.Payment, error) {
+	if r.saveErr != nil {
+		return models.Payment{}, r.saveErr
+	}
+	payment.ID = len(r.payments) + len(r.saved) + 1
+	r.saved = append(r.saved, payment)
+	return payment, nil
+}
+
+func (r *fakePaymentRepo) SaveRefund(refund models.Refund) (int, error) {
+	if r.refundErr != nil {
+		return 0, r.refundErr
+	}
+	r.refunded = append(r.refunded, refund)
+	return len(r.refunds) + len(r.refunded), nil
+}
+
+// fakeProcessor hands out numbered references and records what was voided.
+type fakeProcessor struct {
+	declined bool
+	calls    int
+	voided   []string
+}
+
+func (p *fakeProcessor) reference(prefix string) string {
+	p.calls++
+	return prefix + strconv.Itoa(p.calls)
+}
+
+func (p *fakeProcessor) Charge(payment models.Payment) (string, error) {
+	if p.declined {
+		return "", errors.New("insufficient funds")
+	}
+	return p.reference("ch_"), nil
+}
+
+func (p *fakeProcessor) Refund(payment models.Payment, amount float64) (string, error) {
+	if p.declined {
+		return "", errors.New("processor unavailable")
+	}
+	return p.reference("re_"), nil
+}
+
+func (p *fakeProcessor) Void(reference string) error {
+	p.voided = append(p.voided, reference)
+	return nil
+}
+
+type fakeMenuRepo struct {
+	dal.MenuRepository
+	items []models.MenuItem
+}
+
+func (r *fakeMenuRepo) GetAllIncludingArchived() ([]models.MenuItem, error) {
+	return r.items, nil
+}
+
+type fakeInventoryRepo struct {
+	dal.InventoryRepository
+	items      []models.InventoryItem
+	sufficient bool
+	checkedFor []int
+}
+
+func (r *fakeInventoryRepo) GetAll() ([]models.InventoryItem, error) {
+	return r.items, nil
+}
+
+// CheckInventory records the order whose own reservation is left out.
+func (r *fakeInventoryRepo) CheckInventory(items []models.OrderItem, channel string, orderID int) (bool, error) {
+	r.checkedFor = append(r.checkedFor, orderID)
+	return r.sufficient, nil
+}
+
+type fakeLoyaltyRepo struct {
+	dal.LoyaltyRepository
+	rules   []models.LoyaltyRule
+	balance int
+	entries []models.LoyaltyEntry
+}
+
+func (r *fakeLoyaltyRepo) GetRules() ([]models.LoyaltyRule, error) {
+	return r.rules, nil
+}
+
+func (r *fakeLoyaltyRepo) GetBalance(customerID int) (int, error) {
+	return r.balance, nil
+}
+
+func (r *fakeLoyaltyRepo) AddEntry(entry models.LoyaltyEntry) error {
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+// fakeIdempotencyRepo keeps the keys in memory. A key in expiring is gone
+// by the time it is read, as if it expired right after a failed Reserve.
+type fakeIdempotencyRepo struct {
+	records  map[string]models.IdempotencyRecord
+	expiring map[string]bool
+	reserved int
+}
+
+func newFakeIdempotencyRepo() *fakeIdempotencyRepo {
+	return &fakeIdempotencyRepo{records: map[string]models.IdempotencyRecord{}, expiring: map[string]bool{}}
+}
+
+func (r *fakeIdempotencyRepo) Reserve(caller, key, fingerprint string, ttl time.Duration) (bool, error) {
+	if _, found := r.records[caller+" "+key]; found {
+		return false, nil
+	}
+	r.reserved++
+	r.records[caller+" "+key] = models.IdempotencyRecord{Fingerprint: fingerprint}
+	return true, nil
+}
+
+func (r *fakeIdempotencyRepo) Get(caller, key string) (models.IdempotencyRecord, error) {
+	if r.expiring[caller+" "+key] {
+		delete(r.expiring, caller+" "+key)
+		delete(r.records, caller+" "+key)
+	}
+	record, found := r.records[caller+" "+key]
+	if !found {
+		return models.IdempotencyRecord{}, sql.ErrNoRows
+	}
+	return record, nil
+}
+
+func (r *fakeIdempotencyRepo) Complete(caller, key string, record models.IdempotencyRecord) error {
+	record.Fingerprint = r.records[caller+" "+key].Fingerprint
+	r.records[caller+" "+key] = record
+	return nil
+}
+
+func (r *fakeIdempotencyRepo) Release(caller, key string) error {
+	delete(r.records, caller+" "+key)
+	return nil
+}
+
+func (r *fakeIdempotencyRepo) PurgeExpired() (int, error) {
+	return 0, nil
+}
+
+type fakeEvents struct {
+	notified int
+}
+
+func (e *fakeEvents) Publish(eventType string, data interface{}) {}
+
+func (e *fakeEvents) Notify() {
+	e.notified++
+}
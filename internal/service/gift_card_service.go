@@ -0,0 +1,97 @@
+package service
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+type GiftCardService interface {
+	IssueGiftCard(card models.GiftCard) (models.GiftCard, error)
+	GetGiftCard(code string) (models.GiftCard, error)
+	LoadGiftCard(code string, amount float64) (models.GiftCard, error)
+	GetTransactions(code string) ([]models.GiftCardTransaction, error)
+}
+
+type giftCardService struct {
+	giftCardRepo dal.GiftCardRepository
+}
+
+func NewGiftCardService(giftCardRepo dal.GiftCardRepository) *giftCardService {
+	return &giftCardService{giftCardRepo: giftCardRepo}
+}
+
+func (s *giftCardService) IssueGiftCard(card models.GiftCard) (models.GiftCard, error) {
+	if card.InitialBalance <= 0 {
+		return models.GiftCard{}, errors.New("initial balance must be positive")
+	}
+	card.Code = strings.ToUpper(strings.TrimSpace(card.Code))
+	if card.Code == "" {
+		code, err := generateGiftCardCode()
+		if err != nil {
+			return models.GiftCard{}, err
+		}
+		card.Code = code
+	}
+	if len(card.Code) > 32 {
+		return models.GiftCard{}, errors.New("gift card code is too long")
+	}
+	exists, err := s.giftCardRepo.Exists(card.Code)
+	if err != nil {
+		return models.GiftCard{}, err
+	}
+	if exists {
+		return models.GiftCard{}, errors.New("gift card already exists")
+	}
+	card.Balance = card.InitialBalance
+	card.CreatedAt = getFormattedTime()
+	card.UpdatedAt = card.CreatedAt
+	return card, s.giftCardRepo.Issue(card)
+}
+
+// generateGiftCardCode returns a random code like 9F1C-04AB-77E2-D310.
+func generateGiftCardCode() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := strings.ToUpper(hex.EncodeToString(buf))
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16], nil
+}
+
+func (s *giftCardService) GetGiftCard(code string) (models.GiftCard, error) {
+	card, err := s.giftCardRepo.GetByCode(strings.ToUpper(code))
+	if err == sql.ErrNoRows {
+		return models.GiftCard{}, errors.New("gift card not found")
+	}
+	return card, err
+}
+
+func (s *giftCardService) LoadGiftCard(code string, amount float64) (models.GiftCard, error) {
+	if amount <= 0 {
+		return models.GiftCard{}, errors.New("amount must be positive")
+	}
+	if _, err := s.GetGiftCard(code); err != nil {
+		return models.GiftCard{}, err
+	}
+	return s.giftCardRepo.Load(strings.ToUpper(code), amount)
+}
+
+func (s *giftCardService) GetTransactions(code string) ([]models.GiftCardTransaction, error) {
+	if _, err := s.GetGiftCard(code); err != nil {
+		return nil, err
+	}
+	transactions, err := s.giftCardRepo.GetTransactions(strings.ToUpper(code))
+	if err != nil {
+		return nil, err
+	}
+	if transactions == nil {
+		transactions = []models.GiftCardTransaction{}
+	}
+	return transactions, nil
+}
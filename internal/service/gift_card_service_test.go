@@ -0,0 +1,139 @@
+package service
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+	"testing"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// fakeGiftCardRepo keeps cards by code and logs loads.
+type fakeGiftCardRepo struct {
+	dal.GiftCardRepository
+	cards  map[string]models.GiftCard
+	issued []models.GiftCard
+	loads  []string
+}
+
+func (r *fakeGiftCardRepo) Issue(card models.GiftCard) error {
+	r.issued = append(r.issued, card)
+	return nil
+}
+
+func (r *fakeGiftCardRepo) Exists(code string) (bool, error) {
+	_, found := r.cards[code]
+	return found, nil
+}
+
+func (r *fakeGiftCardRepo) GetByCode(code string) (models.GiftCard, error) {
+	card, found := r.cards[code]
+	if !found {
+		return models.GiftCard{}, sql.ErrNoRows
+	}
+	return card, nil
+}
+
+func (r *fakeGiftCardRepo) Load(code string, amount float64) (models.GiftCard, error) {
+	r.loads = append(r.loads, code)
+	card := r.cards[code]
+	card.Balance += amount
+	return card, nil
+}
+
+func (r *fakeGiftCardRepo) GetTransactions(code string) ([]models.GiftCardTransaction, error) {
+	return nil, nil
+}
+
+func newFakeGiftCardRepo() *fakeGiftCardRepo {
+	return &fakeGiftCardRepo{cards: map[string]models.GiftCard{
+		"GIFT-25": {Code: "GIFT-25", InitialBalance: 25, Balance: 10},
+	}}
+}
+
+func TestIssueGiftCard(t *testing.T) {
+	generated := regexp.MustCompile(`^[0-9A-F]{4}-[0-9A-F]{4}-[0-9A-F]{4}-[0-9A-F]{4}$`)
+	tests := []struct {
+		name     string
+		card     models.GiftCard
+		wantCode string
+		wantErr  string
+	}{
+		{name: "code given", card: models.GiftCard{Code: " bday-ann ", InitialBalance: 20}, wantCode: "BDAY-ANN"},
+		{name: "code generated", card: models.GiftCard{InitialBalance: 20}},
+		{name: "no balance", card: models.GiftCard{Code: "ZERO", InitialBalance: 0}, wantErr: "initial balance must be positive"},
+		{name: "code taken", card: models.GiftCard{Code: "gift-25", InitialBalance: 20}, wantErr: "gift card already exists"},
+		{name: "code too long", card: models.GiftCard{Code: strings.Repeat("A", 33), InitialBalance: 20}, wantErr: "gift card code is too long"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeGiftCardRepo()
+			card, err := NewGiftCardService(repo).IssueGiftCard(tt.card)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("IssueGiftCard() error = %v, want %q", err, tt.wantErr)
+				}
+				if len(repo.issued) != 0 {
+					t.Errorf("refused card was issued")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("IssueGiftCard() error = %v", err)
+			}
+			if tt.wantCode != "" && card.Code != tt.wantCode {
+				t.Errorf("code %q, want %q", card.Code, tt.wantCode)
+			}
+			if tt.wantCode == "" && !generated.MatchString(card.Code) {
+				t.Errorf("generated code %q", card.Code)
+			}
+			if card.Balance != card.InitialBalance || len(repo.issued) != 1 {
+				t.Errorf("issued %+v", repo.issued)
+			}
+		})
+	}
+}
+
+func TestLoadGiftCard(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		amount  float64
+		want    float64
+		wantErr string
+	}{
+		{name: "load", code: "gift-25", amount: 15, want: 25},
+		{name: "nothing to load", code: "GIFT-25", amount: 0, wantErr: "amount must be positive"},
+		{name: "unknown card", code: "NOPE", amount: 5, wantErr: "gift card not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeGiftCardRepo()
+			card, err := NewGiftCardService(repo).LoadGiftCard(tt.code, tt.amount)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("LoadGiftCard() error = %v, want %q", err, tt.wantErr)
+				}
+				if len(repo.loads) != 0 {
+					t.Errorf("refused load was stored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadGiftCard() error = %v", err)
+			}
+			if card.Balance != tt.want {
+				t.Errorf("balance %v, want %v", card.Balance, tt.want)
+			}
+		})
+	}
+}
+
+func TestGiftCardTransactionsNeverNull(t *testing.T) {
+	transactions, err := NewGiftCardService(newFakeGiftCardRepo()).GetTransactions("gift-25")
+	if err != nil || transactions == nil {
+		t.Errorf("GetTransactions() = %v, %v, want an empty list", transactions, err)
+	}
+}
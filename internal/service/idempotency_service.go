@@ -0,0 +1,96 @@
+package service
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+const maxIdempotencyKeyLength = 255
+
+type IdempotencyService interface {
+	Begin(caller, key, fingerprint string) (models.IdempotencyRecord, bool, error)
+	Finish(caller, key string, response models.IdempotencyRecord) error
+}
+
+type idempotencyService struct {
+	idempotencyRepo dal.IdempotencyRepository
+	ttl             time.Duration
+}
+
+// NewIdempotencyService keeps every key for ttl after its first use.
+func NewIdempotencyService(idempotencyRepo dal.IdempotencyRepository, ttl time.Duration) *idempotencyService {
+	return &idempotencyService{idempotencyRepo: idempotencyRepo, ttl: ttl}
+}
+
+// IdempotencyFingerprint identifies a request, a key reused with another
+// fingerprint is a different request.
+func IdempotencyFingerprint(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Begin claims the key for the request. When the key was used before with
+// the same request its stored response is returned to be replayed.
+func (s *idempotencyService) Begin(caller, key, fingerprint string) (models.IdempotencyRecord, bool, error) {
+	if len(key) > maxIdempotencyKeyLength {
+		return models.IdempotencyRecord{}, false, errors.New("Idempotency-Key must be at most 255 characters")
+	}
+	for {
+		reserved, err := s.idempotencyRepo.Reserve(caller, key, fingerprint, s.ttl)
+		if err != nil {
+			return models.IdempotencyRecord{}, false, err
+		}
+		if reserved {
+			return models.IdempotencyRecord{}, false, nil
+		}
+		record, err := s.idempotencyRepo.Get(caller, key)
+		if err == sql.ErrNoRows {
+			// released or expired since, claim it again
+			continue
+		}
+		if err != nil {
+			return models.IdempotencyRecord{}, false, err
+		}
+		if record.Fingerprint != fingerprint {
+			return models.IdempotencyRecord{}, false, errors.New("Idempotency-Key was already used for a different request")
+		}
+		if record.StatusCode == 0 {
+			return models.IdempotencyRecord{}, false, errors.New("a request with this Idempotency-Key is still in progress")
+		}
+		return record, true, nil
+	}
+}
+
+// Finish stores the response for replays. Server errors are not kept, the
+// request can be retried with the same key.
+func (s *idempotencyService) Finish(caller, key string, response models.IdempotencyRecord) error {
+	if response.StatusCode >= 500 {
+		return s.idempotencyRepo.Release(caller, key)
+	}
+	return s.idempotencyRepo.Complete(caller, key, response)
+}
+
+// Start removes expired keys every interval.
+func (s *idempotencyService) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			purged, err := s.idempotencyRepo.PurgeExpired()
+			if err != nil {
+				slog.Error("Failed to PurgeExpired", err.Error(), "idempotency keys kept")
+				continue
+			}
+			slog.Info("idempotency keys purged", "count", purged)
+		}
+	}()
+}
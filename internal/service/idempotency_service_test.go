@@ -0,0 +1,132 @@
+package service
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"hot-coffee/models"
+)
+
+func TestIdempotencyBegin(t *testing.T) {
+	const caller, key = "employee:1", "3f1c"
+	fingerprint := IdempotencyFingerprint("POST", "/orders", []byte(`{"customer_name":"Ann"}`))
+	other := IdempotencyFingerprint("POST", "/orders", []byte(`{"customer_name":"Bob"}`))
+	done := models.IdempotencyRecord{Fingerprint: fingerprint, StatusCode: 201, ContentType: "application/json", Body: []byte(`{"order_id":1}`)}
+
+	tests := []struct {
+		name       string
+		key        string
+		stored     *models.IdempotencyRecord
+		expiring   bool
+		want       models.IdempotencyRecord
+		wantReplay bool
+		wantErr    string
+		reserved   int
+	}{
+		{
+			name:     "first use claims the key",
+			key:      key,
+			reserved: 1,
+		},
+		{
+			name:       "finished request is replayed",
+			key:        key,
+			stored:     &done,
+			want:       done,
+			wantReplay: true,
+		},
+		{
+			name:    "same request still running",
+			key:     key,
+			stored:  &models.IdempotencyRecord{Fingerprint: fingerprint},
+			wantErr: "a request with this Idempotency-Key is still in progress",
+		},
+		{
+			name:    "key reused for another request",
+			key:     key,
+			stored:  &models.IdempotencyRecord{Fingerprint: other, StatusCode: 201},
+			wantErr: "Idempotency-Key was already used for a different request",
+		},
+		{
+			name:     "key that expired meanwhile is claimed again",
+			key:      key,
+			stored:   &models.IdempotencyRecord{Fingerprint: other, StatusCode: 201},
+			expiring: true,
+			reserved: 1,
+		},
+		{
+			name:    "key too long",
+			key:     strings.Repeat("k", maxIdempotencyKeyLength+1),
+			wantErr: "Idempotency-Key must be at most 255 characters",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeIdempotencyRepo()
+			if tt.stored != nil {
+				repo.records[caller+" "+tt.key] = *tt.stored
+				repo.expiring[caller+" "+tt.key] = tt.expiring
+			}
+			s := NewIdempotencyService(repo, time.Hour)
+
+			got, replay, err := s.Begin(caller, tt.key, fingerprint)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Begin() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Begin() error = %v", err)
+			}
+			if replay != tt.wantReplay || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Begin() = %+v, %v, want %+v, %v", got, replay, tt.want, tt.wantReplay)
+			}
+			if repo.reserved != tt.reserved {
+				t.Errorf("reserved %d times, want %d", repo.reserved, tt.reserved)
+			}
+		})
+	}
+}
+
+func TestIdempotencyFinish(t *testing.T) {
+	const caller, key, fingerprint = "api_key:2", "retry-me", "f00d"
+	tests := []struct {
+		name     string
+		response models.IdempotencyRecord
+		kept     bool
+	}{
+		{name: "success is kept", response: models.IdempotencyRecord{StatusCode: 201, Body: []byte(`{}`)}, kept: true},
+		{name: "client error is kept", response: models.IdempotencyRecord{StatusCode: 409, Body: []byte(`{}`)}, kept: true},
+		{name: "server error frees the key", response: models.IdempotencyRecord{StatusCode: 503}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeIdempotencyRepo()
+			s := NewIdempotencyService(repo, time.Hour)
+			if _, _, err := s.Begin(caller, key, fingerprint); err != nil {
+				t.Fatalf("Begin() error = %v", err)
+			}
+			if err := s.Finish(caller, key, tt.response); err != nil {
+				t.Fatalf("Finish() error = %v", err)
+			}
+
+			got, replay, err := s.Begin(caller, key, fingerprint)
+			if err != nil {
+				t.Fatalf("second Begin() error = %v", err)
+			}
+			if replay != tt.kept {
+				t.Fatalf("second Begin() replay = %v, want %v", replay, tt.kept)
+			}
+			if tt.kept && got.StatusCode != tt.response.StatusCode {
+				t.Errorf("replayed status %d, want %d", got.StatusCode, tt.response.StatusCode)
+			}
+			if _, err := repo.Get(caller, key); err == sql.ErrNoRows {
+				t.Errorf("key is not held after the second Begin()")
+			}
+		})
+	}
+}
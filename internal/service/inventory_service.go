@@ -2,43 +2,65 @@ package service
 
 import (
 	"errors"
+	"strings"
 
 	"hot-coffee/internal/dal"
 	"hot-coffee/models"
 )
 
 type InventoryService interface {
-	AddInventoryItem(item models.InventoryItem) error
-	DeleteInventoryItem(id string) error
+	AddInventoryItem(item models.InventoryItem, actor models.Principal) error
+	DeleteInventoryItem(id string, version int, actor models.Principal) error
+	RestoreInventoryItem(id string, actor models.Principal) error
 	GetInventoryItem() ([]models.InventoryItem, error)
 	GetInventoryItemById(id string) (models.InventoryItem, error)
-	UpdateInventoryItem(item models.InventoryItem) error
+	UpdateInventoryItem(item models.InventoryItem, actor models.Principal) error
 	GetLeftovers(sortBy string, page, pageSize int) (map[string]interface{}, error)
+	Produce(id string, quantity float64, actor models.Principal) error
+	GetRecipeSummary(id string) (models.RecipeSummary, error)
+}
+
+// IngredientInUseError is returned when an ingredient that is still part of
+// an active recipe is deleted.
+type IngredientInUseError struct {
+	MenuItemIDs []string
+	PrepItemIDs []string
+}
+
+func (e *IngredientInUseError) Error() string {
+	return "ingredient is used by: " + strings.Join(append(append([]string{}, e.MenuItemIDs...), e.PrepItemIDs...), ", ")
 }
 
 type inventoryService struct {
 	inventoryRepo dal.InventoryRepository
+	events        EventPublisher
+	stockAlerts   *StockAlerts
 }
 
-func NewInventoryService(inventoryRepo dal.InventoryRepository) *inventoryService {
-	return &inventoryService{inventoryRepo: inventoryRepo}
+func NewInventoryService(inventoryRepo dal.InventoryRepository, events EventPublisher, stockAlerts *StockAlerts) *inventoryService {
+	return &inventoryService{inventoryRepo: inventoryRepo, events: events, stockAlerts: stockAlerts}
 }
 
-func (s *inventoryService) AddInventoryItem(item models.InventoryItem) error {
+func (s *inventoryService) AddInventoryItem(item models.InventoryItem, actor models.Principal) error {
 	if !IsInventoryValid(item) {
 		return errors.New("invalid inventory item")
 	}
 	if b, _ := s.inventoryRepo.Exists(item.IngredientID); b {
 		return errors.New("item already exists")
 	}
+	if err := s.validateRecipe(item); err != nil {
+		return err
+	}
 
 	item.CreatedAt = getFormattedTime()
 	item.UpdatedAt = getFormattedTime()
+	item.Allergens = normalizeTags(item.Allergens)
+	item.DietaryTags = normalizeTags(item.DietaryTags)
 
-	return s.inventoryRepo.AddItem(item)
+	return s.inventoryRepo.AddItem(item, actor)
 }
 
-func (s *inventoryService) DeleteInventoryItem(id string) error {
+func (s *inventoryService) DeleteInventoryItem(id string, version int, actor models.Principal) error {
 	exists, err := s.inventoryRepo.Exists(id)
 	if !exists {
 		return errors.New("inventory item not found")
@@ -46,7 +68,43 @@ func (s *inventoryService) DeleteInventoryItem(id string) error {
 	if err != nil {
 		return err
 	}
-	return s.inventoryRepo.DeleteItem(id)
+	archived, err := s.inventoryRepo.IsArchived(id)
+	if err != nil {
+		return err
+	}
+	if archived {
+		return errors.New("inventory item is already archived")
+	}
+	dependents, err := s.inventoryRepo.GetDependentMenuItems(id)
+	if err != nil {
+		return err
+	}
+	prepDependents, err := s.inventoryRepo.GetDependentPrepItems(id)
+	if err != nil {
+		return err
+	}
+	if len(dependents) > 0 || len(prepDependents) > 0 {
+		return &IngredientInUseError{MenuItemIDs: dependents, PrepItemIDs: prepDependents}
+	}
+	return s.inventoryRepo.ArchiveItem(id, version, actor)
+}
+
+func (s *inventoryService) RestoreInventoryItem(id string, actor models.Principal) error {
+	exists, err := s.inventoryRepo.Exists(id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("inventory item not found")
+	}
+	archived, err := s.inventoryRepo.IsArchived(id)
+	if err != nil {
+		return err
+	}
+	if !archived {
+		return errors.New("inventory item is not archived")
+	}
+	return s.inventoryRepo.RestoreItem(id, actor)
 }
 
 func (s *inventoryService) GetInventoryItem() ([]models.InventoryItem, error) {
@@ -71,7 +129,7 @@ func (s *inventoryService) GetInventoryItemById(id string) (models.InventoryItem
 	return models.InventoryItem{}, errors.New("inventory item not found")
 }
 
-func (s *inventoryService) UpdateInventoryItem(item models.InventoryItem) error {
+func (s *inventoryService) UpdateInventoryItem(item models.InventoryItem, actor models.Principal) error {
 	exists, err := s.inventoryRepo.Exists(item.IngredientID)
 	if err != nil {
 		return nil
@@ -79,8 +137,21 @@ func (s *inventoryService) UpdateInventoryItem(item models.InventoryItem) error
 	if !exists {
 		return errors.New("inventory item not found or you cannot change item id")
 	}
+	if !isInventoryChangeValid(item) {
+		return errors.New("invalid inventory item")
+	}
+	if err := s.validateRecipe(item); err != nil {
+		return err
+	}
 	item.UpdatedAt = getFormattedTime()
-	return s.inventoryRepo.UpdateItem(item)
+	item.Allergens = normalizeTags(item.Allergens)
+	item.DietaryTags = normalizeTags(item.DietaryTags)
+	if err := s.inventoryRepo.UpdateItem(item, actor); err != nil {
+		return err
+	}
+	s.events.Notify()
+	s.stockAlerts.Check()
+	return nil
 }
 
 func (s *inventoryService) GetLeftovers(sortBy string, page, pageSize int) (map[string]interface{}, error) {
@@ -108,3 +179,68 @@ func (s *inventoryService) GetLeftovers(sortBy string, page, pageSize int) (map[
 		"data":        items,
 	}, nil
 }
+
+// validateRecipe checks the components of a prep item against the current
+// inventory, with item in place of its stored version.
+func (s *inventoryService) validateRecipe(item models.InventoryItem) error {
+	if !isPrepItem(item) {
+		return nil
+	}
+	inventory, err := s.inventoryRepo.GetAll()
+	if err != nil {
+		return err
+	}
+	book := newRecipeBook(inventory)
+	book.items[item.IngredientID] = item
+	return book.checkCycle(item.IngredientID)
+}
+
+func (s *inventoryService) Produce(id string, quantity float64, actor models.Principal) error {
+	if quantity <= 0 {
+		return errors.New("quantity must be positive")
+	}
+	inventory, err := s.inventoryRepo.GetAll()
+	if err != nil {
+		return err
+	}
+	book := newRecipeBook(inventory)
+	if _, found := book.items[id]; !found {
+		return errors.New("inventory item not found")
+	}
+	deltas, err := book.planProduction(id, quantity)
+	if err != nil {
+		return err
+	}
+	if err = s.inventoryRepo.ApplyProduction(deltas, actor); err != nil {
+		return err
+	}
+	s.stockAlerts.Check()
+	return nil
+}
+
+func (s *inventoryService) GetRecipeSummary(id string) (models.RecipeSummary, error) {
+	inventory, err := s.inventoryRepo.GetAll()
+	if err != nil {
+		return models.RecipeSummary{}, err
+	}
+	book := newRecipeBook(inventory)
+	item, found := book.items[id]
+	if !found {
+		return models.RecipeSummary{}, errors.New("inventory item not found")
+	}
+	unitCost, err := book.unitCost(id)
+	if err != nil {
+		return models.RecipeSummary{}, err
+	}
+	producible, err := book.producible(id)
+	if err != nil {
+		return models.RecipeSummary{}, err
+	}
+	return models.RecipeSummary{
+		IngredientID: id,
+		UnitCost:     unitCost,
+		OnHand:       item.Quantity,
+		Producible:   producible,
+		Recipe:       item.Recipe,
+	}, nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// archiveInventoryRepo knows which ingredients are archived and which
+// recipes still use them, and records the archive and restore calls.
+type archiveInventoryRepo struct {
+	dal.InventoryRepository
+	archived   map[string]bool
+	menuUses   map[string][]string
+	prepUses   map[string][]string
+	archivedAt []string
+	restored   []string
+}
+
+func (r *archiveInventoryRepo) Exists(id string) (bool, error) {
+	_, found := r.archived[id]
+	return found, nil
+}
+
+func (r *archiveInventoryRepo) IsArchived(id string) (bool, error) {
+	return r.archived[id], nil
+}
+
+func (r *archiveInventoryRepo) GetDependentMenuItems(id string) ([]string, error) {
+	return r.menuUses[id], nil
+}
+
+func (r *archiveInventoryRepo) GetDependentPrepItems(id string) ([]string, error) {
+	return r.prepUses[id], nil
+}
+
+func (r *archiveInventoryRepo) ArchiveItem(id string, version int, actor models.Principal) error {
+	r.archivedAt = append(r.archivedAt, id)
+	return nil
+}
+
+func (r *archiveInventoryRepo) RestoreItem(id string, actor models.Principal) error {
+	r.restored = append(r.restored, id)
+	return nil
+}
+
+func newArchiveInventoryRepo() *archiveInventoryRepo {
+	return &archiveInventoryRepo{
+		archived: map[string]bool{"milk": false, "sugar": false, "beans": false, "syrup": true},
+		menuUses: map[string][]string{"milk": {"latte", "flat_white"}},
+		prepUses: map[string][]string{"milk": {"cold_foam"}, "sugar": {"vanilla_syrup"}},
+	}
+}
+
+func TestDeleteInventoryItem(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		wantErr string
+	}{
+		{name: "unused", id: "beans"},
+		{name: "used by menu and prep items", id: "milk", wantErr: "ingredient is used by: latte, flat_white, cold_foam"},
+		{name: "used by a prep item", id: "sugar", wantErr: "ingredient is used by: vanilla_syrup"},
+		{name: "already archived", id: "syrup", wantErr: "inventory item is already archived"},
+		{name: "unknown", id: "cocoa", wantErr: "inventory item not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newArchiveInventoryRepo()
+			err := NewInventoryService(repo, &fakeEvents{}, nil).DeleteInventoryItem(tt.id, 0, models.Principal{})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("DeleteInventoryItem() error = %v, want %q", err, tt.wantErr)
+				}
+				if len(repo.archivedAt) != 0 {
+					t.Errorf("refused item was archived")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DeleteInventoryItem() error = %v", err)
+			}
+			if len(repo.archivedAt) != 1 || repo.archivedAt[0] != tt.id {
+				t.Errorf("archived %v, want [%s]", repo.archivedAt, tt.id)
+			}
+		})
+	}
+}
+
+func TestDeleteInventoryItemInUseError(t *testing.T) {
+	err := NewInventoryService(newArchiveInventoryRepo(), &fakeEvents{}, nil).DeleteInventoryItem("milk", 0, models.Principal{})
+	var inUse *IngredientInUseError
+	if !errors.As(err, &inUse) {
+		t.Fatalf("error %v is not an IngredientInUseError", err)
+	}
+	if len(inUse.MenuItemIDs) != 2 || len(inUse.PrepItemIDs) != 1 {
+		t.Errorf("in use by %+v", inUse)
+	}
+}
+
+func TestRestoreInventoryItem(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		wantErr string
+	}{
+		{name: "archived", id: "syrup"},
+		{name: "not archived", id: "milk", wantErr: "inventory item is not archived"},
+		{name: "unknown", id: "cocoa", wantErr: "inventory item not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newArchiveInventoryRepo()
+			err := NewInventoryService(repo, &fakeEvents{}, nil).RestoreInventoryItem(tt.id, models.Principal{})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("RestoreInventoryItem() error = %v, want %q", err, tt.wantErr)
+				}
+				if len(repo.restored) != 0 {
+					t.Errorf("refused item was restored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RestoreInventoryItem() error = %v", err)
+			}
+			if len(repo.restored) != 1 || repo.restored[0] != tt.id {
+				t.Errorf("restored %v, want [%s]", repo.restored, tt.id)
+			}
+		})
+	}
+}
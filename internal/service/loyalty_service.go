@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// loyaltyPointValue is the discount one redeemed point is worth.
+const loyaltyPointValue = 0.01
+
+type LoyaltyService interface {
+	GetRules() ([]models.LoyaltyRule, error)
+	UpdateRules(rules []models.LoyaltyRule) error
+	GetAccount(customerID int) (models.LoyaltyAccount, error)
+}
+
+type loyaltyService struct {
+	loyaltyRepo  dal.LoyaltyRepository
+	customerRepo dal.CustomerRepository
+}
+
+func NewLoyaltyService(loyaltyRepo dal.LoyaltyRepository, customerRepo dal.CustomerRepository) *loyaltyService {
+	return &loyaltyService{loyaltyRepo: loyaltyRepo, customerRepo: customerRepo}
+}
+
+func (s *loyaltyService) GetRules() ([]models.LoyaltyRule, error) {
+	rules, err := s.loyaltyRepo.GetRules()
+	if err != nil {
+		return nil, err
+	}
+	if rules == nil {
+		rules = []models.LoyaltyRule{}
+	}
+	return rules, nil
+}
+
+func (s *loyaltyService) UpdateRules(rules []models.LoyaltyRule) error {
+	seen := make(map[string]bool)
+	for i := range rules {
+		rules[i].Category = strings.TrimSpace(rules[i].Category)
+		if rules[i].PointsPerUnit < 0 {
+			return errors.New("points_per_unit cannot be negative")
+		}
+		if seen[rules[i].Category] {
+			return errors.New("duplicate loyalty rule for category: " + rules[i].Category)
+		}
+		seen[rules[i].Category] = true
+	}
+	return s.loyaltyRepo.ReplaceRules(rules)
+}
+
+func (s *loyaltyService) GetAccount(customerID int) (models.LoyaltyAccount, error) {
+	exists, err := s.customerRepo.Exists(customerID)
+	if err != nil {
+		return models.LoyaltyAccount{}, err
+	}
+	if !exists {
+		return models.LoyaltyAccount{}, errors.New("customer not found")
+	}
+	balance, err := s.loyaltyRepo.GetBalance(customerID)
+	if err != nil {
+		return models.LoyaltyAccount{}, err
+	}
+	entries, err := s.loyaltyRepo.GetEntries(customerID)
+	if err != nil {
+		return models.LoyaltyAccount{}, err
+	}
+	if entries == nil {
+		entries = []models.LoyaltyEntry{}
+	}
+	return models.LoyaltyAccount{CustomerID: customerID, Balance: balance, History: entries}, nil
+}
+
+// earnedPoints applies the category rule of every line, falling back to the
+// default rule. Discounts on the order lower the points proportionally.
+func earnedPoints(order models.Order, menuItems []models.MenuItem, rules []models.LoyaltyRule) int {
+	categories := make(map[string]string)
+	for _, menuItem := range menuItems {
+		categories[menuItem.ID] = menuItem.Category
+	}
+	rates := make(map[string]float64)
+	for _, rule := range rules {
+		rates[rule.Category] = rule.PointsPerUnit
+	}
+
+	var subtotal, points float64
+	for _, item := range order.Items {
+		lineTotal := item.Price * float64(item.Quantity)
+		subtotal += lineTotal
+		rate, found := rates[categories[item.MenuItemID]]
+		if !found {
+			rate = rates[""]
+		}
+		points += lineTotal * rate
+	}
+	if subtotal > 0 && order.TotalAmount < subtotal {
+		points *= order.TotalAmount / subtotal
+	}
+	return int(math.Floor(points))
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+var loyaltyMenu = []models.MenuItem{
+	{ID: "latte", Category: "coffee", Price: 4},
+	{ID: "croissant", Category: "pastry", Price: 3},
+}
+
+var loyaltyRules = []models.LoyaltyRule{
+	{PointsPerUnit: 1},
+	{Category: "pastry", PointsPerUnit: 2},
+}
+
+// loyaltyOrder is two lattes and a croissant, 11 before discounts and 14
+// points under loyaltyRules.
+func loyaltyOrder(id int, total float64) models.Order {
+	return models.Order{
+		ID:          id,
+		CustomerID:  7,
+		Status:      "closed",
+		TotalAmount: total,
+		Items: []models.OrderItem{
+			{MenuItemID: "latte", Quantity: 2, Price: 4},
+			{MenuItemID: "croissant", Quantity: 1, Price: 3},
+		},
+	}
+}
+
+func TestEarnedPoints(t *testing.T) {
+	tests := []struct {
+		name  string
+		order models.Order
+		rules []models.LoyaltyRule
+		want  int
+	}{
+		{
+			name:  "default rule",
+			order: models.Order{TotalAmount: 8, Items: []models.OrderItem{{MenuItemID: "latte", Quantity: 2, Price: 4}}},
+			rules: loyaltyRules,
+			want:  8,
+		},
+		{
+			name:  "category rule",
+			order: models.Order{TotalAmount: 3, Items: []models.OrderItem{{MenuItemID: "croissant", Quantity: 1, Price: 3}}},
+			rules: loyaltyRules,
+			want:  6,
+		},
+		{
+			name:  "discount lowers the points in proportion",
+			order: loyaltyOrder(1, 5.5),
+			rules: loyaltyRules,
+			want:  7,
+		},
+		{
+			name:  "fractions are dropped",
+			order: models.Order{TotalAmount: 4.5, Items: []models.OrderItem{{MenuItemID: "latte", Quantity: 1, Price: 4.5}}},
+			rules: loyaltyRules,
+			want:  4,
+		},
+		{
+			name:  "no default rule",
+			order: loyaltyOrder(1, 11),
+			rules: []models.LoyaltyRule{{Category: "pastry", PointsPerUnit: 2}},
+			want:  6,
+		},
+		{
+			name:  "no rules",
+			order: loyaltyOrder(1, 11),
+			want:  0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := earnedPoints(tt.order, loyaltyMenu, tt.rules); got != tt.want {
+				t.Errorf("earnedPoints() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedeemDiscount(t *testing.T) {
+	tests := []struct {
+		name     string
+		order    models.Order
+		balance  int
+		subtotal float64
+		want     models.OrderDiscount
+		wantErr  string
+	}{
+		{
+			name:     "points become a discount",
+			order:    models.Order{CustomerID: 7, RedeemPoints: 150},
+			balance:  200,
+			subtotal: 4,
+			want:     models.OrderDiscount{Kind: "loyalty", Description: "150 loyalty points", Amount: 1.5},
+		},
+		{
+			name:     "whole balance",
+			order:    models.Order{CustomerID: 7, RedeemPoints: 200},
+			balance:  200,
+			subtotal: 4,
+			want:     models.OrderDiscount{Kind: "loyalty", Description: "200 loyalty points", Amount: 2},
+		},
+		{
+			name:     "no customer",
+			order:    models.Order{RedeemPoints: 150},
+			subtotal: 4,
+			wantErr:  "redeeming points requires a customer",
+		},
+		{
+			name:     "more than the balance",
+			order:    models.Order{CustomerID: 7, RedeemPoints: 201},
+			balance:  200,
+			subtotal: 4,
+			wantErr:  "not enough loyalty points",
+		},
+		{
+			name:     "more than the order",
+			order:    models.Order{CustomerID: 7, RedeemPoints: 500},
+			balance:  1000,
+			subtotal: 4,
+			wantErr:  "redeemed points exceed order total",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewOrderService(nil, nil, nil, nil, &fakeLoyaltyRepo{balance: tt.balance}, nil, nil, nil, nil, nil)
+			got, err := s.redeemDiscount(tt.order, tt.subtotal)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("redeemDiscount() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("redeemDiscount() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("redeemDiscount() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEarnPoints(t *testing.T) {
+	parent := loyaltyOrder(1, 11)
+	parent.Status = "inactive"
+	share := models.Order{ID: 2, ParentOrderID: 1, CustomerID: 7, Status: "closed", TotalAmount: 5.5}
+
+	tests := []struct {
+		name  string
+		order models.Order
+		rules []models.LoyaltyRule
+		want  []models.LoyaltyEntry
+	}{
+		{
+			name:  "closed order earns for its lines",
+			order: loyaltyOrder(3, 11),
+			rules: loyaltyRules,
+			want:  []models.LoyaltyEntry{{CustomerID: 7, OrderID: 3, EntryType: "earn", Points: 14}},
+		},
+		{
+			name:  "share of an even split earns its part of the parent",
+			order: share,
+			rules: loyaltyRules,
+			want:  []models.LoyaltyEntry{{CustomerID: 7, OrderID: 2, EntryType: "earn", Points: 7}},
+		},
+		{
+			name:  "no customer",
+			order: models.Order{ID: 3, Status: "closed", TotalAmount: 11, Items: parent.Items},
+			rules: loyaltyRules,
+		},
+		{
+			name:  "no points",
+			order: loyaltyOrder(3, 11),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loyaltyRepo := &fakeLoyaltyRepo{rules: tt.rules}
+			s := NewOrderService(&fakeOrderRepo{orders: []models.Order{parent, share}}, &fakeMenuRepo{items: loyaltyMenu},
+				&fakeInventoryRepo{}, nil, loyaltyRepo, nil, nil, nil, nil, nil)
+			if err := s.earnPoints(tt.order); err != nil {
+				t.Fatalf("earnPoints() error = %v", err)
+			}
+			if !reflect.DeepEqual(loyaltyRepo.entries, tt.want) {
+				t.Errorf("entries = %+v, want %+v", loyaltyRepo.entries, tt.want)
+			}
+		})
+	}
+}
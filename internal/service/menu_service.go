@@ -9,22 +9,26 @@ import (
 )
 
 type MenuServiceInterface interface {
-	AddMenuItem(item models.MenuItem) error
-	GetAllMenuItems() ([]models.MenuItem, error)
+	AddMenuItem(item models.MenuItem, actor models.Principal) error
+	GetAllMenuItems(excludeAllergens []string) ([]models.MenuItem, error)
 	GetMenuItemById(id string) (models.MenuItem, error)
-	UpdateMenu(menu models.MenuItem) error
-	DeleteMenuItemById(id string) error
+	UpdateMenu(menu models.MenuItem, actor models.Principal) error
+	DeleteMenuItemById(id string, version int, actor models.Principal) error
+	RestoreMenuItemById(id string, actor models.Principal) error
+	GetMenuItemNutrition(id string) (models.MenuItemNutrition, error)
+	GetMenuNutrition() ([]models.MenuItemNutrition, error)
 }
 
 type menuService struct {
-	menuRepo dal.MenuRepository
+	menuRepo      dal.MenuRepository
+	inventoryRepo dal.InventoryRepository
 }
 
-func NewMenuService(menuRepo dal.MenuRepository) *menuService {
-	return &menuService{menuRepo: menuRepo}
+func NewMenuService(menuRepo dal.MenuRepository, inventoryRepo dal.InventoryRepository) *menuService {
+	return &menuService{menuRepo: menuRepo, inventoryRepo: inventoryRepo}
 }
 
-func (s *menuService) AddMenuItem(item models.MenuItem) error {
+func (s *menuService) AddMenuItem(item models.MenuItem, actor models.Principal) error {
 	if !IsMenuValid(item) {
 		return errors.New("invalid menu")
 	}
@@ -35,19 +39,66 @@ func (s *menuService) AddMenuItem(item models.MenuItem) error {
 	if exists {
 		return errors.New("menu item already exists")
 	}
-	return s.menuRepo.SaveMenuItem(item)
+	if err = s.checkIngredients(item); err != nil {
+		return err
+	}
+	return s.menuRepo.SaveMenuItem(item, actor)
+}
+
+// checkIngredients fails for a recipe with an ingredient that is not in the
+// inventory or is listed twice.
+func (s *menuService) checkIngredients(item models.MenuItem) error {
+	seen := make(map[string]bool)
+	for _, ingredient := range item.Ingredients {
+		if seen[ingredient.IngredientID] {
+			return errors.New("ingredient listed twice: " + ingredient.IngredientID)
+		}
+		seen[ingredient.IngredientID] = true
+		exists, err := s.inventoryRepo.Exists(ingredient.IngredientID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.New("invalid ingredient: " + ingredient.IngredientID)
+		}
+	}
+	return nil
+}
+
+// GetAllMenuItems returns the menu with computed allergens, leaving out items
+// that contain any of excludeAllergens.
+func (s *menuService) GetAllMenuItems(excludeAllergens []string) ([]models.MenuItem, error) {
+	menuItems, err := s.getMenuWithAllergens()
+	if err != nil {
+		return nil, err
+	}
+	excludeAllergens = normalizeTags(excludeAllergens)
+	if len(excludeAllergens) == 0 {
+		return menuItems, nil
+	}
+	var filtered []models.MenuItem
+	for _, menuItem := range menuItems {
+		if !containsAny(menuItem.Allergens, excludeAllergens) {
+			filtered = append(filtered, menuItem)
+		}
+	}
+	return filtered, nil
 }
 
-func (s *menuService) GetAllMenuItems() ([]models.MenuItem, error) {
+func (s *menuService) getMenuWithAllergens() ([]models.MenuItem, error) {
 	menuItems, err := s.menuRepo.GetAll()
 	if err != nil {
 		return nil, err
 	}
-	return menuItems, nil
+	inventory, err := s.inventoryRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return withAllergens(menuItems, newRecipeBook(inventory)), nil
 }
 
 func (s *menuService) GetMenuItemById(id string) (models.MenuItem, error) {
-	menuItems, err := s.menuRepo.GetAll()
+	menuItems, err := s.getMenuWithAllergens()
 	if err != nil {
 		return models.MenuItem{}, err
 	}
@@ -59,7 +110,10 @@ func (s *menuService) GetMenuItemById(id string) (models.MenuItem, error) {
 	return models.MenuItem{}, errors.New("menu item not found")
 }
 
-func (s *menuService) UpdateMenu(menu models.MenuItem) error {
+func (s *menuService) UpdateMenu(menu models.MenuItem, actor models.Principal) error {
+	if !IsMenuValid(menu) {
+		return errors.New("invalid menu")
+	}
 	exists, err := s.menuRepo.Exists(menu.ID)
 	if err != nil {
 		return err
@@ -67,10 +121,31 @@ func (s *menuService) UpdateMenu(menu models.MenuItem) error {
 	if !exists {
 		return sql.ErrNoRows
 	}
-	return s.menuRepo.Update(menu)
+	if err = s.checkIngredients(menu); err != nil {
+		return err
+	}
+	return s.menuRepo.Update(menu, actor)
+}
+
+func (s *menuService) DeleteMenuItemById(id string, version int, actor models.Principal) error {
+	exists, err := s.menuRepo.Exists(id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("menu item not found")
+	}
+	archived, err := s.menuRepo.IsArchived(id)
+	if err != nil {
+		return err
+	}
+	if archived {
+		return errors.New("menu item is already archived")
+	}
+	return s.menuRepo.ArchiveMenuItem(id, version, actor)
 }
 
-func (s *menuService) DeleteMenuItemById(id string) error {
+func (s *menuService) RestoreMenuItemById(id string, actor models.Principal) error {
 	exists, err := s.menuRepo.Exists(id)
 	if err != nil {
 		return err
@@ -78,5 +153,46 @@ func (s *menuService) DeleteMenuItemById(id string) error {
 	if !exists {
 		return errors.New("menu item not found")
 	}
-	return s.menuRepo.DeleteMenuItem(id)
+	archived, err := s.menuRepo.IsArchived(id)
+	if err != nil {
+		return err
+	}
+	if !archived {
+		return errors.New("menu item is not archived")
+	}
+	return s.menuRepo.RestoreMenuItem(id, actor)
+}
+
+func (s *menuService) GetMenuItemNutrition(id string) (models.MenuItemNutrition, error) {
+	menuItem, err := s.GetMenuItemById(id)
+	if err != nil {
+		return models.MenuItemNutrition{}, err
+	}
+	inventory, err := s.inventoryRepo.GetAll()
+	if err != nil {
+		return models.MenuItemNutrition{}, err
+	}
+	return newRecipeBook(inventory).menuItemNutrition(menuItem)
+}
+
+func (s *menuService) GetMenuNutrition() ([]models.MenuItemNutrition, error) {
+	menuItems, err := s.menuRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	inventory, err := s.inventoryRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	book := newRecipeBook(inventory)
+	var table []models.MenuItemNutrition
+	for _, menuItem := range menuItems {
+		nutrition, err := book.menuItemNutrition(menuItem)
+		if err != nil {
+			return nil, err
+		}
+		table = append(table, nutrition)
+	}
+	sortNutrition(table)
+	return table, nil
 }
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// archiveMenuRepo knows which menu items are archived and records the
+// archive and restore calls.
+type archiveMenuRepo struct {
+	dal.MenuRepository
+	archived   map[string]bool
+	archivedAt []string
+	restored   []string
+}
+
+func (r *archiveMenuRepo) Exists(id string) (bool, error) {
+	_, found := r.archived[id]
+	return found, nil
+}
+
+func (r *archiveMenuRepo) IsArchived(id string) (bool, error) {
+	return r.archived[id], nil
+}
+
+func (r *archiveMenuRepo) ArchiveMenuItem(id string, version int, actor models.Principal) error {
+	r.archivedAt = append(r.archivedAt, id)
+	return nil
+}
+
+func (r *archiveMenuRepo) RestoreMenuItem(id string, actor models.Principal) error {
+	r.restored = append(r.restored, id)
+	return nil
+}
+
+func TestArchiveMenuItem(t *testing.T) {
+	tests := []struct {
+		name    string
+		restore bool
+		id      string
+		want    []string
+		wantErr string
+	}{
+		{name: "archive", id: "latte", want: []string{"latte"}},
+		{name: "archive twice", id: "mocha", wantErr: "menu item is already archived"},
+		{name: "archive unknown", id: "chai", wantErr: "menu item not found"},
+		{name: "restore", restore: true, id: "mocha", want: []string{"mocha"}},
+		{name: "restore listed item", restore: true, id: "latte", wantErr: "menu item is not archived"},
+		{name: "restore unknown", restore: true, id: "chai", wantErr: "menu item not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &archiveMenuRepo{archived: map[string]bool{"latte": false, "mocha": true}}
+			s := NewMenuService(repo, nil)
+			var err error
+			var got []string
+			if tt.restore {
+				err = s.RestoreMenuItemById(tt.id, models.Principal{})
+				got = repo.restored
+			} else {
+				err = s.DeleteMenuItemById(tt.id, 0, models.Principal{})
+				got = repo.archivedAt
+			}
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"hot-coffee/models"
+)
+
+// unitFactors maps each measurement unit to its base unit and the size of
+// one unit in that base, units with different bases do not convert.
+var unitFactors = map[string]struct {
+	base   string
+	factor float64
+}{
+	"g":     {"g", 1},
+	"kg":    {"g", 1000},
+	"ml":    {"ml", 1},
+	"l":     {"ml", 1000},
+	"shots": {"shots", 1},
+}
+
+func convertUnit(quantity float64, from, to string) (float64, error) {
+	fromUnit, okFrom := unitFactors[from]
+	toUnit, okTo := unitFactors[to]
+	if !okFrom || !okTo || fromUnit.base != toUnit.base {
+		return 0, errors.New("cannot convert " + from + " to " + to)
+	}
+	return quantity * fromUnit.factor / toUnit.factor, nil
+}
+
+// nutritionPerUnit is the nutrition of one stock unit of id. Prep items
+// without their own values are summed from their recipe. Ingredients
+// without any data are collected in missing.
+func (b *recipeBook) nutritionPerUnit(id string, missing map[string]bool, path map[string]bool) (models.Nutrition, error) {
+	if path[id] {
+		return models.Nutrition{}, recipeCycleError(id)
+	}
+	item, found := b.items[id]
+	if !found {
+		missing[id] = true
+		return models.Nutrition{}, nil
+	}
+	if item.Nutrition != nil && item.Nutrition.Basis > 0 {
+		perStockUnit, err := convertUnit(1, item.Unit, item.Nutrition.Unit)
+		if err != nil {
+			return models.Nutrition{}, errors.New(id + ": " + err.Error())
+		}
+		return scaleNutrition(*item.Nutrition, perStockUnit/item.Nutrition.Basis), nil
+	}
+	if !isPrepItem(item) {
+		missing[id] = true
+		return models.Nutrition{}, nil
+	}
+	path[id] = true
+	defer delete(path, id)
+
+	var total models.Nutrition
+	for _, component := range item.Recipe {
+		componentNutrition, err := b.nutritionPerUnit(component.IngredientID, missing, path)
+		if err != nil {
+			return models.Nutrition{}, err
+		}
+		total = addNutrition(total, scaleNutrition(componentNutrition, component.Quantity))
+	}
+	return total, nil
+}
+
+func scaleNutrition(n models.Nutrition, factor float64) models.Nutrition {
+	return models.Nutrition{
+		Calories: n.Calories * factor,
+		Protein:  n.Protein * factor,
+		Fat:      n.Fat * factor,
+		Carbs:    n.Carbs * factor,
+	}
+}
+
+func addNutrition(a, b models.Nutrition) models.Nutrition {
+	return models.Nutrition{
+		Calories: a.Calories + b.Calories,
+		Protein:  a.Protein + b.Protein,
+		Fat:      a.Fat + b.Fat,
+		Carbs:    a.Carbs + b.Carbs,
+	}
+}
+
+// menuItemNutrition scales the recipe quantities, which are in the stock
+// unit of each ingredient, by the per-unit nutrition values.
+func (b *recipeBook) menuItemNutrition(menuItem models.MenuItem) (models.MenuItemNutrition, error) {
+	missing := make(map[string]bool)
+	var total models.Nutrition
+	for _, ingredient := range menuItem.Ingredients {
+		perUnit, err := b.nutritionPerUnit(ingredient.IngredientID, missing, map[string]bool{})
+		if err != nil {
+			return models.MenuItemNutrition{}, err
+		}
+		total = addNutrition(total, scaleNutrition(perUnit, ingredient.Quantity))
+	}
+	result := models.MenuItemNutrition{
+		MenuItemID:         menuItem.ID,
+		Name:               menuItem.Name,
+		Calories:           roundTo(total.Calories, 1),
+		Protein:            roundTo(total.Protein, 1),
+		Fat:                roundTo(total.Fat, 1),
+		Carbs:              roundTo(total.Carbs, 1),
+		MissingIngredients: setToSortedSlice(missing),
+	}
+	return result, nil
+}
+
+func roundTo(value float64, decimals int) float64 {
+	pow := math.Pow(10, float64(decimals))
+	return math.Round(value*pow) / pow
+}
+
+func sortNutrition(table []models.MenuItemNutrition) {
+	sort.Slice(table, func(i, j int) bool {
+		return table[i].MenuItemID < table[j].MenuItemID
+	})
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+// nutritionInventory keeps milk in litres with values per 100 ml, sugar in
+// grams per 100 g, and a syrup prep item without values of its own.
+var nutritionInventory = []models.InventoryItem{
+	{IngredientID: "espresso", Unit: "shots"},
+	{IngredientID: "milk", Unit: "l", Nutrition: &models.Nutrition{Basis: 100, Unit: "ml", Calories: 64, Protein: 3.4, Fat: 3.6, Carbs: 4.8}},
+	{IngredientID: "sugar", Unit: "g", Nutrition: &models.Nutrition{Basis: 100, Unit: "g", Calories: 400, Carbs: 100}},
+	{IngredientID: "syrup", Unit: "g", Recipe: []models.InventoryRecipeIngredient{
+		{IngredientID: "sugar", Quantity: 0.5},
+	}},
+	{IngredientID: "oat_milk", Unit: "l", Nutrition: &models.Nutrition{Basis: 100, Unit: "g", Calories: 46}},
+}
+
+func TestMenuItemNutrition(t *testing.T) {
+	tests := []struct {
+		name        string
+		ingredients []models.MenuItemIngredient
+		want        models.MenuItemNutrition
+		wantErr     string
+	}{
+		{
+			name:        "converted to the stock unit",
+			ingredients: []models.MenuItemIngredient{{IngredientID: "milk", Quantity: 0.2}},
+			want:        models.MenuItemNutrition{Calories: 128, Protein: 6.8, Fat: 7.2, Carbs: 9.6},
+		},
+		{
+			name:        "prep item from its recipe",
+			ingredients: []models.MenuItemIngredient{{IngredientID: "milk", Quantity: 0.2}, {IngredientID: "syrup", Quantity: 20}},
+			want:        models.MenuItemNutrition{Calories: 168, Protein: 6.8, Fat: 7.2, Carbs: 19.6},
+		},
+		{
+			name:        "ingredients without data are listed",
+			ingredients: []models.MenuItemIngredient{{IngredientID: "espresso", Quantity: 2}, {IngredientID: "cocoa", Quantity: 5}, {IngredientID: "sugar", Quantity: 5}},
+			want:        models.MenuItemNutrition{Calories: 20, Carbs: 5, MissingIngredients: []string{"cocoa", "espresso"}},
+		},
+		{
+			name:        "units that do not convert",
+			ingredients: []models.MenuItemIngredient{{IngredientID: "oat_milk", Quantity: 0.2}},
+			wantErr:     "oat_milk: cannot convert l to g",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newRecipeBook(nutritionInventory).menuItemNutrition(models.MenuItem{ID: "drink", Name: "Drink", Ingredients: tt.ingredients})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("menuItemNutrition() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("menuItemNutrition() error = %v", err)
+			}
+			tt.want.MenuItemID, tt.want.Name = "drink", "Drink"
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("menuItemNutrition() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertUnit(t *testing.T) {
+	tests := []struct {
+		quantity float64
+		from, to string
+		want     float64
+		wantErr  bool
+	}{
+		{quantity: 1, from: "kg", to: "g", want: 1000},
+		{quantity: 250, from: "ml", to: "l", want: 0.25},
+		{quantity: 2, from: "shots", to: "shots", want: 2},
+		{quantity: 1, from: "l", to: "g", wantErr: true},
+		{quantity: 1, from: "cups", to: "ml", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := convertUnit(tt.quantity, tt.from, tt.to)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("convertUnit(%v, %s, %s) = %v, %v", tt.quantity, tt.from, tt.to, got, err)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+// mergingOrderRepo merges like the repository: the target takes the given
+// lines and the sources become inactive.
+type mergingOrderRepo struct {
+	*fakeOrderRepo
+	sources []int
+}
+
+func (r *mergingOrderRepo) MergeOrders(target models.Order, sourceIDs []int, actor models.Principal) error {
+	r.sources = sourceIDs
+	for i, order := range r.orders {
+		if order.ID == target.ID {
+			r.orders[i] = target
+		}
+		for _, id := range sourceIDs {
+			if order.ID == id {
+				r.orders[i].Status = "inactive"
+			}
+		}
+	}
+	return nil
+}
+
+// pricedMenuRepo prices every menu item from its list.
+type pricedMenuRepo struct {
+	*fakeMenuRepo
+	prices map[string]float64
+}
+
+func (r *pricedMenuRepo) GetMenuItemPrice(menuItemID string) (float64, error) {
+	price, found := r.prices[menuItemID]
+	if !found {
+		return 0, errors.New("menu item not found")
+	}
+	return price, nil
+}
+
+func mergeOrders() []models.Order {
+	return []models.Order{
+		{ID: 1, CustomerName: "Ann", Channel: "dine_in", Status: "active", Items: []models.OrderItem{
+			{MenuItemID: "latte", Quantity: 1, Price: 4, PrepStatus: "ready"},
+		}},
+		{ID: 2, CustomerName: "Bob", Channel: "dine_in", Status: "active",
+			Items: []models.OrderItem{
+				{MenuItemID: "latte", Quantity: 2, Price: 4, PrepStatus: "queued"},
+				{MenuItemID: "muffin", Quantity: 1, Price: 3, PrepStatus: "handed_off"},
+			},
+			Discounts: []models.OrderDiscount{{Kind: "loyalty", Amount: 2}},
+		},
+		{ID: 3, CustomerName: "Cleo", Channel: "takeaway", Status: "active", Items: []models.OrderItem{{MenuItemID: "tea", Quantity: 1}}},
+		{ID: 4, CustomerName: "Dan", Channel: "dine_in", Status: "closed", Items: []models.OrderItem{{MenuItemID: "tea", Quantity: 1}}},
+		{ID: 5, CustomerName: "Eve", Channel: "dine_in", Status: "active", Items: []models.OrderItem{{MenuItemID: "tea", Quantity: 1}}},
+	}
+}
+
+func TestMergeOrders(t *testing.T) {
+	orderRepo := &mergingOrderRepo{fakeOrderRepo: &fakeOrderRepo{orders: mergeOrders()}}
+	menuRepo := &pricedMenuRepo{fakeMenuRepo: &fakeMenuRepo{}, prices: map[string]float64{"latte": 4.5, "muffin": 3}}
+	events := &fakeEvents{}
+	s := NewOrderService(orderRepo, menuRepo, &fakeInventoryRepo{}, nil, nil, &fakePaymentRepo{}, nil, nil, events, nil)
+
+	merged, err := s.MergeOrders(models.MergeOrdersRequest{OrderIDs: []int{1, 2}}, models.Principal{EmployeeID: 2, Role: "barista"})
+	if err != nil {
+		t.Fatalf("MergeOrders() error = %v", err)
+	}
+	var lines []string
+	for _, item := range merged.Items {
+		lines = append(lines, fmt.Sprintf("%s x%d at %.2f %s", item.MenuItemID, item.Quantity, item.Price, item.PrepStatus))
+	}
+	// a line is as far along as the least advanced of the lines merged into it
+	want := []string{"latte x3 at 4.50 queued", "muffin x1 at 3.00 handed_off"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines %v, want %v", lines, want)
+	}
+	if merged.ID != 1 || merged.CustomerName != "Ann" || merged.TotalAmount != 14.5 || len(merged.Discounts) != 1 {
+		t.Errorf("merged order %+v, want order 1 at 14.50 with the loyalty discount", merged)
+	}
+	if !reflect.DeepEqual(orderRepo.sources, []int{2}) || events.notified != 1 {
+		t.Errorf("sources %v, notified %d", orderRepo.sources, events.notified)
+	}
+}
+
+func TestMergeOrdersRefused(t *testing.T) {
+	tests := []struct {
+		name     string
+		ids      []int
+		payments []models.Payment
+		wantErr  string
+	}{
+		{name: "one order", ids: []int{1}, wantErr: "at least two orders are needed to merge"},
+		{name: "listed twice", ids: []int{1, 2, 1}, wantErr: "order listed twice: 1"},
+		{name: "unknown order", ids: []int{1, 9}, wantErr: "order not found"},
+		{name: "closed order", ids: []int{1, 4}, wantErr: "only active orders can be merged"},
+		{name: "other channel", ids: []int{1, 3}, wantErr: "only orders of the same channel can be merged"},
+		{
+			name:     "paid source",
+			ids:      []int{1, 5},
+			payments: []models.Payment{{ID: 1, OrderID: 5, Tender: "cash", Amount: 2}},
+			wantErr:  "cannot merge an order with payments into another",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orderRepo := &mergingOrderRepo{fakeOrderRepo: &fakeOrderRepo{orders: mergeOrders()}}
+			events := &fakeEvents{}
+			s := NewOrderService(orderRepo, &pricedMenuRepo{fakeMenuRepo: &fakeMenuRepo{}}, &fakeInventoryRepo{}, nil, nil,
+				&fakePaymentRepo{payments: tt.payments}, nil, nil, events, nil)
+			_, err := s.MergeOrders(models.MergeOrdersRequest{OrderIDs: tt.ids}, models.Principal{})
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("MergeOrders() error = %v, want %q", err, tt.wantErr)
+			}
+			if orderRepo.sources != nil || events.notified != 0 {
+				t.Errorf("refused merge was stored or notified")
+			}
+		})
+	}
+}
@@ -1,7 +1,12 @@
 package service
 
 import (
+	"database/sql"
 	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 
 	"hot-coffee/internal/dal"
 	"hot-coffee/internal/utils"
@@ -10,28 +15,52 @@ import (
 
 type OrderService interface {
 	GetOrderItemById(id int) (models.Order, error)
-	GetOrderItem() ([]models.Order, error)
-	PostOrUpdate(order models.Order, id int) error
-	UpdateOrderStatus(orderId int) error
-	DeleteOrder(orderID int) error
-	GetNumberOfOrderedItems(startDate, endDate string) (map[string]int, error)
-	GetOrdersGroupedByDay(month string) (map[string]interface{}, error)
-	GetOrdersGroupedByMonth(year string) (map[string]interface{}, error)
-	ProcessBatchOrders(orders []models.Order) (*models.BatchOrderResponse, error)
+	GetOrderItem(channel string) ([]models.Order, error)
+	PostOrUpdate(order models.Order, id int, actor models.Principal) (int, error)
+	UpdateOrderStatus(orderId int, tip float64, actor models.Principal) error
+	DeleteOrder(orderID, version int, actor models.Principal) error
+	CancelOrder(orderID int, actor models.Principal) error
+	SplitOrder(orderID int, request models.SplitOrderRequest, actor models.Principal) ([]models.Order, error)
+	MergeOrders(request models.MergeOrdersRequest, actor models.Principal) (models.Order, error)
+	GetNumberOfOrderedItems(startDate, endDate, channel string) (map[string]int, error)
+	GetOrdersGroupedByDay(month, channel string) (map[string]interface{}, error)
+	GetOrdersGroupedByMonth(year, channel string) (map[string]interface{}, error)
+	ProcessBatchOrders(orders []models.Order, actor models.Principal) (*models.BatchOrderResponse, error)
 }
 
 type orderService struct {
 	orderRepo     dal.OrderRepository
 	menuRepo      dal.MenuRepository
 	inventoryRepo dal.InventoryRepository
+	customerRepo  dal.CustomerRepository
+	loyaltyRepo   dal.LoyaltyRepository
+	paymentRepo   dal.PaymentRepository
+	tableRepo     dal.TableRepository
+	pickupRepo    dal.PickupRepository
+	events        EventPublisher
+	stockAlerts   *StockAlerts
 }
 
-func NewOrderService(orderRepo dal.OrderRepository, menuRepo dal.MenuRepository, inventoryRepo dal.InventoryRepository) *orderService {
-	return &orderService{orderRepo: orderRepo, menuRepo: menuRepo, inventoryRepo: inventoryRepo}
+func NewOrderService(orderRepo dal.OrderRepository, menuRepo dal.MenuRepository, inventoryRepo dal.InventoryRepository,
+	customerRepo dal.CustomerRepository, loyaltyRepo dal.LoyaltyRepository, paymentRepo dal.PaymentRepository,
+	tableRepo dal.TableRepository, pickupRepo dal.PickupRepository, events EventPublisher, stockAlerts *StockAlerts,
+) *orderService {
+	return &orderService{
+		orderRepo:     orderRepo,
+		menuRepo:      menuRepo,
+		inventoryRepo: inventoryRepo,
+		customerRepo:  customerRepo,
+		loyaltyRepo:   loyaltyRepo,
+		paymentRepo:   paymentRepo,
+		tableRepo:     tableRepo,
+		pickupRepo:    pickupRepo,
+		events:        events,
+		stockAlerts:   stockAlerts,
+	}
 }
 
 func (s *orderService) GetOrderItemById(id int) (models.Order, error) {
-	orderItems, err := s.orderRepo.GetAll()
+	orderItems, err := s.GetOrderItem("")
 	if err != nil {
 		return models.Order{}, err
 	}
@@ -43,16 +72,51 @@ func (s *orderService) GetOrderItemById(id int) (models.Order, error) {
 	return models.Order{}, errors.New("inventory item not found")
 }
 
-func (s *orderService) GetOrderItem() ([]models.Order, error) {
+// GetOrderItem lists the orders of one channel, or of all channels when
+// channel is empty.
+func (s *orderService) GetOrderItem(channel string) ([]models.Order, error) {
+	if err := checkChannelFilter(channel); err != nil {
+		return []models.Order{}, err
+	}
 	orderItems, err := s.orderRepo.GetAll()
 	if err != nil {
 		return []models.Order{}, err
 	}
+	if channel != "" {
+		var filtered []models.Order
+		for _, order := range orderItems {
+			if order.Channel == channel {
+				filtered = append(filtered, order)
+			}
+		}
+		orderItems = filtered
+	}
+	if err = s.addAllergenWarnings(orderItems); err != nil {
+		return []models.Order{}, err
+	}
 
 	return orderItems, nil
 }
 
-func (s *orderService) UpdateOrderStatus(id int) error {
+func (s *orderService) addAllergenWarnings(orders []models.Order) error {
+	menuItems, err := s.menuRepo.GetAllIncludingArchived()
+	if err != nil {
+		return err
+	}
+	inventory, err := s.inventoryRepo.GetAll()
+	if err != nil {
+		return err
+	}
+	annotateAllergenWarnings(orders, menuItems, newRecipeBook(inventory))
+	return nil
+}
+
+// UpdateOrderStatus closes the order, tip is left on top of the payments.
+// The employee closing it is recorded on the inventory transactions.
+func (s *orderService) UpdateOrderStatus(id int, tip float64, actor models.Principal) error {
+	if tip < 0 {
+		return errors.New("tip cannot be negative")
+	}
 	orderItems, err := s.orderRepo.GetAll()
 	if err != nil {
 		return err
@@ -63,47 +127,366 @@ func (s *orderService) UpdateOrderStatus(id int) error {
 			if orderItems[i].Status == "closed" {
 				return errors.New("order is already closed")
 			}
-
-			if err = s.inventoryRepo.UpdateInventory(orderItems[i].Items); err != nil {
+			if orderItems[i].Status == "cancelled" {
+				return errors.New("order is cancelled")
+			}
+			if orderItems[i].Status == "inactive" {
+				return errors.New("order is inactive")
+			}
+			due, err := s.amountDue(orderItems[i])
+			if err != nil {
 				return err
 			}
+			if due > 0 {
+				return errors.New("order is not fully paid")
+			}
 
-			if err = s.orderRepo.CloseOrder(id); err != nil {
+			if err = s.orderRepo.CloseOrder(orderItems[i], roundTo(tip, 2), actor); err != nil {
 				return err
 			}
-			return nil
+			s.events.Notify()
+			if err = s.releaseTable(orderItems[i], "needs_cleaning"); err != nil {
+				return err
+			}
+			s.stockAlerts.Check()
+			return s.earnPoints(orderItems[i])
 		}
 	}
 	return errors.New("order item not found")
 }
 
-func (s *orderService) DeleteOrder(orderID int) error {
-	return s.orderRepo.DeleteOrder(orderID)
+func (s *orderService) earnPoints(order models.Order) error {
+	if order.CustomerID == 0 {
+		return nil
+	}
+	menuItems, err := s.menuRepo.GetAllIncludingArchived()
+	if err != nil {
+		return err
+	}
+	rules, err := s.loyaltyRepo.GetRules()
+	if err != nil {
+		return err
+	}
+	if len(order.Items) == 0 && order.ParentOrderID != 0 {
+		// a share of an even split earns its part of the parent's lines
+		parent, err := s.GetOrderItemById(order.ParentOrderID)
+		if err != nil {
+			return err
+		}
+		order.Items = parent.Items
+	}
+	points := earnedPoints(order, menuItems, rules)
+	if points <= 0 {
+		return nil
+	}
+	return s.loyaltyRepo.AddEntry(models.LoyaltyEntry{
+		CustomerID: order.CustomerID,
+		OrderID:    order.ID,
+		EntryType:  "earn",
+		Points:     points,
+	})
+}
+
+func (s *orderService) DeleteOrder(orderID, version int, actor models.Principal) error {
+	order, err := s.GetOrderItemById(orderID)
+	if err != nil {
+		return errors.New("order not found")
+	}
+	if order.Status == "closed" {
+		return errors.New("cannot delete a closed order")
+	}
+	if order.Status == "inactive" {
+		return errors.New("cannot delete an inactive order")
+	}
+	paid, err := s.paymentRepo.HasPayments(orderID)
+	if err != nil {
+		return err
+	}
+	if paid {
+		return errors.New("cannot delete an order with payments")
+	}
+	if err = s.orderRepo.DeleteOrder(order, version, actor); err != nil {
+		return err
+	}
+	s.events.Notify()
+	return s.releaseTable(order, "free")
 }
 
-func (s *orderService) PostOrUpdate(order models.Order, id int) error {
+// releaseTable gives the table of a dine-in tab the status once no order
+// of the tab is active any more.
+func (s *orderService) releaseTable(order models.Order, status string) error {
+	if order.TableID == 0 {
+		return nil
+	}
+	return s.tableRepo.Release(order.TableID, status)
+}
+
+// amountDue is what is still to be paid on the order, never below zero.
+func (s *orderService) amountDue(order models.Order) (float64, error) {
+	paid, refunded, err := s.paymentRepo.GetPaidAmount(order.ID)
+	if err != nil {
+		return 0, err
+	}
+	return math.Max(roundTo(order.TotalAmount-(paid-refunded), 2), 0), nil
+}
+
+// CancelOrder cancels an active or closed order and gives back the loyalty
+// points it earned or redeemed. Payments have to be refunded first.
+func (s *orderService) CancelOrder(orderID int, actor models.Principal) error {
+	order, err := s.GetOrderItemById(orderID)
+	if err != nil {
+		return errors.New("order not found")
+	}
+	if order.Status == "cancelled" {
+		return errors.New("order is already cancelled")
+	}
+	paid, refunded, err := s.paymentRepo.GetPaidAmount(orderID)
+	if err != nil {
+		return err
+	}
+	if roundTo(paid-refunded, 2) > 0 {
+		return errors.New("refund the order's payments before cancelling")
+	}
+	if err = s.orderRepo.CancelOrder(orderID, order.Status, actor); err != nil {
+		return err
+	}
+	s.events.Notify()
+	return s.releaseTable(order, "needs_cleaning")
+}
+
+// SplitOrder replaces an active, unpaid order by child orders that point
+// back to it. Children split by lines take their ingredients from
+// inventory when they close, an even split has no lines so the parent's
+// ingredients are taken right away.
+func (s *orderService) SplitOrder(orderID int, request models.SplitOrderRequest, actor models.Principal) ([]models.Order, error) {
+	parent, err := s.GetOrderItemById(orderID)
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+	if parent.Status != "active" {
+		return nil, errors.New("only active orders can be split")
+	}
+	paid, err := s.paymentRepo.HasPayments(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if paid {
+		return nil, errors.New("cannot split an order with payments")
+	}
+
+	var children []models.Order
+	switch {
+	case request.Parts > 0 && len(request.Bills) > 0:
+		return nil, errors.New("split by bills or by parts, not both")
+	case request.Parts > 0:
+		children, err = splitEvenly(parent, request.Parts)
+	default:
+		children, err = splitByBills(parent, request.Bills)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := getFormattedTime()
+	for i := range children {
+		if children[i].CustomerName == "" {
+			children[i].CustomerName = parent.CustomerName
+		}
+		children[i].CustomerID = parent.CustomerID
+		children[i].Channel = parent.Channel
+		children[i].TableID = parent.TableID
+		children[i].Priority = parent.Priority
+		children[i].ScheduledFor = parent.ScheduledFor
+		children[i].EmployeeID = actor.EmployeeID
+		children[i].Status = "active"
+		children[i].CreatedAt = now
+		children[i].UpdatedAt = now
+		children[i].LastStatusChange = now
+	}
+
+	childIDs, err := s.orderRepo.SplitOrder(orderID, children, actor)
+	if err != nil {
+		return nil, err
+	}
+	for i := range children {
+		children[i].ID = childIDs[i]
+		children[i].ParentOrderID = orderID
+		if children[i].Items == nil {
+			children[i].Items = []models.OrderItem{}
+		}
+	}
+	s.events.Notify()
+	if request.Parts > 0 {
+		s.stockAlerts.Check()
+	}
+	return children, nil
+}
+
+// MergeOrders combines active orders into the first one listed. Lines for
+// the same menu item are summed, the total is recomputed from current
+// prices less every discount of the merged orders. Orders that already
+// took payments can only be the target.
+func (s *orderService) MergeOrders(request models.MergeOrdersRequest, actor models.Principal) (models.Order, error) {
+	if len(request.OrderIDs) < 2 {
+		return models.Order{}, errors.New("at least two orders are needed to merge")
+	}
+	orders, err := s.orderRepo.GetAll()
+	if err != nil {
+		return models.Order{}, err
+	}
+	ordersMap := make(map[int]models.Order)
+	for _, order := range orders {
+		ordersMap[order.ID] = order
+	}
+
+	seen := make(map[int]bool)
+	var merging []models.Order
+	for i, id := range request.OrderIDs {
+		if seen[id] {
+			return models.Order{}, errors.New("order listed twice: " + strconv.Itoa(id))
+		}
+		seen[id] = true
+		order, found := ordersMap[id]
+		if !found {
+			return models.Order{}, errors.New("order not found")
+		}
+		if order.Status != "active" {
+			return models.Order{}, errors.New("only active orders can be merged")
+		}
+		if len(merging) > 0 && order.Channel != merging[0].Channel {
+			return models.Order{}, errors.New("only orders of the same channel can be merged")
+		}
+		if i > 0 {
+			paid, err := s.paymentRepo.HasPayments(id)
+			if err != nil {
+				return models.Order{}, err
+			}
+			if paid {
+				return models.Order{}, errors.New("cannot merge an order with payments into another")
+			}
+		}
+		merging = append(merging, order)
+	}
+
+	target := merging[0]
+	lines := make(map[string]int)
+	var items []models.OrderItem
+	var discounts []models.OrderDiscount
+	for _, order := range merging {
+		for _, item := range order.Items {
+			if index, found := lines[item.MenuItemID]; found {
+				items[index].Quantity += item.Quantity
+				items[index].PrepStatus = lessAdvanced(items[index].PrepStatus, item.PrepStatus)
+				if len(items[index].Customization) == 0 {
+					items[index].Customization = item.Customization
+				}
+				continue
+			}
+			item.AllergenWarnings = nil
+			lines[item.MenuItemID] = len(items)
+			items = append(items, item)
+		}
+		discounts = append(discounts, order.Discounts...)
+	}
+
+	var totalAmount float64
+	for i := range items {
+		price, err := s.menuRepo.GetMenuItemPrice(items[i].MenuItemID)
+		if err != nil {
+			return models.Order{}, err
+		}
+		items[i].Price = price
+		totalAmount += price * float64(items[i].Quantity)
+	}
+	for _, discount := range discounts {
+		totalAmount -= discount.Amount
+	}
+	target.Items = items
+	target.Discounts = discounts
+	target.TotalAmount = roundTo(math.Max(totalAmount, 0), 2)
+
+	if err = s.orderRepo.MergeOrders(target, request.OrderIDs[1:], actor); err != nil {
+		return models.Order{}, err
+	}
+	s.events.Notify()
+	for _, source := range merging[1:] {
+		if err = s.releaseTable(source, "needs_cleaning"); err != nil {
+			return models.Order{}, err
+		}
+	}
+	return s.GetOrderItemById(target.ID)
+}
+
+// PostOrUpdate creates the order when id is 0 and updates it otherwise,
+// returning the id of the saved order. New orders without a channel are
+// dine_in, updates without one keep the channel they had.
+func (s *orderService) PostOrUpdate(order models.Order, id int, actor models.Principal) (int, error) {
 	order.ID = id
+	if order.Channel == "" {
+		order.Channel = "dine_in"
+		if id != 0 {
+			if current, err := s.GetOrderItemById(id); err == nil {
+				order.Channel = current.Channel
+			}
+		}
+	}
+	if !isValidChannel(order.Channel) {
+		return 0, errors.New("channel must be one of " + strings.Join(orderChannels, ", "))
+	}
+	if order.TableID != 0 && id == 0 {
+		if order.Channel != "dine_in" {
+			return 0, errors.New("only dine_in orders can be opened on a table")
+		}
+		if _, err := s.tableRepo.GetByID(order.TableID); err != nil {
+			if err == sql.ErrNoRows {
+				return 0, errors.New("table not found")
+			}
+			return 0, err
+		}
+	}
+	if order.CustomerID != 0 {
+		customer, err := s.customerRepo.GetByID(order.CustomerID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return 0, errors.New("customer not found")
+			}
+			return 0, err
+		}
+		if order.CustomerName == "" {
+			order.CustomerName = customer.Name
+		}
+	}
 	if !IsOrderValid(order) {
-		return errors.New("order is invalid")
+		return 0, errors.New("order is invalid")
+	}
+	listed := make(map[string]bool, len(order.Items))
+	for _, item := range order.Items {
+		if listed[item.MenuItemID] {
+			return 0, errors.New("menu item " + item.MenuItemID + " is on the order more than once, use one line with its quantity")
+		}
+		listed[item.MenuItemID] = true
+	}
+	if err := s.checkSchedule(&order); err != nil {
+		return 0, err
 	}
-	sufficient, err := s.inventoryRepo.CheckInventory(order.Items)
+	sufficient, err := s.inventoryRepo.CheckInventory(order.Items, order.Channel, order.ID)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if !sufficient {
-		return errors.New("not enough inventory for order")
+		return 0, errors.New("not enough inventory for order")
 	}
 
 	err = IsValidOrder(order, s.menuRepo, s.inventoryRepo)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	var totalAmount float64
 	for i := range order.Items {
 		price, err := s.menuRepo.GetMenuItemPrice(order.Items[i].MenuItemID)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		order.Items[i].Price = price
 		totalAmount += price * float64(order.Items[i].Quantity)
@@ -112,42 +495,148 @@ func (s *orderService) PostOrUpdate(order models.Order, id int) error {
 	now := getFormattedTime()
 
 	if order.ID == 0 {
+		order.Discounts = nil
+		if order.RedeemPoints > 0 {
+			discount, err := s.redeemDiscount(order, totalAmount)
+			if err != nil {
+				return 0, err
+			}
+			order.Discounts = append(order.Discounts, discount)
+			totalAmount -= discount.Amount
+		}
+		for i := range order.Items {
+			order.Items[i].PrepStatus = "queued"
+		}
 		order.LastStatusChange = now
 		order.CreatedAt = now
 		order.UpdatedAt = now
 		order.TotalAmount = totalAmount
 		order.Status = "active"
-		_, err = s.orderRepo.SaveOrder(order)
-		return err
+		orderID, err := s.orderRepo.SaveOrder(order, actor)
+		if err != nil {
+			return 0, err
+		}
+		s.events.Notify()
+		return orderID, nil
 	} else {
+		if order.RedeemPoints > 0 {
+			return 0, errors.New("points can only be redeemed when the order is created")
+		}
+		discounts, err := s.orderRepo.GetDiscounts(order.ID)
+		if err != nil {
+			return 0, err
+		}
+		for _, discount := range discounts {
+			totalAmount -= discount.Amount
+		}
+		totalAmount = math.Max(totalAmount, 0)
 		exists, err := s.orderRepo.OrderExists(order.ID)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		if !exists {
-			return errors.New("order does not exist")
+			return 0, errors.New("order does not exist")
 		}
-		order.Status = "active"
+		current, err := s.GetOrderItemById(order.ID)
+		if err != nil {
+			return 0, err
+		}
+		carryPrepStatus(current.Items, order.Items)
+		paid, refunded, err := s.paymentRepo.GetPaidAmount(order.ID)
+		if err != nil {
+			return 0, err
+		}
+		if roundTo(paid-refunded, 2) > roundTo(totalAmount, 2) {
+			return 0, errors.New("order total cannot drop below what was paid")
+		}
+		order.Status = current.Status
 		order.UpdatedAt = now
 		order.TotalAmount = totalAmount
-		err = s.orderRepo.UpdateOrder(order)
+		if err = s.orderRepo.UpdateOrder(order, actor); err != nil {
+			return 0, err
+		}
+		s.events.Notify()
+		return order.ID, nil
+	}
+}
+
+// checkSchedule checks the pickup time of a pre-order. Updates without one
+// keep the pickup time they had, which is not checked again.
+func (s *orderService) checkSchedule(order *models.Order) error {
+	var current string
+	if order.ID != 0 {
+		if existing, err := s.GetOrderItemById(order.ID); err == nil {
+			current = existing.ScheduledFor
+		}
+	}
+	if order.ScheduledFor == "" {
+		order.ScheduledFor = current
+		return nil
+	}
+	pickup, err := time.Parse(time.RFC3339, order.ScheduledFor)
+	if err != nil {
+		return errors.New("scheduled_for must be an RFC 3339 time")
+	}
+	order.ScheduledFor = pickup.UTC().Format(time.RFC3339)
+	if previous, err := time.Parse(time.RFC3339, current); err == nil && previous.Equal(pickup) {
+		return nil
+	}
+	if order.ID == 0 && order.TableID != 0 {
+		return errors.New("orders on a table cannot be scheduled")
+	}
+	settings, err := s.pickupRepo.GetSettings()
+	if err != nil {
 		return err
 	}
+	return checkPickupTime(settings, pickup)
+}
+
+// redeemDiscount turns the points to redeem into a discount line, the
+// customer must have enough points and the discount cannot exceed the order.
+func (s *orderService) redeemDiscount(order models.Order, subtotal float64) (models.OrderDiscount, error) {
+	if order.CustomerID == 0 {
+		return models.OrderDiscount{}, errors.New("redeeming points requires a customer")
+	}
+	balance, err := s.loyaltyRepo.GetBalance(order.CustomerID)
+	if err != nil {
+		return models.OrderDiscount{}, err
+	}
+	if balance < order.RedeemPoints {
+		return models.OrderDiscount{}, errors.New("not enough loyalty points")
+	}
+	amount := float64(order.RedeemPoints) * loyaltyPointValue
+	if amount > subtotal {
+		return models.OrderDiscount{}, errors.New("redeemed points exceed order total")
+	}
+	return models.OrderDiscount{
+		Kind:        "loyalty",
+		Description: strconv.Itoa(order.RedeemPoints) + " loyalty points",
+		Amount:      amount,
+	}, nil
 }
 
-func (s *orderService) GetNumberOfOrderedItems(startDate, endDate string) (map[string]int, error) {
-	return s.orderRepo.GetNumberOfOrderedItems(startDate, endDate)
+func (s *orderService) GetNumberOfOrderedItems(startDate, endDate, channel string) (map[string]int, error) {
+	if err := checkChannelFilter(channel); err != nil {
+		return nil, err
+	}
+	return s.orderRepo.GetNumberOfOrderedItems(startDate, endDate, channel)
 }
 
-func (s *orderService) GetOrdersGroupedByDay(month string) (map[string]interface{}, error) {
-	return s.orderRepo.GetOrdersGroupedByDay(month)
+func (s *orderService) GetOrdersGroupedByDay(month, channel string) (map[string]interface{}, error) {
+	if err := checkChannelFilter(channel); err != nil {
+		return nil, err
+	}
+	return s.orderRepo.GetOrdersGroupedByDay(month, channel)
 }
 
-func (s *orderService) GetOrdersGroupedByMonth(year string) (map[string]interface{}, error) {
-	return s.orderRepo.GetOrdersGroupedByMonth(year)
+func (s *orderService) GetOrdersGroupedByMonth(year, channel string) (map[string]interface{}, error) {
+	if err := checkChannelFilter(channel); err != nil {
+		return nil, err
+	}
+	return s.orderRepo.GetOrdersGroupedByMonth(year, channel)
 }
 
-func (s *orderService) ProcessBatchOrders(orders []models.Order) (*models.BatchOrderResponse, error) {
+func (s *orderService) ProcessBatchOrders(orders []models.Order, actor models.Principal) (*models.BatchOrderResponse, error) {
 	var response models.BatchOrderResponse
 	tx, err := utils.DB.Begin()
 	if err != nil {
@@ -156,7 +645,11 @@ func (s *orderService) ProcessBatchOrders(orders []models.Order) (*models.BatchO
 	defer tx.Rollback()
 
 	for _, order := range orders {
-		ok, err := s.inventoryRepo.CheckInventory(order.Items)
+		channel := order.Channel
+		if channel == "" {
+			channel = "dine_in"
+		}
+		ok, err := s.inventoryRepo.CheckInventory(order.Items, channel, 0)
 		if err != nil {
 			return nil, err
 		}
@@ -172,15 +665,23 @@ func (s *orderService) ProcessBatchOrders(orders []models.Order) (*models.BatchO
 			continue
 		}
 
-		err = s.PostOrUpdate(order, 0)
+		orderID, err := s.PostOrUpdate(order, 0, actor)
+		if err != nil && err.Error() == "not enough inventory for order" {
+			// another order reserved the stock after the check above
+			response.ProcessedOrders = append(response.ProcessedOrders, models.ProcessedOrder{
+				CustomerName: order.CustomerName,
+				Status:       "rejected",
+				Reason:       "insufficient_inventory",
+			})
+			response.Summary.Rejected++
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
 
-		var orderID int
 		var total float64
-		err = utils.DB.QueryRow(`SELECT order_id, total_amount
-									FROM orders WHERE customer_name = $1;`, order.CustomerName).Scan(&orderID, &total)
+		err = utils.DB.QueryRow(`SELECT total_amount FROM orders WHERE order_id = $1;`, orderID).Scan(&total)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestPostOrUpdateNeedsUnreservedStock(t *testing.T) {
+	open := models.Order{ID: 5, CustomerName: "Ann", Channel: "dine_in", Status: "active",
+		Items: []models.OrderItem{{MenuItemID: "latte", Quantity: 1, Price: 4}}}
+	tests := []struct {
+		name  string
+		order models.Order
+		id    int
+		// checkedFor is the order whose own reservation is not counted
+		// against it.
+		checkedFor []int
+	}{
+		{
+			name:       "new order",
+			order:      models.Order{CustomerName: "Bob", Items: []models.OrderItem{{MenuItemID: "latte", Quantity: 2}}},
+			checkedFor: []int{0},
+		},
+		{
+			name:       "update keeps its own reservation",
+			order:      models.Order{CustomerName: "Ann", Items: []models.OrderItem{{MenuItemID: "latte", Quantity: 3}}},
+			id:         5,
+			checkedFor: []int{5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inventoryRepo := &fakeInventoryRepo{sufficient: false}
+			s := NewOrderService(&fakeOrderRepo{orders: []models.Order{open}}, &fakeMenuRepo{}, inventoryRepo,
+				nil, nil, nil, nil, nil, nil, nil)
+			_, err := s.PostOrUpdate(tt.order, tt.id, models.Principal{})
+			if err == nil || err.Error() != "not enough inventory for order" {
+				t.Fatalf("PostOrUpdate() error = %v, want %q", err, "not enough inventory for order")
+			}
+			if !reflect.DeepEqual(inventoryRepo.checkedFor, tt.checkedFor) {
+				t.Errorf("checked for orders %v, want %v", inventoryRepo.checkedFor, tt.checkedFor)
+			}
+		})
+	}
+}
+
+func TestDeleteOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		order    models.Order
+		paid     []models.Payment
+		version  int
+		wantErr  string
+		notified int
+	}{
+		{name: "unconditional", order: models.Order{ID: 1, Status: "active", Version: 3}, notified: 1},
+		{name: "at the expected version", order: models.Order{ID: 1, Status: "active", Version: 3}, version: 3, notified: 1},
+		{name: "changed since it was read", order: models.Order{ID: 1, Status: "active", Version: 4}, version: 3, wantErr: "version mismatch"},
+		{name: "closed", order: models.Order{ID: 1, Status: "closed"}, wantErr: "cannot delete a closed order"},
+		{name: "split", order: models.Order{ID: 1, Status: "inactive"}, wantErr: "cannot delete an inactive order"},
+		{
+			name:    "paid",
+			order:   models.Order{ID: 1, Status: "active"},
+			paid:    []models.Payment{{ID: 1, OrderID: 1, Tender: "cash", Amount: 4}},
+			wantErr: "cannot delete an order with payments",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orderRepo := &fakeOrderRepo{orders: []models.Order{tt.order}}
+			events := &fakeEvents{}
+			s := NewOrderService(orderRepo, &fakeMenuRepo{}, &fakeInventoryRepo{}, nil, nil,
+				&fakePaymentRepo{payments: tt.paid}, nil, nil, events, nil)
+
+			err := s.DeleteOrder(tt.order.ID, tt.version, models.Principal{})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("DeleteOrder() error = %v, want %q", err, tt.wantErr)
+				}
+				if len(orderRepo.orders) != 1 {
+					t.Errorf("order was deleted")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("DeleteOrder() error = %v", err)
+				}
+				if len(orderRepo.orders) != 0 {
+					t.Errorf("order was kept")
+				}
+			}
+			if events.notified != tt.notified {
+				t.Errorf("notified %d times, want %d", events.notified, tt.notified)
+			}
+		})
+	}
+}
+
+func TestPostOrUpdateRefusesRepeatedMenuItem(t *testing.T) {
+	order := models.Order{CustomerName: "Ann", Items: []models.OrderItem{
+		{MenuItemID: "latte", Quantity: 1},
+		{MenuItemID: "croissant", Quantity: 1},
+		{MenuItemID: "latte", Quantity: 2},
+	}}
+	inventoryRepo := &fakeInventoryRepo{sufficient: true}
+	s := NewOrderService(&fakeOrderRepo{}, &fakeMenuRepo{}, inventoryRepo, nil, nil, nil, nil, nil, nil, nil)
+
+	_, err := s.PostOrUpdate(order, 0, models.Principal{})
+	want := "menu item latte is on the order more than once, use one line with its quantity"
+	if err == nil || err.Error() != want {
+		t.Fatalf("PostOrUpdate() error = %v, want %q", err, want)
+	}
+	if len(inventoryRepo.checkedFor) != 0 {
+		t.Errorf("inventory was checked for a refused order")
+	}
+}
+
+// closingOrderRepo closes orders like the repository, failing when another
+// close got there first.
+type closingOrderRepo struct {
+	*fakeOrderRepo
+	closeErr error
+	closed   int
+}
+
+func (r *closingOrderRepo) CloseOrder(order models.Order, tip float64, actor models.Principal) error {
+	if r.closeErr != nil {
+		return r.closeErr
+	}
+	r.closed++
+	return nil
+}
+
+func TestUpdateOrderStatusClosedMeanwhile(t *testing.T) {
+	order := models.Order{ID: 1, CustomerID: 9, Status: "active", TotalAmount: 4,
+		Items: []models.OrderItem{{MenuItemID: "latte", Quantity: 1, Price: 4}}}
+	orderRepo := &closingOrderRepo{fakeOrderRepo: &fakeOrderRepo{orders: []models.Order{order}}, closeErr: errors.New("order is already closed")}
+	loyaltyRepo := &fakeLoyaltyRepo{}
+	events := &fakeEvents{}
+	s := NewOrderService(orderRepo, &fakeMenuRepo{}, &fakeInventoryRepo{}, nil, loyaltyRepo,
+		&fakePaymentRepo{payments: []models.Payment{{ID: 1, OrderID: 1, Tender: "cash", Amount: 4}}}, nil, nil, events, nil)
+
+	err := s.UpdateOrderStatus(1, 0, models.Principal{EmployeeID: 2, Role: "barista"})
+	if err == nil || err.Error() != "order is already closed" {
+		t.Fatalf("UpdateOrderStatus() error = %v, want %q", err, "order is already closed")
+	}
+	if len(loyaltyRepo.entries) != 0 || events.notified != 0 {
+		t.Errorf("second close earned points or notified")
+	}
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"hot-coffee/models"
+)
+
+const maxSplitParts = 20
+
+// splitByBills moves the lines and quantities named by each bill off the
+// parent. What is left afterwards becomes one more bill.
+func splitByBills(parent models.Order, bills []models.SplitBill) ([]models.Order, error) {
+	remaining := make(map[string]models.OrderItem)
+	var lineOrder []string
+	for _, item := range parent.Items {
+		remaining[item.MenuItemID] = item
+		lineOrder = append(lineOrder, item.MenuItemID)
+	}
+
+	var children []models.Order
+	for _, bill := range bills {
+		child := models.Order{CustomerName: bill.CustomerName}
+		taken := make(map[string]bool)
+		for _, requested := range bill.Items {
+			line, found := remaining[requested.MenuItemID]
+			if !found || line.Quantity == 0 {
+				return nil, errors.New("item is not left on the order: " + requested.MenuItemID)
+			}
+			if taken[requested.MenuItemID] {
+				return nil, errors.New("item is listed twice on a bill: " + requested.MenuItemID)
+			}
+			taken[requested.MenuItemID] = true
+			quantity := requested.Quantity
+			if quantity == 0 {
+				quantity = line.Quantity
+			}
+			if quantity < 0 || quantity > line.Quantity {
+				return nil, errors.New("split quantity exceeds the order line: " + requested.MenuItemID)
+			}
+			childLine := line
+			childLine.Quantity = quantity
+			childLine.AllergenWarnings = nil
+			child.Items = append(child.Items, childLine)
+			line.Quantity -= quantity
+			remaining[requested.MenuItemID] = line
+		}
+		if len(child.Items) == 0 {
+			return nil, errors.New("every bill needs at least one item")
+		}
+		children = append(children, child)
+	}
+
+	var rest models.Order
+	for _, id := range lineOrder {
+		if line := remaining[id]; line.Quantity > 0 {
+			line.AllergenWarnings = nil
+			rest.Items = append(rest.Items, line)
+		}
+	}
+	if len(rest.Items) > 0 {
+		children = append(children, rest)
+	}
+	if len(children) < 2 {
+		return nil, errors.New("a split needs at least two bills")
+	}
+	allocateTotals(parent, children)
+	return children, nil
+}
+
+// allocateTotals gives every child its share of the parent total in
+// proportion to its lines. The share of the parent's discounts is kept as
+// a discount line on the child and the last child absorbs the rounding.
+func allocateTotals(parent models.Order, children []models.Order) {
+	var subtotal float64
+	for _, item := range parent.Items {
+		subtotal += item.Price * float64(item.Quantity)
+	}
+	ratio := 1.0
+	if subtotal > 0 {
+		ratio = parent.TotalAmount / subtotal
+	}
+	allocated := 0.0
+	for i := range children {
+		var childSubtotal float64
+		for _, item := range children[i].Items {
+			childSubtotal += item.Price * float64(item.Quantity)
+		}
+		total := roundTo(childSubtotal*ratio, 2)
+		if i == len(children)-1 {
+			total = roundTo(parent.TotalAmount-allocated, 2)
+		}
+		allocated += total
+		children[i].TotalAmount = total
+		if discount := roundTo(childSubtotal-total, 2); discount > 0 {
+			children[i].Discounts = []models.OrderDiscount{{
+				Kind:        "split",
+				Description: "share of order " + strconv.Itoa(parent.ID) + " discounts",
+				Amount:      discount,
+			}}
+		}
+	}
+}
+
+// splitEvenly divides the total into parts bills without lines, the first
+// bills take the leftover cents.
+func splitEvenly(parent models.Order, parts int) ([]models.Order, error) {
+	if parts < 2 || parts > maxSplitParts {
+		return nil, errors.New("parts must be between 2 and " + strconv.Itoa(maxSplitParts))
+	}
+	cents := int(math.Round(parent.TotalAmount * 100))
+	share, extra := cents/parts, cents%parts
+	children := make([]models.Order, parts)
+	for i := range children {
+		childCents := share
+		if i < extra {
+			childCents++
+		}
+		children[i].TotalAmount = float64(childCents) / 100
+	}
+	return children, nil
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+// billSummary lists a child's lines and total, e.g. "latte x2, muffin x1 = 11.00".
+func billSummary(child models.Order) string {
+	var lines []string
+	for _, item := range child.Items {
+		lines = append(lines, fmt.Sprintf("%s x%d", item.MenuItemID, item.Quantity))
+	}
+	summary := fmt.Sprintf("%s = %.2f", strings.Join(lines, ", "), child.TotalAmount)
+	for _, discount := range child.Discounts {
+		summary += fmt.Sprintf(" (-%.2f)", discount.Amount)
+	}
+	return summary
+}
+
+func TestSplitByBills(t *testing.T) {
+	parent := models.Order{ID: 7, TotalAmount: 14, Items: []models.OrderItem{
+		{MenuItemID: "latte", Quantity: 2, Price: 4},
+		{MenuItemID: "muffin", Quantity: 2, Price: 3},
+	}}
+	discounted := parent
+	discounted.TotalAmount = 12.6
+
+	tests := []struct {
+		name    string
+		parent  models.Order
+		bills   []models.SplitBill
+		want    []string
+		wantErr string
+	}{
+		{
+			name:   "rest becomes a bill",
+			parent: parent,
+			bills:  []models.SplitBill{{Items: []models.OrderItem{{MenuItemID: "latte", Quantity: 1}, {MenuItemID: "muffin", Quantity: 1}}}},
+			want:   []string{"latte x1, muffin x1 = 7.00", "latte x1, muffin x1 = 7.00"},
+		},
+		{
+			name:   "whole line without a quantity",
+			parent: parent,
+			bills:  []models.SplitBill{{Items: []models.OrderItem{{MenuItemID: "latte"}}}, {Items: []models.OrderItem{{MenuItemID: "muffin"}}}},
+			want:   []string{"latte x2 = 8.00", "muffin x2 = 6.00"},
+		},
+		{
+			name:   "discount shared by subtotal",
+			parent: discounted,
+			bills:  []models.SplitBill{{Items: []models.OrderItem{{MenuItemID: "latte"}}}},
+			want:   []string{"latte x2 = 7.20 (-0.80)", "muffin x2 = 5.40 (-0.60)"},
+		},
+		{
+			name:    "more than is left",
+			parent:  parent,
+			bills:   []models.SplitBill{{Items: []models.OrderItem{{MenuItemID: "latte", Quantity: 2}}}, {Items: []models.OrderItem{{MenuItemID: "latte", Quantity: 1}}}},
+			wantErr: "item is not left on the order: latte",
+		},
+		{
+			name:    "quantity over the line",
+			parent:  parent,
+			bills:   []models.SplitBill{{Items: []models.OrderItem{{MenuItemID: "muffin", Quantity: 3}}}},
+			wantErr: "split quantity exceeds the order line: muffin",
+		},
+		{
+			name:    "item twice on a bill",
+			parent:  parent,
+			bills:   []models.SplitBill{{Items: []models.OrderItem{{MenuItemID: "muffin", Quantity: 1}, {MenuItemID: "muffin", Quantity: 1}}}},
+			wantErr: "item is listed twice on a bill: muffin",
+		},
+		{
+			name:    "empty bill",
+			parent:  parent,
+			bills:   []models.SplitBill{{CustomerName: "Ann"}},
+			wantErr: "every bill needs at least one item",
+		},
+		{
+			name:    "everything on one bill",
+			parent:  parent,
+			bills:   []models.SplitBill{{Items: []models.OrderItem{{MenuItemID: "latte"}, {MenuItemID: "muffin"}}}},
+			wantErr: "a split needs at least two bills",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			children, err := splitByBills(tt.parent, tt.bills)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("splitByBills() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("splitByBills() error = %v", err)
+			}
+			var got []string
+			for _, child := range children {
+				got = append(got, billSummary(child))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("bills %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitEvenly(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   float64
+		parts   int
+		want    []float64
+		wantErr string
+	}{
+		{name: "even", total: 12, parts: 3, want: []float64{4, 4, 4}},
+		{name: "leftover cents go first", total: 10, parts: 3, want: []float64{3.34, 3.33, 3.33}},
+		{name: "cents", total: 0.05, parts: 2, want: []float64{0.03, 0.02}},
+		{name: "one part", total: 10, parts: 1, wantErr: "parts must be between 2 and 20"},
+		{name: "too many parts", total: 10, parts: 21, wantErr: "parts must be between 2 and 20"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			children, err := splitEvenly(models.Order{TotalAmount: tt.total}, tt.parts)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("splitEvenly() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("splitEvenly() error = %v", err)
+			}
+			var got []float64
+			for _, child := range children {
+				if len(child.Items) != 0 {
+					t.Errorf("even bill has lines %+v", child.Items)
+				}
+				got = append(got, child.TotalAmount)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("totals %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitOrderRefused(t *testing.T) {
+	active := models.Order{ID: 1, CustomerName: "Ann", Status: "active", TotalAmount: 8,
+		Items: []models.OrderItem{{MenuItemID: "latte", Quantity: 2, Price: 4}}}
+	closed := models.Order{ID: 2, CustomerName: "Bob", Status: "closed", TotalAmount: 4,
+		Items: []models.OrderItem{{MenuItemID: "latte", Quantity: 1, Price: 4}}}
+	tests := []struct {
+		name     string
+		orderID  int
+		payments []models.Payment
+		request  models.SplitOrderRequest
+		wantErr  string
+	}{
+		{name: "unknown order", orderID: 3, request: models.SplitOrderRequest{Parts: 2}, wantErr: "order not found"},
+		{name: "closed order", orderID: 2, request: models.SplitOrderRequest{Parts: 2}, wantErr: "only active orders can be split"},
+		{
+			name:     "paid in part",
+			orderID:  1,
+			payments: []models.Payment{{ID: 1, OrderID: 1, Tender: "cash", Amount: 4}},
+			request:  models.SplitOrderRequest{Parts: 2},
+			wantErr:  "cannot split an order with payments",
+		},
+		{
+			name:    "bills and parts",
+			orderID: 1,
+			request: models.SplitOrderRequest{Parts: 2, Bills: []models.SplitBill{{Items: []models.OrderItem{{MenuItemID: "latte", Quantity: 1}}}}},
+			wantErr: "split by bills or by parts, not both",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := &fakeEvents{}
+			s := NewOrderService(&fakeOrderRepo{orders: []models.Order{active, closed}}, &fakeMenuRepo{}, &fakeInventoryRepo{}, nil, nil,
+				&fakePaymentRepo{payments: tt.payments}, nil, nil, events, nil)
+			_, err := s.SplitOrder(tt.orderID, tt.request, models.Principal{EmployeeID: 2, Role: "barista"})
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("SplitOrder() error = %v, want %q", err, tt.wantErr)
+			}
+			if events.notified != 0 {
+				t.Errorf("refused split notified")
+			}
+		})
+	}
+}
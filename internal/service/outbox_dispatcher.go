@@ -0,0 +1,132 @@
+package service
+
+import (
+	"log/slog"
+	"time"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+const (
+	outboxBatchSize = 100
+	// outboxRetention is how long events every publisher got are kept.
+	outboxRetention = 7 * 24 * time.Hour
+)
+
+type namedSink struct {
+	name string
+	sink EventSink
+}
+
+// OutboxDispatcher publishes the events saved in the outbox. Every
+// registered publisher gets every event in outbox order, an event stays
+// pending for a publisher until it was handed over without an error.
+type OutboxDispatcher struct {
+	outboxRepo dal.OutboxRepository
+	sinks      []namedSink
+	wake       chan struct{}
+}
+
+func NewOutboxDispatcher(outboxRepo dal.OutboxRepository) *OutboxDispatcher {
+	return &OutboxDispatcher{outboxRepo: outboxRepo, wake: make(chan struct{}, 1)}
+}
+
+// Register adds a publisher, the name keeps track of what it already got
+// so it must stay the same across restarts. Meant for setup, before Start.
+func (d *OutboxDispatcher) Register(name string, sink EventSink) {
+	d.sinks = append(d.sinks, namedSink{name: name, sink: sink})
+}
+
+// Publish saves an event that is not part of a repository transaction.
+func (d *OutboxDispatcher) Publish(eventType string, data interface{}) {
+	if err := d.outboxRepo.Append(eventType, data); err != nil {
+		slog.Error("Failed to Append", err.Error(), eventType)
+		return
+	}
+	d.Notify()
+}
+
+// Notify makes the dispatcher run now instead of at its next tick.
+func (d *OutboxDispatcher) Notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the dispatcher, every interval or when notified.
+func (d *OutboxDispatcher) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		lastPurge := time.Now()
+		for {
+			select {
+			case <-ticker.C:
+			case <-d.wake:
+			}
+			d.Dispatch()
+			if time.Since(lastPurge) > time.Hour {
+				d.purge()
+				lastPurge = time.Now()
+			}
+		}
+	}()
+}
+
+// Dispatch hands the pending events to every publisher. A publisher that
+// fails is skipped until the next run and gets the failed event again.
+func (d *OutboxDispatcher) Dispatch() {
+	for _, s := range d.sinks {
+		for {
+			events, err := d.outboxRepo.GetPending(s.name, outboxBatchSize)
+			if err != nil {
+				slog.Error("Failed to GetPending", err.Error(), s.name)
+				break
+			}
+			if !d.handOver(s, events) || len(events) < outboxBatchSize {
+				break
+			}
+		}
+	}
+}
+
+func (d *OutboxDispatcher) handOver(s namedSink, events []models.Event) bool {
+	for _, event := range events {
+		if err := s.sink.HandleEvent(event); err != nil {
+			slog.Error("Failed to HandleEvent", err.Error(), s.name)
+			return false
+		}
+		if err := d.outboxRepo.MarkDispatched(s.name, event.ID); err != nil {
+			slog.Error("Failed to MarkDispatched", err.Error(), s.name)
+			return false
+		}
+	}
+	return true
+}
+
+func (d *OutboxDispatcher) purge() {
+	var names []string
+	for _, s := range d.sinks {
+		names = append(names, s.name)
+	}
+	purged, err := d.outboxRepo.Purge(outboxRetention, names)
+	if err != nil {
+		slog.Error("Failed to Purge", err.Error(), "outbox kept")
+		return
+	}
+	slog.Info("outbox purged", "count", purged)
+}
+
+// logSink writes every event to the log.
+type logSink struct{}
+
+func NewLogSink() *logSink {
+	return &logSink{}
+}
+
+func (s *logSink) HandleEvent(event models.Event) error {
+	slog.Info("event published", "id", event.ID, "dedup_id", event.DedupID, "type", event.Type)
+	return nil
+}
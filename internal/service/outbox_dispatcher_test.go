@@ -0,0 +1,124 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"hot-coffee/models"
+)
+
+// fakeOutboxRepo keeps the outbox in memory with the publishers every
+// event was handed to.
+type fakeOutboxRepo struct {
+	events     []models.Event
+	dispatched map[string]map[int64]bool
+}
+
+func (r *fakeOutboxRepo) Append(eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	r.events = append(r.events, models.Event{ID: int64(len(r.events) + 1), Type: eventType, Data: payload})
+	return nil
+}
+
+func (r *fakeOutboxRepo) GetPending(publisher string, limit int) ([]models.Event, error) {
+	var pending []models.Event
+	for _, event := range r.events {
+		if !r.dispatched[publisher][event.ID] && len(pending) < limit {
+			pending = append(pending, event)
+		}
+	}
+	return pending, nil
+}
+
+func (r *fakeOutboxRepo) MarkDispatched(publisher string, eventID int64) error {
+	if r.dispatched[publisher] == nil {
+		r.dispatched[publisher] = map[int64]bool{}
+	}
+	r.dispatched[publisher][eventID] = true
+	return nil
+}
+
+func (r *fakeOutboxRepo) Purge(olderThan time.Duration, publishers []string) (int, error) {
+	return 0, nil
+}
+
+// recordingSink keeps the ids it was handed and fails the listed ones the
+// first time they come.
+type recordingSink struct {
+	failOnce map[int64]bool
+	got      []int64
+}
+
+func (s *recordingSink) HandleEvent(event models.Event) error {
+	if s.failOnce[event.ID] {
+		delete(s.failOnce, event.ID)
+		return errors.New("sink unavailable")
+	}
+	s.got = append(s.got, event.ID)
+	return nil
+}
+
+func TestOutboxDispatch(t *testing.T) {
+	repo := &fakeOutboxRepo{dispatched: map[string]map[int64]bool{}}
+	d := NewOutboxDispatcher(repo)
+	healthy := &recordingSink{}
+	flaky := &recordingSink{failOnce: map[int64]bool{2: true}}
+	d.Register("sse", healthy)
+	d.Register("webhooks", flaky)
+
+	for i := 1; i <= 3; i++ {
+		d.Publish(models.EventOrderCreated, map[string]int{"order_id": i})
+	}
+	d.Dispatch()
+	if want := []int64{1, 2, 3}; !reflect.DeepEqual(healthy.got, want) {
+		t.Errorf("sse got %v, want %v", healthy.got, want)
+	}
+	// the failed event holds back the ones after it for that publisher only
+	if want := []int64{1}; !reflect.DeepEqual(flaky.got, want) {
+		t.Errorf("webhooks got %v, want %v", flaky.got, want)
+	}
+
+	d.Dispatch()
+	if want := []int64{1, 2, 3}; !reflect.DeepEqual(flaky.got, want) {
+		t.Errorf("webhooks got %v after the retry, want %v", flaky.got, want)
+	}
+	if want := []int64{1, 2, 3}; !reflect.DeepEqual(healthy.got, want) {
+		t.Errorf("sse got %v after the retry, want each event once", healthy.got)
+	}
+}
+
+func TestOutboxDispatchBatches(t *testing.T) {
+	repo := &fakeOutboxRepo{dispatched: map[string]map[int64]bool{}}
+	d := NewOutboxDispatcher(repo)
+	sink := &recordingSink{}
+	d.Register("log", sink)
+	for i := 0; i < outboxBatchSize*2+5; i++ {
+		repo.Append(models.EventOrderUpdated, nil)
+	}
+	d.Dispatch()
+	if len(sink.got) != outboxBatchSize*2+5 {
+		t.Errorf("handed %d events in one run, want %d", len(sink.got), outboxBatchSize*2+5)
+	}
+}
+
+func TestOutboxPublishWakesDispatcher(t *testing.T) {
+	d := NewOutboxDispatcher(&fakeOutboxRepo{dispatched: map[string]map[int64]bool{}})
+	d.Publish(models.EventInventoryLow, nil)
+	d.Notify()
+	select {
+	case <-d.wake:
+	default:
+		t.Fatal("dispatcher was not woken")
+	}
+	select {
+	case <-d.wake:
+		t.Error("notifications were not coalesced")
+	default:
+	}
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// orderChannels are the ways an order can be placed, dine_in when none is given.
+var orderChannels = []string{"dine_in", "takeaway", "delivery"}
+
+func isValidChannel(channel string) bool {
+	for _, c := range orderChannels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// checkChannelFilter accepts a channel or empty for all channels.
+func checkChannelFilter(channel string) error {
+	if channel != "" && !isValidChannel(channel) {
+		return errors.New("channel must be one of " + strings.Join(orderChannels, ", "))
+	}
+	return nil
+}
+
+type PackagingService interface {
+	GetRules() ([]models.PackagingRule, error)
+	UpdateRules(rules []models.PackagingRule) error
+}
+
+type packagingService struct {
+	packagingRepo dal.PackagingRepository
+	inventoryRepo dal.InventoryRepository
+}
+
+func NewPackagingService(packagingRepo dal.PackagingRepository, inventoryRepo dal.InventoryRepository) *packagingService {
+	return &packagingService{packagingRepo: packagingRepo, inventoryRepo: inventoryRepo}
+}
+
+func (s *packagingService) GetRules() ([]models.PackagingRule, error) {
+	rules, err := s.packagingRepo.GetRules()
+	if err != nil {
+		return nil, err
+	}
+	if rules == nil {
+		rules = []models.PackagingRule{}
+	}
+	return rules, nil
+}
+
+// UpdateRules replaces all packaging rules. Every rule needs a known
+// channel and inventory item, and an item can be listed once per channel
+// and category.
+func (s *packagingService) UpdateRules(rules []models.PackagingRule) error {
+	seen := make(map[string]bool)
+	for i := range rules {
+		rules[i].Category = strings.TrimSpace(rules[i].Category)
+		if !isValidChannel(rules[i].Channel) {
+			return errors.New("channel must be one of " + strings.Join(orderChannels, ", "))
+		}
+		if rules[i].Quantity <= 0 {
+			return errors.New("quantity must be positive")
+		}
+		exists, err := s.inventoryRepo.Exists(rules[i].IngredientID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.New("ingredient not found in inventory: " + rules[i].IngredientID)
+		}
+		key := rules[i].Channel + "/" + rules[i].Category + "/" + rules[i].IngredientID
+		if seen[key] {
+			return errors.New("duplicate packaging rule for " + rules[i].IngredientID + " on " + rules[i].Channel)
+		}
+		seen[key] = true
+	}
+	return s.packagingRepo.ReplaceRules(rules)
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// fakePackagingRepo keeps the rules it was given.
+type fakePackagingRepo struct {
+	dal.PackagingRepository
+	rules []models.PackagingRule
+}
+
+func (r *fakePackagingRepo) ReplaceRules(rules []models.PackagingRule) error {
+	r.rules = rules
+	return nil
+}
+
+// packagingInventoryRepo knows the packaging items.
+type packagingInventoryRepo struct {
+	dal.InventoryRepository
+}
+
+func (r *packagingInventoryRepo) Exists(id string) (bool, error) {
+	return id == "cup" || id == "lid" || id == "bag", nil
+}
+
+func TestUpdatePackagingRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []models.PackagingRule
+		wantErr string
+	}{
+		{
+			name: "per channel and category",
+			rules: []models.PackagingRule{
+				{Channel: "takeaway", IngredientID: "cup", Quantity: 1},
+				{Channel: "delivery", IngredientID: "cup", Quantity: 1},
+				{Channel: "delivery", Category: "pastry", IngredientID: "bag", Quantity: 1},
+				{Channel: "delivery", Category: "coffee", IngredientID: "bag", Quantity: 0.5},
+			},
+		},
+		{
+			name:    "unknown channel",
+			rules:   []models.PackagingRule{{Channel: "drive_in", IngredientID: "cup", Quantity: 1}},
+			wantErr: "channel must be one of dine_in, takeaway, delivery",
+		},
+		{
+			name:    "no quantity",
+			rules:   []models.PackagingRule{{Channel: "takeaway", IngredientID: "lid"}},
+			wantErr: "quantity must be positive",
+		},
+		{
+			name:    "unknown item",
+			rules:   []models.PackagingRule{{Channel: "takeaway", IngredientID: "straw", Quantity: 1}},
+			wantErr: "ingredient not found in inventory: straw",
+		},
+		{
+			name: "item twice for a category",
+			rules: []models.PackagingRule{
+				{Channel: "delivery", Category: "pastry", IngredientID: "bag", Quantity: 1},
+				{Channel: "delivery", Category: " pastry ", IngredientID: "bag", Quantity: 2},
+			},
+			wantErr: "duplicate packaging rule for bag on delivery",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakePackagingRepo{}
+			err := NewPackagingService(repo, &packagingInventoryRepo{}).UpdateRules(tt.rules)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("UpdateRules() error = %v, want %q", err, tt.wantErr)
+				}
+				if repo.rules != nil {
+					t.Errorf("refused rules were stored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UpdateRules() error = %v", err)
+			}
+			if !reflect.DeepEqual(repo.rules, tt.rules) {
+				t.Errorf("stored %+v, want %+v", repo.rules, tt.rules)
+			}
+		})
+	}
+}
+
+func TestCheckChannelFilter(t *testing.T) {
+	for _, channel := range []string{"", "dine_in", "takeaway", "delivery"} {
+		if err := checkChannelFilter(channel); err != nil {
+			t.Errorf("checkChannelFilter(%q) error = %v", channel, err)
+		}
+	}
+	if err := checkChannelFilter("Delivery"); err == nil {
+		t.Errorf("checkChannelFilter(Delivery) accepted")
+	}
+}
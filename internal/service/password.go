@@ -0,0 +1,63 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const passwordIterations = 100000
+
+// hashPassword derives a key with PBKDF2-HMAC-SHA256 and stores it as
+// pbkdf2-sha256$iterations$salt$hash.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2SHA256([]byte(password), salt, passwordIterations)
+	return "pbkdf2-sha256$" + strconv.Itoa(passwordIterations) + "$" + hex.EncodeToString(salt) + "$" + hex.EncodeToString(key), nil
+}
+
+func checkPassword(password, encoded string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false, errors.New("unknown password hash format")
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false, err
+	}
+	salt, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return false, err
+	}
+	want, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return false, err
+	}
+	return hmac.Equal(pbkdf2SHA256([]byte(password), salt, iterations), want), nil
+}
+
+// pbkdf2SHA256 is PBKDF2 (RFC 8018) for a single 32 byte block, which is
+// all a SHA-256 sized key needs.
+func pbkdf2SHA256(password, salt []byte, iterations int) []byte {
+	mac := hmac.New(sha256.New, password)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+	u := mac.Sum(nil)
+	key := append([]byte{}, u...)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
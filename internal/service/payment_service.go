@@ -0,0 +1,298 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+type PaymentService interface {
+	AddPayment(orderID int, payment models.Payment) (models.Payment, error)
+	GetOrderPayments(orderID int) (models.OrderPayments, error)
+	RefundOrder(orderID int, request models.RefundRequest) (models.Refund, error)
+}
+
+type paymentService struct {
+	paymentRepo dal.PaymentRepository
+	orderRepo   dal.OrderRepository
+	processor   PaymentProcessor
+}
+
+func NewPaymentService(paymentRepo dal.PaymentRepository, orderRepo dal.OrderRepository, processor PaymentProcessor) *paymentService {
+	return &paymentService{
+		paymentRepo: paymentRepo,
+		orderRepo:   orderRepo,
+		processor:   processor,
+	}
+}
+
+func (s *paymentService) findOrder(orderID int) (models.Order, error) {
+	orders, err := s.orderRepo.GetAll()
+	if err != nil {
+		return models.Order{}, err
+	}
+	for _, order := range orders {
+		if order.ID == orderID {
+			return order, nil
+		}
+	}
+	return models.Order{}, errors.New("order not found")
+}
+
+// AddPayment takes one payment towards an active order. Without an amount
+// the payment covers whatever is still due. Gift cards pay as much as
+// their balance allows, so the saved amount can be lower than requested.
+// A card charge that cannot be saved, e.g. because another payment covered
+// the order first, is voided.
+func (s *paymentService) AddPayment(orderID int, payment models.Payment) (models.Payment, error) {
+	order, err := s.findOrder(orderID)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	if order.Status != "active" {
+		return models.Payment{}, errors.New("only active orders can be paid")
+	}
+	paid, refunded, err := s.paymentRepo.GetPaidAmount(orderID)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	due := roundTo(order.TotalAmount-(paid-refunded), 2)
+	if due <= 0 {
+		return models.Payment{}, errors.New("order is already paid")
+	}
+	if payment.Amount == 0 {
+		payment.Amount = due
+	}
+	payment.Amount = roundTo(payment.Amount, 2)
+	payment.Tip = roundTo(payment.Tip, 2)
+	if payment.Amount < 0 || payment.Tip < 0 {
+		return models.Payment{}, errors.New("amount and tip cannot be negative")
+	}
+	if payment.Amount > due {
+		return models.Payment{}, errors.New("payment exceeds the amount due")
+	}
+	payment.OrderID = orderID
+	payment.ChangeDue = 0
+	payment.ProcessorReference = ""
+
+	switch payment.Tender {
+	case "cash":
+		if payment.Tendered == 0 {
+			payment.Tendered = payment.Amount + payment.Tip
+		}
+		payment.ChangeDue = roundTo(payment.Tendered-payment.Amount-payment.Tip, 2)
+		if payment.ChangeDue < 0 {
+			return models.Payment{}, errors.New("tendered cash does not cover the payment")
+		}
+	case "card":
+		payment.Tendered = 0
+		reference, err := s.processor.Charge(payment)
+		if err != nil {
+			return models.Payment{}, errors.New("card declined: " + err.Error())
+		}
+		payment.ProcessorReference = reference
+	case "gift_card":
+		payment.Tendered = 0
+		payment.GiftCardCode = strings.ToUpper(strings.TrimSpace(payment.GiftCardCode))
+		if payment.GiftCardCode == "" {
+			return models.Payment{}, errors.New("gift card code is required")
+		}
+	default:
+		return models.Payment{}, errors.New("tender must be cash, card or gift_card")
+	}
+	if payment.Tender != "gift_card" {
+		payment.GiftCardCode = ""
+	}
+
+	payment.CreatedAt = getFormattedTime()
+	saved, err := s.paymentRepo.SavePayment(payment)
+	if err != nil {
+		s.void(payment.ProcessorReference)
+		return models.Payment{}, err
+	}
+	return saved, nil
+}
+
+// void cancels a card transaction whose payment or refund was not saved.
+func (s *paymentService) void(reference string) {
+	if reference == "" {
+		return
+	}
+	if err := s.processor.Void(reference); err != nil {
+		slog.Error("Failed to Void", err.Error(), "card transaction "+reference+" has to be voided by hand")
+	}
+}
+
+func (s *paymentService) GetOrderPayments(orderID int) (models.OrderPayments, error) {
+	order, err := s.findOrder(orderID)
+	if err != nil {
+		return models.OrderPayments{}, err
+	}
+	payments, err := s.paymentRepo.GetPayments(orderID)
+	if err != nil {
+		return models.OrderPayments{}, err
+	}
+	refunds, err := s.paymentRepo.GetRefunds(orderID)
+	if err != nil {
+		return models.OrderPayments{}, err
+	}
+	if payments == nil {
+		payments = []models.Payment{}
+	}
+	if refunds == nil {
+		refunds = []models.Refund{}
+	}
+	result := models.OrderPayments{
+		OrderID:  orderID,
+		Total:    order.TotalAmount,
+		Payments: payments,
+		Refunds:  refunds,
+	}
+	for _, payment := range payments {
+		result.Paid += payment.Amount
+	}
+	for _, refund := range refunds {
+		result.Refunded += refund.Amount
+	}
+	result.Paid = roundTo(result.Paid, 2)
+	result.Refunded = roundTo(result.Refunded, 2)
+	result.Due = math.Max(roundTo(order.TotalAmount-(result.Paid-result.Refunded), 2), 0)
+	return result, nil
+}
+
+// RefundOrder refunds the requested lines, or everything still paid when
+// no lines are given. Line amounts carry the order's discounts in
+// proportion. The money goes back over the payments, latest first, each
+// on its own tender. Tips are not refunded. Card refunds that cannot be
+// saved are voided.
+func (s *paymentService) RefundOrder(orderID int, request models.RefundRequest) (models.Refund, error) {
+	order, err := s.findOrder(orderID)
+	if err != nil {
+		return models.Refund{}, err
+	}
+	payments, err := s.paymentRepo.GetPayments(orderID)
+	if err != nil {
+		return models.Refund{}, err
+	}
+	refunds, err := s.paymentRepo.GetRefunds(orderID)
+	if err != nil {
+		return models.Refund{}, err
+	}
+
+	refundable := make(map[int]float64)
+	var netPaid float64
+	for _, payment := range payments {
+		refundable[payment.ID] = payment.Amount
+		netPaid += payment.Amount
+	}
+	refundedQuantities := make(map[string]int)
+	for _, previous := range refunds {
+		netPaid -= previous.Amount
+		for _, tender := range previous.Tenders {
+			refundable[tender.PaymentID] -= tender.Amount
+		}
+		for _, line := range previous.Lines {
+			refundedQuantities[line.MenuItemID] += line.Quantity
+		}
+	}
+	netPaid = roundTo(netPaid, 2)
+	if netPaid <= 0 {
+		return models.Refund{}, errors.New("nothing left to refund")
+	}
+
+	refund := models.Refund{OrderID: orderID, Reason: request.Reason}
+	if len(request.Lines) == 0 {
+		refund.Amount = netPaid
+	} else {
+		refund.Lines, err = refundLines(order, request.Lines, refundedQuantities)
+		if err != nil {
+			return models.Refund{}, err
+		}
+		for _, line := range refund.Lines {
+			refund.Amount += line.Amount
+		}
+		refund.Amount = roundTo(refund.Amount, 2)
+		if refund.Amount > netPaid {
+			return models.Refund{}, errors.New("refund exceeds what was paid")
+		}
+	}
+
+	sort.Slice(payments, func(i, j int) bool {
+		return payments[i].ID > payments[j].ID
+	})
+	remaining := refund.Amount
+	for _, payment := range payments {
+		if remaining <= 0 {
+			break
+		}
+		amount := roundTo(math.Min(remaining, refundable[payment.ID]), 2)
+		if amount <= 0 {
+			continue
+		}
+		tender := models.RefundTender{PaymentID: payment.ID, Amount: amount}
+		switch payment.Tender {
+		case "card":
+			reference, err := s.processor.Refund(payment, amount)
+			if err != nil {
+				s.voidTenders(refund.Tenders)
+				return models.Refund{}, errors.New("card refund failed: " + err.Error())
+			}
+			tender.ProcessorReference = reference
+		}
+		refund.Tenders = append(refund.Tenders, tender)
+		remaining = roundTo(remaining-amount, 2)
+	}
+
+	refund.CreatedAt = getFormattedTime()
+	refund.ID, err = s.paymentRepo.SaveRefund(refund)
+	if err != nil {
+		s.voidTenders(refund.Tenders)
+		return models.Refund{}, err
+	}
+	return refund, nil
+}
+
+func (s *paymentService) voidTenders(tenders []models.RefundTender) {
+	for _, tender := range tenders {
+		s.void(tender.ProcessorReference)
+	}
+}
+
+// refundLines prices the requested lines at their share of the order
+// total and checks they were sold and not refunded yet.
+func refundLines(order models.Order, requested []models.RefundLine, refunded map[string]int) ([]models.RefundLine, error) {
+	sold := make(map[string]int)
+	prices := make(map[string]float64)
+	var subtotal float64
+	for _, item := range order.Items {
+		sold[item.MenuItemID] += item.Quantity
+		prices[item.MenuItemID] = item.Price
+		subtotal += item.Price * float64(item.Quantity)
+	}
+	ratio := 0.0
+	if subtotal > 0 {
+		ratio = order.TotalAmount / subtotal
+	}
+
+	var lines []models.RefundLine
+	for _, line := range requested {
+		if line.Quantity <= 0 {
+			return nil, errors.New("refund quantity must be positive")
+		}
+		if _, found := sold[line.MenuItemID]; !found {
+			return nil, errors.New("item is not on the order: " + line.MenuItemID)
+		}
+		refunded[line.MenuItemID] += line.Quantity
+		if refunded[line.MenuItemID] > sold[line.MenuItemID] {
+			return nil, errors.New("refund quantity exceeds what was sold: " + line.MenuItemID)
+		}
+		line.Amount = roundTo(prices[line.MenuItemID]*float64(line.Quantity)*ratio, 2)
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
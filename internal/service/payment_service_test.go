@@ -0,0 +1,242 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+func TestAddPayment(t *testing.T) {
+	active := models.Order{ID: 1, Status: "active", TotalAmount: 10}
+	tests := []struct {
+		name     string
+		order    models.Order
+		paid     []models.Payment
+		payment  models.Payment
+		declined bool
+		saveErr  error
+		want     models.Payment
+		wantErr  string
+		voided   []string
+	}{
+		{
+			name:    "cash without amount pays what is due and gives change",
+			order:   active,
+			paid:    []models.Payment{{ID: 1, Tender: "cash", Amount: 4}},
+			payment: models.Payment{Tender: "cash", Tendered: 10, Tip: 1},
+			want:    models.Payment{ID: 2, OrderID: 1, Tender: "cash", Amount: 6, Tip: 1, Tendered: 10, ChangeDue: 3},
+		},
+		{
+			name:    "card is charged",
+			order:   active,
+			payment: models.Payment{Tender: "card", Amount: 10, Tendered: 50},
+			want:    models.Payment{ID: 1, OrderID: 1, Tender: "card", Amount: 10, ProcessorReference: "ch_1"},
+		},
+		{
+			name:    "gift card code is normalized",
+			order:   active,
+			payment: models.Payment{Tender: "gift_card", GiftCardCode: " ab12 "},
+			want:    models.Payment{ID: 1, OrderID: 1, Tender: "gift_card", Amount: 10, GiftCardCode: "AB12"},
+		},
+		{
+			name:    "more than is due",
+			order:   active,
+			payment: models.Payment{Tender: "cash", Amount: 10.01},
+			wantErr: "payment exceeds the amount due",
+		},
+		{
+			name:    "order already paid",
+			order:   active,
+			paid:    []models.Payment{{ID: 1, Tender: "cash", Amount: 10}},
+			payment: models.Payment{Tender: "cash"},
+			wantErr: "order is already paid",
+		},
+		{
+			name:    "closed order",
+			order:   models.Order{ID: 1, Status: "closed", TotalAmount: 10},
+			payment: models.Payment{Tender: "cash"},
+			wantErr: "only active orders can be paid",
+		},
+		{
+			name:    "cash that does not cover the tip",
+			order:   active,
+			payment: models.Payment{Tender: "cash", Tendered: 10, Tip: 2},
+			wantErr: "tendered cash does not cover the payment",
+		},
+		{
+			name:    "gift card without code",
+			order:   active,
+			payment: models.Payment{Tender: "gift_card"},
+			wantErr: "gift card code is required",
+		},
+		{
+			name:    "unknown tender",
+			order:   active,
+			payment: models.Payment{Tender: "cheque"},
+			wantErr: "tender must be cash, card or gift_card",
+		},
+		{
+			name:     "declined card",
+			order:    active,
+			payment:  models.Payment{Tender: "card"},
+			declined: true,
+			wantErr:  "card declined: insufficient funds",
+		},
+		{
+			name:    "card charge that cannot be saved is voided",
+			order:   active,
+			payment: models.Payment{Tender: "card"},
+			saveErr: errors.New("order is already paid"),
+			wantErr: "order is already paid",
+			voided:  []string{"ch_1"},
+		},
+		{
+			name:    "cash that cannot be saved voids nothing",
+			order:   active,
+			payment: models.Payment{Tender: "cash"},
+			saveErr: errors.New("order is already paid"),
+			wantErr: "order is already paid",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paymentRepo := &fakePaymentRepo{payments: tt.paid, saveErr: tt.saveErr}
+			processor := &fakeProcessor{declined: tt.declined}
+			s := NewPaymentService(paymentRepo, &fakeOrderRepo{orders: []models.Order{tt.order}}, processor)
+
+			got, err := s.AddPayment(tt.order.ID, tt.payment)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("AddPayment() error = %v, want %q", err, tt.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("AddPayment() error = %v", err)
+				}
+				got.CreatedAt = ""
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("AddPayment() = %+v, want %+v", got, tt.want)
+				}
+			}
+			if !reflect.DeepEqual(processor.voided, tt.voided) {
+				t.Errorf("voided %v, want %v", processor.voided, tt.voided)
+			}
+		})
+	}
+}
+
+func TestRefundOrder(t *testing.T) {
+	// two lattes at 4 with a discount of 2, paid in cash and then by card
+	order := models.Order{
+		ID:          1,
+		Status:      "closed",
+		TotalAmount: 6,
+		Items:       []models.OrderItem{{MenuItemID: "latte", Quantity: 2, Price: 4}},
+	}
+	payments := []models.Payment{
+		{ID: 1, OrderID: 1, Tender: "cash", Amount: 2},
+		{ID: 2, OrderID: 1, Tender: "card", Amount: 4, ProcessorReference: "ch_0"},
+	}
+	tests := []struct {
+		name      string
+		previous  []models.Refund
+		request   models.RefundRequest
+		declined  bool
+		refundErr error
+		want      models.Refund
+		wantErr   string
+		voided    []string
+	}{
+		{
+			name:    "everything goes back over the latest payment first",
+			request: models.RefundRequest{Reason: "wrong order"},
+			want: models.Refund{ID: 1, OrderID: 1, Amount: 6, Reason: "wrong order", Tenders: []models.RefundTender{
+				{PaymentID: 2, Amount: 4, ProcessorReference: "re_1"},
+				{PaymentID: 1, Amount: 2},
+			}},
+		},
+		{
+			name:    "a line is priced at its share of the discounted total",
+			request: models.RefundRequest{Lines: []models.RefundLine{{MenuItemID: "latte", Quantity: 1}}},
+			want: models.Refund{ID: 1, OrderID: 1, Amount: 3,
+				Lines:   []models.RefundLine{{MenuItemID: "latte", Quantity: 1, Amount: 3}},
+				Tenders: []models.RefundTender{{PaymentID: 2, Amount: 3, ProcessorReference: "re_1"}},
+			},
+		},
+		{
+			name: "a payment is not refunded twice",
+			previous: []models.Refund{{ID: 1, Amount: 3,
+				Lines:   []models.RefundLine{{MenuItemID: "latte", Quantity: 1, Amount: 3}},
+				Tenders: []models.RefundTender{{PaymentID: 2, Amount: 3}},
+			}},
+			request: models.RefundRequest{Lines: []models.RefundLine{{MenuItemID: "latte", Quantity: 1}}},
+			want: models.Refund{ID: 2, OrderID: 1, Amount: 3,
+				Lines: []models.RefundLine{{MenuItemID: "latte", Quantity: 1, Amount: 3}},
+				Tenders: []models.RefundTender{
+					{PaymentID: 2, Amount: 1, ProcessorReference: "re_1"},
+					{PaymentID: 1, Amount: 2},
+				},
+			},
+		},
+		{
+			name: "more lines than were sold",
+			previous: []models.Refund{{ID: 1, Amount: 3,
+				Lines:   []models.RefundLine{{MenuItemID: "latte", Quantity: 1, Amount: 3}},
+				Tenders: []models.RefundTender{{PaymentID: 2, Amount: 3}},
+			}},
+			request: models.RefundRequest{Lines: []models.RefundLine{{MenuItemID: "latte", Quantity: 2}}},
+			wantErr: "refund quantity exceeds what was sold: latte",
+		},
+		{
+			name:    "a line that is not on the order",
+			request: models.RefundRequest{Lines: []models.RefundLine{{MenuItemID: "mocha", Quantity: 1}}},
+			wantErr: "item is not on the order: mocha",
+		},
+		{
+			name:     "nothing left",
+			previous: []models.Refund{{ID: 1, Amount: 6, Tenders: []models.RefundTender{{PaymentID: 2, Amount: 4}, {PaymentID: 1, Amount: 2}}}},
+			request:  models.RefundRequest{},
+			wantErr:  "nothing left to refund",
+		},
+		{
+			name:     "failed card refund",
+			request:  models.RefundRequest{},
+			declined: true,
+			wantErr:  "card refund failed: processor unavailable",
+		},
+		{
+			name:      "card refund that cannot be saved is voided",
+			request:   models.RefundRequest{},
+			refundErr: errors.New("refund exceeds what was paid"),
+			wantErr:   "refund exceeds what was paid",
+			voided:    []string{"re_1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paymentRepo := &fakePaymentRepo{payments: payments, refunds: tt.previous, refundErr: tt.refundErr}
+			processor := &fakeProcessor{declined: tt.declined}
+			s := NewPaymentService(paymentRepo, &fakeOrderRepo{orders: []models.Order{order}}, processor)
+
+			got, err := s.RefundOrder(order.ID, tt.request)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("RefundOrder() error = %v, want %q", err, tt.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("RefundOrder() error = %v", err)
+				}
+				got.CreatedAt = ""
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("RefundOrder() = %+v, want %+v", got, tt.want)
+				}
+			}
+			if !reflect.DeepEqual(processor.voided, tt.voided) {
+				t.Errorf("voided %v, want %v", processor.voided, tt.voided)
+			}
+		})
+	}
+}
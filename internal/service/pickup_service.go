@@ -0,0 +1,124 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// pickupSlotLength is the length of a pickup window, pre-orders are counted
+// against the capacity of the window their pickup time falls in.
+const pickupSlotLength = 15 * time.Minute
+
+type PickupService interface {
+	GetSlots(date string) ([]models.PickupSlot, error)
+	GetSettings() (models.PickupSettings, error)
+	UpdateSettings(settings models.PickupSettings) error
+}
+
+type pickupService struct {
+	pickupRepo dal.PickupRepository
+}
+
+func NewPickupService(pickupRepo dal.PickupRepository) *pickupService {
+	return &pickupService{pickupRepo: pickupRepo}
+}
+
+// GetSlots lists the pickup windows of the day, today when date is empty,
+// that still take pre-orders. Windows sooner than the lead time are left out.
+func (s *pickupService) GetSlots(date string) ([]models.PickupSlot, error) {
+	day := time.Now()
+	if date != "" {
+		var err error
+		day, err = time.ParseInLocation("2006-01-02", date, time.Local)
+		if err != nil {
+			return nil, errors.New("date must be YYYY-MM-DD")
+		}
+	}
+	settings, err := s.pickupRepo.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	opens, closes := atTime(day, settings.OpensAt), atTime(day, settings.ClosesAt)
+	booked, err := s.pickupRepo.CountBooked(opens, closes)
+	if err != nil {
+		return nil, err
+	}
+
+	earliest := time.Now().Add(time.Duration(settings.LeadMinutes) * time.Minute)
+	slots := []models.PickupSlot{}
+	for start := opens; start.Before(closes); start = start.Add(pickupSlotLength) {
+		if start.Before(earliest) {
+			continue
+		}
+		slot := models.PickupSlot{
+			Start:    start.Format(time.RFC3339),
+			End:      start.Add(pickupSlotLength).Format(time.RFC3339),
+			Capacity: settings.SlotCapacity,
+			Booked:   booked[start.Unix()],
+		}
+		slot.Available = slot.Capacity - slot.Booked
+		if slot.Available > 0 {
+			slots = append(slots, slot)
+		}
+	}
+	return slots, nil
+}
+
+func (s *pickupService) GetSettings() (models.PickupSettings, error) {
+	return s.pickupRepo.GetSettings()
+}
+
+// UpdateSettings changes the limits for new pre-orders, orders already
+// booked keep their slot.
+func (s *pickupService) UpdateSettings(settings models.PickupSettings) error {
+	if settings.SlotCapacity < 0 {
+		return errors.New("slot_capacity cannot be negative")
+	}
+	if settings.LeadMinutes < 0 || settings.LeadMinutes > 24*60 {
+		return errors.New("lead_minutes must be between 0 and 1440")
+	}
+	opens, err := minuteOfDay(settings.OpensAt)
+	if err != nil {
+		return errors.New("opens_at must be HH:MM")
+	}
+	closes, err := minuteOfDay(settings.ClosesAt)
+	if err != nil {
+		return errors.New("closes_at must be HH:MM")
+	}
+	if opens >= closes {
+		return errors.New("opens_at must be before closes_at")
+	}
+	return s.pickupRepo.UpdateSettings(settings)
+}
+
+// checkPickupTime is what a new pickup time must meet: it is no sooner than
+// the lead time and its window lies within the pickup hours.
+func checkPickupTime(settings models.PickupSettings, pickup time.Time) error {
+	if pickup.Before(time.Now().Add(time.Duration(settings.LeadMinutes) * time.Minute)) {
+		return errors.New("scheduled_for must be at least " + strconv.Itoa(settings.LeadMinutes) + " minutes ahead")
+	}
+	local := pickup.In(time.Local).Truncate(pickupSlotLength)
+	if local.Before(atTime(local, settings.OpensAt)) || !local.Before(atTime(local, settings.ClosesAt)) {
+		return errors.New("scheduled_for is outside pickup hours " + settings.OpensAt + "-" + settings.ClosesAt)
+	}
+	return nil
+}
+
+// atTime is the HH:MM clock time on the day of t, in server local time.
+func atTime(t time.Time, clock string) time.Time {
+	minute, _ := minuteOfDay(clock)
+	year, month, day := t.In(time.Local).Date()
+	return time.Date(year, month, day, minute/60, minute%60, 0, 0, time.Local)
+}
+
+func minuteOfDay(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// fakePickupRepo counts the given bookings per slot and keeps the settings
+// it was given.
+type fakePickupRepo struct {
+	dal.PickupRepository
+	settings models.PickupSettings
+	booked   map[int64]int
+	saved    *models.PickupSettings
+}
+
+func (r *fakePickupRepo) GetSettings() (models.PickupSettings, error) {
+	return r.settings, nil
+}
+
+func (r *fakePickupRepo) UpdateSettings(settings models.PickupSettings) error {
+	r.saved = &settings
+	return nil
+}
+
+func (r *fakePickupRepo) CountBooked(from, to time.Time) (map[int64]int, error) {
+	return r.booked, nil
+}
+
+func TestGetSlots(t *testing.T) {
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	date := tomorrow.Format("2006-01-02")
+	at := func(clock string) time.Time { return atTime(tomorrow, clock) }
+	settings := models.PickupSettings{SlotCapacity: 2, OpensAt: "08:00", ClosesAt: "09:00"}
+
+	tests := []struct {
+		name     string
+		capacity int
+		booked   map[int64]int
+		want     []string
+	}{
+		{name: "nothing booked", capacity: 2, want: []string{"08:00 2", "08:15 2", "08:30 2", "08:45 2"}},
+		{
+			name:     "full slot left out",
+			capacity: 2,
+			booked:   map[int64]int{at("08:15").Unix(): 2, at("08:30").Unix(): 1},
+			want:     []string{"08:00 2", "08:30 1", "08:45 2"},
+		},
+		{
+			name:     "overbooked after the capacity was lowered",
+			capacity: 1,
+			booked:   map[int64]int{at("08:00").Unix(): 2},
+			want:     []string{"08:15 1", "08:30 1", "08:45 1"},
+		},
+		{name: "no pre-orders taken", capacity: 0, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings.SlotCapacity = tt.capacity
+			s := NewPickupService(&fakePickupRepo{settings: settings, booked: tt.booked})
+			slots, err := s.GetSlots(date)
+			if err != nil {
+				t.Fatalf("GetSlots() error = %v", err)
+			}
+			got := []string{}
+			for _, slot := range slots {
+				start, _ := time.Parse(time.RFC3339, slot.Start)
+				end, _ := time.Parse(time.RFC3339, slot.End)
+				if end.Sub(start) != pickupSlotLength || slot.Available != slot.Capacity-slot.Booked {
+					t.Errorf("slot %+v", slot)
+				}
+				got = append(got, start.In(time.Local).Format("15:04")+" "+strconv.Itoa(slot.Available))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("slots %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewPickupService(&fakePickupRepo{settings: settings}).GetSlots("tomorrow"); err == nil || err.Error() != "date must be YYYY-MM-DD" {
+		t.Errorf("GetSlots(tomorrow) error = %v", err)
+	}
+}
+
+func TestCheckPickupTime(t *testing.T) {
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	settings := models.PickupSettings{SlotCapacity: 5, LeadMinutes: 30, OpensAt: "07:00", ClosesAt: "18:00"}
+	tests := []struct {
+		name    string
+		pickup  time.Time
+		wantErr string
+	}{
+		{name: "within hours", pickup: atTime(tomorrow, "10:00")},
+		{name: "first slot", pickup: atTime(tomorrow, "07:00")},
+		{name: "last slot", pickup: atTime(tomorrow, "17:59")},
+		{name: "too soon", pickup: time.Now().Add(10 * time.Minute), wantErr: "scheduled_for must be at least 30 minutes ahead"},
+		{name: "before opening", pickup: atTime(tomorrow, "06:50"), wantErr: "scheduled_for is outside pickup hours 07:00-18:00"},
+		{name: "at closing", pickup: atTime(tomorrow, "18:00"), wantErr: "scheduled_for is outside pickup hours 07:00-18:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPickupTime(settings, tt.pickup)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkPickupTime() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("checkPickupTime() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdatePickupSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings models.PickupSettings
+		wantErr  string
+	}{
+		{name: "valid", settings: models.PickupSettings{SlotCapacity: 4, LeadMinutes: 20, OpensAt: "07:00", ClosesAt: "18:30"}},
+		{name: "negative capacity", settings: models.PickupSettings{SlotCapacity: -1, OpensAt: "07:00", ClosesAt: "18:00"}, wantErr: "slot_capacity cannot be negative"},
+		{name: "lead over a day", settings: models.PickupSettings{LeadMinutes: 1441, OpensAt: "07:00", ClosesAt: "18:00"}, wantErr: "lead_minutes must be between 0 and 1440"},
+		{name: "bad opening", settings: models.PickupSettings{OpensAt: "7am", ClosesAt: "18:00"}, wantErr: "opens_at must be HH:MM"},
+		{name: "bad closing", settings: models.PickupSettings{OpensAt: "07:00", ClosesAt: "24:00"}, wantErr: "closes_at must be HH:MM"},
+		{name: "closes before opening", settings: models.PickupSettings{OpensAt: "18:00", ClosesAt: "07:00"}, wantErr: "opens_at must be before closes_at"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakePickupRepo{}
+			err := NewPickupService(repo).UpdateSettings(tt.settings)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("UpdateSettings() error = %v, want %q", err, tt.wantErr)
+				}
+				if repo.saved != nil {
+					t.Errorf("refused settings were stored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UpdateSettings() error = %v", err)
+			}
+			if repo.saved == nil || *repo.saved != tt.settings {
+				t.Errorf("stored %+v, want %+v", repo.saved, tt.settings)
+			}
+		})
+	}
+}
+
+func TestPostOrUpdateChecksPickupTime(t *testing.T) {
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	settings := models.PickupSettings{SlotCapacity: 5, LeadMinutes: 30, OpensAt: "07:00", ClosesAt: "18:00"}
+	tests := []struct {
+		name         string
+		scheduledFor string
+		wantErr      string
+	}{
+		// a pickup time that is accepted gets as far as the stock check
+		{name: "accepted", scheduledFor: atTime(tomorrow, "09:30").Format(time.RFC3339), wantErr: "not enough inventory for order"},
+		{name: "not RFC 3339", scheduledFor: "tomorrow 9:30", wantErr: "scheduled_for must be an RFC 3339 time"},
+		{name: "too soon", scheduledFor: time.Now().Add(5 * time.Minute).Format(time.RFC3339), wantErr: "scheduled_for must be at least 30 minutes ahead"},
+		{name: "outside hours", scheduledFor: atTime(tomorrow, "19:00").Format(time.RFC3339), wantErr: "scheduled_for is outside pickup hours 07:00-18:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inventoryRepo := &fakeInventoryRepo{}
+			s := NewOrderService(&fakeOrderRepo{}, &fakeMenuRepo{}, inventoryRepo, nil, nil, nil, nil,
+				&fakePickupRepo{settings: settings}, nil, nil)
+			order := models.Order{CustomerName: "Ann", Channel: "takeaway", ScheduledFor: tt.scheduledFor,
+				Items: []models.OrderItem{{MenuItemID: "latte", Quantity: 1}}}
+			_, err := s.PostOrUpdate(order, 0, models.Principal{})
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("PostOrUpdate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// prepStatuses are the steps an order line moves through at its station.
+var prepStatuses = []string{"queued", "in_progress", "ready", "handed_off"}
+
+func prepStatusRank(status string) int {
+	for i, s := range prepStatuses {
+		if s == status {
+			return i
+		}
+	}
+	return 0
+}
+
+// lessAdvanced picks the status of the line that is further behind.
+func lessAdvanced(a, b string) string {
+	if prepStatusRank(b) < prepStatusRank(a) {
+		return b
+	}
+	return a
+}
+
+// carryPrepStatus keeps the prep status of lines an update did not grow,
+// lines that are new or ask for more go back to the queue.
+func carryPrepStatus(current, updated []models.OrderItem) {
+	previous := make(map[string]models.OrderItem)
+	for _, item := range current {
+		previous[item.MenuItemID] = item
+	}
+	for i := range updated {
+		updated[i].PrepStatus = "queued"
+		if item, found := previous[updated[i].MenuItemID]; found && item.Quantity >= updated[i].Quantity {
+			updated[i].PrepStatus = item.PrepStatus
+		}
+	}
+}
+
+// prepAction is one barista step on a line.
+type prepAction struct {
+	from, to string
+	notFrom  string
+}
+
+var prepActions = map[string]prepAction{
+	"start":   {from: "queued", to: "in_progress", notFrom: "line is not queued"},
+	"ready":   {from: "in_progress", to: "ready", notFrom: "line is not in progress"},
+	"handoff": {from: "ready", to: "handed_off", notFrom: "line is not ready"},
+}
+
+const maxStationLength = 30
+
+type PrepService interface {
+	GetQueue(station, sort string) ([]models.QueueLine, error)
+	AdvanceLine(lineID int, action string, employeeID int) (models.QueueLine, error)
+	GetRoutes() ([]models.StationRoute, error)
+	UpdateRoutes(routes []models.StationRoute) error
+}
+
+type prepService struct {
+	prepRepo dal.PrepRepository
+	events   EventPublisher
+}
+
+func NewPrepService(prepRepo dal.PrepRepository, events EventPublisher) *prepService {
+	return &prepService{prepRepo: prepRepo, events: events}
+}
+
+// GetQueue lists the lines not yet handed off, the soonest due first or,
+// when sorted by priority, the most urgent orders first.
+func (s *prepService) GetQueue(station, sort string) ([]models.QueueLine, error) {
+	if sort != "" && sort != "fifo" && sort != "priority" {
+		return nil, errors.New("sort must be fifo or priority")
+	}
+	return s.prepRepo.GetQueue(strings.TrimSpace(station), sort == "priority")
+}
+
+// AdvanceLine moves a line one step, the line must be at the step the
+// action starts from.
+func (s *prepService) AdvanceLine(lineID int, action string, employeeID int) (models.QueueLine, error) {
+	step, found := prepActions[action]
+	if !found {
+		return models.QueueLine{}, errors.New("unknown action")
+	}
+	line, err := s.prepRepo.GetLine(lineID)
+	if err != nil {
+		return models.QueueLine{}, err
+	}
+	if line.PrepStatus != step.from {
+		return models.QueueLine{}, errors.New(step.notFrom)
+	}
+	if err = s.prepRepo.AdvanceLine(line, step.to, employeeID); err != nil {
+		if err.Error() == "line status changed" {
+			return models.QueueLine{}, errors.New(step.notFrom)
+		}
+		return models.QueueLine{}, err
+	}
+	s.events.Notify()
+	return s.prepRepo.GetLine(lineID)
+}
+
+func (s *prepService) GetRoutes() ([]models.StationRoute, error) {
+	routes, err := s.prepRepo.GetRoutes()
+	if err != nil {
+		return nil, err
+	}
+	if routes == nil {
+		routes = []models.StationRoute{}
+	}
+	return routes, nil
+}
+
+// UpdateRoutes replaces the station routes. A category can be routed once
+// per channel, the route without a category is the default station.
+func (s *prepService) UpdateRoutes(routes []models.StationRoute) error {
+	seen := make(map[string]bool)
+	for i := range routes {
+		routes[i].Channel = strings.TrimSpace(routes[i].Channel)
+		routes[i].Category = strings.TrimSpace(routes[i].Category)
+		routes[i].Station = strings.ToLower(strings.TrimSpace(routes[i].Station))
+		if routes[i].Station == "" || len(routes[i].Station) > maxStationLength {
+			return errors.New("station must be between 1 and 30 characters")
+		}
+		if err := checkChannelFilter(routes[i].Channel); err != nil {
+			return err
+		}
+		key := routes[i].Channel + "/" + routes[i].Category
+		if seen[key] {
+			forChannel := ""
+			if routes[i].Channel != "" {
+				forChannel = " for channel " + routes[i].Channel
+			}
+			if routes[i].Category == "" {
+				return errors.New("only one default station route is allowed" + forChannel)
+			}
+			return errors.New("duplicate station route for category " + routes[i].Category + forChannel)
+		}
+		seen[key] = true
+	}
+	return s.prepRepo.ReplaceRoutes(routes)
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// fakePrepRepo keeps one line. When raced is set another barista moves the
+// line on between the read and the write.
+type fakePrepRepo struct {
+	dal.PrepRepository
+	line     models.QueueLine
+	raced    bool
+	advanced []string
+}
+
+func (r *fakePrepRepo) GetLine(lineID int) (models.QueueLine, error) {
+	if lineID != r.line.LineID {
+		return models.QueueLine{}, errors.New("line not found")
+	}
+	return r.line, nil
+}
+
+func (r *fakePrepRepo) AdvanceLine(line models.QueueLine, to string, employeeID int) error {
+	if r.raced || line.PrepStatus != r.line.PrepStatus {
+		return errors.New("line status changed")
+	}
+	r.advanced = append(r.advanced, line.PrepStatus+" "+to)
+	r.line.PrepStatus = to
+	r.line.PreparedBy = employeeID
+	return nil
+}
+
+func TestAdvanceLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		action  string
+		lineID  int
+		raced   bool
+		want    string
+		wantErr string
+	}{
+		{name: "start", status: "queued", action: "start", lineID: 3, want: "in_progress"},
+		{name: "ready", status: "in_progress", action: "ready", lineID: 3, want: "ready"},
+		{name: "handoff", status: "ready", action: "handoff", lineID: 3, want: "handed_off"},
+		{name: "step skipped", status: "queued", action: "ready", lineID: 3, wantErr: "line is not in progress"},
+		{name: "step taken twice", status: "in_progress", action: "start", lineID: 3, wantErr: "line is not queued"},
+		{name: "taken meanwhile", status: "queued", action: "start", lineID: 3, raced: true, wantErr: "line is not queued"},
+		{name: "unknown action", status: "queued", action: "serve", lineID: 3, wantErr: "unknown action"},
+		{name: "unknown line", status: "queued", action: "start", lineID: 4, wantErr: "line not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakePrepRepo{line: models.QueueLine{LineID: 3, OrderID: 1, Station: "bar", PrepStatus: tt.status}, raced: tt.raced}
+			events := &fakeEvents{}
+			s := NewPrepService(repo, events)
+
+			line, err := s.AdvanceLine(tt.lineID, tt.action, 2)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("AdvanceLine() error = %v, want %q", err, tt.wantErr)
+				}
+				if len(repo.advanced) != 0 || events.notified != 0 {
+					t.Errorf("refused step was stored or notified")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AdvanceLine() error = %v", err)
+			}
+			if line.PrepStatus != tt.want || line.PreparedBy != 2 {
+				t.Errorf("line = %+v, want %s by employee 2", line, tt.want)
+			}
+			if events.notified != 1 {
+				t.Errorf("notified %d times, want 1", events.notified)
+			}
+		})
+	}
+}
+
+func TestCarryPrepStatus(t *testing.T) {
+	current := []models.OrderItem{
+		{MenuItemID: "latte", Quantity: 2, PrepStatus: "ready"},
+		{MenuItemID: "croissant", Quantity: 1, PrepStatus: "in_progress"},
+		{MenuItemID: "muffin", Quantity: 1, PrepStatus: "handed_off"},
+	}
+	updated := []models.OrderItem{
+		{MenuItemID: "latte", Quantity: 1},
+		{MenuItemID: "croissant", Quantity: 2},
+		{MenuItemID: "tea", Quantity: 1},
+	}
+	carryPrepStatus(current, updated)
+	var got []string
+	for _, item := range updated {
+		got = append(got, item.PrepStatus)
+	}
+	want := []string{"ready", "queued", "queued"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("prep statuses %v, want %v", got, want)
+	}
+}
+
+// fakeRouteRepo keeps the routes it was given.
+type fakeRouteRepo struct {
+	dal.PrepRepository
+	routes []models.StationRoute
+}
+
+func (r *fakeRouteRepo) ReplaceRoutes(routes []models.StationRoute) error {
+	r.routes = routes
+	return nil
+}
+
+func TestUpdateRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		routes  []models.StationRoute
+		wantErr string
+	}{
+		{
+			name: "per channel",
+			routes: []models.StationRoute{
+				{Station: "bar"},
+				{Category: "pastry", Station: "kitchen"},
+				{Channel: "takeaway", Station: "counter"},
+				{Channel: "delivery", Category: "pastry", Station: "pack"},
+			},
+		},
+		{
+			name:    "second default",
+			routes:  []models.StationRoute{{Station: "bar"}, {Station: " Kitchen "}},
+			wantErr: "only one default station route is allowed",
+		},
+		{
+			name:    "second default of a channel",
+			routes:  []models.StationRoute{{Channel: "takeaway", Station: "bar"}, {Channel: "takeaway", Station: "counter"}},
+			wantErr: "only one default station route is allowed for channel takeaway",
+		},
+		{
+			name:    "category twice on a channel",
+			routes:  []models.StationRoute{{Channel: "delivery", Category: "pastry", Station: "kitchen"}, {Channel: "delivery", Category: "pastry", Station: "pack"}},
+			wantErr: "duplicate station route for category pastry for channel delivery",
+		},
+		{
+			name:    "unknown channel",
+			routes:  []models.StationRoute{{Channel: "drive_in", Station: "bar"}},
+			wantErr: "channel must be one of dine_in, takeaway, delivery",
+		},
+		{
+			name:    "no station",
+			routes:  []models.StationRoute{{Category: "pastry", Station: " "}},
+			wantErr: "station must be between 1 and 30 characters",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRouteRepo{}
+			err := NewPrepService(repo, &fakeEvents{}).UpdateRoutes(tt.routes)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("UpdateRoutes() error = %v, want %q", err, tt.wantErr)
+				}
+				if repo.routes != nil {
+					t.Errorf("refused routes were stored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UpdateRoutes() error = %v", err)
+			}
+			if !reflect.DeepEqual(repo.routes, tt.routes) {
+				t.Errorf("stored %+v, want %+v", repo.routes, tt.routes)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"errors"
+	"math"
+
+	"hot-coffee/models"
+)
+
+// recipeBook resolves prep item recipes against a snapshot of the inventory.
+// Every walk keeps the ids on the current path so a recipe that ends up
+// using itself is reported instead of recursing forever.
+type recipeBook struct {
+	items map[string]models.InventoryItem
+}
+
+func newRecipeBook(inventory []models.InventoryItem) *recipeBook {
+	items := make(map[string]models.InventoryItem)
+	for _, item := range inventory {
+		items[item.IngredientID] = item
+	}
+	return &recipeBook{items: items}
+}
+
+func isPrepItem(item models.InventoryItem) bool {
+	return len(item.Recipe) > 0
+}
+
+func recipeCycleError(id string) error {
+	return errors.New("recipe cycle detected at ingredient: " + id)
+}
+
+// checkCycle walks the recipe tree below id.
+func (b *recipeBook) checkCycle(id string) error {
+	return b.walk(id, map[string]bool{})
+}
+
+func (b *recipeBook) walk(id string, path map[string]bool) error {
+	if path[id] {
+		return recipeCycleError(id)
+	}
+	item, found := b.items[id]
+	if !found {
+		return errors.New("ingredient not found in inventory: " + id)
+	}
+	path[id] = true
+	defer delete(path, id)
+	for _, component := range item.Recipe {
+		if err := b.walk(component.IngredientID, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unitCost is the cost of one unit of id. Raw ingredients use their own
+// cost_per_unit, prep items are costed from their components.
+func (b *recipeBook) unitCost(id string) (float64, error) {
+	return b.cost(id, map[string]bool{})
+}
+
+func (b *recipeBook) cost(id string, path map[string]bool) (float64, error) {
+	if path[id] {
+		return 0, recipeCycleError(id)
+	}
+	item, found := b.items[id]
+	if !found {
+		return 0, errors.New("ingredient not found in inventory: " + id)
+	}
+	if !isPrepItem(item) {
+		return item.CostPerUnit, nil
+	}
+	path[id] = true
+	defer delete(path, id)
+
+	var total float64
+	for _, component := range item.Recipe {
+		componentCost, err := b.cost(component.IngredientID, path)
+		if err != nil {
+			return 0, err
+		}
+		total += componentCost * component.Quantity
+	}
+	return total, nil
+}
+
+// planProduction works out the stock changes needed to produce quantity of id.
+// When a component is itself a prep item and its stock is short, the missing
+// amount is produced first from its own recipe.
+func (b *recipeBook) planProduction(id string, quantity float64) (map[string]float64, error) {
+	stock := make(map[string]float64)
+	for itemID, item := range b.items {
+		// stock held for open orders is not there to produce from
+		stock[itemID] = item.Available
+	}
+	deltas := make(map[string]float64)
+	if err := b.produce(id, quantity, stock, deltas, map[string]bool{}); err != nil {
+		return nil, err
+	}
+	return deltas, nil
+}
+
+func (b *recipeBook) produce(id string, quantity float64, stock, deltas map[string]float64, path map[string]bool) error {
+	if path[id] {
+		return recipeCycleError(id)
+	}
+	item, found := b.items[id]
+	if !found {
+		return errors.New("ingredient not found in inventory: " + id)
+	}
+	if !isPrepItem(item) {
+		return errors.New("ingredient has no recipe: " + id)
+	}
+	path[id] = true
+	defer delete(path, id)
+
+	for _, component := range item.Recipe {
+		required := component.Quantity * quantity
+		available := stock[component.IngredientID]
+		if available < required {
+			componentItem := b.items[component.IngredientID]
+			if !isPrepItem(componentItem) {
+				return errors.New("not enough ingredient: " + component.IngredientID)
+			}
+			if err := b.produce(component.IngredientID, required-available, stock, deltas, path); err != nil {
+				return err
+			}
+		}
+		stock[component.IngredientID] -= required
+		deltas[component.IngredientID] -= required
+	}
+	stock[id] += quantity
+	deltas[id] += quantity
+	return nil
+}
+
+// producible is how much of id can still be made from the current stock,
+// to a hundredth of a unit. Components shared between branches of the tree
+// are accounted for because every guess is checked with a full plan.
+func (b *recipeBook) producible(id string) (float64, error) {
+	if err := b.checkCycle(id); err != nil {
+		return 0, err
+	}
+	if !isPrepItem(b.items[id]) {
+		return 0, nil
+	}
+	upper := b.upperBound(id)
+	lo, hi := 0, int(math.Floor(upper*100))
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if _, err := b.planProduction(id, float64(mid)/100); err == nil {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return float64(lo) / 100, nil
+}
+
+// upperBound treats every component as if it was not shared with any other
+// branch, which can only overestimate what is producible.
+func (b *recipeBook) upperBound(id string) float64 {
+	item := b.items[id]
+	bound := math.Inf(1)
+	for _, component := range item.Recipe {
+		if component.Quantity <= 0 {
+			continue
+		}
+		available := b.items[component.IngredientID].Quantity
+		if isPrepItem(b.items[component.IngredientID]) {
+			available += b.upperBound(component.IngredientID)
+		}
+		bound = math.Min(bound, available/component.Quantity)
+	}
+	if math.IsInf(bound, 1) {
+		return 0
+	}
+	return bound
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"hot-coffee/models"
+)
+
+// reservedInventory holds stock for open orders: 8 of the 10 sugar and 2 of
+// the 3 syrup are reserved.
+var reservedInventory = []models.InventoryItem{
+	{IngredientID: "sugar", Quantity: 10, Reserved: 8, Available: 2},
+	{IngredientID: "water", Quantity: 100, Available: 100},
+	{IngredientID: "syrup", Quantity: 3, Reserved: 2, Available: 1, Recipe: []models.InventoryRecipeIngredient{
+		{IngredientID: "sugar", Quantity: 0.5},
+		{IngredientID: "water", Quantity: 0.5},
+	}},
+	{IngredientID: "base", Recipe: []models.InventoryRecipeIngredient{
+		{IngredientID: "syrup", Quantity: 2},
+		{IngredientID: "water", Quantity: 1},
+	}},
+}
+
+func TestPlanProduction(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		quantity float64
+		want     map[string]float64
+		wantErr  string
+	}{
+		{
+			name:     "unreserved stock is used",
+			id:       "syrup",
+			quantity: 4,
+			want:     map[string]float64{"sugar": -2, "water": -2, "syrup": 4},
+		},
+		{
+			name:     "reserved stock is not used",
+			id:       "syrup",
+			quantity: 5,
+			wantErr:  "not enough ingredient: sugar",
+		},
+		{
+			name:     "short prep component is made first",
+			id:       "base",
+			quantity: 1,
+			want:     map[string]float64{"sugar": -0.5, "water": -1.5, "syrup": -1, "base": 1},
+		},
+		{
+			name:     "item without recipe",
+			id:       "sugar",
+			quantity: 1,
+			wantErr:  "ingredient has no recipe: sugar",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newRecipeBook(reservedInventory).planProduction(tt.id, tt.quantity)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("planProduction() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("planProduction() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planProduction() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProducible(t *testing.T) {
+	tests := []struct {
+		id   string
+		want float64
+	}{
+		{id: "syrup", want: 4},
+		{id: "base", want: 2.5},
+		{id: "sugar", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			got, err := newRecipeBook(reservedInventory).producible(tt.id)
+			if err != nil {
+				t.Fatalf("producible() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("producible() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// costedInventory has a prep item made from raw ingredients and another
+// prep item, costs are per unit.
+var costedInventory = []models.InventoryItem{
+	{IngredientID: "milk", CostPerUnit: 0.002},
+	{IngredientID: "sugar", CostPerUnit: 0.004},
+	{IngredientID: "vanilla", CostPerUnit: 0.1},
+	{IngredientID: "syrup", Recipe: []models.InventoryRecipeIngredient{
+		{IngredientID: "sugar", Quantity: 50},
+		{IngredientID: "vanilla", Quantity: 2},
+	}},
+	{IngredientID: "cold_foam", Recipe: []models.InventoryRecipeIngredient{
+		{IngredientID: "milk", Quantity: 100},
+		{IngredientID: "syrup", Quantity: 0.5},
+	}},
+}
+
+func TestUnitCost(t *testing.T) {
+	tests := []struct {
+		id      string
+		want    float64
+		wantErr string
+	}{
+		{id: "milk", want: 0.002},
+		{id: "syrup", want: 0.4},
+		{id: "cold_foam", want: 0.4},
+		{id: "oat_milk", wantErr: "ingredient not found in inventory: oat_milk"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			got, err := newRecipeBook(costedInventory).unitCost(tt.id)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("unitCost() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unitCost() error = %v", err)
+			}
+			if roundTo(got, 6) != tt.want {
+				t.Errorf("unitCost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecipeCycle(t *testing.T) {
+	inventory := append([]models.InventoryItem{}, costedInventory...)
+	// syrup now uses cold foam, which uses syrup
+	inventory[3] = models.InventoryItem{IngredientID: "syrup", Recipe: []models.InventoryRecipeIngredient{
+		{IngredientID: "sugar", Quantity: 50},
+		{IngredientID: "cold_foam", Quantity: 1},
+	}}
+	book := newRecipeBook(inventory)
+
+	tests := []struct {
+		id      string
+		wantErr string
+	}{
+		{id: "milk"},
+		{id: "syrup", wantErr: "recipe cycle detected at ingredient: syrup"},
+		{id: "cold_foam", wantErr: "recipe cycle detected at ingredient: cold_foam"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			err := book.checkCycle(tt.id)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkCycle() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("checkCycle() error = %v, want %q", err, tt.wantErr)
+			}
+			if _, err := book.unitCost(tt.id); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("unitCost() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"log/slog"
+	"sync"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// StockAlerts publishes inventory.low once when an item drops to its
+// reorder level, and again only after it was restocked above it.
+type StockAlerts struct {
+	inventoryRepo dal.InventoryRepository
+	events        EventPublisher
+	mu            sync.Mutex
+	low           map[string]bool
+}
+
+func NewStockAlerts(inventoryRepo dal.InventoryRepository, events EventPublisher) *StockAlerts {
+	return &StockAlerts{inventoryRepo: inventoryRepo, events: events, low: make(map[string]bool)}
+}
+
+// Check is called after stock went down. A failed check is only logged,
+// the stock change itself already happened.
+func (a *StockAlerts) Check() {
+	items, err := a.inventoryRepo.GetLowStock()
+	if err != nil {
+		slog.Error("Failed to GetLowStock", err.Error(), "no stock alerts")
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	low := make(map[string]bool)
+	for _, item := range items {
+		low[item.IngredientID] = true
+		if a.low[item.IngredientID] {
+			continue
+		}
+		a.events.Publish(models.EventInventoryLow, models.InventoryLowEvent{
+			IngredientID: item.IngredientID,
+			Name:         item.Name,
+			Quantity:     item.Quantity,
+			Unit:         item.Unit,
+			ReorderLevel: item.ReorderLevel,
+		})
+	}
+	a.low = low
+}
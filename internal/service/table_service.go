@@ -0,0 +1,144 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+type TableService interface {
+	AddTable(table models.Table) (models.Table, error)
+	GetTables() ([]models.Table, error)
+	GetTable(id int) (models.Table, error)
+	UpdateTable(table models.Table) (models.Table, error)
+	SetStatus(id int, status string) (models.Table, error)
+	TransferTab(fromID, toID int) (models.Table, error)
+}
+
+type tableService struct {
+	tableRepo dal.TableRepository
+}
+
+func NewTableService(tableRepo dal.TableRepository) *tableService {
+	return &tableService{tableRepo: tableRepo}
+}
+
+var tableStatuses = map[string]bool{"free": true, "occupied": true, "needs_cleaning": true}
+
+func isTableValid(table models.Table) bool {
+	return table.Number > 0 && table.Seats > 0 && len(table.Area) <= 50
+}
+
+// checkNumber makes sure no other table uses the number.
+func (s *tableService) checkNumber(table models.Table) error {
+	tables, err := s.tableRepo.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, existing := range tables {
+		if existing.Number == table.Number && existing.ID != table.ID {
+			return errors.New("table number already in use")
+		}
+	}
+	return nil
+}
+
+// AddTable creates a free table.
+func (s *tableService) AddTable(table models.Table) (models.Table, error) {
+	table.Area = strings.TrimSpace(table.Area)
+	if !isTableValid(table) {
+		return models.Table{}, errors.New("invalid table")
+	}
+	if err := s.checkNumber(table); err != nil {
+		return models.Table{}, err
+	}
+	table.Status = "free"
+	table.OpenOrders = []models.TableOrder{}
+	table.CreatedAt = getFormattedTime()
+	table.UpdatedAt = table.CreatedAt
+	id, err := s.tableRepo.SaveTable(table)
+	table.ID = id
+	return table, err
+}
+
+// GetTables lists every table with the orders of its open tab.
+func (s *tableService) GetTables() ([]models.Table, error) {
+	tables, err := s.tableRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if tables == nil {
+		tables = []models.Table{}
+	}
+	for i := range tables {
+		tables[i].OpenTotal = roundTo(tables[i].OpenTotal, 2)
+	}
+	return tables, nil
+}
+
+func (s *tableService) GetTable(id int) (models.Table, error) {
+	table, err := s.tableRepo.GetByID(id)
+	if err == sql.ErrNoRows {
+		return models.Table{}, errors.New("table not found")
+	}
+	table.OpenTotal = roundTo(table.OpenTotal, 2)
+	return table, err
+}
+
+// UpdateTable changes the number, seats and area, the status changes
+// through SetStatus and the tab.
+func (s *tableService) UpdateTable(table models.Table) (models.Table, error) {
+	table.Area = strings.TrimSpace(table.Area)
+	if !isTableValid(table) {
+		return models.Table{}, errors.New("invalid table")
+	}
+	existing, err := s.GetTable(table.ID)
+	if err != nil {
+		return models.Table{}, err
+	}
+	if err = s.checkNumber(table); err != nil {
+		return models.Table{}, err
+	}
+	existing.Number = table.Number
+	existing.Seats = table.Seats
+	existing.Area = table.Area
+	existing.UpdatedAt = getFormattedTime()
+	return existing, s.tableRepo.UpdateTable(existing)
+}
+
+// SetStatus is used to seat guests without an order yet and to mark a
+// table clean again. A table with an open tab stays occupied.
+func (s *tableService) SetStatus(id int, status string) (models.Table, error) {
+	if !tableStatuses[status] {
+		return models.Table{}, errors.New("status must be free, occupied or needs_cleaning")
+	}
+	table, err := s.GetTable(id)
+	if err != nil {
+		return models.Table{}, err
+	}
+	if status != "occupied" && len(table.OpenOrders) > 0 {
+		return models.Table{}, errors.New("table has an open tab")
+	}
+	if err = s.tableRepo.SetStatus(id, status); err != nil {
+		return models.Table{}, err
+	}
+	return s.GetTable(id)
+}
+
+// TransferTab moves the open tab of fromID to the free table toID and
+// returns the table it moved to.
+func (s *tableService) TransferTab(fromID, toID int) (models.Table, error) {
+	if fromID == toID {
+		return models.Table{}, errors.New("tab is already on this table")
+	}
+	if _, err := s.GetTable(fromID); err != nil {
+		return models.Table{}, err
+	}
+	if _, err := s.tableRepo.TransferTab(fromID, toID); err != nil {
+		return models.Table{}, err
+	}
+	return s.GetTable(toID)
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"database/sql"
+	"testing"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// fakeTableRepo keeps tables by id and records status changes.
+type fakeTableRepo struct {
+	dal.TableRepository
+	tables   map[int]models.Table
+	saved    []models.Table
+	statuses []string
+}
+
+func (r *fakeTableRepo) SaveTable(table models.Table) (int, error) {
+	r.saved = append(r.saved, table)
+	return 10 + len(r.saved), nil
+}
+
+func (r *fakeTableRepo) GetAll() ([]models.Table, error) {
+	var tables []models.Table
+	for _, table := range r.tables {
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+func (r *fakeTableRepo) GetByID(id int) (models.Table, error) {
+	table, found := r.tables[id]
+	if !found {
+		return models.Table{}, sql.ErrNoRows
+	}
+	return table, nil
+}
+
+func (r *fakeTableRepo) SetStatus(id int, status string) error {
+	r.statuses = append(r.statuses, status)
+	table := r.tables[id]
+	table.Status = status
+	r.tables[id] = table
+	return nil
+}
+
+func newFakeTableRepo() *fakeTableRepo {
+	return &fakeTableRepo{tables: map[int]models.Table{
+		1: {ID: 1, Number: 1, Seats: 2, Status: "free"},
+		2: {ID: 2, Number: 2, Seats: 4, Status: "occupied", OpenOrders: []models.TableOrder{{OrderID: 7, TotalAmount: 12}}},
+		3: {ID: 3, Number: 3, Seats: 4, Status: "needs_cleaning"},
+	}}
+}
+
+func TestAddTable(t *testing.T) {
+	tests := []struct {
+		name    string
+		table   models.Table
+		wantErr string
+	}{
+		{name: "new number", table: models.Table{Number: 4, Seats: 2, Area: " patio "}},
+		{name: "number taken", table: models.Table{Number: 2, Seats: 2}, wantErr: "table number already in use"},
+		{name: "no seats", table: models.Table{Number: 5}, wantErr: "invalid table"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeTableRepo()
+			table, err := NewTableService(repo).AddTable(tt.table)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("AddTable() error = %v, want %q", err, tt.wantErr)
+				}
+				if len(repo.saved) != 0 {
+					t.Errorf("refused table was saved")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AddTable() error = %v", err)
+			}
+			if table.Status != "free" || table.Area != "patio" || table.OpenOrders == nil {
+				t.Errorf("table %+v, want a free table in the patio", table)
+			}
+		})
+	}
+}
+
+func TestSetTableStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      int
+		status  string
+		wantErr string
+	}{
+		{name: "seat guests", id: 1, status: "occupied"},
+		{name: "cleaned", id: 3, status: "free"},
+		{name: "tab still open", id: 2, status: "needs_cleaning", wantErr: "table has an open tab"},
+		{name: "unknown status", id: 1, status: "reserved", wantErr: "status must be free, occupied or needs_cleaning"},
+		{name: "unknown table", id: 9, status: "free", wantErr: "table not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeTableRepo()
+			table, err := NewTableService(repo).SetStatus(tt.id, tt.status)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("SetStatus() error = %v, want %q", err, tt.wantErr)
+				}
+				if len(repo.statuses) != 0 {
+					t.Errorf("refused status was stored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SetStatus() error = %v", err)
+			}
+			if table.Status != tt.status {
+				t.Errorf("status %q, want %q", table.Status, tt.status)
+			}
+		})
+	}
+}
+
+func TestTransferTabToSameTable(t *testing.T) {
+	_, err := NewTableService(newFakeTableRepo()).TransferTab(2, 2)
+	if err == nil || err.Error() != "tab is already on this table" {
+		t.Errorf("TransferTab() error = %v", err)
+	}
+}
+
+func TestPostOrUpdateOnTable(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   models.Order
+		wantErr string
+	}{
+		// an order that may open the tab gets as far as the stock check
+		{name: "dine in", order: models.Order{TableID: 1}, wantErr: "not enough inventory for order"},
+		{name: "takeaway", order: models.Order{TableID: 1, Channel: "takeaway"}, wantErr: "only dine_in orders can be opened on a table"},
+		{name: "unknown table", order: models.Order{TableID: 9}, wantErr: "table not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewOrderService(&fakeOrderRepo{}, &fakeMenuRepo{}, &fakeInventoryRepo{}, nil, nil, nil, newFakeTableRepo(), nil, nil, nil)
+			tt.order.CustomerName = "Ann"
+			tt.order.Items = []models.OrderItem{{MenuItemID: "latte", Quantity: 1}}
+			_, err := s.PostOrUpdate(tt.order, 0, models.Principal{})
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("PostOrUpdate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
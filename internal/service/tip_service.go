@@ -0,0 +1,111 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+type TipService interface {
+	GetTipReport(date, rule string, staff []models.TipShare) (models.TipReport, error)
+}
+
+type tipService struct {
+	tipRepo   dal.TipRepository
+	shiftRepo dal.ShiftRepository
+}
+
+func NewTipService(tipRepo dal.TipRepository, shiftRepo dal.ShiftRepository) *tipService {
+	return &tipService{tipRepo: tipRepo, shiftRepo: shiftRepo}
+}
+
+// GetTipReport totals the tips of one day, today when date is empty, and
+// pools them over the staff with the equal or hours-weighted rule. Without
+// staff the tips go to everyone who worked a shift that day.
+func (s *tipService) GetTipReport(date, rule string, staff []models.TipShare) (models.TipReport, error) {
+	if date == "" {
+		date = time.Now().UTC().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return models.TipReport{}, errors.New("date must be in YYYY-MM-DD format")
+	}
+	if rule == "" {
+		rule = "equal"
+	}
+	if rule != "equal" && rule != "hours" {
+		return models.TipReport{}, errors.New("rule must be equal or hours")
+	}
+
+	tips, err := s.tipRepo.GetTipsByOrder(date)
+	if err != nil {
+		return models.TipReport{}, err
+	}
+	if tips == nil {
+		tips = []models.OrderTip{}
+	}
+	report := models.TipReport{Date: date, Rule: rule, Orders: tips}
+	for _, tip := range tips {
+		report.TotalTips += tip.Tip
+	}
+	report.TotalTips = roundTo(report.TotalTips, 2)
+
+	if len(staff) == 0 {
+		labor, err := s.shiftRepo.GetLabor(date, date)
+		if err != nil {
+			return models.TipReport{}, err
+		}
+		for _, entry := range labor {
+			if hours := roundTo(entry.Hours, 2); hours > 0 {
+				staff = append(staff, models.TipShare{Staff: entry.Name, Hours: hours})
+			}
+		}
+	}
+	report.Shares, err = poolTips(report.TotalTips, rule, staff)
+	if err != nil {
+		return models.TipReport{}, err
+	}
+	return report, nil
+}
+
+// poolTips splits total in cents so the shares always add up to it, the
+// leftover cents go to the first staff members.
+func poolTips(total float64, rule string, staff []models.TipShare) ([]models.TipShare, error) {
+	shares := []models.TipShare{}
+	if len(staff) == 0 {
+		return shares, nil
+	}
+	weights := make([]float64, len(staff))
+	var totalWeight float64
+	for i, member := range staff {
+		if member.Staff == "" {
+			return nil, errors.New("staff name is required")
+		}
+		weights[i] = 1
+		if rule == "hours" {
+			if member.Hours <= 0 {
+				return nil, errors.New("hours must be positive for staff: " + member.Staff)
+			}
+			weights[i] = member.Hours
+		}
+		totalWeight += weights[i]
+	}
+
+	cents := int(math.Round(total * 100))
+	allocated := 0
+	for i, member := range staff {
+		share := int(math.Floor(float64(cents) * weights[i] / totalWeight))
+		allocated += share
+		shares = append(shares, models.TipShare{Staff: member.Staff, Hours: member.Hours, Amount: float64(share)})
+	}
+	for i := 0; allocated < cents; i = (i + 1) % len(shares) {
+		shares[i].Amount++
+		allocated++
+	}
+	for i := range shares {
+		shares[i].Amount /= 100
+	}
+	return shares, nil
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+type fakeTipRepo struct {
+	dal.TipRepository
+	tips []models.OrderTip
+}
+
+func (r *fakeTipRepo) GetTipsByOrder(date string) ([]models.OrderTip, error) {
+	return r.tips, nil
+}
+
+// tipShiftRepo reports who worked on the day of the tips.
+type tipShiftRepo struct {
+	dal.ShiftRepository
+	labor []models.LaborEntry
+}
+
+func (r *tipShiftRepo) GetLabor(from, to string) ([]models.LaborEntry, error) {
+	return r.labor, nil
+}
+
+func TestPoolTips(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   float64
+		rule    string
+		staff   []models.TipShare
+		want    []float64
+		wantErr string
+	}{
+		{name: "equal", total: 30, rule: "equal", staff: []models.TipShare{{Staff: "Ann"}, {Staff: "Bob"}, {Staff: "Cleo"}}, want: []float64{10, 10, 10}},
+		{name: "leftover cents go first", total: 10, rule: "equal", staff: []models.TipShare{{Staff: "Ann"}, {Staff: "Bob"}, {Staff: "Cleo"}}, want: []float64{3.34, 3.33, 3.33}},
+		{name: "by hours", total: 20, rule: "hours", staff: []models.TipShare{{Staff: "Ann", Hours: 6}, {Staff: "Bob", Hours: 2}}, want: []float64{15, 5}},
+		{name: "by odd hours", total: 10, rule: "hours", staff: []models.TipShare{{Staff: "Ann", Hours: 1}, {Staff: "Bob", Hours: 2}}, want: []float64{3.34, 6.66}},
+		{name: "nobody", total: 10, rule: "equal", want: []float64{}},
+		{name: "no hours", total: 10, rule: "hours", staff: []models.TipShare{{Staff: "Ann", Hours: 4}, {Staff: "Bob"}}, wantErr: "hours must be positive for staff: Bob"},
+		{name: "no name", total: 10, rule: "equal", staff: []models.TipShare{{Hours: 4}}, wantErr: "staff name is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares, err := poolTips(tt.total, tt.rule, tt.staff)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("poolTips() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("poolTips() error = %v", err)
+			}
+			got := []float64{}
+			for _, share := range shares {
+				got = append(got, share.Amount)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("shares %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetTipReport(t *testing.T) {
+	tipRepo := &fakeTipRepo{tips: []models.OrderTip{{OrderID: 1, Tip: 2.5}, {OrderID: 2, Tip: 1.25}, {OrderID: 3, Tip: 4.25}}}
+	shiftRepo := &tipShiftRepo{labor: []models.LaborEntry{
+		{Name: "Ann", Hours: 6},
+		{Name: "Bob", Hours: 2},
+		{Name: "Cleo", Hours: 0},
+	}}
+	s := NewTipService(tipRepo, shiftRepo)
+
+	report, err := s.GetTipReport("2024-05-01", "hours", nil)
+	if err != nil {
+		t.Fatalf("GetTipReport() error = %v", err)
+	}
+	// without staff given the pool goes to whoever worked that day
+	want := []models.TipShare{{Staff: "Ann", Hours: 6, Amount: 6}, {Staff: "Bob", Hours: 2, Amount: 2}}
+	if report.TotalTips != 8 || !reflect.DeepEqual(report.Shares, want) {
+		t.Errorf("report %+v, want 8.00 shared %+v", report, want)
+	}
+
+	report, err = s.GetTipReport("2024-05-01", "", []models.TipShare{{Staff: "Dan"}, {Staff: "Eve"}})
+	if err != nil || report.Rule != "equal" || len(report.Shares) != 2 || report.Shares[0].Amount != 4 {
+		t.Errorf("report with staff = %+v, %v", report, err)
+	}
+
+	if _, err := s.GetTipReport("2024-05-01", "seniority", nil); err == nil || err.Error() != "rule must be equal or hours" {
+		t.Errorf("GetTipReport(seniority) error = %v", err)
+	}
+	if _, err := s.GetTipReport("May 1", "equal", nil); err == nil || err.Error() != "date must be in YYYY-MM-DD format" {
+		t.Errorf("GetTipReport(May 1) error = %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"errors"
+	"strings"
 	"time"
 
 	"hot-coffee/internal/dal"
@@ -127,10 +128,43 @@ func IsOrderValid(order models.Order) bool {
 	return true
 }
 
+func IsCustomerValid(customer models.Customer) bool {
+	if customer.Name == "" || len(customer.Name) > 50 {
+		return false
+	}
+	if customer.Email != "" && !strings.Contains(customer.Email, "@") {
+		return false
+	}
+	return true
+}
+
 func IsInventoryValid(inventory models.InventoryItem) bool {
-	if inventory.IngredientID == "" || inventory.Quantity <= 0 {
+	// prep items may start with an empty stock, raw ingredients may not
+	if inventory.Quantity == 0 && len(inventory.Recipe) == 0 {
 		return false
 	}
+	return isInventoryChangeValid(inventory)
+}
+
+// isInventoryChangeValid is what an updated item must meet, its stock may
+// have run out by then.
+func isInventoryChangeValid(inventory models.InventoryItem) bool {
+	if inventory.IngredientID == "" || inventory.Quantity < 0 || inventory.CostPerUnit < 0 || inventory.ReorderLevel < 0 {
+		return false
+	}
+	for _, component := range inventory.Recipe {
+		if component.IngredientID == "" || component.IngredientID == inventory.IngredientID || component.Quantity <= 0 {
+			return false
+		}
+	}
+	if n := inventory.Nutrition; n != nil {
+		if n.Basis <= 0 || n.Calories < 0 || n.Protein < 0 || n.Fat < 0 || n.Carbs < 0 {
+			return false
+		}
+		if _, err := convertUnit(1, inventory.Unit, n.Unit); err != nil {
+			return false
+		}
+	}
 	return true
 }
 
@@ -0,0 +1,305 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// WebhookClient sends the webhook requests, *http.Client fits and tests
+// can plug in anything that answers like a receiver.
+type WebhookClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Deliveries are retried with exponential backoff, starting at
+// webhookRetryBase and capped at webhookRetryMax, and fail for good after
+// webhookMaxAttempts.
+const (
+	webhookRetryBase   = 30 * time.Second
+	webhookRetryMax    = 6 * time.Hour
+	webhookMaxAttempts = 10
+	webhookBatchSize   = 20
+	// webhookLease is how long a claimed delivery is kept from other workers.
+	webhookLease = 2 * time.Minute
+)
+
+// Headers sent with every delivery. The signature is the hex HMAC-SHA256
+// of "<timestamp>.<body>" with the webhook secret.
+const (
+	HeaderWebhookEvent     = "X-Hotcoffee-Event"
+	HeaderWebhookDelivery  = "X-Hotcoffee-Delivery"
+	HeaderWebhookTimestamp = "X-Hotcoffee-Timestamp"
+	HeaderWebhookSignature = "X-Hotcoffee-Signature"
+)
+
+type WebhookService interface {
+	CreateWebhook(webhook models.Webhook) (models.Webhook, error)
+	GetWebhooks() ([]models.Webhook, error)
+	GetWebhook(id int) (models.Webhook, error)
+	UpdateWebhook(webhook models.Webhook) (models.Webhook, error)
+	DeleteWebhook(id int) error
+	GetDeliveries(webhookID int, status string) ([]models.WebhookDelivery, error)
+}
+
+type webhookService struct {
+	webhookRepo dal.WebhookRepository
+	client      WebhookClient
+}
+
+func NewWebhookService(webhookRepo dal.WebhookRepository, client WebhookClient) *webhookService {
+	return &webhookService{webhookRepo: webhookRepo, client: client}
+}
+
+// CreateWebhook generates a secret when none is given. The secret is only
+// returned here and when it is changed.
+func (s *webhookService) CreateWebhook(webhook models.Webhook) (models.Webhook, error) {
+	if err := validateWebhook(&webhook); err != nil {
+		return models.Webhook{}, err
+	}
+	if webhook.Secret == "" {
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			return models.Webhook{}, err
+		}
+		webhook.Secret = "whsec_" + hex.EncodeToString(buf)
+	}
+	webhook.Active = true
+	webhook.CreatedAt = getFormattedTime()
+	webhook.UpdatedAt = webhook.CreatedAt
+	id, err := s.webhookRepo.SaveWebhook(webhook)
+	webhook.ID = id
+	return webhook, err
+}
+
+func (s *webhookService) GetWebhooks() ([]models.Webhook, error) {
+	webhooks, err := s.webhookRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if webhooks == nil {
+		webhooks = []models.Webhook{}
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+func (s *webhookService) GetWebhook(id int) (models.Webhook, error) {
+	webhook, err := s.webhookRepo.GetByID(id)
+	if err == sql.ErrNoRows {
+		return models.Webhook{}, errors.New("webhook not found")
+	}
+	webhook.Secret = ""
+	return webhook, err
+}
+
+// UpdateWebhook replaces the url, event types and active flag, the secret
+// only changes when a new one is given.
+func (s *webhookService) UpdateWebhook(webhook models.Webhook) (models.Webhook, error) {
+	if err := validateWebhook(&webhook); err != nil {
+		return models.Webhook{}, err
+	}
+	webhook.UpdatedAt = getFormattedTime()
+	updated, err := s.webhookRepo.UpdateWebhook(webhook)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	if !updated {
+		return models.Webhook{}, errors.New("webhook not found")
+	}
+	secret := webhook.Secret
+	webhook, err = s.GetWebhook(webhook.ID)
+	webhook.Secret = secret
+	return webhook, err
+}
+
+func (s *webhookService) DeleteWebhook(id int) error {
+	deleted, err := s.webhookRepo.DeleteWebhook(id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.New("webhook not found")
+	}
+	return nil
+}
+
+func (s *webhookService) GetDeliveries(webhookID int, status string) ([]models.WebhookDelivery, error) {
+	if status != "" && status != "pending" && status != "delivered" && status != "failed" {
+		return nil, errors.New("status must be pending, delivered or failed")
+	}
+	if _, err := s.GetWebhook(webhookID); err != nil {
+		return nil, err
+	}
+	deliveries, err := s.webhookRepo.GetDeliveries(webhookID, status)
+	if err != nil {
+		return nil, err
+	}
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+	return deliveries, nil
+}
+
+func validateWebhook(webhook *models.Webhook) error {
+	webhook.URL = strings.TrimSpace(webhook.URL)
+	parsed, err := url.Parse(webhook.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || len(webhook.URL) > 500 {
+		return errors.New("url must be an http or https URL")
+	}
+	if len(webhook.EventTypes) == 0 {
+		return errors.New("at least one event type is required")
+	}
+	seen := make(map[string]bool)
+	var types []string
+	for _, eventType := range webhook.EventTypes {
+		if !isValidEventType(eventType) {
+			return errors.New("event type must be one of " + strings.Join(eventTypes, ", "))
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			types = append(types, eventType)
+		}
+	}
+	webhook.EventTypes = types
+	if len(webhook.Secret) > 100 {
+		return errors.New("secret must be at most 100 characters")
+	}
+	return nil
+}
+
+// HandleEvent queues a delivery of the event for every subscribed webhook,
+// the worker sends them.
+func (s *webhookService) HandleEvent(event models.Event) error {
+	_, err := s.webhookRepo.QueueDeliveries(event)
+	return err
+}
+
+// Start runs the delivery worker, every interval it sends what is due.
+func (s *webhookService) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.DeliverDue()
+		}
+	}()
+}
+
+// DeliverDue sends the deliveries that are due, in batches until none are
+// left.
+func (s *webhookService) DeliverDue() {
+	for {
+		deliveries, err := s.webhookRepo.ClaimDue(webhookBatchSize, webhookLease)
+		if err != nil {
+			slog.Error("Failed to ClaimDue", err.Error(), "no webhooks delivered")
+			return
+		}
+		webhooks := make(map[int]models.Webhook)
+		for _, delivery := range deliveries {
+			webhook, found := webhooks[delivery.WebhookID]
+			if !found {
+				webhook, err = s.webhookRepo.GetByID(delivery.WebhookID)
+				if err != nil {
+					slog.Error("Failed to GetByID", err.Error(), "webhook delivery postponed")
+					continue
+				}
+				webhooks[webhook.ID] = webhook
+			}
+			s.attempt(webhook, delivery)
+		}
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// attempt sends one delivery and records the outcome. Any 2xx answer
+// counts as delivered.
+func (s *webhookService) attempt(webhook models.Webhook, delivery models.WebhookDelivery) {
+	delivery.Attempts++
+	delivery.LastStatusCode = 0
+	delivery.LastError = ""
+	if !webhook.Active {
+		delivery.Status = "failed"
+		delivery.LastError = "webhook is inactive"
+	} else {
+		delivery.LastStatusCode, delivery.LastError = s.send(webhook, delivery)
+		switch {
+		case delivery.LastError == "":
+			delivery.Status = "delivered"
+		case delivery.Attempts >= webhookMaxAttempts:
+			delivery.Status = "failed"
+		default:
+			delivery.Status = "pending"
+		}
+	}
+	if err := s.webhookRepo.RecordAttempt(delivery, webhookRetryDelay(delivery.Attempts)); err != nil {
+		slog.Error("Failed to RecordAttempt", err.Error(), "delivery "+strconv.Itoa(delivery.ID))
+		return
+	}
+	slog.Info("webhook delivery attempted", "deliveryID", delivery.ID, "webhookID", webhook.ID, "status", delivery.Status, "attempts", delivery.Attempts)
+}
+
+// send posts the payload and returns the response code and, when the
+// delivery did not succeed, why.
+func (s *webhookService) send(webhook models.Webhook, delivery models.WebhookDelivery) (int, string) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err.Error()
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookEvent, delivery.EventType)
+	req.Header.Set(HeaderWebhookDelivery, strconv.Itoa(delivery.ID))
+	req.Header.Set(HeaderWebhookTimestamp, timestamp)
+	req.Header.Set(HeaderWebhookSignature, "sha256="+SignWebhook(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, "receiver answered " + resp.Status
+	}
+	return resp.StatusCode, ""
+}
+
+// SignWebhook is how receivers check a delivery: recompute it from the
+// timestamp header and the raw body and compare with the signature header.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRetryDelay doubles with every failed attempt.
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBase
+	for i := 1; i < attempts && delay < webhookRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > webhookRetryMax {
+		delay = webhookRetryMax
+	}
+	return delay
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"hot-coffee/internal/dal"
+	"hot-coffee/models"
+)
+
+// webhookAttempt is one entry of the delivery log kept by the fake repo.
+type webhookAttempt struct {
+	delivery   models.WebhookDelivery
+	retryAfter time.Duration
+}
+
+// fakeWebhookRepo hands out every pending delivery on each claim, as if
+// its retry was already due, and logs every recorded attempt.
+type fakeWebhookRepo struct {
+	dal.WebhookRepository
+	webhook    models.Webhook
+	deliveries []models.WebhookDelivery
+	log        []webhookAttempt
+}
+
+func (r *fakeWebhookRepo) GetByID(id int) (models.Webhook, error) {
+	return r.webhook, nil
+}
+
+func (r *fakeWebhookRepo) ClaimDue(limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	var due []models.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if delivery.Status == "pending" && len(due) < limit {
+			due = append(due, delivery)
+		}
+	}
+	return due, nil
+}
+
+func (r *fakeWebhookRepo) RecordAttempt(delivery models.WebhookDelivery, retryAfter time.Duration) error {
+	for i := range r.deliveries {
+		if r.deliveries[i].ID == delivery.ID {
+			r.deliveries[i] = delivery
+		}
+	}
+	r.log = append(r.log, webhookAttempt{delivery: delivery, retryAfter: retryAfter})
+	return nil
+}
+
+// stubReceiver answers deliveries with the given codes in turn, the last
+// one for every request after them, and keeps the requests it got.
+type stubReceiver struct {
+	mu       sync.Mutex
+	codes    []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (s *stubReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, body)
+	code := s.codes[len(s.codes)-1]
+	if len(s.requests) <= len(s.codes) {
+		code = s.codes[len(s.requests)-1]
+	}
+	w.WriteHeader(code)
+}
+
+func newWebhookFixture(t *testing.T, active bool, codes ...int) (*webhookService, *fakeWebhookRepo, *stubReceiver) {
+	receiver := &stubReceiver{codes: codes}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+	repo := &fakeWebhookRepo{
+		webhook: models.Webhook{ID: 1, URL: server.URL + "/hooks", EventTypes: []string{models.EventOrderCreated}, Secret: "whsec_test", Active: active},
+		deliveries: []models.WebhookDelivery{{
+			ID:        7,
+			WebhookID: 1,
+			EventType: models.EventOrderCreated,
+			Payload:   json.RawMessage(`{"order_id":1}`),
+			Status:    "pending",
+		}},
+	}
+	return NewWebhookService(repo, server.Client()), repo, receiver
+}
+
+func TestWebhookSignature(t *testing.T) {
+	s, _, receiver := newWebhookFixture(t, true, http.StatusNoContent)
+	s.DeliverDue()
+	if len(receiver.requests) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(receiver.requests))
+	}
+	r, body := receiver.requests[0], receiver.bodies[0]
+	if string(body) != `{"order_id":1}` {
+		t.Errorf("body %s", body)
+	}
+	timestamp := r.Header.Get(HeaderWebhookTimestamp)
+	if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+		t.Errorf("timestamp %q is not unix seconds", timestamp)
+	}
+	want := "sha256=" + SignWebhook("whsec_test", timestamp, body)
+	if got := r.Header.Get(HeaderWebhookSignature); got != want {
+		t.Errorf("signature %q, want %q", got, want)
+	}
+	if got := r.Header.Get(HeaderWebhookEvent); got != models.EventOrderCreated {
+		t.Errorf("event header %q", got)
+	}
+	if got := r.Header.Get(HeaderWebhookDelivery); got != "7" {
+		t.Errorf("delivery header %q, want 7", got)
+	}
+	if SignWebhook("other", timestamp, body) == SignWebhook("whsec_test", timestamp, body) {
+		t.Errorf("signature does not depend on the secret")
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	tests := []struct {
+		name   string
+		active bool
+		codes  []int
+		rounds int
+		// want is the delivery log: status, attempts, last code and the
+		// retry delay of every recorded attempt.
+		want     []webhookAttempt
+		requests int
+	}{
+		{
+			name:   "delivered at once",
+			active: true,
+			codes:  []int{http.StatusOK},
+			rounds: 2,
+			want: []webhookAttempt{
+				{models.WebhookDelivery{Status: "delivered", Attempts: 1, LastStatusCode: 200}, 30 * time.Second},
+			},
+			requests: 1,
+		},
+		{
+			name:   "server errors are retried with backoff",
+			active: true,
+			codes:  []int{http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusAccepted},
+			rounds: 4,
+			want: []webhookAttempt{
+				{models.WebhookDelivery{Status: "pending", Attempts: 1, LastStatusCode: 503, LastError: "receiver answered 503 Service Unavailable"}, 30 * time.Second},
+				{models.WebhookDelivery{Status: "pending", Attempts: 2, LastStatusCode: 500, LastError: "receiver answered 500 Internal Server Error"}, time.Minute},
+				{models.WebhookDelivery{Status: "delivered", Attempts: 3, LastStatusCode: 202}, 2 * time.Minute},
+			},
+			requests: 3,
+		},
+		{
+			name:   "inactive webhook fails without a request",
+			active: false,
+			codes:  []int{http.StatusOK},
+			rounds: 1,
+			want: []webhookAttempt{
+				{models.WebhookDelivery{Status: "failed", Attempts: 1, LastError: "webhook is inactive"}, 30 * time.Second},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo, receiver := newWebhookFixture(t, tt.active, tt.codes...)
+			for i := 0; i < tt.rounds; i++ {
+				s.DeliverDue()
+			}
+			if len(receiver.requests) != tt.requests {
+				t.Errorf("receiver got %d requests, want %d", len(receiver.requests), tt.requests)
+			}
+			if len(repo.log) != len(tt.want) {
+				t.Fatalf("recorded %d attempts, want %d", len(repo.log), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := repo.log[i]
+				if got.delivery.Status != want.delivery.Status || got.delivery.Attempts != want.delivery.Attempts ||
+					got.delivery.LastStatusCode != want.delivery.LastStatusCode || got.delivery.LastError != want.delivery.LastError {
+					t.Errorf("attempt %d = %+v, want %+v", i, got.delivery, want.delivery)
+				}
+				if got.retryAfter != want.retryAfter {
+					t.Errorf("attempt %d retries after %v, want %v", i, got.retryAfter, want.retryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookGivesUp(t *testing.T) {
+	s, repo, receiver := newWebhookFixture(t, true, http.StatusBadGateway)
+	for i := 0; i < webhookMaxAttempts+3; i++ {
+		s.DeliverDue()
+	}
+	if len(receiver.requests) != webhookMaxAttempts {
+		t.Errorf("receiver got %d requests, want %d", len(receiver.requests), webhookMaxAttempts)
+	}
+	if len(repo.log) != webhookMaxAttempts {
+		t.Fatalf("recorded %d attempts, want %d", len(repo.log), webhookMaxAttempts)
+	}
+	for i, attempt := range repo.log[:webhookMaxAttempts-1] {
+		if attempt.delivery.Status != "pending" {
+			t.Errorf("attempt %d is %s, want pending", i+1, attempt.delivery.Status)
+		}
+	}
+	last := repo.log[webhookMaxAttempts-1].delivery
+	if last.Status != "failed" || last.Attempts != webhookMaxAttempts || last.LastStatusCode != http.StatusBadGateway {
+		t.Errorf("last attempt = %+v, want failed after %d attempts", last, webhookMaxAttempts)
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 30 * time.Second},
+		{attempts: 2, want: time.Minute},
+		{attempts: 5, want: 8 * time.Minute},
+		{attempts: 9, want: 128 * time.Minute},
+		{attempts: 10, want: 256 * time.Minute},
+		{attempts: 11, want: webhookRetryMax},
+		{attempts: 40, want: webhookRetryMax},
+	}
+	for _, tt := range tests {
+		if got := webhookRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("webhookRetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package models
+
+import "encoding/json"
+
+// Entity types that are audited.
+const (
+	AuditOrder         = "order"
+	AuditMenuItem      = "menu_item"
+	AuditInventoryItem = "inventory_item"
+)
+
+// AuditEntry is one change made through the API. Before and After are the
+// stored columns of the entity, Before is empty for creates and After once
+// the row is gone. Diff only has the fields that changed.
+type AuditEntry struct {
+	ID              int                    `json:"audit_id"`
+	ActorEmployeeID int                    `json:"actor_employee_id,omitempty"`
+	ActorAPIKeyID   int                    `json:"actor_api_key_id,omitempty"`
+	Action          string                 `json:"action"` // create, update, delete or restore
+	EntityType      string                 `json:"entity_type"`
+	EntityID        string                 `json:"entity_id"`
+	Before          json.RawMessage        `json:"before,omitempty"`
+	After           json.RawMessage        `json:"after,omitempty"`
+	Diff            map[string]FieldChange `json:"diff"`
+	CreatedAt       string                 `json:"created_at"`
+}
+
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+type AuditFilter struct {
+	EntityType string
+	EntityID   string
+	From       string
+	To         string
+}
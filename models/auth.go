@@ -0,0 +1,29 @@
+package models
+
+type LoginRequest struct {
+	EmployeeID int    `json:"employee_id"`
+	Password   string `json:"password"`
+}
+
+type LoginResponse struct {
+	Token     string   `json:"token"`
+	ExpiresAt string   `json:"expires_at"`
+	Employee  Employee `json:"employee"`
+}
+
+// Principal is who a request is made by, a logged in employee or an API
+// key.
+type Principal struct {
+	EmployeeID int    `json:"employee_id,omitempty"`
+	APIKeyID   int    `json:"api_key_id,omitempty"`
+	Role       string `json:"role"`
+}
+
+type APIKey struct {
+	ID        int    `json:"api_key_id"`
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	Key       string `json:"key,omitempty"` // only returned when the key is created
+	CreatedAt string `json:"created_at"`
+	RevokedAt string `json:"revoked_at,omitempty"`
+}
@@ -0,0 +1,23 @@
+package models
+
+type Customer struct {
+	ID        int    `json:"customer_id"`
+	Name      string `json:"name"`
+	Phone     string `json:"phone,omitempty"`
+	Email     string `json:"email,omitempty"`
+	Notes     string `json:"notes,omitempty"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type MergeCustomersRequest struct {
+	TargetID  int   `json:"target_id"`
+	SourceIDs []int `json:"source_ids"`
+}
+
+type CustomerOrderHistory struct {
+	Customer      Customer `json:"customer"`
+	Orders        []Order  `json:"orders"`
+	OrderCount    int      `json:"order_count"`
+	LifetimeSpend float64  `json:"lifetime_spend"`
+}
@@ -0,0 +1,29 @@
+package models
+
+type Employee struct {
+	ID        int    `json:"employee_id"`
+	Name      string `json:"name"`
+	Role      string `json:"role"` // barista, shift_lead or manager
+	Active    bool   `json:"active"`
+	Password  string `json:"password,omitempty"` // only read, never returned
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type Shift struct {
+	ID         int     `json:"shift_id"`
+	EmployeeID int     `json:"employee_id"`
+	ClockIn    string  `json:"clock_in"`
+	ClockOut   string  `json:"clock_out,omitempty"` // empty while the shift is running
+	Hours      float64 `json:"hours"`
+}
+
+// LaborEntry is the time one employee worked on one day, shifts running
+// past midnight count towards both days.
+type LaborEntry struct {
+	Date       string  `json:"date"`
+	EmployeeID int     `json:"employee_id"`
+	Name       string  `json:"name"`
+	Role       string  `json:"role"`
+	Hours      float64 `json:"hours"`
+}
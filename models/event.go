@@ -0,0 +1,62 @@
+package models
+
+import "encoding/json"
+
+// Event types, every event is saved to the outbox before it is published.
+const (
+	EventOrderCreated       = "order.created"
+	EventOrderUpdated       = "order.updated"
+	EventOrderStatusChanged = "order.status_changed"
+	EventOrderDeleted       = "order.deleted"
+	EventOrderClosed        = "order.closed"
+	EventOrderLineChanged   = "order.line_status_changed"
+	EventInventoryLow       = "inventory.low"
+	EventInventoryReceived  = "inventory.received"
+)
+
+// Event is something that happened to an order or the stock. The ID is the
+// outbox position, clients resume after the last one they saw. An event can
+// be published more than once, DedupID tells the copies apart from new
+// events.
+type Event struct {
+	ID        int64           `json:"id"`
+	DedupID   string          `json:"dedup_id"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt string          `json:"created_at"`
+}
+
+type OrderStatusEvent struct {
+	OrderID   int    `json:"order_id"`
+	OldStatus string `json:"old_status"`
+	Status    string `json:"status"`
+}
+
+// LineStatusEvent is an order line moved one prep step on at its station.
+type LineStatusEvent struct {
+	OrderID    int    `json:"order_id"`
+	LineID     int    `json:"line_id"`
+	MenuItemID string `json:"menu_item_id"`
+	Station    string `json:"station"`
+	OldStatus  string `json:"old_status"`
+	Status     string `json:"status"`
+	EmployeeID int    `json:"employee_id,omitempty"`
+}
+
+type InventoryLowEvent struct {
+	IngredientID string  `json:"ingredient_id"`
+	Name         string  `json:"name"`
+	Quantity     float64 `json:"quantity"`
+	Unit         string  `json:"unit"`
+	ReorderLevel float64 `json:"reorder_level"`
+}
+
+// InventoryReceivedEvent is stock added to an item by hand, e.g. a delivery.
+type InventoryReceivedEvent struct {
+	IngredientID string  `json:"ingredient_id"`
+	Name         string  `json:"name"`
+	Received     float64 `json:"received"`
+	Quantity     float64 `json:"quantity"`
+	Unit         string  `json:"unit"`
+	EmployeeID   int     `json:"employee_id,omitempty"`
+}
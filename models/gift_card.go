@@ -0,0 +1,23 @@
+package models
+
+type GiftCard struct {
+	Code           string  `json:"code"`
+	InitialBalance float64 `json:"initial_balance"`
+	Balance        float64 `json:"balance"`
+	CreatedAt      string  `json:"created_at"`
+	UpdatedAt      string  `json:"updated_at"`
+}
+
+type GiftCardTransaction struct {
+	ID              int     `json:"transaction_id"`
+	Code            string  `json:"code"`
+	OrderID         int     `json:"order_id,omitempty"`
+	TransactionType string  `json:"transaction_type"` // issue, load, spend or refund
+	Amount          float64 `json:"amount"`
+	BalanceAfter    float64 `json:"balance_after"`
+	CreatedAt       string  `json:"created_at"`
+}
+
+type GiftCardAmountRequest struct {
+	Amount float64 `json:"amount"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// IdempotencyRecord is what is kept for an Idempotency-Key: the request it
+// was first used with and, once that request finished, its response.
+type IdempotencyRecord struct {
+	Fingerprint string
+	StatusCode  int // 0 while the first request is still running
+	ContentType string
+	Body        []byte
+}
@@ -1,10 +1,52 @@
 package models
 
+// InventoryItem is an ingredient in stock. Quantity is what is on hand,
+// Reserved is held for open orders and Available is what is left for new
+// orders, the last two are computed and not stored.
 type InventoryItem struct {
+	IngredientID string                      `json:"ingredient_id"`
+	Name         string                      `json:"name"`
+	Quantity     float64                     `json:"quantity"`
+	Reserved     float64                     `json:"reserved"`
+	Available    float64                     `json:"available"`
+	Unit         string                      `json:"unit"`
+	CostPerUnit  float64                     `json:"cost_per_unit"`
+	ReorderLevel float64                     `json:"reorder_level,omitempty"`
+	Recipe       []InventoryRecipeIngredient `json:"recipe,omitempty"`
+	Allergens    []string                    `json:"allergens,omitempty"`
+	DietaryTags  []string                    `json:"dietary_tags,omitempty"`
+	Nutrition    *Nutrition                  `json:"nutrition,omitempty"`
+	CreatedAt    string                      `json:"created_at"`
+	UpdatedAt    string                      `json:"updated_at"`
+	Version      int                         `json:"version"`
+}
+
+// InventoryRecipeIngredient is one component of a prep item, the quantity is
+// given per one unit of the prep item.
+type InventoryRecipeIngredient struct {
 	IngredientID string  `json:"ingredient_id"`
-	Name         string  `json:"name"`
 	Quantity     float64 `json:"quantity"`
-	Unit         string  `json:"unit"`
-	CreatedAt    string  `json:"created_at"`
-	UpdatedAt    string  `json:"updated_at"`
+}
+
+// Nutrition values are per Basis Unit of the ingredient, e.g. per 100 g.
+// Unit may differ from the stock unit as long as it converts (kg/g, l/ml).
+type Nutrition struct {
+	Basis    float64 `json:"basis"`
+	Unit     string  `json:"unit"`
+	Calories float64 `json:"calories"`
+	Protein  float64 `json:"protein"`
+	Fat      float64 `json:"fat"`
+	Carbs    float64 `json:"carbs"`
+}
+
+type ProduceRequest struct {
+	Quantity float64 `json:"quantity"`
+}
+
+type RecipeSummary struct {
+	IngredientID string                      `json:"ingredient_id"`
+	UnitCost     float64                     `json:"unit_cost"`
+	OnHand       float64                     `json:"on_hand"`
+	Producible   float64                     `json:"producible"`
+	Recipe       []InventoryRecipeIngredient `json:"recipe"`
 }
@@ -0,0 +1,24 @@
+package models
+
+// LoyaltyRule gives points per currency unit spent. A rule without a
+// category is the default for items whose category has no rule.
+type LoyaltyRule struct {
+	ID            int     `json:"rule_id,omitempty"`
+	Category      string  `json:"category,omitempty"`
+	PointsPerUnit float64 `json:"points_per_unit"`
+}
+
+type LoyaltyEntry struct {
+	ID         int    `json:"entry_id"`
+	CustomerID int    `json:"customer_id"`
+	OrderID    int    `json:"order_id,omitempty"`
+	EntryType  string `json:"entry_type"` // earn, redeem or reversal
+	Points     int    `json:"points"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type LoyaltyAccount struct {
+	CustomerID int            `json:"customer_id"`
+	Balance    int            `json:"balance"`
+	History    []LoyaltyEntry `json:"history"`
+}
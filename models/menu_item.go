@@ -4,12 +4,35 @@ type MenuItem struct {
 	ID          string               `json:"menu_item_id"`
 	Name        string               `json:"name"`
 	Description string               `json:"description"`
+	Category    string               `json:"category,omitempty"`
 	Price       float64              `json:"price"`
 	Ingredients []MenuItemIngredient `json:"ingredients"`
+	Allergens   []string             `json:"allergens,omitempty"`
+	DietaryTags []string             `json:"dietary_tags,omitempty"`
 	Relevance   float64              `json:"relevance"`
+	ArchivedAt  string               `json:"archived_at,omitempty"`
+	Version     int                  `json:"version"`
 }
 
 type MenuItemIngredient struct {
 	IngredientID string  `json:"ingredient_id"`
 	Quantity     float64 `json:"quantity"`
 }
+
+// Customization is the structured part of an order line customization,
+// ingredients added to or removed from the menu item's recipe.
+type Customization struct {
+	Add    []MenuItemIngredient `json:"add,omitempty"`
+	Remove []string             `json:"remove,omitempty"`
+}
+
+type MenuItemNutrition struct {
+	MenuItemID string  `json:"menu_item_id"`
+	Name       string  `json:"name"`
+	Calories   float64 `json:"calories"`
+	Protein    float64 `json:"protein"`
+	Fat        float64 `json:"fat"`
+	Carbs      float64 `json:"carbs"`
+	// MissingIngredients have no nutrition data and are left out of the totals.
+	MissingIngredients []string `json:"missing_ingredients,omitempty"`
+}
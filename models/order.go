@@ -3,25 +3,73 @@ package models
 import "encoding/json"
 
 type Order struct {
-	ID               int         `json:"order_id"`
-	CustomerName     string      `json:"customer_name"`
-	Items            []OrderItem `json:"items"`
-	Status           string      `json:"status"`
-	CreatedAt        string      `json:"created_at"`
-	TotalAmount      float64     `json:"total_amount"`
-	UpdatedAt        string      `json:"updated_at"`
-	LastStatusChange string      `json:"last_status_change"`
+	ID               int             `json:"order_id"`
+	CustomerName     string          `json:"customer_name"`
+	CustomerID       int             `json:"customer_id,omitempty"`
+	ParentOrderID    int             `json:"parent_order_id,omitempty"`
+	TableID          int             `json:"table_id,omitempty"`    // set when the order is created, changed by tab transfers
+	EmployeeID       int             `json:"employee_id,omitempty"` // who took the order
+	Items            []OrderItem     `json:"items"`
+	Discounts        []OrderDiscount `json:"discounts,omitempty"`
+	Status           string          `json:"status"`
+	Channel          string          `json:"channel"` // dine_in, takeaway or delivery
+	Priority         int             `json:"priority,omitempty"`
+	PrepStatus       string          `json:"prep_status,omitempty"`   // derived from the lines, not stored
+	ScheduledFor     string          `json:"scheduled_for,omitempty"` // pickup time of a pre-order
+	CreatedAt        string          `json:"created_at"`
+	TotalAmount      float64         `json:"total_amount"`
+	Tip              float64         `json:"tip"` // tips left at close and on payments, not part of the total
+	UpdatedAt        string          `json:"updated_at"`
+	LastStatusChange string          `json:"last_status_change"`
+	RedeemPoints     int             `json:"redeem_points,omitempty"` // only read when the order is created
+	Version          int             `json:"version"`
 }
 
 type OrderItem struct {
+	LineID        int             `json:"line_id,omitempty"`
 	MenuItemID    string          `json:"menu_item_id"`
 	Quantity      int             `json:"quantity"`
 	Price         float64         `json:"-"`
 	Customization json.RawMessage `json:"customization,omitempty"`
+	// AllergenWarnings is computed from the recipe and customization, it is not stored.
+	AllergenWarnings []string `json:"allergen_warnings,omitempty"`
+	PrepStatus       string   `json:"prep_status,omitempty"` // queued, in_progress, ready or handed_off
+}
+
+type OrderDiscount struct {
+	Kind        string  `json:"kind"`
+	Description string  `json:"description,omitempty"`
+	Amount      float64 `json:"amount"`
+}
+
+// SplitOrderRequest splits an order into the given bills, or evenly into
+// Parts bills. A bill line without a quantity takes the whole line, items
+// left over after the bills go onto one more bill.
+type SplitOrderRequest struct {
+	Bills []SplitBill `json:"bills,omitempty"`
+	Parts int         `json:"parts,omitempty"`
+}
+
+type SplitBill struct {
+	CustomerName string      `json:"customer_name,omitempty"`
+	Items        []OrderItem `json:"items"`
+}
+
+// MergeOrdersRequest merges active orders into the first one listed.
+type MergeOrdersRequest struct {
+	OrderIDs []int `json:"order_ids"`
+}
+
+// CloseOrderRequest is the optional body of a close, for a tip left when
+// the order is closed rather than with a payment.
+type CloseOrderRequest struct {
+	Tip float64 `json:"tip"`
 }
 
 type TotalSales struct {
-	Sales float64 `json:"total_sales: "`
+	Sales             float64 `json:"total_sales: "`
+	Tips              float64 `json:"tips"`
+	GiftCardLiability float64 `json:"gift_card_liability"`
 }
 
 type OrderStatusHistory struct {
@@ -0,0 +1,11 @@
+package models
+
+// PackagingRule takes Quantity of an inventory item for every unit sold on
+// Channel. A rule without a category applies to every menu item.
+type PackagingRule struct {
+	ID           int     `json:"rule_id,omitempty"`
+	Channel      string  `json:"channel"`
+	Category     string  `json:"category,omitempty"`
+	IngredientID string  `json:"ingredient_id"`
+	Quantity     float64 `json:"quantity"`
+}
@@ -0,0 +1,53 @@
+package models
+
+type Payment struct {
+	ID                 int     `json:"payment_id"`
+	OrderID            int     `json:"order_id"`
+	Tender             string  `json:"tender"` // cash, card or gift_card
+	Amount             float64 `json:"amount"`
+	Tip                float64 `json:"tip"`
+	Tendered           float64 `json:"tendered,omitempty"`
+	ChangeDue          float64 `json:"change_due"`
+	GiftCardCode       string  `json:"gift_card_code,omitempty"`
+	ProcessorReference string  `json:"processor_reference,omitempty"`
+	CreatedAt          string  `json:"created_at"`
+}
+
+// RefundRequest refunds the given lines, or whatever is still paid on the
+// order when no lines are given.
+type RefundRequest struct {
+	Lines  []RefundLine `json:"lines,omitempty"`
+	Reason string       `json:"reason,omitempty"`
+}
+
+type RefundLine struct {
+	MenuItemID string  `json:"menu_item_id"`
+	Quantity   int     `json:"quantity"`
+	Amount     float64 `json:"amount"`
+}
+
+type RefundTender struct {
+	PaymentID          int     `json:"payment_id"`
+	Amount             float64 `json:"amount"`
+	ProcessorReference string  `json:"processor_reference,omitempty"`
+}
+
+type Refund struct {
+	ID        int            `json:"refund_id"`
+	OrderID   int            `json:"order_id"`
+	Amount    float64        `json:"amount"`
+	Reason    string         `json:"reason,omitempty"`
+	Lines     []RefundLine   `json:"lines,omitempty"`
+	Tenders   []RefundTender `json:"tenders"`
+	CreatedAt string         `json:"created_at"`
+}
+
+type OrderPayments struct {
+	OrderID  int       `json:"order_id"`
+	Total    float64   `json:"total_amount"`
+	Paid     float64   `json:"paid"`
+	Refunded float64   `json:"refunded"`
+	Due      float64   `json:"amount_due"`
+	Payments []Payment `json:"payments"`
+	Refunds  []Refund  `json:"refunds"`
+}
@@ -0,0 +1,20 @@
+package models
+
+// PickupSettings limit pre-orders: how many orders a 15 minute pickup slot
+// takes, how long before pickup their lines reach the prep queue and the
+// hours pickups are offered, as HH:MM in server local time.
+type PickupSettings struct {
+	SlotCapacity int    `json:"slot_capacity"`
+	LeadMinutes  int    `json:"lead_minutes"`
+	OpensAt      string `json:"opens_at"`
+	ClosesAt     string `json:"closes_at"`
+}
+
+// PickupSlot is a pickup window that still takes pre-orders.
+type PickupSlot struct {
+	Start     string `json:"start"`
+	End       string `json:"end"`
+	Capacity  int    `json:"capacity"`
+	Booked    int    `json:"booked"`
+	Available int    `json:"available"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "encoding/json"
+
+// QueueLine is an order line waiting at a prep station.
+type QueueLine struct {
+	LineID        int             `json:"line_id"`
+	OrderID       int             `json:"order_id"`
+	CustomerName  string          `json:"customer_name"`
+	TableID       int             `json:"table_id,omitempty"`
+	Channel       string          `json:"channel"`
+	Priority      int             `json:"priority"`
+	MenuItemID    string          `json:"menu_item_id"`
+	Name          string          `json:"name"`
+	Quantity      int             `json:"quantity"`
+	Customization json.RawMessage `json:"customization,omitempty"`
+	Station       string          `json:"station"`
+	PrepStatus    string          `json:"prep_status"`
+	OrderedAt     string          `json:"ordered_at"`
+	ScheduledFor  string          `json:"scheduled_for,omitempty"`
+	PrepChangedAt string          `json:"prep_changed_at,omitempty"`
+	PreparedBy    int             `json:"prepared_by,omitempty"`
+}
+
+// StationRoute sends the items of a category to a prep station. The route
+// without a category takes every other item, a route with a channel only
+// the items of orders on that channel.
+type StationRoute struct {
+	ID       int    `json:"route_id,omitempty"`
+	Channel  string `json:"channel,omitempty"`
+	Category string `json:"category,omitempty"`
+	Station  string `json:"station"`
+}
@@ -0,0 +1,31 @@
+package models
+
+// Table is a dining table. Its active orders are the table's tab, split
+// bills of the same tab stay on the table.
+type Table struct {
+	ID         int          `json:"table_id"`
+	Number     int          `json:"number"`
+	Seats      int          `json:"seats"`
+	Area       string       `json:"area,omitempty"`
+	Status     string       `json:"status"` // free, occupied or needs_cleaning
+	OpenOrders []TableOrder `json:"open_orders"`
+	OpenTotal  float64      `json:"open_total"`
+	CreatedAt  string       `json:"created_at"`
+	UpdatedAt  string       `json:"updated_at"`
+}
+
+type TableOrder struct {
+	OrderID      int     `json:"order_id"`
+	CustomerName string  `json:"customer_name"`
+	TotalAmount  float64 `json:"total_amount"`
+	OpenedAt     string  `json:"opened_at"`
+}
+
+type TableStatusRequest struct {
+	Status string `json:"status"`
+}
+
+// TransferTabRequest moves the tab of a table to TableID.
+type TransferTabRequest struct {
+	TableID int `json:"table_id"`
+}
@@ -0,0 +1,22 @@
+package models
+
+type OrderTip struct {
+	OrderID int     `json:"order_id"`
+	Tip     float64 `json:"tip"`
+}
+
+// TipShare is one staff member's part of the tip pool. Hours are only
+// used by the hours-weighted rule.
+type TipShare struct {
+	Staff  string  `json:"staff"`
+	Hours  float64 `json:"hours,omitempty"`
+	Amount float64 `json:"amount"`
+}
+
+type TipReport struct {
+	Date      string     `json:"date"`
+	Rule      string     `json:"rule"` // equal or hours
+	TotalTips float64    `json:"total_tips"`
+	Orders    []OrderTip `json:"orders"`
+	Shares    []TipShare `json:"shares"`
+}